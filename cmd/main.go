@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 
+	"github.com/minghsu0107/saga-product/cmd/all"
 	"github.com/minghsu0107/saga-product/cmd/orchestrator"
 	"github.com/minghsu0107/saga-product/cmd/order"
 	"github.com/minghsu0107/saga-product/cmd/payment"
@@ -24,7 +25,9 @@ func main() {
 		payment.RunPaymentServer(app)
 	case "orchestrator":
 		orchestrator.RunOrchestratorServer(app)
+	case "all", "dev":
+		all.RunAllInOneServer(app)
 	default:
-		log.Fatalf("invalid app name: %s. Should be one of 'product', 'order', 'payment', or 'orchestrator'", app)
+		log.Fatalf("invalid app name: %s. Should be one of 'product', 'order', 'payment', 'orchestrator', 'all' or 'dev'", app)
 	}
 }
@@ -12,6 +12,14 @@ import (
 )
 
 func RunOrchestratorServer(app string) {
+	migrator, err := dep.InitializeMigrator(app)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := migrator.Migrate(); err != nil {
+		log.Fatal(err)
+	}
+
 	server, err := dep.InitializeOrchestratorServer()
 	if err != nil {
 		log.Fatal(err)
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"time"
 
@@ -12,19 +13,27 @@ import (
 
 // Config is a type for general configuration
 type Config struct {
-	App              string            `yaml:"app" envconfig:"APP"`
-	GinMode          string            `yaml:"ginMode" envconfig:"GIN_MODE"`
-	HTTPPort         string            `yaml:"httpPort" envconfig:"HTTP_PORT"`
-	GRPCPort         string            `yaml:"grpcPort" envconfig:"GRPC_PORT"`
-	PromPort         string            `yaml:"promPort" envconfig:"PROM_PORT"`
-	JaegerUrl        string            `yaml:"jaegerUrl" envconfig:"JAEGER_URL"`
-	DBConfig         *DBConfig         `yaml:"dbConfig"`
-	LocalCacheConfig *LocalCacheConfig `yaml:"localCacheConfig"`
-	RedisConfig      *RedisConfig      `yaml:"redisConfig"`
-	NATSConfig       *NATSConfig       `yaml:"natsConfig"`
-	RPCEndpoints     *RPCEndpoints     `yaml:"rpcEndpoints"`
-	ServiceOptions   *ServiceOptions   `yaml:"serviceOptions"`
-	Logger           *Logger
+	App                string              `yaml:"app" envconfig:"APP"`
+	GinMode            string              `yaml:"ginMode" envconfig:"GIN_MODE"`
+	HTTPPort           string              `yaml:"httpPort" envconfig:"HTTP_PORT"`
+	GRPCPort           string              `yaml:"grpcPort" envconfig:"GRPC_PORT"`
+	PromPort           string              `yaml:"promPort" envconfig:"PROM_PORT"`
+	JaegerUrl          string              `yaml:"jaegerUrl" envconfig:"JAEGER_URL"`
+	DBConfig           *DBConfig           `yaml:"dbConfig"`
+	LocalCacheConfig   *LocalCacheConfig   `yaml:"localCacheConfig"`
+	RedisConfig        *RedisConfig        `yaml:"redisConfig"`
+	NATSConfig         *NATSConfig         `yaml:"natsConfig"`
+	RouterConfig       *RouterConfig       `yaml:"routerConfig"`
+	RPCEndpoints       *RPCEndpoints       `yaml:"rpcEndpoints"`
+	ServiceOptions     *ServiceOptions     `yaml:"serviceOptions"`
+	SagaConfig         *SagaConfig         `yaml:"sagaConfig"`
+	ReservationConfig  *ReservationConfig  `yaml:"reservationConfig"`
+	PricingConfig      *PricingConfig      `yaml:"pricingConfig"`
+	OutboxConfig       *OutboxConfig       `yaml:"outboxConfig"`
+	ResultStreamConfig *ResultStreamConfig `yaml:"resultStreamConfig"`
+	AllInOneConfig     *AllInOneConfig     `yaml:"allInOneConfig"`
+	StaffConfig        *StaffConfig        `yaml:"staffConfig"`
+	Logger             *Logger
 }
 
 // DBConfig is database config type
@@ -73,6 +82,7 @@ type RedisPublisher struct {
 
 // NATSConfig wraps NATS client configurations
 type NATSConfig struct {
+	Transport  string          `yaml:"transport" envconfig:"NATS_TRANSPORT"`
 	ClusterID  string          `yaml:"clusterID" envconfig:"NATS_CLUSTER_ID"`
 	URL        string          `yaml:"url" envconfig:"NATS_URL"`
 	ClientID   string          `yaml:"clientID" envconfig:"NATS_CLIENT_ID"`
@@ -80,9 +90,20 @@ type NATSConfig struct {
 }
 
 type NATSSubscriber struct {
-	QueueGroup  string `yaml:"queueGroup" envconfig:"NATS_SUBSCRIBER_QUEUE_GROUP"`
-	DurableName string `yaml:"durableName" envconfig:"NATS_SUBSCRIBER_DURABLE_NAME"`
-	Count       int    `yaml:"count" envconfig:"NATS_SUBSCRIBER_COUNT"`
+	QueueGroup    string `yaml:"queueGroup" envconfig:"NATS_SUBSCRIBER_QUEUE_GROUP"`
+	DurableName   string `yaml:"durableName" envconfig:"NATS_SUBSCRIBER_DURABLE_NAME"`
+	Count         int    `yaml:"count" envconfig:"NATS_SUBSCRIBER_COUNT"`
+	MaxDeliver    int    `yaml:"maxDeliver" envconfig:"NATS_SUBSCRIBER_MAX_DELIVER"`
+	AckWaitSecond int    `yaml:"ackWaitSecond" envconfig:"NATS_SUBSCRIBER_ACK_WAIT_SECOND"`
+}
+
+// RouterConfig defines how event routers retry failed messages
+type RouterConfig struct {
+	MaxRetries           int     `yaml:"maxRetries" envconfig:"ROUTER_MAX_RETRIES"`
+	InitialIntervalMilli int     `yaml:"initialIntervalMilli" envconfig:"ROUTER_INITIAL_INTERVAL_MILLI"`
+	MaxIntervalMilli     int     `yaml:"maxIntervalMilli" envconfig:"ROUTER_MAX_INTERVAL_MILLI"`
+	Multiplier           float64 `yaml:"multiplier" envconfig:"ROUTER_MULTIPLIER"`
+	PoisonQueueTopic     string  `yaml:"poisonQueueTopic" envconfig:"ROUTER_POISON_QUEUE_TOPIC"`
 }
 
 // RPCEndpoints wraps all rpc server urls
@@ -98,6 +119,63 @@ type ServiceOptions struct {
 	Timeout       time.Duration
 }
 
+// SagaConfig defines the deadline of each saga step and how soon a failed cancellation step is retried
+type SagaConfig struct {
+	UpdateProductInventoryTimeoutSecond int `yaml:"updateProductInventoryTimeoutSecond" envconfig:"SAGA_UPDATE_PRODUCT_INVENTORY_TIMEOUT_SECOND"`
+	CreateOrderTimeoutSecond            int `yaml:"createOrderTimeoutSecond" envconfig:"SAGA_CREATE_ORDER_TIMEOUT_SECOND"`
+	CreatePaymentTimeoutSecond          int `yaml:"createPaymentTimeoutSecond" envconfig:"SAGA_CREATE_PAYMENT_TIMEOUT_SECOND"`
+	CancellationRetryIntervalSecond     int `yaml:"cancellationRetryIntervalSecond" envconfig:"SAGA_CANCELLATION_RETRY_INTERVAL_SECOND"`
+	TimeoutCheckIntervalSecond          int `yaml:"timeoutCheckIntervalSecond" envconfig:"SAGA_TIMEOUT_CHECK_INTERVAL_SECOND"`
+}
+
+// ReservationConfig defines how long inventory is held for a purchase and how often expired holds are released
+// HoldTTLSecond must be longer than all saga step timeouts together plus holdTTLMargin, so that a hold is rarely released before the purchase succeeds
+type ReservationConfig struct {
+	HoldTTLSecond            int `yaml:"holdTTLSecond" envconfig:"RESERVATION_HOLD_TTL_SECOND"`
+	ExpiryCheckIntervalMilli int `yaml:"expiryCheckIntervalMilli" envconfig:"RESERVATION_EXPIRY_CHECK_INTERVAL_MILLI"`
+	ExpiryBatchSize          int `yaml:"expiryBatchSize" envconfig:"RESERVATION_EXPIRY_BATCH_SIZE"`
+}
+
+// PricingConfig defines how often and how many due scheduled price changes are applied
+type PricingConfig struct {
+	ChangeCheckIntervalMilli int `yaml:"changeCheckIntervalMilli" envconfig:"PRICING_CHANGE_CHECK_INTERVAL_MILLI"`
+	ChangeBatchSize          int `yaml:"changeBatchSize" envconfig:"PRICING_CHANGE_BATCH_SIZE"`
+}
+
+// OutboxConfig defines how often and how many outbox messages are relayed to the broker
+type OutboxConfig struct {
+	RelayIntervalMilli int `yaml:"relayIntervalMilli" envconfig:"OUTBOX_RELAY_INTERVAL_MILLI"`
+	BatchSize          int `yaml:"batchSize" envconfig:"OUTBOX_BATCH_SIZE"`
+}
+
+// ResultStreamConfig defines how purchase results are streamed to customers
+// A subscriber whose buffer is full is dropped and resumes from its last event after reconnecting
+type ResultStreamConfig struct {
+	SubscriberBufferSize int   `yaml:"subscriberBufferSize" envconfig:"RESULT_STREAM_SUBSCRIBER_BUFFER_SIZE"`
+	ReadBatchSize        int64 `yaml:"readBatchSize" envconfig:"RESULT_STREAM_READ_BATCH_SIZE"`
+	ReadBlockMilli       int   `yaml:"readBlockMilli" envconfig:"RESULT_STREAM_READ_BLOCK_MILLI"`
+	KeepaliveSecond      int   `yaml:"keepaliveSecond" envconfig:"RESULT_STREAM_KEEPALIVE_SECOND"`
+	ReplayWindowSecond   int   `yaml:"replayWindowSecond" envconfig:"RESULT_STREAM_REPLAY_WINDOW_SECOND"`
+}
+
+// AllInOneConfig defines the HTTP port of each service when all services run in one process
+// The product service serves gRPC on GRPCPort, so the orchestrator needs a port of its own
+type AllInOneConfig struct {
+	ProductHTTPPort      string `yaml:"productHTTPPort" envconfig:"ALL_IN_ONE_PRODUCT_HTTP_PORT"`
+	OrderHTTPPort        string `yaml:"orderHTTPPort" envconfig:"ALL_IN_ONE_ORDER_HTTP_PORT"`
+	PaymentHTTPPort      string `yaml:"paymentHTTPPort" envconfig:"ALL_IN_ONE_PAYMENT_HTTP_PORT"`
+	OrchestratorHTTPPort string `yaml:"orchestratorHTTPPort" envconfig:"ALL_IN_ONE_ORCHESTRATOR_HTTP_PORT"`
+	OrchestratorGRPCPort string `yaml:"orchestratorGRPCPort" envconfig:"ALL_IN_ONE_ORCHESTRATOR_GRPC_PORT"`
+}
+
+// StaffConfig lists the customers authorized as support staff, such as for issuing refunds
+type StaffConfig struct {
+	CustomerIDs []uint64 `yaml:"customerIDs" envconfig:"STAFF_CUSTOMER_IDS"`
+}
+
+// holdTTLMargin bounds the delays of relaying and delivering saga commands and replies on top of the saga step timeouts
+const holdTTLMargin = time.Minute
+
 // NewConfig is the factory of Config instance
 func NewConfig() (*Config, error) {
 	var config Config
@@ -112,10 +190,40 @@ func NewConfig() (*Config, error) {
 	if config.NATSConfig.ClientID == "" {
 		config.NATSConfig.ClientID = watermill.NewShortUUID()
 	}
+	if config.RouterConfig == nil {
+		config.RouterConfig = &RouterConfig{}
+	}
+	if config.RouterConfig.PoisonQueueTopic == "" {
+		config.RouterConfig.PoisonQueueTopic = config.App + ".poison"
+	}
+	if config.ReservationConfig == nil {
+		config.ReservationConfig = &ReservationConfig{}
+	}
+	if config.ReservationConfig.HoldTTLSecond <= 0 {
+		config.ReservationConfig.HoldTTLSecond = 300
+	}
+	if config.SagaConfig == nil {
+		config.SagaConfig = &SagaConfig{}
+	}
+	if err := validateHoldTTL(&config); err != nil {
+		return nil, err
+	}
 
 	return &config, nil
 }
 
+// validateHoldTTL rejects a hold TTL that does not outlast every saga step timing out in turn
+// A timed-out step is only compensated on the next timeout check, which is taken into account as well
+func validateHoldTTL(config *Config) error {
+	saga := config.SagaConfig
+	stepTimeouts := time.Duration(saga.UpdateProductInventoryTimeoutSecond+saga.CreateOrderTimeoutSecond+saga.CreatePaymentTimeoutSecond+saga.TimeoutCheckIntervalSecond) * time.Second
+	holdTTL := time.Duration(config.ReservationConfig.HoldTTLSecond) * time.Second
+	if holdTTL <= stepTimeouts+holdTTLMargin {
+		return fmt.Errorf("hold TTL %v must be longer than saga step timeouts %v plus %v", holdTTL, stepTimeouts, holdTTLMargin)
+	}
+	return nil
+}
+
 func readFile(config *Config) error {
 	f, err := os.Open("config.yml")
 	if err != nil {
@@ -5,10 +5,17 @@ type HTTPContextKey string
 var (
 	// JWTAuthHeader is the auth header containing customer ID
 	JWTAuthHeader = "Authorization"
+	// IdempotencyKeyHeader is the header containing the idempotency key of a refund
+	IdempotencyKeyHeader = "Idempotency-Key"
 
 	// CustomerKey is the key name for retrieving jwt-decoded customer id in a http request context
 	CustomerKey HTTPContextKey = "customer_key"
 
+	// NATSTransportSTAN selects NATS Streaming as the transactional bus
+	NATSTransportSTAN = "stan"
+	// NATSTransportJetStream selects NATS JetStream as the transactional bus
+	NATSTransportJetStream = "jetstream"
+
 	// SpanContextKey is the message metadata key of span context passed accross process boundaries
 	SpanContextKey = "span_ctx_key"
 
@@ -19,6 +26,8 @@ var (
 	UpdateProductInventoryHandler = "update_product_inventory_handler"
 	// RollbackProductInventoryHandler identifier
 	RollbackProductInventoryHandler = "rollback_product_inventory_handler"
+	// ConfirmProductInventoryHandler identifier
+	ConfirmProductInventoryHandler = "confirm_product_inventory_handler"
 	// CreateOrderHandler identifier
 	CreateOrderHandler = "create_order_handler"
 	// RollbackOrderHandler identifier
@@ -30,6 +39,8 @@ var (
 
 	// PurchaseTopic is the subscribed topic for new purchase
 	PurchaseTopic = "purchase"
+	// CancelPurchaseTopic is the subscribed topic for cancelling a succeeded purchase
+	CancelPurchaseTopic = "purchase.cancel"
 	// PurchaseResultTopic is the topic to which we publish new purchase result
 	PurchaseResultTopic = "purchase.result"
 
@@ -39,10 +50,14 @@ var (
 	UpdateProductInventoryTopic = "product.update.inventory"
 	// RollbackProductInventoryTopic topic
 	RollbackProductInventoryTopic = "product.rollback.inventory"
+	// ConfirmProductInventoryTopic topic
+	ConfirmProductInventoryTopic = "product.confirm.inventory"
 	// CreateOrderTopic topic
 	CreateOrderTopic = "order.create"
 	// RollbackOrderTopic topic
 	RollbackOrderTopic = "order.rollback"
+	// ConfirmOrderTopic topic
+	ConfirmOrderTopic = "order.confirm"
 	// CreatePaymentTopic topic
 	CreatePaymentTopic = "payment.create"
 	// RollbackPaymentTopic topic
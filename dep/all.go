@@ -0,0 +1,76 @@
+package dep
+
+import (
+	conf "github.com/minghsu0107/saga-product/config"
+	"github.com/minghsu0107/saga-product/infra"
+	"github.com/minghsu0107/saga-product/infra/broker"
+	"github.com/minghsu0107/saga-product/infra/cache"
+	"github.com/minghsu0107/saga-product/infra/db"
+	infra_grpc_auth "github.com/minghsu0107/saga-product/infra/grpc/auth"
+	infra_observe "github.com/minghsu0107/saga-product/infra/observe"
+	"github.com/minghsu0107/saga-product/repo"
+)
+
+// InitializeAllInOneServer wires all services into one process over an in-memory broker
+// The services share the database, redis and auth connections of the process
+func InitializeAllInOneServer() (*infra.AllInOneServer, error) {
+	config, err := conf.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	gormDB, err := db.NewDatabaseConnection(config)
+	if err != nil {
+		return nil, err
+	}
+	redisClient, err := cache.NewRedisClient(config)
+	if err != nil {
+		return nil, err
+	}
+	authConn, err := infra_grpc_auth.NewAuthConn(config)
+	if err != nil {
+		return nil, err
+	}
+	pubSub := broker.NewInMemoryPubSub()
+
+	productServer, err := InitializeInMemoryProductServer(newServiceConfig(config, "product", config.AllInOneConfig.ProductHTTPPort), gormDB, redisClient, pubSub, pubSub)
+	if err != nil {
+		return nil, err
+	}
+	orderConfig := newServiceConfig(config, "order", config.AllInOneConfig.OrderHTTPPort)
+	if orderConfig.RPCEndpoints.ProductSvcHost == "" {
+		rpcEndpoints := *orderConfig.RPCEndpoints
+		rpcEndpoints.ProductSvcHost = "localhost:" + config.GRPCPort
+		orderConfig.RPCEndpoints = &rpcEndpoints
+	}
+	orderServer, err := InitializeInMemoryOrderServer(orderConfig, gormDB, redisClient, authConn, pubSub, pubSub)
+	if err != nil {
+		return nil, err
+	}
+	paymentServer, err := InitializeInMemoryPaymentServer(newServiceConfig(config, "payment", config.AllInOneConfig.PaymentHTTPPort), gormDB, redisClient, authConn, pubSub, pubSub)
+	if err != nil {
+		return nil, err
+	}
+	orchestratorConfig := newServiceConfig(config, "orchestrator", config.AllInOneConfig.OrchestratorHTTPPort)
+	orchestratorConfig.GRPCPort = config.AllInOneConfig.OrchestratorGRPCPort
+	resultStream := repo.NewInMemoryPurchaseResultRepository(config.RedisConfig.Publisher.PurchaseResultTopicMaxlen)
+	orchestratorServer, err := InitializeInMemoryOrchestratorServer(orchestratorConfig, gormDB, authConn, pubSub, pubSub, resultStream, resultStream)
+	if err != nil {
+		return nil, err
+	}
+	obsInjector, err := infra_observe.NewObservabilityInjector(config)
+	if err != nil {
+		return nil, err
+	}
+	return infra.NewAllInOneServer(productServer, orderServer, paymentServer, orchestratorServer, pubSub, obsInjector), nil
+}
+
+// newServiceConfig derives the config of a service from the config of the process
+// Observability is registered once by the process instead of by each service
+func newServiceConfig(config *conf.Config, app, httpPort string) *conf.Config {
+	serviceConfig := *config
+	serviceConfig.App = app
+	serviceConfig.HTTPPort = httpPort
+	serviceConfig.PromPort = ""
+	serviceConfig.JaegerUrl = ""
+	return &serviceConfig
+}
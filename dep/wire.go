@@ -22,14 +22,21 @@ import (
 	"github.com/minghsu0107/saga-product/infra/cache"
 	"github.com/minghsu0107/saga-product/infra/db"
 	infra_grpc_auth "github.com/minghsu0107/saga-product/infra/grpc/auth"
+	infra_grpc_orchestrator "github.com/minghsu0107/saga-product/infra/grpc/orchestrator"
 	infra_grpc_order "github.com/minghsu0107/saga-product/infra/grpc/order"
 	infra_grpc_product "github.com/minghsu0107/saga-product/infra/grpc/product"
 	"github.com/minghsu0107/saga-product/infra/http/middleware"
+	infra_http_orchestrator "github.com/minghsu0107/saga-product/infra/http/orchestrator"
 	infra_http_order "github.com/minghsu0107/saga-product/infra/http/order"
 	infra_http_payment "github.com/minghsu0107/saga-product/infra/http/payment"
 	infra_http_product "github.com/minghsu0107/saga-product/infra/http/product"
+	infra_job "github.com/minghsu0107/saga-product/infra/job"
+	infra_job_orchestrator "github.com/minghsu0107/saga-product/infra/job/orchestrator"
+	infra_job_product "github.com/minghsu0107/saga-product/infra/job/product"
 	infra_observe "github.com/minghsu0107/saga-product/infra/observe"
 	"github.com/minghsu0107/saga-product/pkg"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 )
 
 func InitializeProductServer() (*infra.ProductServer, error) {
@@ -45,25 +52,36 @@ func InitializeProductServer() (*infra.ProductServer, error) {
 
 		infra_broker_product.NewProductEventRouter,
 
+		infra_job_product.NewReservationExpiryJob,
+		infra_job_product.NewPriceChangeJob,
+		infra_job.NewOutboxRelayJob,
+
 		infra_observe.NewObservabilityInjector,
 
 		db.NewDatabaseConnection,
 
 		broker.NewNATSPublisher,
 		broker.NewNATSSubscriber,
+		broker.NewOutboxRelay,
 
 		cache.NewLocalCache,
 		cache.NewRedisClient,
 		cache.NewRedisCache,
 
 		proxy.NewProductRepoCache,
+		proxy.NewCategoryRepoCache,
 
 		product.NewProductService,
+		product.NewCategoryService,
 		product.NewSagaProductService,
 
 		repo.NewProductRepository,
+		repo.NewCategoryRepository,
+		repo.NewOutboxRepository,
+		repo.NewProcessedMessageRepository,
 
 		pkg.NewSonyFlake,
+		pkg.NewClock,
 	)
 	return &infra.ProductServer{}, nil
 }
@@ -84,12 +102,15 @@ func InitializeOrderServer() (*infra.OrderServer, error) {
 
 		infra_broker_order.NewOrderEventRouter,
 
+		infra_job.NewOutboxRelayJob,
+
 		infra_observe.NewObservabilityInjector,
 
 		db.NewDatabaseConnection,
 
 		broker.NewNATSPublisher,
 		broker.NewNATSSubscriber,
+		broker.NewOutboxRelay,
 
 		cache.NewRedisClient,
 		cache.NewRedisCache,
@@ -100,6 +121,8 @@ func InitializeOrderServer() (*infra.OrderServer, error) {
 		order.NewSagaOrderService,
 
 		repo.NewOrderRepository,
+		repo.NewOutboxRepository,
+		repo.NewProcessedMessageRepository,
 		repo.NewAuthRepository,
 	)
 	return &infra.OrderServer{}, nil
@@ -120,12 +143,15 @@ func InitializePaymentServer() (*infra.PaymentServer, error) {
 
 		infra_broker_payment.NewPaymentEventRouter,
 
+		infra_job.NewOutboxRelayJob,
+
 		infra_observe.NewObservabilityInjector,
 
 		db.NewDatabaseConnection,
 
 		broker.NewNATSPublisher,
 		broker.NewNATSSubscriber,
+		broker.NewOutboxRelay,
 
 		cache.NewRedisClient,
 		cache.NewRedisCache,
@@ -136,6 +162,8 @@ func InitializePaymentServer() (*infra.PaymentServer, error) {
 		payment.NewSagaPaymentService,
 
 		repo.NewPaymentRepository,
+		repo.NewOutboxRepository,
+		repo.NewProcessedMessageRepository,
 		repo.NewAuthRepository,
 	)
 	return &infra.PaymentServer{}, nil
@@ -146,16 +174,184 @@ func InitializeOrchestratorServer() (*infra.OrchestratorServer, error) {
 		conf.NewConfig,
 
 		infra.NewOrchestratorServer,
+		infra_http_orchestrator.NewOrchestratorServer,
+		infra_http_orchestrator.NewEngine,
+		infra_http_orchestrator.NewRouter,
+
+		middleware.NewJWTAuthChecker,
+
+		infra_grpc_orchestrator.NewOrchestratorServer,
+		infra_grpc_auth.NewAuthConn,
 
 		infra_broker_orchestrator.NewOrchestratorEventRouter,
 
+		infra_job_orchestrator.NewSagaTimeoutJob,
+		infra_job_orchestrator.NewPurchaseResultJob,
+		infra_job.NewOutboxRelayJob,
+
 		infra_observe.NewObservabilityInjector,
 
+		db.NewDatabaseConnection,
+
 		broker.NewNATSPublisher,
 		broker.NewNATSSubscriber,
 		broker.NewRedisPublisher,
+		broker.NewOrchestratorOutboxRelay,
+
+		cache.NewRedisClient,
+
+		orchestrator.NewOrchestratorService,
+		orchestrator.NewPurchaseService,
+		orchestrator.NewPurchaseResultService,
+		orchestrator.NewPurchaseSagaDefinition,
+
+		repo.NewSagaRepository,
+		repo.NewOutboxRepository,
+		repo.NewPurchaseResultRepository,
+		repo.NewAuthRepository,
+
+		pkg.NewClock,
+	)
+	return &infra.OrchestratorServer{}, nil
+}
+
+func InitializeInMemoryProductServer(config *conf.Config, gormDB *gorm.DB, redisClient redis.UniversalClient, txPublisher broker.NATSPublisher, txSubscriber broker.NATSSubscriber) (*infra.ProductServer, error) {
+	wire.Build(
+		infra.NewProductServer,
+		infra_http_product.NewProductServer,
+		infra_http_product.NewEngine,
+		infra_http_product.NewRouter,
+
+		infra_grpc_product.NewProductServer,
+
+		infra_broker_product.NewProductEventRouter,
+
+		infra_job_product.NewReservationExpiryJob,
+		infra_job_product.NewPriceChangeJob,
+		infra_job.NewOutboxRelayJob,
+
+		broker.NewOutboxRelay,
+
+		infra_observe.NewObservabilityInjector,
+
+		cache.NewLocalCache,
+		cache.NewRedisCache,
+
+		proxy.NewProductRepoCache,
+		proxy.NewCategoryRepoCache,
+
+		product.NewProductService,
+		product.NewCategoryService,
+		product.NewSagaProductService,
+
+		repo.NewProductRepository,
+		repo.NewCategoryRepository,
+		repo.NewOutboxRepository,
+		repo.NewProcessedMessageRepository,
+
+		pkg.NewSonyFlake,
+		pkg.NewClock,
+	)
+	return &infra.ProductServer{}, nil
+}
+
+func InitializeInMemoryOrderServer(config *conf.Config, gormDB *gorm.DB, redisClient redis.UniversalClient, authConn *infra_grpc_auth.AuthConn, txPublisher broker.NATSPublisher, txSubscriber broker.NATSSubscriber) (*infra.OrderServer, error) {
+	wire.Build(
+		infra.NewOrderServer,
+		infra_http_order.NewOrderServer,
+		infra_http_order.NewEngine,
+		infra_http_order.NewRouter,
+
+		middleware.NewJWTAuthChecker,
+
+		infra_grpc_order.NewProductConn,
+
+		infra_broker_order.NewOrderEventRouter,
+
+		infra_job.NewOutboxRelayJob,
+
+		broker.NewOutboxRelay,
+
+		infra_observe.NewObservabilityInjector,
+
+		cache.NewRedisCache,
+
+		proxy.NewOrderRepoCache,
+
+		order.NewOrderService,
+		order.NewSagaOrderService,
+
+		repo.NewOrderRepository,
+		repo.NewOutboxRepository,
+		repo.NewProcessedMessageRepository,
+		repo.NewAuthRepository,
+	)
+	return &infra.OrderServer{}, nil
+}
+
+func InitializeInMemoryPaymentServer(config *conf.Config, gormDB *gorm.DB, redisClient redis.UniversalClient, authConn *infra_grpc_auth.AuthConn, txPublisher broker.NATSPublisher, txSubscriber broker.NATSSubscriber) (*infra.PaymentServer, error) {
+	wire.Build(
+		infra.NewPaymentServer,
+		infra_http_payment.NewPaymentServer,
+		infra_http_payment.NewEngine,
+		infra_http_payment.NewRouter,
+
+		middleware.NewJWTAuthChecker,
+
+		infra_broker_payment.NewPaymentEventRouter,
+
+		infra_job.NewOutboxRelayJob,
+
+		broker.NewOutboxRelay,
+
+		infra_observe.NewObservabilityInjector,
+
+		cache.NewRedisCache,
+
+		proxy.NewPaymentRepoCache,
+
+		payment.NewPaymentService,
+		payment.NewSagaPaymentService,
+
+		repo.NewPaymentRepository,
+		repo.NewOutboxRepository,
+		repo.NewProcessedMessageRepository,
+		repo.NewAuthRepository,
+	)
+	return &infra.PaymentServer{}, nil
+}
+
+func InitializeInMemoryOrchestratorServer(config *conf.Config, gormDB *gorm.DB, authConn *infra_grpc_auth.AuthConn, txPublisher broker.NATSPublisher, txSubscriber broker.NATSSubscriber, resultPublisher broker.RedisPublisher, resultRepo repo.PurchaseResultRepository) (*infra.OrchestratorServer, error) {
+	wire.Build(
+		infra.NewOrchestratorServer,
+		infra_http_orchestrator.NewOrchestratorServer,
+		infra_http_orchestrator.NewEngine,
+		infra_http_orchestrator.NewRouter,
+
+		middleware.NewJWTAuthChecker,
+
+		infra_grpc_orchestrator.NewOrchestratorServer,
+
+		infra_broker_orchestrator.NewOrchestratorEventRouter,
+
+		infra_job_orchestrator.NewSagaTimeoutJob,
+		infra_job_orchestrator.NewPurchaseResultJob,
+		infra_job.NewOutboxRelayJob,
+
+		broker.NewOrchestratorOutboxRelay,
+
+		infra_observe.NewObservabilityInjector,
 
 		orchestrator.NewOrchestratorService,
+		orchestrator.NewPurchaseService,
+		orchestrator.NewPurchaseResultService,
+		orchestrator.NewPurchaseSagaDefinition,
+
+		repo.NewSagaRepository,
+		repo.NewOutboxRepository,
+		repo.NewAuthRepository,
+
+		pkg.NewClock,
 	)
 	return &infra.OrchestratorServer{}, nil
 }
@@ -10,27 +10,34 @@ import (
 	"github.com/minghsu0107/saga-product/config"
 	"github.com/minghsu0107/saga-product/infra"
 	"github.com/minghsu0107/saga-product/infra/broker"
-	orchestrator2 "github.com/minghsu0107/saga-product/infra/broker/orchestrator"
+	orchestrator4 "github.com/minghsu0107/saga-product/infra/broker/orchestrator"
 	order4 "github.com/minghsu0107/saga-product/infra/broker/order"
 	payment3 "github.com/minghsu0107/saga-product/infra/broker/payment"
 	product4 "github.com/minghsu0107/saga-product/infra/broker/product"
 	"github.com/minghsu0107/saga-product/infra/cache"
 	"github.com/minghsu0107/saga-product/infra/db"
 	"github.com/minghsu0107/saga-product/infra/grpc/auth"
+	orchestrator3 "github.com/minghsu0107/saga-product/infra/grpc/orchestrator"
 	order2 "github.com/minghsu0107/saga-product/infra/grpc/order"
 	product3 "github.com/minghsu0107/saga-product/infra/grpc/product"
 	"github.com/minghsu0107/saga-product/infra/http/middleware"
+	"github.com/minghsu0107/saga-product/infra/http/orchestrator"
 	"github.com/minghsu0107/saga-product/infra/http/order"
 	"github.com/minghsu0107/saga-product/infra/http/payment"
 	"github.com/minghsu0107/saga-product/infra/http/product"
+	"github.com/minghsu0107/saga-product/infra/job"
+	orchestrator5 "github.com/minghsu0107/saga-product/infra/job/orchestrator"
+	product5 "github.com/minghsu0107/saga-product/infra/job/product"
 	pkg2 "github.com/minghsu0107/saga-product/infra/observe"
 	"github.com/minghsu0107/saga-product/pkg"
 	"github.com/minghsu0107/saga-product/repo"
 	"github.com/minghsu0107/saga-product/repo/proxy"
-	"github.com/minghsu0107/saga-product/service/orchestrator"
+	orchestrator2 "github.com/minghsu0107/saga-product/service/orchestrator"
 	order3 "github.com/minghsu0107/saga-product/service/order"
 	payment2 "github.com/minghsu0107/saga-product/service/payment"
 	product2 "github.com/minghsu0107/saga-product/service/product"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 )
 
 // Injectors from wire.go:
@@ -63,10 +70,15 @@ func InitializeProductServer() (*infra.ProductServer, error) {
 	if err != nil {
 		return nil, err
 	}
-	productService := product2.NewProductService(configConfig, productRepoCache)
-	router := product.NewRouter(productService)
+	categoryRepository := repo.NewCategoryRepository(gormDB, idGenerator)
+	categoryRepoCache := proxy.NewCategoryRepoCache(configConfig, categoryRepository, redisCache)
+	clock := pkg.NewClock()
+	productService := product2.NewProductService(configConfig, productRepoCache, categoryRepoCache, clock)
+	categoryService := product2.NewCategoryService(configConfig, categoryRepoCache)
+	router := product.NewRouter(productService, categoryService)
 	server := product.NewProductServer(configConfig, engine, router)
-	sagaProductService := product2.NewSagaProductService(configConfig, productRepoCache)
+	processedMessageRepository := repo.NewProcessedMessageRepository(gormDB)
+	sagaProductService := product2.NewSagaProductService(configConfig, productRepoCache, processedMessageRepository, clock)
 	grpcServer := product3.NewProductServer(configConfig, productService, sagaProductService)
 	natsSubscriber, err := broker.NewNATSSubscriber(configConfig)
 	if err != nil {
@@ -80,11 +92,16 @@ func InitializeProductServer() (*infra.ProductServer, error) {
 	if err != nil {
 		return nil, err
 	}
+	jobJob := product5.NewReservationExpiryJob(configConfig, sagaProductService)
+	priceChangeJob := product5.NewPriceChangeJob(configConfig, productService)
+	outboxRepository := repo.NewOutboxRepository(gormDB)
+	outboxRelay := broker.NewOutboxRelay(configConfig, outboxRepository, natsPublisher)
+	outboxRelayJob := job.NewOutboxRelayJob(configConfig, outboxRelay)
 	observabilityInjector, err := pkg2.NewObservabilityInjector(configConfig)
 	if err != nil {
 		return nil, err
 	}
-	productServer := infra.NewProductServer(server, grpcServer, eventRouter, observabilityInjector)
+	productServer := infra.NewProductServer(server, grpcServer, eventRouter, jobJob, priceChangeJob, outboxRelayJob, observabilityInjector)
 	return productServer, nil
 }
 
@@ -112,7 +129,8 @@ func InitializeOrderServer() (*infra.OrderServer, error) {
 	if err != nil {
 		return nil, err
 	}
-	orderService := order3.NewOrderService(configConfig, orderRepoCache)
+	outboxRepository := repo.NewOutboxRepository(gormDB)
+	orderService := order3.NewOrderService(configConfig, orderRepoCache, outboxRepository)
 	router := order.NewRouter(orderService)
 	authConn, err := auth.NewAuthConn(configConfig)
 	if err != nil {
@@ -121,7 +139,8 @@ func InitializeOrderServer() (*infra.OrderServer, error) {
 	authRepository := repo.NewAuthRepository(authConn, configConfig)
 	jwtAuthChecker := middleware.NewJWTAuthChecker(configConfig, authRepository)
 	server := order.NewOrderServer(configConfig, engine, router, jwtAuthChecker)
-	sagaOrderService := order3.NewSagaOrderService(configConfig, orderRepoCache)
+	processedMessageRepository := repo.NewProcessedMessageRepository(gormDB)
+	sagaOrderService := order3.NewSagaOrderService(configConfig, orderRepoCache, processedMessageRepository)
 	natsSubscriber, err := broker.NewNATSSubscriber(configConfig)
 	if err != nil {
 		return nil, err
@@ -134,11 +153,13 @@ func InitializeOrderServer() (*infra.OrderServer, error) {
 	if err != nil {
 		return nil, err
 	}
+	outboxRelay := broker.NewOutboxRelay(configConfig, outboxRepository, natsPublisher)
+	outboxRelayJob := job.NewOutboxRelayJob(configConfig, outboxRelay)
 	observabilityInjector, err := pkg2.NewObservabilityInjector(configConfig)
 	if err != nil {
 		return nil, err
 	}
-	orderServer := infra.NewOrderServer(server, eventRouter, observabilityInjector)
+	orderServer := infra.NewOrderServer(server, eventRouter, outboxRelayJob, observabilityInjector)
 	return orderServer, nil
 }
 
@@ -171,7 +192,8 @@ func InitializePaymentServer() (*infra.PaymentServer, error) {
 	authRepository := repo.NewAuthRepository(authConn, configConfig)
 	jwtAuthChecker := middleware.NewJWTAuthChecker(configConfig, authRepository)
 	server := payment.NewPaymentServer(configConfig, engine, router, jwtAuthChecker)
-	sagaPaymentService := payment2.NewSagaPaymentService(configConfig, paymentRepoCache)
+	processedMessageRepository := repo.NewProcessedMessageRepository(gormDB)
+	sagaPaymentService := payment2.NewSagaPaymentService(configConfig, paymentRepoCache, processedMessageRepository)
 	natsSubscriber, err := broker.NewNATSSubscriber(configConfig)
 	if err != nil {
 		return nil, err
@@ -184,11 +206,14 @@ func InitializePaymentServer() (*infra.PaymentServer, error) {
 	if err != nil {
 		return nil, err
 	}
+	outboxRepository := repo.NewOutboxRepository(gormDB)
+	outboxRelay := broker.NewOutboxRelay(configConfig, outboxRepository, natsPublisher)
+	outboxRelayJob := job.NewOutboxRelayJob(configConfig, outboxRelay)
 	observabilityInjector, err := pkg2.NewObservabilityInjector(configConfig)
 	if err != nil {
 		return nil, err
 	}
-	paymentServer := infra.NewPaymentServer(server, eventRouter, observabilityInjector)
+	paymentServer := infra.NewPaymentServer(server, eventRouter, outboxRelayJob, observabilityInjector)
 	return paymentServer, nil
 }
 
@@ -197,15 +222,31 @@ func InitializeOrchestratorServer() (*infra.OrchestratorServer, error) {
 	if err != nil {
 		return nil, err
 	}
-	natsPublisher, err := broker.NewNATSPublisher(configConfig)
+	engine := orchestrator.NewEngine(configConfig)
+	gormDB, err := db.NewDatabaseConnection(configConfig)
 	if err != nil {
 		return nil, err
 	}
-	redisPublisher, err := broker.NewRedisPublisher(configConfig)
+	sagaRepository := repo.NewSagaRepository(gormDB)
+	purchaseService := orchestrator2.NewPurchaseService(configConfig, sagaRepository)
+	universalClient, err := cache.NewRedisClient(configConfig)
+	if err != nil {
+		return nil, err
+	}
+	purchaseResultRepository := repo.NewPurchaseResultRepository(universalClient)
+	purchaseResultService := orchestrator2.NewPurchaseResultService(configConfig, purchaseResultRepository)
+	router := orchestrator.NewRouter(configConfig, purchaseService, purchaseResultService)
+	authConn, err := auth.NewAuthConn(configConfig)
 	if err != nil {
 		return nil, err
 	}
-	orchestratorService, err := orchestrator.NewOrchestratorService(configConfig, natsPublisher, redisPublisher)
+	authRepository := repo.NewAuthRepository(authConn, configConfig)
+	jwtAuthChecker := middleware.NewJWTAuthChecker(configConfig, authRepository)
+	server := orchestrator.NewOrchestratorServer(configConfig, engine, router, jwtAuthChecker)
+	grpcServer := orchestrator3.NewOrchestratorServer(configConfig, purchaseService, authRepository)
+	sagaDefinition := orchestrator2.NewPurchaseSagaDefinition(configConfig)
+	clock := pkg.NewClock()
+	orchestratorService, err := orchestrator2.NewOrchestratorService(configConfig, sagaRepository, sagaDefinition, clock)
 	if err != nil {
 		return nil, err
 	}
@@ -213,15 +254,168 @@ func InitializeOrchestratorServer() (*infra.OrchestratorServer, error) {
 	if err != nil {
 		return nil, err
 	}
-	eventRouter, err := orchestrator2.NewOrchestratorEventRouter(configConfig, orchestratorService, natsSubscriber)
+	natsPublisher, err := broker.NewNATSPublisher(configConfig)
+	if err != nil {
+		return nil, err
+	}
+	eventRouter, err := orchestrator4.NewOrchestratorEventRouter(configConfig, orchestratorService, natsSubscriber, natsPublisher)
+	if err != nil {
+		return nil, err
+	}
+	jobJob := orchestrator5.NewSagaTimeoutJob(configConfig, orchestratorService)
+	outboxRepository := repo.NewOutboxRepository(gormDB)
+	redisPublisher, err := broker.NewRedisPublisher(configConfig)
 	if err != nil {
 		return nil, err
 	}
+	outboxRelay := broker.NewOrchestratorOutboxRelay(configConfig, outboxRepository, natsPublisher, redisPublisher)
+	outboxRelayJob := job.NewOutboxRelayJob(configConfig, outboxRelay)
+	purchaseResultJob := orchestrator5.NewPurchaseResultJob(configConfig, purchaseResultService)
 	observabilityInjector, err := pkg2.NewObservabilityInjector(configConfig)
 	if err != nil {
 		return nil, err
 	}
-	orchestratorServer := infra.NewOrchestratorServer(eventRouter, observabilityInjector)
+	orchestratorServer := infra.NewOrchestratorServer(server, grpcServer, eventRouter, jobJob, outboxRelayJob, purchaseResultJob, observabilityInjector)
+	return orchestratorServer, nil
+}
+
+func InitializeInMemoryProductServer(config2 *config.Config, gormDB *gorm.DB, redisClient redis.UniversalClient, txPublisher broker.NATSPublisher, txSubscriber broker.NATSSubscriber) (*infra.ProductServer, error) {
+	engine := product.NewEngine(config2)
+	idGenerator, err := pkg.NewSonyFlake()
+	if err != nil {
+		return nil, err
+	}
+	productRepository := repo.NewProductRepository(gormDB, idGenerator)
+	localCache, err := cache.NewLocalCache(config2)
+	if err != nil {
+		return nil, err
+	}
+	redisCache := cache.NewRedisCache(config2, redisClient)
+	productRepoCache, err := proxy.NewProductRepoCache(config2, productRepository, localCache, redisCache)
+	if err != nil {
+		return nil, err
+	}
+	categoryRepository := repo.NewCategoryRepository(gormDB, idGenerator)
+	categoryRepoCache := proxy.NewCategoryRepoCache(config2, categoryRepository, redisCache)
+	clock := pkg.NewClock()
+	productService := product2.NewProductService(config2, productRepoCache, categoryRepoCache, clock)
+	categoryService := product2.NewCategoryService(config2, categoryRepoCache)
+	router := product.NewRouter(productService, categoryService)
+	server := product.NewProductServer(config2, engine, router)
+	processedMessageRepository := repo.NewProcessedMessageRepository(gormDB)
+	sagaProductService := product2.NewSagaProductService(config2, productRepoCache, processedMessageRepository, clock)
+	grpcServer := product3.NewProductServer(config2, productService, sagaProductService)
+	eventRouter, err := product4.NewProductEventRouter(config2, sagaProductService, txSubscriber, txPublisher)
+	if err != nil {
+		return nil, err
+	}
+	jobJob := product5.NewReservationExpiryJob(config2, sagaProductService)
+	priceChangeJob := product5.NewPriceChangeJob(config2, productService)
+	outboxRepository := repo.NewOutboxRepository(gormDB)
+	outboxRelay := broker.NewOutboxRelay(config2, outboxRepository, txPublisher)
+	outboxRelayJob := job.NewOutboxRelayJob(config2, outboxRelay)
+	observabilityInjector, err := pkg2.NewObservabilityInjector(config2)
+	if err != nil {
+		return nil, err
+	}
+	productServer := infra.NewProductServer(server, grpcServer, eventRouter, jobJob, priceChangeJob, outboxRelayJob, observabilityInjector)
+	return productServer, nil
+}
+
+func InitializeInMemoryOrderServer(config2 *config.Config, gormDB *gorm.DB, redisClient redis.UniversalClient, authConn *auth.AuthConn, txPublisher broker.NATSPublisher, txSubscriber broker.NATSSubscriber) (*infra.OrderServer, error) {
+	engine := order.NewEngine(config2)
+	productConn, err := order2.NewProductConn(config2)
+	if err != nil {
+		return nil, err
+	}
+	orderRepository := repo.NewOrderRepository(config2, productConn, gormDB)
+	redisCache := cache.NewRedisCache(config2, redisClient)
+	orderRepoCache, err := proxy.NewOrderRepoCache(config2, orderRepository, redisCache)
+	if err != nil {
+		return nil, err
+	}
+	outboxRepository := repo.NewOutboxRepository(gormDB)
+	orderService := order3.NewOrderService(config2, orderRepoCache, outboxRepository)
+	router := order.NewRouter(orderService)
+	authRepository := repo.NewAuthRepository(authConn, config2)
+	jwtAuthChecker := middleware.NewJWTAuthChecker(config2, authRepository)
+	server := order.NewOrderServer(config2, engine, router, jwtAuthChecker)
+	processedMessageRepository := repo.NewProcessedMessageRepository(gormDB)
+	sagaOrderService := order3.NewSagaOrderService(config2, orderRepoCache, processedMessageRepository)
+	eventRouter, err := order4.NewOrderEventRouter(config2, sagaOrderService, txSubscriber, txPublisher)
+	if err != nil {
+		return nil, err
+	}
+	outboxRelay := broker.NewOutboxRelay(config2, outboxRepository, txPublisher)
+	outboxRelayJob := job.NewOutboxRelayJob(config2, outboxRelay)
+	observabilityInjector, err := pkg2.NewObservabilityInjector(config2)
+	if err != nil {
+		return nil, err
+	}
+	orderServer := infra.NewOrderServer(server, eventRouter, outboxRelayJob, observabilityInjector)
+	return orderServer, nil
+}
+
+func InitializeInMemoryPaymentServer(config2 *config.Config, gormDB *gorm.DB, redisClient redis.UniversalClient, authConn *auth.AuthConn, txPublisher broker.NATSPublisher, txSubscriber broker.NATSSubscriber) (*infra.PaymentServer, error) {
+	engine := payment.NewEngine(config2)
+	paymentRepository := repo.NewPaymentRepository(gormDB)
+	redisCache := cache.NewRedisCache(config2, redisClient)
+	paymentRepoCache, err := proxy.NewPaymentRepoCache(config2, paymentRepository, redisCache)
+	if err != nil {
+		return nil, err
+	}
+	paymentService := payment2.NewPaymentService(config2, paymentRepoCache)
+	router := payment.NewRouter(paymentService)
+	authRepository := repo.NewAuthRepository(authConn, config2)
+	jwtAuthChecker := middleware.NewJWTAuthChecker(config2, authRepository)
+	server := payment.NewPaymentServer(config2, engine, router, jwtAuthChecker)
+	processedMessageRepository := repo.NewProcessedMessageRepository(gormDB)
+	sagaPaymentService := payment2.NewSagaPaymentService(config2, paymentRepoCache, processedMessageRepository)
+	eventRouter, err := payment3.NewPaymentEventRouter(config2, sagaPaymentService, txSubscriber, txPublisher)
+	if err != nil {
+		return nil, err
+	}
+	outboxRepository := repo.NewOutboxRepository(gormDB)
+	outboxRelay := broker.NewOutboxRelay(config2, outboxRepository, txPublisher)
+	outboxRelayJob := job.NewOutboxRelayJob(config2, outboxRelay)
+	observabilityInjector, err := pkg2.NewObservabilityInjector(config2)
+	if err != nil {
+		return nil, err
+	}
+	paymentServer := infra.NewPaymentServer(server, eventRouter, outboxRelayJob, observabilityInjector)
+	return paymentServer, nil
+}
+
+func InitializeInMemoryOrchestratorServer(config2 *config.Config, gormDB *gorm.DB, authConn *auth.AuthConn, txPublisher broker.NATSPublisher, txSubscriber broker.NATSSubscriber, resultPublisher broker.RedisPublisher, resultRepo repo.PurchaseResultRepository) (*infra.OrchestratorServer, error) {
+	engine := orchestrator.NewEngine(config2)
+	sagaRepository := repo.NewSagaRepository(gormDB)
+	purchaseService := orchestrator2.NewPurchaseService(config2, sagaRepository)
+	purchaseResultService := orchestrator2.NewPurchaseResultService(config2, resultRepo)
+	router := orchestrator.NewRouter(config2, purchaseService, purchaseResultService)
+	authRepository := repo.NewAuthRepository(authConn, config2)
+	jwtAuthChecker := middleware.NewJWTAuthChecker(config2, authRepository)
+	server := orchestrator.NewOrchestratorServer(config2, engine, router, jwtAuthChecker)
+	grpcServer := orchestrator3.NewOrchestratorServer(config2, purchaseService, authRepository)
+	sagaDefinition := orchestrator2.NewPurchaseSagaDefinition(config2)
+	clock := pkg.NewClock()
+	orchestratorService, err := orchestrator2.NewOrchestratorService(config2, sagaRepository, sagaDefinition, clock)
+	if err != nil {
+		return nil, err
+	}
+	eventRouter, err := orchestrator4.NewOrchestratorEventRouter(config2, orchestratorService, txSubscriber, txPublisher)
+	if err != nil {
+		return nil, err
+	}
+	jobJob := orchestrator5.NewSagaTimeoutJob(config2, orchestratorService)
+	outboxRepository := repo.NewOutboxRepository(gormDB)
+	outboxRelay := broker.NewOrchestratorOutboxRelay(config2, outboxRepository, txPublisher, resultPublisher)
+	outboxRelayJob := job.NewOutboxRelayJob(config2, outboxRelay)
+	purchaseResultJob := orchestrator5.NewPurchaseResultJob(config2, purchaseResultService)
+	observabilityInjector, err := pkg2.NewObservabilityInjector(config2)
+	if err != nil {
+		return nil, err
+	}
+	orchestratorServer := infra.NewOrchestratorServer(server, grpcServer, eventRouter, jobJob, outboxRelayJob, purchaseResultJob, observabilityInjector)
 	return orchestratorServer, nil
 }
 
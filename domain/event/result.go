@@ -9,11 +9,16 @@ var (
 	StepCreateOrder            = "CREATE_ORDER"
 	StepCreatePayment          = "CREATE_PAYMENT"
 
+	StepCancelOrder             = "CANCEL_ORDER"
+	StepRefundPayment           = "REFUND_PAYMENT"
+	StepRestockProductInventory = "RESTOCK_PRODUCT_INVENTORY"
+
 	StatusExecute        = "STATUS_EXUCUTE"
 	StatusSucess         = "STATUS_SUCCESS"
 	StatusFailed         = "STATUS_FAILED"
 	StatusRollbacked     = "STATUS_ROLLBACKED"
 	StatusRollbackFailed = "STATUS_ROLLBACK_FAIL"
+	StatusTimeout        = "STATUS_TIMEOUT"
 )
 
 // PurchaseResult event
@@ -22,5 +27,12 @@ type PurchaseResult struct {
 	PurchaseID uint64
 	Step       string
 	Status     string
+	Error      string
 	Timestamp  time.Time
 }
+
+// StreamedPurchaseResult is a purchase result together with its ID in the result stream
+type StreamedPurchaseResult struct {
+	ID     string
+	Result *PurchaseResult
+}
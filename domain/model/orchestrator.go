@@ -14,3 +14,10 @@ type RollbackResponse struct {
 	Success    bool
 	Error      string
 }
+
+// ConfirmResponse value object
+type ConfirmResponse struct {
+	PurchaseID uint64
+	Success    bool
+	Error      string
+}
@@ -1,25 +1,102 @@
 package model
 
+import "time"
+
+// OrderStatus enumeration
+type OrderStatus string
+
+const (
+	// OrderPending is an order whose purchase is in progress
+	OrderPending OrderStatus = "pending"
+	// OrderConfirmed is an order whose purchase has succeeded
+	OrderConfirmed OrderStatus = "confirmed"
+	// OrderCancelled is an order whose purchase has failed or is cancelled
+	OrderCancelled OrderStatus = "cancelled"
+	// OrderRefunded is an order whose payment has been refunded
+	OrderRefunded OrderStatus = "refunded"
+	// OrderFulfilled is an order that has been delivered to the customer
+	OrderFulfilled OrderStatus = "fulfilled"
+)
+
+// OrderTransitions maps an order status to the statuses the order may change to
+// Cancelled and refunded orders cannot change anymore
+var OrderTransitions = map[OrderStatus][]OrderStatus{
+	OrderPending:   {OrderConfirmed, OrderCancelled},
+	OrderConfirmed: {OrderCancelled, OrderRefunded, OrderFulfilled},
+	OrderFulfilled: {OrderRefunded},
+}
+
 // Order entity
+// CurrencyCode is the currency of the payment of the order, and PlacedAt is when the customer checked out
 type Order struct {
 	ID             uint64
 	CustomerID     uint64
+	CurrencyCode   string
 	PurchasedItems *[]PurchasedItem
+	PlacedAt       time.Time
+}
+
+// OrderTransition value object
+// It is a status that the order changed to at Timestamp
+type OrderTransition struct {
+	Status    OrderStatus
+	Timestamp time.Time
 }
 
 // DetailedOrder value object
+// TotalAmount is the number of purchased units, and TotalPrice is their price when the order was created
+// Transitions are the status changes of the order from the earliest
 type DetailedOrder struct {
 	ID                     uint64
 	CustomerID             uint64
+	CurrencyCode           string
 	DetailedPurchasedItems *[]DetailedPurchasedItem
+	TotalAmount            int64
+	TotalPrice             int64
+	Status                 OrderStatus
+	StatusUpdatedAt        time.Time
+	Transitions            *[]OrderTransition
+	CreatedAt              time.Time
 }
 
 // DetailedPurchasedItem value object
+// Name, Description, BrandName and Price are those of the product, or of its variant, when the order was created
 type DetailedPurchasedItem struct {
 	ProductID   uint64
+	VariantID   uint64
 	Name        string
 	Description string
 	BrandName   string
 	Price       int64
 	Amount      int64
 }
+
+// OrderQuery value object
+// Orders of the customer created from From until before To are listed from the latest; a zero From or To is not applied
+// Cursor is an opaque keyset pagination token returned with a previous page
+type OrderQuery struct {
+	CustomerID uint64
+	From       time.Time
+	To         time.Time
+	Size       int
+	Cursor     string
+}
+
+// OrderSummary value object
+// TotalAmount is the number of purchased units, and TotalPrice is their price when the order was created
+type OrderSummary struct {
+	ID          uint64
+	CustomerID  uint64
+	ItemCount   int
+	TotalAmount int64
+	TotalPrice  int64
+	Status      OrderStatus
+	CreatedAt   time.Time
+}
+
+// OrderPage value object
+// NextCursor is empty on the last page
+type OrderPage struct {
+	Orders     []OrderSummary
+	NextCursor string
+}
@@ -0,0 +1,28 @@
+package model
+
+const (
+	// OutboxTransportTx relays a message to the transactional bus
+	OutboxTransportTx = "tx"
+	// OutboxTransportResult relays a message to the result stream
+	OutboxTransportResult = "result"
+)
+
+// OutboxMessage entity
+// It is recorded together with a business change and relayed to the broker afterwards
+type OutboxMessage struct {
+	ID        uint64
+	Service   string
+	Transport string
+	Topic     string
+	UUID      string
+	Payload   []byte
+	Metadata  map[string]string
+}
+
+// ProcessedMessage entity
+// It records the reply to a consumed command so that a redelivered command is answered with the same reply
+type ProcessedMessage struct {
+	UUID       string
+	PurchaseID uint64
+	Reply      *OutboxMessage
+}
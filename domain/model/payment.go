@@ -1,9 +1,30 @@
 package model
 
+import "time"
+
 // payment value object
+// RefundedAmount is the sum of all refunds of the payment
 type Payment struct {
-	ID           uint64
-	CustomerID   uint64
-	CurrencyCode string
-	Amount       int64
+	ID             uint64
+	CustomerID     uint64
+	CurrencyCode   string
+	Amount         int64
+	RefundedAmount int64
+}
+
+// RefundableAmount is the amount of the payment that has not been refunded yet
+func (p *Payment) RefundableAmount() int64 {
+	return p.Amount - p.RefundedAmount
+}
+
+// Refund entity
+// Refunds with the same IdempotencyKey are issued only once
+type Refund struct {
+	ID             uint64
+	PaymentID      uint64
+	IdempotencyKey string
+	Amount         int64
+	Reason         string
+	Actor          string
+	CreatedAt      time.Time
 }
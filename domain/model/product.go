@@ -1,10 +1,54 @@
 package model
 
+import "time"
+
 // Product entity
+// Inventory is the available quantity, excluding the quantity reserved for ongoing purchases
 type Product struct {
+	ID         uint64
+	Detail     *ProductDetail
+	Inventory  int64
+	Reserved   int64
+	Categories []Category
+	Variants   []ProductVariant
+}
+
+// ProductVariant entity
+// A variant is a SKU of a product with its own price and inventory
+// Inventory is the available quantity, excluding the quantity reserved for ongoing purchases
+type ProductVariant struct {
 	ID        uint64
-	Detail    *ProductDetail
+	ProductID uint64
+	SKU       string
+	Name      string
+	Price     int64
 	Inventory int64
+	Reserved  int64
+	Archived  bool
+}
+
+// ProductVariantUpdate value object
+// Only non-nil fields are updated
+type ProductVariantUpdate struct {
+	SKU      *string
+	Name     *string
+	Price    *int64
+	Archived *bool
+}
+
+// Category entity
+// A root category has no parent, that is, a zero ParentID
+type Category struct {
+	ID       uint64
+	ParentID uint64
+	Name     string
+}
+
+// CategoryUpdate value object
+// Only non-nil fields are updated; a zero ParentID moves the category to the root
+type CategoryUpdate struct {
+	Name     *string
+	ParentID *uint64
 }
 
 // ProductDetail value object
@@ -13,18 +57,49 @@ type ProductDetail struct {
 	Description string
 	BrandName   string
 	Price       int64
+	Archived    bool
+}
+
+// ProductImportError value object
+// Index is the position of the rejected product among the imported products
+type ProductImportError struct {
+	Index int
+	Err   error
+}
+
+// ProductImportResult value object
+// ProductIDs are in the order of the imported products, with a zero ID for each rejected product
+type ProductImportResult struct {
+	ProductIDs []uint64
+	Errors     []ProductImportError
+}
+
+// ProductUpdate value object
+// Only non-nil fields are updated
+type ProductUpdate struct {
+	Name        *string
+	Description *string
+	BrandName   *string
+	Price       *int64
+	Archived    *bool
 }
 
 // CartItem value object
+// A zero VariantID refers to the product itself rather than one of its variants
 type CartItem struct {
 	ProductID uint64
+	VariantID uint64
 	Amount    int64
 }
 
 // PurchasedItem value object
+// A zero VariantID refers to the product itself rather than one of its variants;
+// Price is the unit price the customer checked out at, which is zero if the purchase does not carry it
 type PurchasedItem struct {
 	ProductID uint64
+	VariantID uint64
 	Amount    int64
+	Price     int64
 }
 
 // Status enumeration
@@ -40,6 +115,7 @@ const (
 // ProductStatus value object
 type ProductStatus struct {
 	ProductID uint64
+	VariantID uint64
 	Price     int64
 	Status    Status
 }
@@ -49,12 +125,109 @@ type ProductCatalog struct {
 	ID        uint64
 	Name      string
 	Inventory int64
+	Reserved  int64
 	Price     int64
 }
 
+// ProductSortField enumeration
+type ProductSortField string
+
+const (
+	// SortByRelevance sorts by full-text relevance when searching by keyword, and by product ID otherwise
+	SortByRelevance ProductSortField = ""
+	// SortByPrice sorts by price
+	SortByPrice ProductSortField = "price"
+	// SortByName sorts by name
+	SortByName ProductSortField = "name"
+	// SortByCreatedAt sorts by creation time
+	SortByCreatedAt ProductSortField = "created_at"
+)
+
+// ProductQuery value object
+// Zero-valued filters are not applied; MinPrice and MaxPrice are inclusive bounds
+// CategoryID matches products assigned to the category or any of its descendants
+// Cursor is an opaque keyset pagination token returned with a previous page, which replaces Offset
+type ProductQuery struct {
+	Keyword    string
+	BrandName  string
+	CategoryID uint64
+	MinPrice   int64
+	MaxPrice   int64
+	InStock    bool
+	SortBy     ProductSortField
+	Descending bool
+	Offset     int
+	Size       int
+	Cursor     string
+}
+
+// ProductCatalogPage value object
+// NextCursor is empty on the last page and when products are not listed in product ID order
+type ProductCatalogPage struct {
+	Catalogs   []ProductCatalog
+	NextCursor string
+}
+
 // Idempotency entity
+// Confirmed tells whether the inventory hold has been confirmed
 type Idempotency struct {
 	ID        uint64
 	ProductID uint64
+	VariantID uint64
 	Amount    int64
+	Confirmed bool
+}
+
+// AdjustmentReason enumeration
+type AdjustmentReason string
+
+const (
+	// InventoryRestock adds received stock
+	InventoryRestock AdjustmentReason = "restock"
+	// InventoryShrinkage removes lost, stolen or damaged stock
+	InventoryShrinkage AdjustmentReason = "shrinkage"
+	// InventoryCorrection fixes a miscounted inventory in either direction
+	InventoryCorrection AdjustmentReason = "correction"
+)
+
+// InventoryAdjustment value object
+// A non-zero VariantID adjusts the inventory of the variant instead of the product
+type InventoryAdjustment struct {
+	ProductID uint64
+	VariantID uint64
+	Delta     int64
+	Reason    AdjustmentReason
+	Actor     string
+}
+
+// InventoryLedgerEntry entity
+// Inventory is the inventory of the product, or of its variant, right after the adjustment
+type InventoryLedgerEntry struct {
+	ID        uint64
+	ProductID uint64
+	VariantID uint64
+	Delta     int64
+	Reason    AdjustmentReason
+	Actor     string
+	Inventory int64
+	CreatedAt time.Time
+}
+
+// ProductPrice value object
+// It is the price of a product, or of its variant, that took effect at EffectiveAt
+type ProductPrice struct {
+	ProductID   uint64
+	VariantID   uint64
+	Price       int64
+	EffectiveAt time.Time
+}
+
+// PriceChange entity
+// It is a change of the price of a product, or of its variant, scheduled to take effect at EffectiveAt
+type PriceChange struct {
+	ID          uint64
+	ProductID   uint64
+	VariantID   uint64
+	Price       int64
+	EffectiveAt time.Time
 }
@@ -0,0 +1,51 @@
+package model
+
+import "time"
+
+// SagaInstance entity
+type SagaInstance struct {
+	PurchaseID    uint64
+	CustomerID    uint64
+	CorrelationID string
+	CurrentStep   string
+	Status        string
+	Steps         *[]SagaStep
+	Deadline      time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// SagaStep value object
+type SagaStep struct {
+	Step      string
+	Status    string
+	UpdatedAt time.Time
+}
+
+// SagaState value object
+// It is the state of a saga instance that recording transitions is conditioned on
+type SagaState struct {
+	CurrentStep string
+	Status      string
+	Deadline    time.Time
+}
+
+// SagaTransition value object
+type SagaTransition struct {
+	Step      string
+	Status    string
+	Error     string
+	Timestamp time.Time
+}
+
+// PurchaseStatus value object
+type PurchaseStatus struct {
+	PurchaseID  uint64
+	CustomerID  uint64
+	CurrentStep string
+	Status      string
+	Error       string
+	Transitions *[]SagaTransition
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
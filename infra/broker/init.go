@@ -7,11 +7,13 @@ import (
 	"github.com/ThreeDotsLabs/watermill/components/metrics"
 	"github.com/ThreeDotsLabs/watermill/message"
 	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+	conf "github.com/minghsu0107/saga-product/config"
 	prom "github.com/prometheus/client_golang/prometheus"
 )
 
 // InitializeRouter factory
-func InitializeRouter(app string) (*message.Router, error) {
+// Messages whose handler still fails after all retries are published to the poison queue by poisonPublisher
+func InitializeRouter(config *conf.Config, poisonPublisher message.Publisher) (*message.Router, error) {
 	router, err := message.NewRouter(message.RouterConfig{}, logger)
 	if err != nil {
 		return nil, err
@@ -20,15 +22,33 @@ func InitializeRouter(app string) (*message.Router, error) {
 	if !ok {
 		return nil, fmt.Errorf("prometheus type casting error")
 	}
-	metricsBuilder := metrics.NewPrometheusMetricsBuilder(registry, app, "pubsub")
+	metricsBuilder := metrics.NewPrometheusMetricsBuilder(registry, config.App, "pubsub")
 	metricsBuilder.AddPrometheusRouterMetrics(router)
 
+	poisonQueue, err := middleware.PoisonQueue(poisonPublisher, config.RouterConfig.PoisonQueueTopic)
+	if err != nil {
+		return nil, err
+	}
+	retry := middleware.Retry{
+		MaxRetries:      config.RouterConfig.MaxRetries,
+		InitialInterval: time.Duration(config.RouterConfig.InitialIntervalMilli) * time.Millisecond,
+		MaxInterval:     time.Duration(config.RouterConfig.MaxIntervalMilli) * time.Millisecond,
+		Multiplier:      config.RouterConfig.Multiplier,
+		Logger:          logger,
+	}
+
 	// Router level middleware are executed for every message sent to the router
 	router.AddMiddleware(
 		// CorrelationID will copy the correlation id from the incoming message's metadata to the produced messages
 		middleware.CorrelationID,
+		// PoisonQueue publishes the message together with the failing handler and error once retries are exhausted
+		poisonQueue,
 		// Timeout makes the handler cancel the incoming message's context after a specified time
+		// It wraps the retries since they stop once the context is done
 		middleware.Timeout(time.Second*15),
+		// Retry reprocesses the message with exponential backoff
+		retry.Middleware,
+		countAttempts,
 		middleware.Recoverer,
 	)
 	return router, nil
@@ -0,0 +1,288 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/minghsu0107/saga-product/pkg"
+	"github.com/nats-io/nats.go"
+)
+
+// UUIDHeader is the NATS header carrying the watermill message UUID
+const UUIDHeader = "_watermill_message_uuid"
+
+// JetStreamConfig configures the JetStream publisher and subscriber
+type JetStreamConfig struct {
+	URL     string
+	Name    string
+	Options []nats.Option
+
+	// QueueGroup and DurableName identify the durable consumer shared by all subscribers of a service
+	QueueGroup       string
+	DurableName      string
+	SubscribersCount int
+	// MaxDeliver is the maximum number of times a message is delivered before it is dropped
+	MaxDeliver int
+	// AckWait is how long the server waits for an ack before it redelivers the message
+	AckWait time.Duration
+}
+
+type jetStream struct {
+	conn    *nats.Conn
+	js      nats.JetStreamContext
+	streams sync.Map
+}
+
+func newJetStream(config JetStreamConfig) (*jetStream, error) {
+	conn, err := nats.Connect(config.URL, append(config.Options, nats.Name(config.Name))...)
+	if err != nil {
+		return nil, err
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &jetStream{
+		conn: conn,
+		js:   js,
+	}, nil
+}
+
+// ensureStream creates the stream of the topic if it does not exist
+// Each topic is stored in its own stream, named after the topic
+func (s *jetStream) ensureStream(topic string) error {
+	if _, ok := s.streams.Load(topic); ok {
+		return nil
+	}
+	name := streamName(topic)
+	_, err := s.js.StreamInfo(name)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		_, err = s.js.AddStream(&nats.StreamConfig{
+			Name:     name,
+			Subjects: []string{topic},
+			Storage:  nats.FileStorage,
+		})
+		if err != nil {
+			// the stream may have been created concurrently by another client
+			_, err = s.js.StreamInfo(name)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	s.streams.Store(topic, struct{}{})
+	return nil
+}
+
+func streamName(topic string) string {
+	return strings.NewReplacer(".", "_", "*", "_", ">", "_").Replace(topic)
+}
+
+// JetStreamPublisher publishes messages to NATS JetStream
+type JetStreamPublisher struct {
+	*jetStream
+}
+
+// NewJetStreamPublisher is the factory of JetStreamPublisher
+func NewJetStreamPublisher(config JetStreamConfig) (*JetStreamPublisher, error) {
+	js, err := newJetStream(config)
+	if err != nil {
+		return nil, err
+	}
+	return &JetStreamPublisher{
+		jetStream: js,
+	}, nil
+}
+
+// Publish publishes messages and waits for JetStream to persist each of them
+func (p *JetStreamPublisher) Publish(topic string, messages ...*message.Message) error {
+	if err := p.ensureStream(topic); err != nil {
+		return err
+	}
+	for _, msg := range messages {
+		natsMsg := nats.NewMsg(topic)
+		natsMsg.Data = msg.Payload
+		natsMsg.Header.Set(UUIDHeader, msg.UUID)
+		for k, v := range msg.Metadata {
+			natsMsg.Header.Set(k, v)
+		}
+		if _, err := p.js.PublishMsg(natsMsg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the publisher
+func (p *JetStreamPublisher) Close() error {
+	p.conn.Close()
+	return nil
+}
+
+// JetStreamSubscriber consumes messages from NATS JetStream with durable queue consumers and explicit acks
+type JetStreamSubscriber struct {
+	*jetStream
+	config  JetStreamConfig
+	logger  watermill.LoggerAdapter
+	closing chan struct{}
+	closed  bool
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+}
+
+// NewJetStreamSubscriber is the factory of JetStreamSubscriber
+func NewJetStreamSubscriber(config JetStreamConfig, logger watermill.LoggerAdapter) (*JetStreamSubscriber, error) {
+	if config.SubscribersCount < 1 {
+		config.SubscribersCount = 1
+	}
+	js, err := newJetStream(config)
+	if err != nil {
+		return nil, err
+	}
+	return &JetStreamSubscriber{
+		jetStream: js,
+		config:    config,
+		logger:    logger,
+		closing:   make(chan struct{}),
+	}, nil
+}
+
+// Subscribe subscribes to the topic with the durable consumer
+// A message is acked only after the handler acks it and is redelivered after a nack or AckWait
+func (s *JetStreamSubscriber) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, errors.New("subscriber closed")
+	}
+	if err := s.ensureStream(topic); err != nil {
+		return nil, err
+	}
+	stream := streamName(topic)
+	if err := s.ensureConsumer(stream); err != nil {
+		return nil, err
+	}
+
+	output := make(chan *message.Message)
+	var outputClosed bool
+	var outputMu sync.RWMutex
+	var subs []*nats.Subscription
+	unsubscribe := func() {
+		for _, sub := range subs {
+			if err := sub.Unsubscribe(); err != nil {
+				s.logger.Error("Cannot unsubscribe", err, watermill.LogFields{"topic": topic})
+			}
+		}
+	}
+	for i := 0; i < s.config.SubscribersCount; i++ {
+		sub, err := s.js.QueueSubscribe(topic, s.config.QueueGroup, func(natsMsg *nats.Msg) {
+			outputMu.RLock()
+			defer outputMu.RUnlock()
+			if outputClosed {
+				return
+			}
+			s.processMessage(ctx, natsMsg, output)
+		}, nats.Bind(stream, s.config.DurableName), nats.ManualAck())
+		if err != nil {
+			unsubscribe()
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		select {
+		case <-s.closing:
+		case <-ctx.Done():
+		}
+		// the consumer is bound rather than created by the subscriptions, so unsubscribing keeps it on the server
+		unsubscribe()
+		outputMu.Lock()
+		outputClosed = true
+		close(output)
+		outputMu.Unlock()
+	}()
+	return output, nil
+}
+
+// ensureConsumer creates the durable consumer of the stream if it does not exist
+func (s *JetStreamSubscriber) ensureConsumer(stream string) error {
+	_, err := s.js.ConsumerInfo(stream, s.config.DurableName)
+	if errors.Is(err, nats.ErrConsumerNotFound) {
+		_, err = s.js.AddConsumer(stream, &nats.ConsumerConfig{
+			Durable:        s.config.DurableName,
+			DeliverSubject: pkg.Join("_deliver.", stream, ".", s.config.DurableName),
+			DeliverGroup:   s.config.QueueGroup,
+			DeliverPolicy:  nats.DeliverAllPolicy,
+			AckPolicy:      nats.AckExplicitPolicy,
+			MaxDeliver:     s.config.MaxDeliver,
+			AckWait:        s.config.AckWait,
+		})
+		if err != nil {
+			// the consumer may have been created concurrently by another instance
+			_, err = s.js.ConsumerInfo(stream, s.config.DurableName)
+		}
+	}
+	return err
+}
+
+func (s *JetStreamSubscriber) processMessage(ctx context.Context, natsMsg *nats.Msg, output chan *message.Message) {
+	uuid := natsMsg.Header.Get(UUIDHeader)
+	msg := message.NewMessage(uuid, natsMsg.Data)
+	for k := range natsMsg.Header {
+		if k == UUIDHeader {
+			continue
+		}
+		msg.Metadata.Set(k, natsMsg.Header.Get(k))
+	}
+	logFields := watermill.LogFields{"message_uuid": uuid, "subject": natsMsg.Subject}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	msg.SetContext(ctx)
+
+	select {
+	case <-s.closing:
+		return
+	case <-ctx.Done():
+		return
+	case output <- msg:
+	}
+
+	select {
+	case <-msg.Acked():
+		if err := natsMsg.Ack(); err != nil {
+			s.logger.Error("Cannot ack message", err, logFields)
+		}
+	case <-msg.Nacked():
+		if err := natsMsg.Nak(); err != nil {
+			s.logger.Error("Cannot nack message", err, logFields)
+		}
+	case <-s.closing:
+	case <-ctx.Done():
+	}
+}
+
+// Close closes the subscriber and waits for the running handlers
+func (s *JetStreamSubscriber) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.closing)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	s.conn.Close()
+	return nil
+}
@@ -0,0 +1,130 @@
+package broker
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/nats-io/nats-server/v2/server"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("jetstream", func() {
+	var (
+		natsServer *server.Server
+		storeDir   string
+		config     JetStreamConfig
+	)
+	BeforeEach(func() {
+		var err error
+		storeDir, err = ioutil.TempDir("", "jetstream")
+		Expect(err).To(BeNil())
+		natsServer, err = server.NewServer(&server.Options{
+			Host:      "127.0.0.1",
+			Port:      server.RANDOM_PORT,
+			JetStream: true,
+			StoreDir:  storeDir,
+			NoLog:     true,
+			NoSigs:    true,
+		})
+		Expect(err).To(BeNil())
+		go natsServer.Start()
+		Expect(natsServer.ReadyForConnections(5 * time.Second)).To(BeTrue())
+		config = JetStreamConfig{
+			URL:              natsServer.ClientURL(),
+			Name:             "test",
+			QueueGroup:       "test",
+			DurableName:      "test",
+			SubscribersCount: 2,
+			MaxDeliver:       2,
+			AckWait:          time.Second,
+		}
+	})
+	AfterEach(func() {
+		natsServer.Shutdown()
+		natsServer.WaitForShutdown()
+		os.RemoveAll(storeDir)
+	})
+
+	receive := func(messages <-chan *message.Message) *message.Message {
+		select {
+		case msg := <-messages:
+			return msg
+		case <-time.After(5 * time.Second):
+			Fail("timed out waiting for message")
+		}
+		return nil
+	}
+
+	var _ = It("should redeliver nacked messages", func() {
+		publisher, err := NewJetStreamPublisher(config)
+		Expect(err).To(BeNil())
+		defer publisher.Close()
+		subscriber, err := NewJetStreamSubscriber(config, watermill.NopLogger{})
+		Expect(err).To(BeNil())
+		defer subscriber.Close()
+
+		messages, err := subscriber.Subscribe(context.Background(), "test.redeliver")
+		Expect(err).To(BeNil())
+		msg := message.NewMessage(watermill.NewUUID(), []byte("payload"))
+		msg.Metadata.Set("Handler", "test_handler")
+		err = publisher.Publish("test.redeliver", msg)
+		Expect(err).To(BeNil())
+
+		received := receive(messages)
+		Expect(received.UUID).To(Equal(msg.UUID))
+		Expect(string(received.Payload)).To(Equal("payload"))
+		Expect(received.Metadata.Get("Handler")).To(Equal("test_handler"))
+		received.Nack()
+
+		received = receive(messages)
+		Expect(received.UUID).To(Equal(msg.UUID))
+		received.Ack()
+	})
+	var _ = It("should keep messages for the durable consumer while it is offline", func() {
+		publisher, err := NewJetStreamPublisher(config)
+		Expect(err).To(BeNil())
+		defer publisher.Close()
+
+		subscriber, err := NewJetStreamSubscriber(config, watermill.NopLogger{})
+		Expect(err).To(BeNil())
+		_, err = subscriber.Subscribe(context.Background(), "test.durable")
+		Expect(err).To(BeNil())
+		Expect(subscriber.Close()).To(BeNil())
+
+		msg := message.NewMessage(watermill.NewUUID(), []byte("payload"))
+		err = publisher.Publish("test.durable", msg)
+		Expect(err).To(BeNil())
+
+		subscriber, err = NewJetStreamSubscriber(config, watermill.NopLogger{})
+		Expect(err).To(BeNil())
+		defer subscriber.Close()
+		messages, err := subscriber.Subscribe(context.Background(), "test.durable")
+		Expect(err).To(BeNil())
+		received := receive(messages)
+		Expect(received.UUID).To(Equal(msg.UUID))
+		received.Ack()
+	})
+	var _ = It("should stop delivering after max deliver", func() {
+		publisher, err := NewJetStreamPublisher(config)
+		Expect(err).To(BeNil())
+		defer publisher.Close()
+		subscriber, err := NewJetStreamSubscriber(config, watermill.NopLogger{})
+		Expect(err).To(BeNil())
+		defer subscriber.Close()
+
+		messages, err := subscriber.Subscribe(context.Background(), "test.maxdeliver")
+		Expect(err).To(BeNil())
+		err = publisher.Publish("test.maxdeliver", message.NewMessage(watermill.NewUUID(), []byte("payload")))
+		Expect(err).To(BeNil())
+
+		receive(messages).Nack()
+		receive(messages).Nack()
+		Consistently(messages, 2*config.AckWait).ShouldNot(Receive())
+	})
+})
@@ -0,0 +1,12 @@
+package broker
+
+import (
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+// NewInMemoryPubSub returns an in-memory pub/sub, which serves as both the tx bus and the result stream
+// when all services run in one process
+// Messages are not persisted, so a message published to a topic without subscribers is dropped
+func NewInMemoryPubSub() *gochannel.GoChannel {
+	return gochannel.NewGoChannel(gochannel.Config{}, logger)
+}
@@ -0,0 +1,20 @@
+package broker
+
+import (
+	"strconv"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// AttemptsKey is the metadata key of how many times a handler has processed the message
+const AttemptsKey = "attempts"
+
+// countAttempts records the number of handler attempts in the message metadata
+// It should be placed inside the retry middleware so that every retry is counted
+func countAttempts(h message.HandlerFunc) message.HandlerFunc {
+	return func(msg *message.Message) ([]*message.Message, error) {
+		attempts, _ := strconv.Atoi(msg.Metadata.Get(AttemptsKey))
+		msg.Metadata.Set(AttemptsKey, strconv.Itoa(attempts+1))
+		return h(msg)
+	}
+}
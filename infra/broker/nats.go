@@ -2,6 +2,7 @@ package broker
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/ThreeDotsLabs/watermill-nats/pkg/nats"
 	"github.com/ThreeDotsLabs/watermill/components/metrics"
@@ -20,19 +21,17 @@ var (
 )
 
 // NewNATSPublisher returns a NATS publisher for event streaming
+// It publishes to NATS JetStream or NATS Streaming depending on the configured transport
 func NewNATSPublisher(config *conf.Config) (NATSPublisher, error) {
 	var err error
-	TxPublisher, err = nats.NewStreamingPublisher(
-		nats.StreamingPublisherConfig{
-			ClusterID: config.NATSConfig.ClusterID,
-			ClientID:  config.NATSConfig.ClientID + "_publisher",
-			StanOptions: []stan.Option{
-				stan.NatsURL(config.NATSConfig.URL),
-			},
-			Marshaler: nats.GobMarshaler{},
-		},
-		logger,
-	)
+	switch config.NATSConfig.Transport {
+	case conf.NATSTransportJetStream:
+		TxPublisher, err = NewJetStreamPublisher(newJetStreamConfig(config, "_publisher"))
+	case conf.NATSTransportSTAN, "":
+		TxPublisher, err = newSTANPublisher(config)
+	default:
+		err = fmt.Errorf("unknown nats transport: %s", config.NATSConfig.Transport)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -50,23 +49,17 @@ func NewNATSPublisher(config *conf.Config) (NATSPublisher, error) {
 }
 
 // NewNATSSubscriber returns a NATS subscriber for event streaming
+// It subscribes to NATS JetStream or NATS Streaming depending on the configured transport
 func NewNATSSubscriber(config *conf.Config) (NATSSubscriber, error) {
 	var err error
-	TxSubscriber, err = nats.NewStreamingSubscriber(
-		nats.StreamingSubscriberConfig{
-			ClusterID: config.NATSConfig.ClusterID,
-			ClientID:  config.NATSConfig.ClientID + "_subscriber",
-
-			QueueGroup:       config.NATSConfig.Subscriber.QueueGroup,
-			DurableName:      config.NATSConfig.Subscriber.DurableName,
-			SubscribersCount: config.NATSConfig.Subscriber.Count,
-			StanOptions: []stan.Option{
-				stan.NatsURL(config.NATSConfig.URL),
-			},
-			Unmarshaler: nats.GobMarshaler{},
-		},
-		logger,
-	)
+	switch config.NATSConfig.Transport {
+	case conf.NATSTransportJetStream:
+		TxSubscriber, err = NewJetStreamSubscriber(newJetStreamConfig(config, "_subscriber"), logger)
+	case conf.NATSTransportSTAN, "":
+		TxSubscriber, err = newSTANSubscriber(config)
+	default:
+		err = fmt.Errorf("unknown nats transport: %s", config.NATSConfig.Transport)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -82,3 +75,47 @@ func NewNATSSubscriber(config *conf.Config) (NATSSubscriber, error) {
 	}
 	return TxSubscriber, nil
 }
+
+func newJetStreamConfig(config *conf.Config, suffix string) JetStreamConfig {
+	return JetStreamConfig{
+		URL:              config.NATSConfig.URL,
+		Name:             config.NATSConfig.ClientID + suffix,
+		QueueGroup:       config.NATSConfig.Subscriber.QueueGroup,
+		DurableName:      config.NATSConfig.Subscriber.DurableName,
+		SubscribersCount: config.NATSConfig.Subscriber.Count,
+		MaxDeliver:       config.NATSConfig.Subscriber.MaxDeliver,
+		AckWait:          time.Duration(config.NATSConfig.Subscriber.AckWaitSecond) * time.Second,
+	}
+}
+
+func newSTANPublisher(config *conf.Config) (message.Publisher, error) {
+	return nats.NewStreamingPublisher(
+		nats.StreamingPublisherConfig{
+			ClusterID: config.NATSConfig.ClusterID,
+			ClientID:  config.NATSConfig.ClientID + "_publisher",
+			StanOptions: []stan.Option{
+				stan.NatsURL(config.NATSConfig.URL),
+			},
+			Marshaler: nats.GobMarshaler{},
+		},
+		logger,
+	)
+}
+
+func newSTANSubscriber(config *conf.Config) (message.Subscriber, error) {
+	return nats.NewStreamingSubscriber(
+		nats.StreamingSubscriberConfig{
+			ClusterID: config.NATSConfig.ClusterID,
+			ClientID:  config.NATSConfig.ClientID + "_subscriber",
+
+			QueueGroup:       config.NATSConfig.Subscriber.QueueGroup,
+			DurableName:      config.NATSConfig.Subscriber.DurableName,
+			SubscribersCount: config.NATSConfig.Subscriber.Count,
+			StanOptions: []stan.Option{
+				stan.NatsURL(config.NATSConfig.URL),
+			},
+			Unmarshaler: nats.GobMarshaler{},
+		},
+		logger,
+	)
+}
@@ -2,11 +2,13 @@ package orchestrator
 
 import (
 	"context"
+	"encoding/json"
 
 	"github.com/ThreeDotsLabs/watermill/message"
 	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
 	conf "github.com/minghsu0107/saga-product/config"
 	"github.com/minghsu0107/saga-product/infra/broker"
+	saga_pb "github.com/minghsu0107/saga-product/pb"
 	"github.com/minghsu0107/saga-product/service/orchestrator"
 	"go.opentelemetry.io/otel/propagation"
 )
@@ -29,6 +31,19 @@ func (h *OrchestratorHandler) StartTransaction(msg *message.Message) error {
 	return h.svc.StartTransaction(parentCtx, purchase, correlationID)
 }
 
+// CancelPurchase cancels a succeeded purchase
+func (h *OrchestratorHandler) CancelPurchase(msg *message.Message) error {
+	var cmd saga_pb.CancelPurchaseCmd
+	if err := json.Unmarshal(msg.Payload, &cmd); err != nil {
+		return err
+	}
+	correlationID := msg.Metadata.Get(middleware.CorrelationIDMetadataKey)
+	carrier := make(propagation.HeaderCarrier)
+	carrier.Set(broker.TraceparentHeader, msg.Metadata.Get(conf.SpanContextKey))
+	parentCtx := broker.TraceContext.Extract(context.Background(), carrier)
+	return h.svc.CancelPurchase(parentCtx, cmd.CustomerId, cmd.PurchaseId, correlationID)
+}
+
 func (h *OrchestratorHandler) HandleReply(msg *message.Message) error {
 	correlationID := msg.Metadata.Get(middleware.CorrelationIDMetadataKey)
 	carrier := make(propagation.HeaderCarrier)
@@ -45,8 +60,8 @@ type OrchestratorEventRouter struct {
 }
 
 // NewOrchestratorEventRouter factory
-func NewOrchestratorEventRouter(config *conf.Config, orchestratorSvc orchestrator.OrchestratorService, txSubscriber broker.NATSSubscriber) (broker.EventRouter, error) {
-	router, err := broker.InitializeRouter(config.App)
+func NewOrchestratorEventRouter(config *conf.Config, orchestratorSvc orchestrator.OrchestratorService, txSubscriber broker.NATSSubscriber, txPublisher broker.NATSPublisher) (broker.EventRouter, error) {
+	router, err := broker.InitializeRouter(config, txPublisher)
 	if err != nil {
 		return nil, err
 	}
@@ -67,6 +82,12 @@ func (r *OrchestratorEventRouter) RegisterHandlers() {
 		r.txSubscriber,
 		r.orchestratorHandler.StartTransaction,
 	)
+	r.router.AddNoPublisherHandler(
+		"sagaorchestrator_cancel_purchase_handler",
+		conf.CancelPurchaseTopic,
+		r.txSubscriber,
+		r.orchestratorHandler.CancelPurchase,
+	)
 	r.router.AddNoPublisherHandler(
 		"sagaorchestrator_handle_reply_handler",
 		conf.ReplyTopic,
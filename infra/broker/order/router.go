@@ -5,11 +5,11 @@ import (
 	"encoding/json"
 	"time"
 
-	"github.com/ThreeDotsLabs/watermill"
 	"github.com/ThreeDotsLabs/watermill/message"
 	pb "github.com/minghsu0107/saga-pb"
 	conf "github.com/minghsu0107/saga-product/config"
 	"github.com/minghsu0107/saga-product/infra/broker"
+	saga_pb "github.com/minghsu0107/saga-product/pb"
 	"github.com/minghsu0107/saga-product/pkg"
 	"github.com/minghsu0107/saga-product/service/order"
 	"go.opentelemetry.io/otel"
@@ -18,11 +18,12 @@ import (
 
 // SagaOrderHandler handler
 type SagaOrderHandler struct {
-	svc order.SagaOrderService
+	service string
+	svc     order.SagaOrderService
 }
 
 // CreateOrder handler
-func (h *SagaOrderHandler) CreateOrder(msg *message.Message) ([]*message.Message, error) {
+func (h *SagaOrderHandler) CreateOrder(msg *message.Message) error {
 	carrier := make(propagation.HeaderCarrier)
 	carrier.Set(broker.TraceparentHeader, msg.Metadata.Get(conf.SpanContextKey))
 	parentCtx := broker.TraceContext.Extract(context.Background(), carrier)
@@ -32,35 +33,35 @@ func (h *SagaOrderHandler) CreateOrder(msg *message.Message) ([]*message.Message
 
 	purchase, pbPurchase, err := broker.DecodeCreatePurchaseCmd(msg.Payload)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	reply := pb.CreatePurchaseResponse{
+	reply := saga_pb.CreatePurchaseResponse{
 		PurchaseId: purchase.ID,
 		Purchase:   pbPurchase,
+		Success:    true,
+		Error:      "",
+		Timestamp:  pkg.Time2pbTimestamp(time.Now()),
 	}
-	err = h.svc.CreateOrder(context.Background(), purchase.Order)
+	processed, err := broker.NewProcessedMessage(ctx, h.service, msg, reply.PurchaseId, &reply, conf.CreateOrderHandler)
 	if err != nil {
-		reply.Success = false
-		reply.Error = err.Error()
-	} else {
-		reply.Success = true
-		reply.Error = ""
+		return err
+	}
+	err = h.svc.CreateOrder(context.Background(), purchase.Order, processed)
+	if err == nil {
+		return nil
 	}
-	reply.Timestamp = pkg.Time2pbTimestamp(time.Now())
 
-	payload, err := json.Marshal(&reply)
+	reply.Success = false
+	reply.Error = err.Error()
+	reply.Timestamp = pkg.Time2pbTimestamp(time.Now())
+	processed, err = broker.NewProcessedMessage(ctx, h.service, msg, reply.PurchaseId, &reply, conf.CreateOrderHandler)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	var replyMsgs []*message.Message
-	replyMsg := message.NewMessage(watermill.NewUUID(), payload)
-	broker.SetSpanContext(ctx, replyMsg)
-	replyMsg.Metadata.Set(conf.HandlerHeader, conf.CreateOrderHandler)
-	replyMsgs = append(replyMsgs, replyMsg)
-	return replyMsgs, nil
+	return h.svc.RecordReply(context.Background(), processed)
 }
 
-func (h *SagaOrderHandler) RollbackOrder(msg *message.Message) ([]*message.Message, error) {
+func (h *SagaOrderHandler) RollbackOrder(msg *message.Message) error {
 	carrier := make(propagation.HeaderCarrier)
 	carrier.Set(broker.TraceparentHeader, msg.Metadata.Get(conf.SpanContextKey))
 	parentCtx := broker.TraceContext.Extract(context.Background(), carrier)
@@ -70,33 +71,55 @@ func (h *SagaOrderHandler) RollbackOrder(msg *message.Message) ([]*message.Messa
 
 	var cmd pb.RollbackCmd
 	if err := json.Unmarshal(msg.Payload, &cmd); err != nil {
-		return nil, err
+		return err
 	}
 
 	reply := pb.RollbackResponse{
 		CustomerId: cmd.CustomerId,
 		PurchaseId: cmd.PurchaseId,
+		Success:    true,
+		Error:      "",
+		Timestamp:  pkg.Time2pbTimestamp(time.Now()),
 	}
-	err := h.svc.RollbackOrder(context.Background(), cmd.PurchaseId)
+	processed, err := broker.NewProcessedMessage(ctx, h.service, msg, reply.PurchaseId, &reply, conf.RollbackOrderHandler)
 	if err != nil {
-		reply.Success = false
-		reply.Error = err.Error()
-	} else {
-		reply.Success = true
-		reply.Error = ""
+		return err
+	}
+	err = h.svc.RollbackOrder(context.Background(), cmd.PurchaseId, processed)
+	if err == nil {
+		return nil
 	}
-	reply.Timestamp = pkg.Time2pbTimestamp(time.Now())
 
-	payload, err := json.Marshal(&reply)
+	reply.Success = false
+	reply.Error = err.Error()
+	reply.Timestamp = pkg.Time2pbTimestamp(time.Now())
+	processed, err = broker.NewProcessedMessage(ctx, h.service, msg, reply.PurchaseId, &reply, conf.RollbackOrderHandler)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	return h.svc.RecordReply(context.Background(), processed)
+}
+
+// ConfirmOrder handler
+// The command has no reply; confirming an order again does nothing
+// An order cancelled before the confirmation stays cancelled, and the confirmation is dropped after the service logs it as an error
+func (h *SagaOrderHandler) ConfirmOrder(msg *message.Message) error {
+	carrier := make(propagation.HeaderCarrier)
+	carrier.Set(broker.TraceparentHeader, msg.Metadata.Get(conf.SpanContextKey))
+	parentCtx := broker.TraceContext.Extract(context.Background(), carrier)
+	tr := otel.Tracer("confirmOrder")
+	ctx, span := tr.Start(parentCtx, "event.ConfirmOrder")
+	defer span.End()
+
+	var cmd saga_pb.ConfirmCmd
+	if err := json.Unmarshal(msg.Payload, &cmd); err != nil {
+		return err
+	}
+	err := h.svc.ConfirmOrder(ctx, cmd.PurchaseId)
+	if err != nil && err != order.ErrInvalidOrderTransition {
+		return err
 	}
-	var replyMsgs []*message.Message
-	replyMsg := message.NewMessage(watermill.NewUUID(), payload)
-	broker.SetSpanContext(ctx, replyMsg)
-	replyMsg.Metadata.Set(conf.HandlerHeader, conf.RollbackOrderHandler)
-	replyMsgs = append(replyMsgs, replyMsg)
-	return replyMsgs, nil
+	return nil
 }
 
 // OrderEventRouter implementation
@@ -104,43 +127,44 @@ type OrderEventRouter struct {
 	router           *message.Router
 	sagaOrderHandler *SagaOrderHandler
 	txSubscriber     broker.NATSSubscriber
-	txPublisher      broker.NATSPublisher
 }
 
 // NewOrderEventRouter factory
 func NewOrderEventRouter(config *conf.Config, sagaOrderSvc order.SagaOrderService, txSubscriber broker.NATSSubscriber, txPublisher broker.NATSPublisher) (broker.EventRouter, error) {
-	router, err := broker.InitializeRouter(config.App)
+	router, err := broker.InitializeRouter(config, txPublisher)
 	if err != nil {
 		return nil, err
 	}
 	sagaOrderHandler := SagaOrderHandler{
-		svc: sagaOrderSvc,
+		service: config.App,
+		svc:     sagaOrderSvc,
 	}
 	return &OrderEventRouter{
 		router:           router,
 		sagaOrderHandler: &sagaOrderHandler,
 		txSubscriber:     txSubscriber,
-		txPublisher:      txPublisher,
 	}, nil
 }
 
 func (r *OrderEventRouter) RegisterHandlers() {
-	r.router.AddHandler(
+	r.router.AddNoPublisherHandler(
 		"sagaorder_create_order_handler",
 		conf.CreateOrderTopic,
 		r.txSubscriber,
-		conf.ReplyTopic,
-		r.txPublisher,
 		r.sagaOrderHandler.CreateOrder,
 	)
-	r.router.AddHandler(
+	r.router.AddNoPublisherHandler(
 		"sagaorder_rollback_order_handler",
 		conf.RollbackOrderTopic,
 		r.txSubscriber,
-		conf.ReplyTopic,
-		r.txPublisher,
 		r.sagaOrderHandler.RollbackOrder,
 	)
+	r.router.AddNoPublisherHandler(
+		"sagaorder_confirm_order_handler",
+		conf.ConfirmOrderTopic,
+		r.txSubscriber,
+		r.sagaOrderHandler.ConfirmOrder,
+	)
 }
 
 func (r *OrderEventRouter) Run() error {
@@ -0,0 +1,112 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+	conf "github.com/minghsu0107/saga-product/config"
+	"github.com/minghsu0107/saga-product/domain/model"
+	"github.com/minghsu0107/saga-product/repo"
+)
+
+// NewOutboxMessage converts a message into an outbox message of the service
+func NewOutboxMessage(service, transport, topic string, msg *message.Message) *model.OutboxMessage {
+	metadata := make(map[string]string)
+	for k, v := range msg.Metadata {
+		metadata[k] = v
+	}
+	return &model.OutboxMessage{
+		Service:   service,
+		Transport: transport,
+		Topic:     topic,
+		UUID:      msg.UUID,
+		Payload:   msg.Payload,
+		Metadata:  metadata,
+	}
+}
+
+// NewProcessedMessage encodes the reply to a command of a purchase as an outbox message of the service
+// The reply inherits the correlation ID of the command and carries the span context of ctx
+func NewProcessedMessage(ctx context.Context, service string, cmd *message.Message, purchaseID uint64, reply interface{}, handler string) (*model.ProcessedMessage, error) {
+	payload, err := json.Marshal(reply)
+	if err != nil {
+		return nil, err
+	}
+	replyMsg := message.NewMessage(watermill.NewUUID(), payload)
+	SetSpanContext(ctx, replyMsg)
+	middleware.SetCorrelationID(middleware.MessageCorrelationID(cmd), replyMsg)
+	replyMsg.Metadata.Set(conf.HandlerHeader, handler)
+	return &model.ProcessedMessage{
+		UUID:       cmd.UUID,
+		PurchaseID: purchaseID,
+		Reply:      NewOutboxMessage(service, model.OutboxTransportTx, conf.ReplyTopic, replyMsg),
+	}, nil
+}
+
+// OutboxRelay publishes the outbox messages of a service and deletes them once they are published
+// A message may be published more than once if the relay fails before deleting it, so consumers should be idempotent
+type OutboxRelay struct {
+	service    string
+	outboxRepo repo.OutboxRepository
+	publishers map[string]message.Publisher
+	batchSize  int
+}
+
+// NewOutboxRelay is the factory of OutboxRelay for services that only publish to the transactional bus
+func NewOutboxRelay(config *conf.Config, outboxRepo repo.OutboxRepository, txPublisher NATSPublisher) *OutboxRelay {
+	return newOutboxRelay(config, outboxRepo, map[string]message.Publisher{
+		model.OutboxTransportTx: txPublisher,
+	})
+}
+
+// NewOrchestratorOutboxRelay is the factory of OutboxRelay for the orchestrator, which also publishes purchase results
+func NewOrchestratorOutboxRelay(config *conf.Config, outboxRepo repo.OutboxRepository, txPublisher NATSPublisher, resultPublisher RedisPublisher) *OutboxRelay {
+	return newOutboxRelay(config, outboxRepo, map[string]message.Publisher{
+		model.OutboxTransportTx:     txPublisher,
+		model.OutboxTransportResult: resultPublisher,
+	})
+}
+
+func newOutboxRelay(config *conf.Config, outboxRepo repo.OutboxRepository, publishers map[string]message.Publisher) *OutboxRelay {
+	batchSize := config.OutboxConfig.BatchSize
+	if batchSize < 1 {
+		batchSize = 100
+	}
+	return &OutboxRelay{
+		service:    config.App,
+		outboxRepo: outboxRepo,
+		publishers: publishers,
+		batchSize:  batchSize,
+	}
+}
+
+// Relay publishes outbox messages in the order they were recorded until the outbox is drained
+// It stops at the first message that fails to be published so that the order of messages is kept;
+// messages claimed by the relay of another replica are left to it
+func (r *OutboxRelay) Relay(ctx context.Context) error {
+	for {
+		claimed, err := r.outboxRepo.RelayOutboxMessages(ctx, r.service, r.batchSize, r.publish)
+		if err != nil {
+			return err
+		}
+		if claimed < r.batchSize || ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+func (r *OutboxRelay) publish(outboxMsg *model.OutboxMessage) error {
+	publisher, ok := r.publishers[outboxMsg.Transport]
+	if !ok {
+		return fmt.Errorf("unkown outbox transport: %s", outboxMsg.Transport)
+	}
+	msg := message.NewMessage(outboxMsg.UUID, outboxMsg.Payload)
+	for k, v := range outboxMsg.Metadata {
+		msg.Metadata.Set(k, v)
+	}
+	return publisher.Publish(outboxMsg.Topic, msg)
+}
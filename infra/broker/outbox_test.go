@@ -0,0 +1,103 @@
+package broker
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+	conf "github.com/minghsu0107/saga-product/config"
+	"github.com/minghsu0107/saga-product/domain/model"
+	"github.com/minghsu0107/saga-product/repo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakePublisher struct {
+	published []*message.Message
+	failAt    int
+}
+
+func (p *fakePublisher) Publish(topic string, messages ...*message.Message) error {
+	for _, msg := range messages {
+		if len(p.published) == p.failAt {
+			return errors.New("broker unavailable")
+		}
+		p.published = append(p.published, msg)
+	}
+	return nil
+}
+
+func (p *fakePublisher) Close() error {
+	return nil
+}
+
+var _ = Describe("outbox relay", func() {
+	var (
+		config = &conf.Config{
+			App: "test",
+			OutboxConfig: &conf.OutboxConfig{
+				BatchSize: 2,
+			},
+		}
+		outboxRepo *repo.InMemorySagaRepository
+		publisher  *fakePublisher
+		relay      *OutboxRelay
+	)
+	BeforeEach(func() {
+		outboxRepo = repo.NewInMemorySagaRepository()
+		publisher = &fakePublisher{
+			failAt: -1,
+		}
+		relay = NewOutboxRelay(config, outboxRepo, publisher)
+	})
+
+	newMessages := func(n int) []*model.OutboxMessage {
+		var messages []*model.OutboxMessage
+		for i := 0; i < n; i++ {
+			msg := message.NewMessage(watermill.NewUUID(), []byte("payload"))
+			middleware.SetCorrelationID("correlation", msg)
+			messages = append(messages, NewOutboxMessage(config.App, model.OutboxTransportTx, "test.topic", msg))
+		}
+		return messages
+	}
+
+	var _ = It("should relay all messages in order and delete them", func() {
+		messages := newMessages(5)
+		Expect(outboxRepo.CreateOutboxMessages(context.Background(), messages...)).To(BeNil())
+		Expect(outboxRepo.CreateOutboxMessages(context.Background(), NewOutboxMessage("other", model.OutboxTransportTx, "test.topic", message.NewMessage(watermill.NewUUID(), nil)))).To(BeNil())
+
+		Expect(relay.Relay(context.Background())).To(BeNil())
+		Expect(len(publisher.published)).To(Equal(5))
+		for i, msg := range publisher.published {
+			Expect(msg.UUID).To(Equal(messages[i].UUID))
+			Expect(middleware.MessageCorrelationID(msg)).To(Equal("correlation"))
+		}
+		remaining, err := outboxRepo.ListOutboxMessages(context.Background(), config.App, 10)
+		Expect(err).To(BeNil())
+		Expect(len(*remaining)).To(Equal(0))
+	})
+	var _ = It("should keep unpublished messages for the next relay", func() {
+		messages := newMessages(3)
+		Expect(outboxRepo.CreateOutboxMessages(context.Background(), messages...)).To(BeNil())
+
+		publisher.failAt = 1
+		Expect(relay.Relay(context.Background())).NotTo(BeNil())
+		remaining, err := outboxRepo.ListOutboxMessages(context.Background(), config.App, 10)
+		Expect(err).To(BeNil())
+		Expect(len(*remaining)).To(Equal(2))
+		Expect((*remaining)[0].UUID).To(Equal(messages[1].UUID))
+
+		publisher.failAt = -1
+		Expect(relay.Relay(context.Background())).To(BeNil())
+		Expect(len(publisher.published)).To(Equal(3))
+		Expect(publisher.published[2].UUID).To(Equal(messages[2].UUID))
+	})
+	var _ = It("should not relay messages of unknown transports", func() {
+		Expect(outboxRepo.CreateOutboxMessages(context.Background(), NewOutboxMessage(config.App, model.OutboxTransportResult, "test.topic", message.NewMessage(watermill.NewUUID(), nil)))).To(BeNil())
+		Expect(relay.Relay(context.Background())).NotTo(BeNil())
+		Expect(len(publisher.published)).To(Equal(0))
+	})
+})
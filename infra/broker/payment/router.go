@@ -5,11 +5,11 @@ import (
 	"encoding/json"
 	"time"
 
-	"github.com/ThreeDotsLabs/watermill"
 	"github.com/ThreeDotsLabs/watermill/message"
 	pb "github.com/minghsu0107/saga-pb"
 	conf "github.com/minghsu0107/saga-product/config"
 	"github.com/minghsu0107/saga-product/infra/broker"
+	saga_pb "github.com/minghsu0107/saga-product/pb"
 	"github.com/minghsu0107/saga-product/pkg"
 	"github.com/minghsu0107/saga-product/service/payment"
 	"go.opentelemetry.io/otel"
@@ -18,11 +18,12 @@ import (
 
 // SagaPaymentHandler handler
 type SagaPaymentHandler struct {
-	svc payment.SagaPaymentService
+	service string
+	svc     payment.SagaPaymentService
 }
 
 // CreatePayment handler
-func (h *SagaPaymentHandler) CreatePayment(msg *message.Message) ([]*message.Message, error) {
+func (h *SagaPaymentHandler) CreatePayment(msg *message.Message) error {
 	carrier := make(propagation.HeaderCarrier)
 	carrier.Set(broker.TraceparentHeader, msg.Metadata.Get(conf.SpanContextKey))
 	parentCtx := broker.TraceContext.Extract(context.Background(), carrier)
@@ -32,35 +33,35 @@ func (h *SagaPaymentHandler) CreatePayment(msg *message.Message) ([]*message.Mes
 
 	purchase, pbPurchase, err := broker.DecodeCreatePurchaseCmd(msg.Payload)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	reply := pb.CreatePurchaseResponse{
+	reply := saga_pb.CreatePurchaseResponse{
 		PurchaseId: purchase.ID,
 		Purchase:   pbPurchase,
+		Success:    true,
+		Error:      "",
+		Timestamp:  pkg.Time2pbTimestamp(time.Now()),
 	}
-	err = h.svc.CreatePayment(context.Background(), purchase.Payment)
+	processed, err := broker.NewProcessedMessage(ctx, h.service, msg, reply.PurchaseId, &reply, conf.CreatePaymentHandler)
 	if err != nil {
-		reply.Success = false
-		reply.Error = err.Error()
-	} else {
-		reply.Success = true
-		reply.Error = ""
+		return err
+	}
+	err = h.svc.CreatePayment(context.Background(), purchase.Payment, processed)
+	if err == nil {
+		return nil
 	}
-	reply.Timestamp = pkg.Time2pbTimestamp(time.Now())
 
-	payload, err := json.Marshal(&reply)
+	reply.Success = false
+	reply.Error = err.Error()
+	reply.Timestamp = pkg.Time2pbTimestamp(time.Now())
+	processed, err = broker.NewProcessedMessage(ctx, h.service, msg, reply.PurchaseId, &reply, conf.CreatePaymentHandler)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	var replyMsgs []*message.Message
-	replyMsg := message.NewMessage(watermill.NewUUID(), payload)
-	broker.SetSpanContext(ctx, replyMsg)
-	replyMsg.Metadata.Set(conf.HandlerHeader, conf.CreatePaymentHandler)
-	replyMsgs = append(replyMsgs, replyMsg)
-	return replyMsgs, nil
+	return h.svc.RecordReply(context.Background(), processed)
 }
 
-func (h *SagaPaymentHandler) RollbackPayment(msg *message.Message) ([]*message.Message, error) {
+func (h *SagaPaymentHandler) RollbackPayment(msg *message.Message) error {
 	carrier := make(propagation.HeaderCarrier)
 	carrier.Set(broker.TraceparentHeader, msg.Metadata.Get(conf.SpanContextKey))
 	parentCtx := broker.TraceContext.Extract(context.Background(), carrier)
@@ -70,33 +71,33 @@ func (h *SagaPaymentHandler) RollbackPayment(msg *message.Message) ([]*message.M
 
 	var cmd pb.RollbackCmd
 	if err := json.Unmarshal(msg.Payload, &cmd); err != nil {
-		return nil, err
+		return err
 	}
 
 	reply := pb.RollbackResponse{
 		CustomerId: cmd.CustomerId,
 		PurchaseId: cmd.PurchaseId,
+		Success:    true,
+		Error:      "",
+		Timestamp:  pkg.Time2pbTimestamp(time.Now()),
 	}
-	err := h.svc.RollbackPayment(context.Background(), cmd.PurchaseId)
+	processed, err := broker.NewProcessedMessage(ctx, h.service, msg, reply.PurchaseId, &reply, conf.RollbackPaymentHandler)
 	if err != nil {
-		reply.Success = false
-		reply.Error = err.Error()
-	} else {
-		reply.Success = true
-		reply.Error = ""
+		return err
+	}
+	err = h.svc.RollbackPayment(context.Background(), cmd.PurchaseId, processed)
+	if err == nil {
+		return nil
 	}
-	reply.Timestamp = pkg.Time2pbTimestamp(time.Now())
 
-	payload, err := json.Marshal(&reply)
+	reply.Success = false
+	reply.Error = err.Error()
+	reply.Timestamp = pkg.Time2pbTimestamp(time.Now())
+	processed, err = broker.NewProcessedMessage(ctx, h.service, msg, reply.PurchaseId, &reply, conf.RollbackPaymentHandler)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	var replyMsgs []*message.Message
-	replyMsg := message.NewMessage(watermill.NewUUID(), payload)
-	broker.SetSpanContext(ctx, replyMsg)
-	replyMsg.Metadata.Set(conf.HandlerHeader, conf.RollbackPaymentHandler)
-	replyMsgs = append(replyMsgs, replyMsg)
-	return replyMsgs, nil
+	return h.svc.RecordReply(context.Background(), processed)
 }
 
 // PaymentEventRouter implementation
@@ -104,41 +105,36 @@ type PaymentEventRouter struct {
 	router             *message.Router
 	sagaPaymentHandler *SagaPaymentHandler
 	txSubscriber       broker.NATSSubscriber
-	txPublisher        broker.NATSPublisher
 }
 
 // NewPaymentEventRouter factory
 func NewPaymentEventRouter(config *conf.Config, sagaPaymentSvc payment.SagaPaymentService, txSubscriber broker.NATSSubscriber, txPublisher broker.NATSPublisher) (broker.EventRouter, error) {
-	router, err := broker.InitializeRouter(config.App)
+	router, err := broker.InitializeRouter(config, txPublisher)
 	if err != nil {
 		return nil, err
 	}
 	sagaPaymentHandler := SagaPaymentHandler{
-		svc: sagaPaymentSvc,
+		service: config.App,
+		svc:     sagaPaymentSvc,
 	}
 	return &PaymentEventRouter{
 		router:             router,
 		sagaPaymentHandler: &sagaPaymentHandler,
 		txSubscriber:       txSubscriber,
-		txPublisher:        txPublisher,
 	}, nil
 }
 
 func (r *PaymentEventRouter) RegisterHandlers() {
-	r.router.AddHandler(
+	r.router.AddNoPublisherHandler(
 		"sagapayment_create_payment_handler",
 		conf.CreatePaymentTopic,
 		r.txSubscriber,
-		conf.ReplyTopic,
-		r.txPublisher,
 		r.sagaPaymentHandler.CreatePayment,
 	)
-	r.router.AddHandler(
+	r.router.AddNoPublisherHandler(
 		"sagapayment_rollback_payment_handler",
 		conf.RollbackPaymentTopic,
 		r.txSubscriber,
-		conf.ReplyTopic,
-		r.txPublisher,
 		r.sagaPaymentHandler.RollbackPayment,
 	)
 }
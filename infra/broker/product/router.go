@@ -5,10 +5,10 @@ import (
 	"encoding/json"
 	"time"
 
-	"github.com/ThreeDotsLabs/watermill"
 	"github.com/ThreeDotsLabs/watermill/message"
 	pb "github.com/minghsu0107/saga-pb"
 	conf "github.com/minghsu0107/saga-product/config"
+	saga_pb "github.com/minghsu0107/saga-product/pb"
 
 	"github.com/minghsu0107/saga-product/infra/broker"
 	"github.com/minghsu0107/saga-product/pkg"
@@ -19,11 +19,13 @@ import (
 
 // SagaProductHandler handler
 type SagaProductHandler struct {
-	svc product.SagaProductService
+	service string
+	svc     product.SagaProductService
 }
 
 // UpdateProductInventory handler
-func (h *SagaProductHandler) UpdateProductInventory(msg *message.Message) ([]*message.Message, error) {
+// The reply is recorded to the outbox together with the inventory change and relayed to the orchestrator afterwards
+func (h *SagaProductHandler) UpdateProductInventory(msg *message.Message) error {
 	carrier := make(propagation.HeaderCarrier)
 	carrier.Set(broker.TraceparentHeader, msg.Metadata.Get(conf.SpanContextKey))
 	parentCtx := broker.TraceContext.Extract(context.Background(), carrier)
@@ -33,36 +35,37 @@ func (h *SagaProductHandler) UpdateProductInventory(msg *message.Message) ([]*me
 
 	purchase, pbPurchase, err := broker.DecodeCreatePurchaseCmd(msg.Payload)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	reply := pb.CreatePurchaseResponse{
+	reply := saga_pb.CreatePurchaseResponse{
 		PurchaseId: purchase.ID,
 		Purchase:   pbPurchase,
+		Success:    true,
+		Error:      "",
+		Timestamp:  pkg.Time2pbTimestamp(time.Now()),
 	}
-	err = h.svc.UpdateProductInventory(context.Background(), purchase.ID, purchase.Order.PurchasedItems)
+	processed, err := broker.NewProcessedMessage(ctx, h.service, msg, reply.PurchaseId, &reply, conf.UpdateProductInventoryHandler)
 	if err != nil {
-		reply.Success = false
-		reply.Error = err.Error()
-	} else {
-		reply.Success = true
-		reply.Error = ""
+		return err
+	}
+	err = h.svc.UpdateProductInventory(ctx, purchase.ID, purchase.Order.PurchasedItems, processed)
+	if err == nil {
+		return nil
 	}
-	reply.Timestamp = pkg.Time2pbTimestamp(time.Now())
 
-	payload, err := json.Marshal(&reply)
+	reply.Success = false
+	reply.Error = err.Error()
+	reply.Timestamp = pkg.Time2pbTimestamp(time.Now())
+	processed, err = broker.NewProcessedMessage(ctx, h.service, msg, reply.PurchaseId, &reply, conf.UpdateProductInventoryHandler)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	var replyMsgs []*message.Message
-	replyMsg := message.NewMessage(watermill.NewUUID(), payload)
-	broker.SetSpanContext(ctx, replyMsg)
-	replyMsg.Metadata.Set(conf.HandlerHeader, conf.UpdateProductInventoryHandler)
-	replyMsgs = append(replyMsgs, replyMsg)
-	return replyMsgs, nil
+	return h.svc.RecordReply(ctx, processed)
 }
 
 // RollbackProductInventory handler
-func (h *SagaProductHandler) RollbackProductInventory(msg *message.Message) ([]*message.Message, error) {
+// The reply is recorded to the outbox together with the inventory change and relayed to the orchestrator afterwards
+func (h *SagaProductHandler) RollbackProductInventory(msg *message.Message) error {
 	carrier := make(propagation.HeaderCarrier)
 	carrier.Set(broker.TraceparentHeader, msg.Metadata.Get(conf.SpanContextKey))
 	parentCtx := broker.TraceContext.Extract(context.Background(), carrier)
@@ -72,33 +75,65 @@ func (h *SagaProductHandler) RollbackProductInventory(msg *message.Message) ([]*
 
 	var cmd pb.RollbackCmd
 	if err := json.Unmarshal(msg.Payload, &cmd); err != nil {
-		return nil, err
+		return err
 	}
 
 	reply := pb.RollbackResponse{
 		CustomerId: cmd.CustomerId,
 		PurchaseId: cmd.PurchaseId,
+		Success:    true,
+		Error:      "",
+		Timestamp:  pkg.Time2pbTimestamp(time.Now()),
 	}
-	err := h.svc.RollbackProductInventory(context.Background(), cmd.PurchaseId)
+	processed, err := broker.NewProcessedMessage(ctx, h.service, msg, reply.PurchaseId, &reply, conf.RollbackProductInventoryHandler)
 	if err != nil {
-		reply.Success = false
-		reply.Error = err.Error()
-	} else {
-		reply.Success = true
-		reply.Error = ""
+		return err
 	}
+	err = h.svc.RollbackProductInventory(ctx, cmd.PurchaseId, processed)
+	if err == nil {
+		return nil
+	}
+
+	reply.Success = false
+	reply.Error = err.Error()
 	reply.Timestamp = pkg.Time2pbTimestamp(time.Now())
+	processed, err = broker.NewProcessedMessage(ctx, h.service, msg, reply.PurchaseId, &reply, conf.RollbackProductInventoryHandler)
+	if err != nil {
+		return err
+	}
+	return h.svc.RecordReply(ctx, processed)
+}
+
+// ConfirmProductInventory handler
+// A confirmed command has no reply; a hold released on expiry is reserved again, and a hold rollbacked by the saga is left released
+// An oversold purchase is replied with a failed confirmation, which is recorded to the outbox and makes the orchestrator cancel the purchase
+func (h *SagaProductHandler) ConfirmProductInventory(msg *message.Message) error {
+	carrier := make(propagation.HeaderCarrier)
+	carrier.Set(broker.TraceparentHeader, msg.Metadata.Get(conf.SpanContextKey))
+	parentCtx := broker.TraceContext.Extract(context.Background(), carrier)
+	tr := otel.Tracer("confirmProductInventory")
+	ctx, span := tr.Start(parentCtx, "event.ConfirmProductInventory")
+	defer span.End()
 
-	payload, err := json.Marshal(&reply)
+	var cmd saga_pb.ConfirmCmd
+	if err := json.Unmarshal(msg.Payload, &cmd); err != nil {
+		return err
+	}
+	reply := saga_pb.ConfirmResponse{
+		PurchaseId: cmd.PurchaseId,
+		Success:    false,
+		Error:      product.ErrInsuffientInventory.Error(),
+		Timestamp:  pkg.Time2pbTimestamp(time.Now()),
+	}
+	processed, err := broker.NewProcessedMessage(ctx, h.service, msg, reply.PurchaseId, &reply, conf.ConfirmProductInventoryHandler)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	var replyMsgs []*message.Message
-	replyMsg := message.NewMessage(watermill.NewUUID(), payload)
-	broker.SetSpanContext(ctx, replyMsg)
-	replyMsg.Metadata.Set(conf.HandlerHeader, conf.RollbackProductInventoryHandler)
-	replyMsgs = append(replyMsgs, replyMsg)
-	return replyMsgs, nil
+	err = h.svc.ConfirmProductInventory(ctx, cmd.PurchaseId, processed)
+	if err != nil && err != product.ErrReservationReleased && err != product.ErrInsuffientInventory {
+		return err
+	}
+	return nil
 }
 
 // ProductEventRouter implementation
@@ -106,43 +141,44 @@ type ProductEventRouter struct {
 	router             *message.Router
 	sagaProductHandler *SagaProductHandler
 	txSubscriber       broker.NATSSubscriber
-	txPublisher        broker.NATSPublisher
 }
 
 // NewProductEventRouter factory
 func NewProductEventRouter(config *conf.Config, sagaProductSvc product.SagaProductService, txSubscriber broker.NATSSubscriber, txPublisher broker.NATSPublisher) (broker.EventRouter, error) {
-	router, err := broker.InitializeRouter(config.App)
+	router, err := broker.InitializeRouter(config, txPublisher)
 	if err != nil {
 		return nil, err
 	}
 	sagaProductHandler := SagaProductHandler{
-		svc: sagaProductSvc,
+		service: config.App,
+		svc:     sagaProductSvc,
 	}
 	return &ProductEventRouter{
 		router:             router,
 		sagaProductHandler: &sagaProductHandler,
 		txSubscriber:       txSubscriber,
-		txPublisher:        txPublisher,
 	}, nil
 }
 
 func (r *ProductEventRouter) RegisterHandlers() {
-	r.router.AddHandler(
+	r.router.AddNoPublisherHandler(
 		"sagaproduct_update_product_inventory_handler",
 		conf.UpdateProductInventoryTopic,
 		r.txSubscriber,
-		conf.ReplyTopic,
-		r.txPublisher,
 		r.sagaProductHandler.UpdateProductInventory,
 	)
-	r.router.AddHandler(
+	r.router.AddNoPublisherHandler(
 		"sagaproduct_rollback_product_inventory_handler",
 		conf.RollbackProductInventoryTopic,
 		r.txSubscriber,
-		conf.ReplyTopic,
-		r.txPublisher,
 		r.sagaProductHandler.RollbackProductInventory,
 	)
+	r.router.AddNoPublisherHandler(
+		"sagaproduct_confirm_product_inventory_handler",
+		conf.ConfirmProductInventoryTopic,
+		r.txSubscriber,
+		r.sagaProductHandler.ConfirmProductInventory,
+	)
 }
 
 func (r *ProductEventRouter) Run() error {
@@ -0,0 +1,68 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+	conf "github.com/minghsu0107/saga-product/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestBroker(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "broker suite")
+}
+
+var _ = Describe("router", func() {
+	var _ = It("should move a failing message to the poison queue after retries", func() {
+		config := &conf.Config{
+			App: "test",
+			RouterConfig: &conf.RouterConfig{
+				MaxRetries:           2,
+				InitialIntervalMilli: 1,
+				MaxIntervalMilli:     10,
+				Multiplier:           2,
+				PoisonQueueTopic:     "test.poison",
+			},
+		}
+		pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+		defer pubSub.Close()
+		router, err := InitializeRouter(config, pubSub)
+		Expect(err).To(BeNil())
+
+		var attempts int
+		router.AddNoPublisherHandler("failing_handler", "test.topic", pubSub, func(msg *message.Message) error {
+			attempts++
+			return errors.New("malformed payload")
+		})
+		poisoned, err := pubSub.Subscribe(context.Background(), config.RouterConfig.PoisonQueueTopic)
+		Expect(err).To(BeNil())
+		go router.Run(context.Background())
+		defer router.Close()
+		<-router.Running()
+
+		err = pubSub.Publish("test.topic", message.NewMessage(watermill.NewUUID(), []byte("{")))
+		Expect(err).To(BeNil())
+
+		select {
+		case msg := <-poisoned:
+			msg.Ack()
+			Expect(string(msg.Payload)).To(Equal("{"))
+			Expect(msg.Metadata.Get(middleware.PoisonedHandlerKey)).To(Equal("failing_handler"))
+			Expect(msg.Metadata.Get(middleware.PoisonedTopicKey)).To(Equal("test.topic"))
+			Expect(msg.Metadata.Get(middleware.ReasonForPoisonedKey)).To(Equal("malformed payload"))
+			Expect(msg.Metadata.Get(AttemptsKey)).To(Equal("3"))
+			Expect(attempts).To(Equal(3))
+		case <-time.After(5 * time.Second):
+			Fail("timed out waiting for the poisoned message")
+		}
+	})
+})
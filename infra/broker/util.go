@@ -4,11 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/ThreeDotsLabs/watermill/message"
-	pb "github.com/minghsu0107/saga-pb"
 	conf "github.com/minghsu0107/saga-product/config"
 	"github.com/minghsu0107/saga-product/domain/model"
+	saga_pb "github.com/minghsu0107/saga-product/pb"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -19,8 +20,9 @@ var (
 	W3CSupportedVersion = 0
 )
 
-func DecodeCreatePurchaseCmd(payload message.Payload) (*model.Purchase, *pb.Purchase, error) {
-	var cmd pb.CreatePurchaseCmd
+// DecodeCreatePurchaseCmd decodes a purchase command, whose purchased items may refer to product variants
+func DecodeCreatePurchaseCmd(payload message.Payload) (*model.Purchase, *saga_pb.Purchase, error) {
+	var cmd saga_pb.CreatePurchaseCmd
 	if err := json.Unmarshal(payload, &cmd); err != nil {
 		return nil, nil, err
 	}
@@ -32,15 +34,26 @@ func DecodeCreatePurchaseCmd(payload message.Payload) (*model.Purchase, *pb.Purc
 	for _, pbPurchasedItem := range pbPurchasedItems {
 		purchasedItems = append(purchasedItems, model.PurchasedItem{
 			ProductID: pbPurchasedItem.ProductId,
+			VariantID: pbPurchasedItem.VariantId,
 			Amount:    pbPurchasedItem.Amount,
+			Price:     pbPurchasedItem.Price,
 		})
 	}
+	// a purchase from saga-pb is placed when it is published
+	var placedAt time.Time
+	if cmd.Purchase.Order.PlacedAt != nil {
+		placedAt = cmd.Purchase.Order.PlacedAt.AsTime()
+	} else if cmd.Timestamp != nil {
+		placedAt = cmd.Timestamp.AsTime()
+	}
 	return &model.Purchase{
 		ID: purchaseID,
 		Order: &model.Order{
 			ID:             purchaseID,
 			CustomerID:     cmd.Purchase.Order.CustomerId,
+			CurrencyCode:   cmd.Purchase.Payment.CurrencyCode,
 			PurchasedItems: &purchasedItems,
+			PlacedAt:       placedAt,
 		},
 		Payment: &model.Payment{
 			ID:           purchaseID,
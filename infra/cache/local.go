@@ -60,9 +60,9 @@ func (lc *LocalCacheImpl) Set(key string, val interface{}) error {
 	return nil
 }
 
-// Delete deletes a key
+// Delete deletes a key; deleting a key that does not exist is not an error
 func (lc *LocalCacheImpl) Delete(key string) error {
-	if err := lc.cache.Delete(key); err != nil {
+	if err := lc.cache.Delete(key); err != nil && err != bigcache.ErrEntryNotFound {
 		return err
 	}
 	return nil
@@ -20,12 +20,13 @@ var (
 	//ErrRedisUnlockFail is redis unlock fail error
 	ErrRedisUnlockFail = errors.New("redis unlock fail")
 	// ErrRedisCmdNotFound is redis command not found error
-	ErrRedisCmdNotFound = errors.New("redis command not found; supports only SET and DELETE")
+	ErrRedisCmdNotFound = errors.New("redis command not found; supports only SET, DELETE, INCRBYX, BFADD and CFADD")
 )
 
 // RedisCache is the interface of redis cache
 type RedisCache interface {
 	Get(ctx context.Context, key string, dst interface{}) (bool, error)
+	MGet(ctx context.Context, keys []string, dsts []interface{}) ([]bool, error)
 	Exist(ctx context.Context, key string) (bool, error)
 	Set(ctx context.Context, key string, val interface{}) error
 	BFReserve(ctx context.Context, key string, errorRate float64, capacity int64) error
@@ -60,6 +61,10 @@ const (
 	DELETE
 	// INCRBYX represents incrBy if exists operation
 	INCRBYX
+	// BFADD represents bloom filter add operation
+	BFADD
+	// CFADD represents cuckoo filter add operation
+	CFADD
 )
 
 // RedisPayload is a abstract interface for payload type
@@ -87,6 +92,13 @@ type RedisIncrByXPayload struct {
 	Val int64
 }
 
+// RedisFilterAddPayload is the payload type of bloom and cuckoo filter add methods
+type RedisFilterAddPayload struct {
+	RedisPayload
+	Key  string
+	Item interface{}
+}
+
 // Payload implements abstract interface
 func (RedisSetPayload) Payload() {}
 
@@ -96,6 +108,9 @@ func (RedisDeletePayload) Payload() {}
 // Payload implements abstract interface
 func (RedisIncrByXPayload) Payload() {}
 
+// Payload implements abstract interface
+func (RedisFilterAddPayload) Payload() {}
+
 // RedisCmd represents an operation and its payload
 type RedisCmd struct {
 	OpType  RedisOpType
@@ -152,6 +167,31 @@ func (rc *RedisCacheImpl) Get(ctx context.Context, key string, dst interface{})
 	return true, nil
 }
 
+// MGet gets the values of the keys into dsts in a single pipeline
+// The keys are fetched one by one in the pipeline since they may live in different cluster slots; the returned flags tell which keys exist
+func (rc *RedisCacheImpl) MGet(ctx context.Context, keys []string, dsts []interface{}) ([]bool, error) {
+	pipe := rc.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Get(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	exists := make([]bool, len(keys))
+	for i, cmd := range cmds {
+		val, err := cmd.Result()
+		if err == redis.Nil {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(val), dsts[i])
+		exists[i] = true
+	}
+	return exists, nil
+}
+
 // Exist checks whether a key exists
 func (rc *RedisCacheImpl) Exist(ctx context.Context, key string) (bool, error) {
 	numExistKey, err := rc.client.Exists(ctx, key).Result()
@@ -283,6 +323,18 @@ func (rc *RedisCacheImpl) ExecPipeLine(ctx context.Context, cmds *[]RedisCmd) er
 				OpType: INCRBYX,
 				Cmd:    incrByX.Run(ctx, pipe, []string{payload.Key}, payload.Val),
 			})
+		case BFADD:
+			payload := cmd.Payload.(RedisFilterAddPayload)
+			pipelineCmds = append(pipelineCmds, RedisPipelineCmd{
+				OpType: BFADD,
+				Cmd:    pipe.Do(ctx, "bf.add", payload.Key, payload.Item),
+			})
+		case CFADD:
+			payload := cmd.Payload.(RedisFilterAddPayload)
+			pipelineCmds = append(pipelineCmds, RedisPipelineCmd{
+				OpType: CFADD,
+				Cmd:    pipe.Do(ctx, "cf.add", payload.Key, payload.Item),
+			})
 		default:
 			return ErrRedisCmdNotFound
 		}
@@ -302,7 +354,7 @@ func (rc *RedisCacheImpl) ExecPipeLine(ctx context.Context, cmds *[]RedisCmd) er
 			if err := executedCmd.Cmd.(*redis.IntCmd).Err(); err != nil {
 				return err
 			}
-		case INCRBYX:
+		case INCRBYX, BFADD, CFADD:
 			if err := executedCmd.Cmd.(*redis.Cmd).Err(); err != nil {
 				return err
 			}
@@ -25,13 +25,90 @@ func NewMigrator(db *gorm.DB, app string) *Migrator {
 func (m *Migrator) Migrate() error {
 	switch m.app {
 	case "product":
-		return m.db.AutoMigrate(&model.Product{}, &model.Idempotency{})
+		return m.migrateProductSchema(func() error {
+			return m.db.AutoMigrate(&model.Product{}, &model.ProductVariant{}, &model.Idempotency{}, &model.InventoryLedger{}, &model.PriceHistory{}, &model.ScheduledPriceChange{}, &model.Category{}, &model.ProductCategory{}, &model.Outbox{}, &model.ProcessedMessage{})
+		})
 	case "order":
-		return m.db.AutoMigrate(&model.Order{})
+		return m.migrateOrderSchema(func() error {
+			return m.db.AutoMigrate(&model.Order{}, &model.OrderItem{}, &model.OrderTransition{}, &model.Outbox{}, &model.ProcessedMessage{})
+		})
 	case "payment":
-		return m.db.AutoMigrate(&model.Payment{})
+		return m.db.AutoMigrate(&model.Payment{}, &model.Refund{}, &model.Outbox{}, &model.ProcessedMessage{})
 	case "orchestrator":
-		return nil
+		return m.db.AutoMigrate(&model.SagaInstance{}, &model.SagaTransition{}, &model.Outbox{})
+	case "all", "dev":
+		return m.migrateProductSchema(func() error {
+			return m.migrateOrderSchema(func() error {
+				return m.db.AutoMigrate(&model.Product{}, &model.ProductVariant{}, &model.Idempotency{}, &model.InventoryLedger{}, &model.PriceHistory{}, &model.ScheduledPriceChange{}, &model.Category{}, &model.ProductCategory{}, &model.Order{}, &model.OrderItem{}, &model.OrderTransition{}, &model.Payment{}, &model.Refund{}, &model.SagaInstance{}, &model.SagaTransition{}, &model.Outbox{}, &model.ProcessedMessage{})
+			})
+		})
 	}
 	return fmt.Errorf("invalid app name")
 }
+
+// migrateProductSchema runs the auto migration of product tables and then migrates what the auto migration does not:
+// it adds the variant to the primary key of an idempotency table created before variants,
+// and seeds a new price history with the current prices, taken as effective since each product or variant was created
+func (m *Migrator) migrateProductSchema(autoMigrate func() error) error {
+	migrator := m.db.Migrator()
+	outdatedIdempotencyKey := migrator.HasTable(&model.Idempotency{}) && !migrator.HasColumn(&model.Idempotency{}, "VariantID")
+	newPriceHistory := !migrator.HasTable(&model.PriceHistory{})
+	if err := autoMigrate(); err != nil {
+		return err
+	}
+	if outdatedIdempotencyKey {
+		if err := m.db.Exec("ALTER TABLE idempotencies DROP PRIMARY KEY, ADD PRIMARY KEY (id, product_id, variant_id)").Error; err != nil {
+			return err
+		}
+	}
+	if newPriceHistory {
+		if err := m.db.Exec("INSERT INTO price_histories (product_id, variant_id, price, effective_at, created_at) SELECT id, 0, price, created_at, created_at FROM products").Error; err != nil {
+			return err
+		}
+		if err := m.db.Exec("INSERT INTO price_histories (product_id, variant_id, price, effective_at, created_at) SELECT product_id, id, price, created_at, created_at FROM product_variants").Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateOrderSchema runs the auto migration of order tables and then migrates orders of the former layout,
+// where an order was a row per purchased product in the orders table:
+// the former table is renamed to legacy_orders, copied into order headers and order items, and dropped
+// The copy skips orders already copied, so a migration interrupted before the drop is resumed on the next startup;
+// order items are copied without snapshots, and are priced at the time their order was created whenever the order is read;
+// orders created before order statuses are marked as confirmed, since orders of failed purchases used to be deleted
+func (m *Migrator) migrateOrderSchema(autoMigrate func() error) error {
+	migrator := m.db.Migrator()
+	if migrator.HasTable(&model.Order{}) && migrator.HasColumn(&model.Order{}, "product_id") {
+		if err := migrator.RenameTable("orders", "legacy_orders"); err != nil {
+			return err
+		}
+	}
+	if err := autoMigrate(); err != nil {
+		return err
+	}
+	if !migrator.HasTable("legacy_orders") {
+		return nil
+	}
+	legacyStatus := m.db.Table("legacy_orders").Migrator().HasColumn(&model.Order{}, "status")
+	if err := m.db.Transaction(func(tx *gorm.DB) error {
+		status, statusUpdatedAt := "'confirmed'", "MAX(updated_at)"
+		if legacyStatus {
+			status, statusUpdatedAt = "MAX(status)", "MAX(status_updated_at)"
+		}
+		if err := tx.Exec("INSERT INTO orders (id, customer_id, total_amount, total_price, status, status_updated_at, updated_at, created_at) SELECT id, MAX(customer_id), SUM(amount), 0, " + status + ", " + statusUpdatedAt + ", MAX(updated_at), MIN(created_at) FROM legacy_orders WHERE NOT EXISTS (SELECT 1 FROM orders WHERE orders.id = legacy_orders.id) GROUP BY id").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("INSERT INTO order_items (order_id, product_id, variant_id, amount, snapshotted, created_at) SELECT id, product_id, 0, amount, false, created_at FROM legacy_orders WHERE NOT EXISTS (SELECT 1 FROM order_items WHERE order_items.order_id = legacy_orders.id AND order_items.product_id = legacy_orders.product_id AND order_items.variant_id = 0)").Error; err != nil {
+			return err
+		}
+		if !legacyStatus {
+			return tx.Exec("INSERT INTO order_transitions (order_id, status, timestamp) SELECT id, 'confirmed', MAX(updated_at) FROM legacy_orders WHERE NOT EXISTS (SELECT 1 FROM order_transitions WHERE order_transitions.order_id = legacy_orders.id) GROUP BY id").Error
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return migrator.DropTable("legacy_orders")
+}
@@ -1,11 +1,42 @@
 package model
 
 // Order data model
+// It is the header of an order, whose purchased products are order items; orders of a customer are listed through the customer_id index
+// TotalAmount and TotalPrice sum up the amounts and prices of the order items
 type Order struct {
-	ID         uint64 `gorm:"primaryKey"`
-	ProductID  uint64 `gorm:"primaryKey"`
-	Amount     int64  `gorm:"not null"`
-	CustomerID uint64 `gorm:"not null"`
-	UpdatedAt  int64  `gorm:"autoUpdateTime:milli"`
-	CreatedAt  int64  `gorm:"autoCreateTime:milli"`
+	ID              uint64 `gorm:"primaryKey"`
+	CustomerID      uint64 `gorm:"not null;index"`
+	CurrencyCode    string `gorm:"type:varchar(8);not null;default:''"`
+	TotalAmount     int64  `gorm:"not null;default:0"`
+	TotalPrice      int64  `gorm:"not null;default:0"`
+	Status          string `gorm:"type:varchar(16);not null;default:pending"`
+	StatusUpdatedAt int64  `gorm:"not null;default:0"`
+	UpdatedAt       int64  `gorm:"autoUpdateTime:milli"`
+	CreatedAt       int64  `gorm:"autoCreateTime:milli"`
+}
+
+// OrderItem data model
+// Each row is a purchased product, or variant, of an order
+// Name, Description, BrandName and Price are snapshots taken when the order is created;
+// items migrated from orders without snapshots are priced at the time their order was created whenever the order is read
+type OrderItem struct {
+	OrderID     uint64 `gorm:"primaryKey"`
+	ProductID   uint64 `gorm:"primaryKey"`
+	VariantID   uint64 `gorm:"primaryKey"`
+	Amount      int64  `gorm:"not null"`
+	Name        string `gorm:"type:varchar(256);not null;default:''"`
+	Description string `gorm:"type:text"`
+	BrandName   string `gorm:"type:varchar(256);not null;default:''"`
+	Price       int64  `gorm:"not null;default:0"`
+	Snapshotted bool   `gorm:"not null;default:false"`
+	CreatedAt   int64  `gorm:"autoCreateTime:milli"`
+}
+
+// OrderTransition data model
+// Each row is a status that the order changed to
+type OrderTransition struct {
+	ID        uint64 `gorm:"primaryKey;autoIncrement"`
+	OrderID   uint64 `gorm:"index;not null"`
+	Status    string `gorm:"type:varchar(16);not null"`
+	Timestamp int64  `gorm:"not null"`
 }
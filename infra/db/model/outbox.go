@@ -0,0 +1,21 @@
+package model
+
+// Outbox data model
+type Outbox struct {
+	ID        uint64 `gorm:"primaryKey;autoIncrement"`
+	Service   string `gorm:"type:varchar(64);index;not null"`
+	Transport string `gorm:"type:varchar(16);not null"`
+	Topic     string `gorm:"type:varchar(256);not null"`
+	UUID      string `gorm:"type:varchar(64);not null"`
+	Payload   []byte `gorm:"type:blob;not null"`
+	Metadata  string `gorm:"type:text;not null"`
+	CreatedAt int64  `gorm:"autoCreateTime:milli"`
+}
+
+// ProcessedMessage data model
+type ProcessedMessage struct {
+	UUID       string `gorm:"primaryKey;type:varchar(64)"`
+	PurchaseID uint64 `gorm:"primaryKey"`
+	Reply      string `gorm:"type:text;not null"`
+	CreatedAt  int64  `gorm:"autoCreateTime:milli"`
+}
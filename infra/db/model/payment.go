@@ -2,10 +2,23 @@ package model
 
 // Payment data model
 type Payment struct {
-	ID           uint64 `gorm:"primaryKey"`
-	CustomerID   uint64 `gorm:"index;not null"`
-	CurrencyCode string `gorm:"not null"`
-	Amount       int64  `gorm:"not null"`
-	UpdatedAt    int64  `gorm:"autoUpdateTime:milli"`
-	CreatedAt    int64  `gorm:"autoCreateTime:milli"`
+	ID             uint64 `gorm:"primaryKey"`
+	CustomerID     uint64 `gorm:"index;not null"`
+	CurrencyCode   string `gorm:"not null"`
+	Amount         int64  `gorm:"not null"`
+	RefundedAmount int64  `gorm:"not null;default:0"`
+	UpdatedAt      int64  `gorm:"autoUpdateTime:milli"`
+	CreatedAt      int64  `gorm:"autoCreateTime:milli"`
+}
+
+// Refund data model
+// Refunds are only appended, and their amounts add up to the refunded amount of the payment
+type Refund struct {
+	ID             uint64 `gorm:"primaryKey"`
+	PaymentID      uint64 `gorm:"index;not null"`
+	IdempotencyKey string `gorm:"type:varchar(128);uniqueIndex;not null"`
+	Amount         int64  `gorm:"not null"`
+	Reason         string `gorm:"type:varchar(256);not null"`
+	Actor          string `gorm:"type:varchar(256);not null"`
+	CreatedAt      int64  `gorm:"autoCreateTime:milli"`
 }
@@ -1,22 +1,106 @@
 package model
 
+import "gorm.io/gorm"
+
 // Product data model
+// An archived product is kept but no longer sold, while a deleted product is soft-deleted and hidden from all queries
+// Inventory is the available quantity, and Reserved is the quantity held for ongoing purchases
+// Name and description share a full-text index for catalog search
 type Product struct {
-	ID          uint64 `gorm:"primaryKey"`
-	Name        string `gorm:"type:varchar(256);not null"`
-	Description string `gorm:"type:text;not null"`
-	BrandName   string `gorm:"type:varchar(256);not null"`
-	Inventory   int64  `gorm:"not null"`
-	Price       int64  `gorm:"not null"`
-	UpdatedAt   int64  `gorm:"autoUpdateTime:milli"`
-	CreatedAt   int64  `gorm:"autoCreateTime:milli"`
+	ID          uint64         `gorm:"primaryKey"`
+	Name        string         `gorm:"type:varchar(256);not null;index:idx_products_search,class:FULLTEXT"`
+	Description string         `gorm:"type:text;not null;index:idx_products_search,class:FULLTEXT"`
+	BrandName   string         `gorm:"type:varchar(256);not null;index"`
+	Inventory   int64          `gorm:"not null"`
+	Reserved    int64          `gorm:"not null;default:0"`
+	Price       int64          `gorm:"not null;index"`
+	Archived    bool           `gorm:"not null;default:false"`
+	UpdatedAt   int64          `gorm:"autoUpdateTime:milli"`
+	CreatedAt   int64          `gorm:"autoCreateTime:milli"`
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+// ProductVariant data model
+// A variant is a SKU of a product; it is sold at its own price from its own inventory
+type ProductVariant struct {
+	ID        uint64 `gorm:"primaryKey"`
+	ProductID uint64 `gorm:"index;not null"`
+	SKU       string `gorm:"type:varchar(64);uniqueIndex;not null"`
+	Name      string `gorm:"type:varchar(256);not null"`
+	Price     int64  `gorm:"not null"`
+	Inventory int64  `gorm:"not null"`
+	Reserved  int64  `gorm:"not null;default:0"`
+	Archived  bool   `gorm:"not null;default:false"`
+	UpdatedAt int64  `gorm:"autoUpdateTime:milli"`
+	CreatedAt int64  `gorm:"autoCreateTime:milli"`
 }
 
 // Idempotency data model
+// It is also the inventory hold of a purchase, which is released once rollbacked or expired unless it is confirmed
+// Expired tells a hold released on expiry from one rollbacked by the saga
+// A zero VariantID holds the inventory of the product itself
 type Idempotency struct {
 	ID         uint64 `gorm:"primaryKey"`
 	ProductID  uint64 `gorm:"primaryKey"`
+	VariantID  uint64 `gorm:"primaryKey;autoIncrement:false;default:0"`
 	Amount     int64  `gorm:"not null"`
 	Rollbacked bool   `gorm:"not null"`
+	Expired    bool   `gorm:"not null;default:false"`
+	Confirmed  bool   `gorm:"not null;default:false"`
+	ExpiresAt  int64  `gorm:"index;not null;default:0"`
 	CreatedAt  int64  `gorm:"autoCreateTime:milli"`
 }
+
+// InventoryLedger data model
+// Entries are only appended, one for each administrative inventory adjustment
+type InventoryLedger struct {
+	ID        uint64 `gorm:"primaryKey"`
+	ProductID uint64 `gorm:"index;not null"`
+	VariantID uint64 `gorm:"not null;default:0"`
+	Delta     int64  `gorm:"not null"`
+	Reason    string `gorm:"type:varchar(32);not null"`
+	Actor     string `gorm:"type:varchar(256);not null"`
+	Inventory int64  `gorm:"not null"`
+	CreatedAt int64  `gorm:"autoCreateTime:milli"`
+}
+
+// PriceHistory data model
+// Entries are only appended, one each time the price of a product or variant takes effect
+// A zero VariantID records the price of the product itself
+type PriceHistory struct {
+	ID          uint64 `gorm:"primaryKey"`
+	ProductID   uint64 `gorm:"not null;index:idx_price_histories_lookup,priority:1"`
+	VariantID   uint64 `gorm:"not null;default:0;index:idx_price_histories_lookup,priority:2"`
+	Price       int64  `gorm:"not null"`
+	EffectiveAt int64  `gorm:"not null;index:idx_price_histories_lookup,priority:3"`
+	CreatedAt   int64  `gorm:"autoCreateTime:milli"`
+}
+
+// ScheduledPriceChange data model
+// A change is removed once its price takes effect or it is cancelled
+type ScheduledPriceChange struct {
+	ID          uint64 `gorm:"primaryKey"`
+	ProductID   uint64 `gorm:"index;not null"`
+	VariantID   uint64 `gorm:"not null;default:0"`
+	Price       int64  `gorm:"not null"`
+	EffectiveAt int64  `gorm:"index;not null"`
+	CreatedAt   int64  `gorm:"autoCreateTime:milli"`
+}
+
+// Category data model
+// Path is the materialized path of category IDs from the root, such as /1/5/, so that a subtree shares its path prefix
+type Category struct {
+	ID        uint64 `gorm:"primaryKey"`
+	ParentID  uint64 `gorm:"index;not null;default:0"`
+	Name      string `gorm:"type:varchar(256);not null"`
+	Path      string `gorm:"type:varchar(768);index;not null"`
+	UpdatedAt int64  `gorm:"autoUpdateTime:milli"`
+	CreatedAt int64  `gorm:"autoCreateTime:milli"`
+}
+
+// ProductCategory data model
+// It assigns a product to a category
+type ProductCategory struct {
+	ProductID  uint64 `gorm:"primaryKey"`
+	CategoryID uint64 `gorm:"primaryKey;index"`
+}
@@ -0,0 +1,23 @@
+package model
+
+// SagaInstance data model
+type SagaInstance struct {
+	PurchaseID    uint64 `gorm:"primaryKey"`
+	CustomerID    uint64 `gorm:"index;not null"`
+	CorrelationID string `gorm:"type:varchar(256);not null"`
+	CurrentStep   string `gorm:"type:varchar(64);not null"`
+	Status        string `gorm:"type:varchar(64);not null"`
+	Deadline      int64  `gorm:"index;not null"`
+	UpdatedAt     int64  `gorm:"autoUpdateTime:milli"`
+	CreatedAt     int64  `gorm:"autoCreateTime:milli"`
+}
+
+// SagaTransition data model
+type SagaTransition struct {
+	ID         uint64 `gorm:"primaryKey;autoIncrement"`
+	PurchaseID uint64 `gorm:"index;not null"`
+	Step       string `gorm:"type:varchar(64);not null"`
+	Status     string `gorm:"type:varchar(64);not null"`
+	Error      string `gorm:"type:text"`
+	Timestamp  int64  `gorm:"not null"`
+}
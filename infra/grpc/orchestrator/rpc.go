@@ -0,0 +1,78 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	conf "github.com/minghsu0107/saga-product/config"
+	"github.com/minghsu0107/saga-product/pb"
+	"github.com/minghsu0107/saga-product/pkg"
+	"github.com/minghsu0107/saga-product/service/orchestrator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// GetPurchase returns the saga status of a purchase owned by the customer of the access token
+func (srv *OrchestratorServer) GetPurchase(ctx context.Context, req *pb.GetPurchaseRequest) (*pb.PurchaseStatus, error) {
+	customerID, err := srv.auth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	purchase, err := srv.purchaseSvc.GetPurchase(ctx, customerID, req.PurchaseId)
+	switch err {
+	case orchestrator.ErrPurchaseNotFound:
+		return nil, status.Error(codes.NotFound, err.Error())
+	case orchestrator.ErrUnauthorized:
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	case nil:
+	default:
+		return nil, status.Errorf(
+			codes.Internal,
+			fmt.Sprintf("internal error: %v", err),
+		)
+	}
+	var pbTransitions []*pb.SagaTransition
+	for _, transition := range *purchase.Transitions {
+		pbTransitions = append(pbTransitions, &pb.SagaTransition{
+			Step:      transition.Step,
+			Status:    transition.Status,
+			Error:     transition.Error,
+			Timestamp: pkg.Time2pbTimestamp(transition.Timestamp),
+		})
+	}
+	return &pb.PurchaseStatus{
+		PurchaseId:  purchase.PurchaseID,
+		CustomerId:  purchase.CustomerID,
+		CurrentStep: purchase.CurrentStep,
+		Status:      purchase.Status,
+		Error:       purchase.Error,
+		Transitions: pbTransitions,
+		CreatedAt:   pkg.Time2pbTimestamp(purchase.CreatedAt),
+		UpdatedAt:   pkg.Time2pbTimestamp(purchase.UpdatedAt),
+	}, nil
+}
+
+// auth authorizes a call by the bearer token in its authorization metadata, as JWTAuthChecker does for http requests
+func (srv *OrchestratorServer) auth(ctx context.Context) (uint64, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var accessToken string
+	if values := md.Get(conf.JWTAuthHeader); len(values) > 0 {
+		if strArr := strings.Split(values[0], " "); len(strArr) == 2 {
+			accessToken = strArr[1]
+		}
+	}
+	if accessToken == "" {
+		return 0, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	authResult, err := srv.authRepo.Auth(ctx, accessToken)
+	if err != nil {
+		srv.logger.Error(err)
+		return 0, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	if authResult.Expired {
+		return 0, status.Error(codes.Unauthenticated, "token expired")
+	}
+	return authResult.CustomerID, nil
+}
@@ -0,0 +1,63 @@
+package orchestrator
+
+import (
+	"net"
+
+	infra_grpc "github.com/minghsu0107/saga-product/infra/grpc"
+	log "github.com/sirupsen/logrus"
+
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/minghsu0107/saga-product/config"
+	"github.com/minghsu0107/saga-product/pb"
+	"github.com/minghsu0107/saga-product/repo"
+	"github.com/minghsu0107/saga-product/service/orchestrator"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+// OrchestratorServer implementation
+type OrchestratorServer struct {
+	Port        string
+	s           *grpc.Server
+	purchaseSvc orchestrator.PurchaseService
+	authRepo    repo.AuthRepository
+	logger      *log.Entry
+}
+
+// NewOrchestratorServer is the factory of orchestrator server
+func NewOrchestratorServer(config *config.Config, purchaseSvc orchestrator.PurchaseService, authRepo repo.AuthRepository) infra_grpc.Server {
+	srv := &OrchestratorServer{
+		Port:        config.GRPCPort,
+		purchaseSvc: purchaseSvc,
+		authRepo:    authRepo,
+		logger: config.Logger.ContextLogger.WithFields(log.Fields{
+			"type": "grpc:OrchestratorServer",
+		}),
+	}
+
+	srv.s = infra_grpc.InitializeServer(config.Logger.ContextLogger)
+	pb.RegisterOrchestratorServiceServer(srv.s, srv)
+
+	grpc_prometheus.Register(srv.s)
+	reflection.Register(srv.s)
+	return srv
+}
+
+// Run method starts the grpc server
+func (srv *OrchestratorServer) Run() error {
+	addr := "0.0.0.0:" + srv.Port
+	log.Infoln("grpc server listening on ", addr)
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if err := srv.s.Serve(lis); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GracefulStop stops grpc server gracefully
+func (srv *OrchestratorServer) GracefulStop() {
+	srv.s.GracefulStop()
+}
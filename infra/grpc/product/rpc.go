@@ -4,18 +4,22 @@ import (
 	"context"
 	"fmt"
 
-	pb "github.com/minghsu0107/saga-pb"
 	"github.com/minghsu0107/saga-product/domain/model"
+	product_pb "github.com/minghsu0107/saga-product/pb"
+	"github.com/minghsu0107/saga-product/pkg"
+	"github.com/minghsu0107/saga-product/service/product"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
-func (srv *ProductServer) CheckProducts(ctx context.Context, req *pb.CheckProductsRequest) (*pb.CheckProductsResponse, error) {
+// CheckProducts checks whether the products or their variants are for sale
+func (srv *ProductServer) CheckProducts(ctx context.Context, req *product_pb.CheckProductsRequest) (*product_pb.CheckProductsResponse, error) {
 	var cartItems []model.CartItem
 	pbCartItems := req.CartItems
 	for _, pbCartItem := range pbCartItems {
 		cartItems = append(cartItems, model.CartItem{
 			ProductID: pbCartItem.ProductId,
+			VariantID: pbCartItem.VariantId,
 			Amount:    pbCartItem.Amount,
 		})
 	}
@@ -26,20 +30,22 @@ func (srv *ProductServer) CheckProducts(ctx context.Context, req *pb.CheckProduc
 			fmt.Sprintf("internal error: %v", err),
 		)
 	}
-	var pbProductStatuses []*pb.ProductStatus
-	for _, status := range *productStatuses {
-		pbProductStatuses = append(pbProductStatuses, &pb.ProductStatus{
-			ProductId: status.ProductID,
-			Price:     status.Price,
-			Status:    getPbProductStatus(status.Status),
+	var pbProductStatuses []*product_pb.ProductStatus
+	for _, productStatus := range *productStatuses {
+		pbProductStatuses = append(pbProductStatuses, &product_pb.ProductStatus{
+			ProductId: productStatus.ProductID,
+			Price:     productStatus.Price,
+			Status:    getPbProductStatus(productStatus.Status),
+			VariantId: productStatus.VariantID,
 		})
 	}
-	return &pb.CheckProductsResponse{
+	return &product_pb.CheckProductsResponse{
 		ProductStatuses: pbProductStatuses,
 	}, nil
 }
 
-func (srv *ProductServer) GetProducts(ctx context.Context, req *pb.GetProductsRequest) (*pb.Products, error) {
+// GetProducts gets the products together with their categories and variants
+func (srv *ProductServer) GetProducts(ctx context.Context, req *product_pb.GetProductsRequest) (*product_pb.Products, error) {
 	productIDs := req.ProductIds
 	products, err := srv.productSvc.GetProducts(ctx, productIDs)
 	if err != nil {
@@ -48,28 +54,230 @@ func (srv *ProductServer) GetProducts(ctx context.Context, req *pb.GetProductsRe
 			fmt.Sprintf("internal error: %v", err),
 		)
 	}
-	var pbProducts []*pb.Product
+	var pbProducts []*product_pb.Product
 	for _, product := range *products {
-		pbProducts = append(pbProducts, &pb.Product{
+		pbProducts = append(pbProducts, &product_pb.Product{
 			ProductId:   product.ID,
 			ProductName: product.Detail.Name,
 			Description: product.Detail.Description,
 			BrandName:   product.Detail.BrandName,
 			Inventory:   product.Inventory,
 			Price:       product.Detail.Price,
+			Categories:  encodeCategories(product.Categories),
+			Variants:    encodeVariants(product.Variants),
 		})
 	}
-	return &pb.Products{
+	return &product_pb.Products{
 		Products: pbProducts,
 	}, nil
 }
 
-func getPbProductStatus(status model.Status) pb.Status {
+// ListProducts searches the product catalog with filters and sorting
+func (srv *ProductServer) ListProducts(ctx context.Context, req *product_pb.ListProductsRequest) (*product_pb.ProductCatalogs, error) {
+	page, err := srv.productSvc.ListProducts(ctx, &model.ProductQuery{
+		Keyword:    req.Keyword,
+		BrandName:  req.BrandName,
+		CategoryID: req.CategoryId,
+		MinPrice:   req.MinPrice,
+		MaxPrice:   req.MaxPrice,
+		InStock:    req.InStock,
+		SortBy:     getProductSortField(req.SortBy),
+		Descending: req.Descending,
+		Offset:     int(req.Offset),
+		Size:       int(req.Size),
+		Cursor:     req.Cursor,
+	})
+	switch err {
+	case product.ErrInvalidProductQuery, product.ErrInvalidCursor:
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case nil:
+	default:
+		return nil, status.Errorf(
+			codes.Internal,
+			fmt.Sprintf("internal error: %v", err),
+		)
+	}
+	var pbCatalogs []*product_pb.ProductCatalog
+	for _, catalog := range page.Catalogs {
+		pbCatalogs = append(pbCatalogs, &product_pb.ProductCatalog{
+			ProductId:   catalog.ID,
+			ProductName: catalog.Name,
+			Inventory:   catalog.Inventory,
+			Reserved:    catalog.Reserved,
+			Price:       catalog.Price,
+		})
+	}
+	return &product_pb.ProductCatalogs{
+		Catalogs:   pbCatalogs,
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+// AdjustInventory records an administrative inventory adjustment of a product in the inventory ledger
+func (srv *ProductServer) AdjustInventory(ctx context.Context, req *product_pb.AdjustInventoryRequest) (*product_pb.InventoryLedgerEntry, error) {
+	entry, err := srv.productSvc.AdjustInventory(ctx, &model.InventoryAdjustment{
+		ProductID: req.ProductId,
+		VariantID: req.VariantId,
+		Delta:     req.Delta,
+		Reason:    getAdjustmentReason(req.Reason),
+		Actor:     req.Actor,
+	})
+	switch err {
+	case product.ErrInvalidAdjustment:
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case product.ErrProductNotFound, product.ErrVariantNotFound:
+		return nil, status.Error(codes.NotFound, err.Error())
+	case product.ErrInsuffientInventory:
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case nil:
+		return encodeInventoryLedgerEntry(entry), nil
+	default:
+		return nil, status.Errorf(
+			codes.Internal,
+			fmt.Sprintf("internal error: %v", err),
+		)
+	}
+}
+
+// ListInventoryLedger lists the inventory ledger of a product from the oldest entry
+func (srv *ProductServer) ListInventoryLedger(ctx context.Context, req *product_pb.ListInventoryLedgerRequest) (*product_pb.InventoryLedger, error) {
+	ledger, err := srv.productSvc.ListInventoryLedger(ctx, req.ProductId, int(req.Offset), int(req.Size))
+	if err != nil {
+		return nil, status.Errorf(
+			codes.Internal,
+			fmt.Sprintf("internal error: %v", err),
+		)
+	}
+	var pbEntries []*product_pb.InventoryLedgerEntry
+	for i := range *ledger {
+		pbEntries = append(pbEntries, encodeInventoryLedgerEntry(&(*ledger)[i]))
+	}
+	return &product_pb.InventoryLedger{
+		Entries: pbEntries,
+	}, nil
+}
+
+// GetPricesAt returns the prices of products or variants in effect at the time of the request, or of each item
+func (srv *ProductServer) GetPricesAt(ctx context.Context, req *product_pb.GetPricesAtRequest) (*product_pb.Prices, error) {
+	var pbPrices []*product_pb.Price
+	for _, item := range req.Items {
+		at := req.At
+		if item.At != nil {
+			at = item.At
+		}
+		if at == nil {
+			return nil, status.Error(codes.InvalidArgument, "missing time")
+		}
+		prices, err := srv.productSvc.GetPricesAt(ctx, at.AsTime(), &[]model.PurchasedItem{
+			{
+				ProductID: item.ProductId,
+				VariantID: item.VariantId,
+			},
+		})
+		if err != nil {
+			return nil, status.Errorf(
+				codes.Internal,
+				fmt.Sprintf("internal error: %v", err),
+			)
+		}
+		for _, price := range *prices {
+			pbPrices = append(pbPrices, &product_pb.Price{
+				ProductId:   price.ProductID,
+				VariantId:   price.VariantID,
+				Price:       price.Price,
+				EffectiveAt: pkg.Time2pbTimestamp(price.EffectiveAt),
+				At:          at,
+			})
+		}
+	}
+	return &product_pb.Prices{
+		Prices: pbPrices,
+	}, nil
+}
+
+func encodeInventoryLedgerEntry(entry *model.InventoryLedgerEntry) *product_pb.InventoryLedgerEntry {
+	return &product_pb.InventoryLedgerEntry{
+		Id:        entry.ID,
+		ProductId: entry.ProductID,
+		VariantId: entry.VariantID,
+		Delta:     entry.Delta,
+		Reason:    getPbAdjustmentReason(entry.Reason),
+		Actor:     entry.Actor,
+		Inventory: entry.Inventory,
+		CreatedAt: pkg.Time2pbTimestamp(entry.CreatedAt),
+	}
+}
+
+func encodeCategories(categories []model.Category) []*product_pb.Category {
+	var pbCategories []*product_pb.Category
+	for _, category := range categories {
+		pbCategories = append(pbCategories, &product_pb.Category{
+			CategoryId: category.ID,
+			ParentId:   category.ParentID,
+			Name:       category.Name,
+		})
+	}
+	return pbCategories
+}
+
+func encodeVariants(variants []model.ProductVariant) []*product_pb.Variant {
+	var pbVariants []*product_pb.Variant
+	for _, variant := range variants {
+		pbVariants = append(pbVariants, &product_pb.Variant{
+			VariantId: variant.ID,
+			Sku:       variant.SKU,
+			Name:      variant.Name,
+			Price:     variant.Price,
+			Inventory: variant.Inventory,
+			Reserved:  variant.Reserved,
+			Archived:  variant.Archived,
+		})
+	}
+	return pbVariants
+}
+
+func getProductSortField(field product_pb.SortField) model.ProductSortField {
+	switch field {
+	case product_pb.SortField_SORT_PRICE:
+		return model.SortByPrice
+	case product_pb.SortField_SORT_NAME:
+		return model.SortByName
+	case product_pb.SortField_SORT_CREATED_AT:
+		return model.SortByCreatedAt
+	}
+	return model.SortByRelevance
+}
+
+func getAdjustmentReason(reason product_pb.AdjustmentReason) model.AdjustmentReason {
+	switch reason {
+	case product_pb.AdjustmentReason_REASON_RESTOCK:
+		return model.InventoryRestock
+	case product_pb.AdjustmentReason_REASON_SHRINKAGE:
+		return model.InventoryShrinkage
+	case product_pb.AdjustmentReason_REASON_CORRECTION:
+		return model.InventoryCorrection
+	}
+	return ""
+}
+
+func getPbAdjustmentReason(reason model.AdjustmentReason) product_pb.AdjustmentReason {
+	switch reason {
+	case model.InventoryRestock:
+		return product_pb.AdjustmentReason_REASON_RESTOCK
+	case model.InventoryShrinkage:
+		return product_pb.AdjustmentReason_REASON_SHRINKAGE
+	case model.InventoryCorrection:
+		return product_pb.AdjustmentReason_REASON_CORRECTION
+	}
+	return product_pb.AdjustmentReason_REASON_UNKNOWN
+}
+
+func getPbProductStatus(status model.Status) product_pb.Status {
 	switch status {
 	case model.ProductOk:
-		return pb.Status_STATUS_OK
+		return product_pb.Status_STATUS_OK
 	case model.ProductNotFound:
-		return pb.Status_STATUS_NOT_FOUND
+		return product_pb.Status_STATUS_NOT_FOUND
 	}
-	return pb.Status_STATUS_NOT_FOUND
+	return product_pb.Status_STATUS_NOT_FOUND
 }
@@ -1,14 +1,15 @@
 package product
 
 import (
+	"context"
 	"net"
 
 	infra_grpc "github.com/minghsu0107/saga-product/infra/grpc"
 	log "github.com/sirupsen/logrus"
 
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
-	pb "github.com/minghsu0107/saga-pb"
 	"github.com/minghsu0107/saga-product/config"
+	product_pb "github.com/minghsu0107/saga-product/pb"
 	"github.com/minghsu0107/saga-product/service/product"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
@@ -31,13 +32,77 @@ func NewProductServer(config *config.Config, productSvc product.ProductService,
 	}
 
 	srv.s = infra_grpc.InitializeServer(config.Logger.ContextLogger)
-	pb.RegisterProductServiceServer(srv.s, srv)
+	srv.s.RegisterService(&productServiceDesc, srv)
+	product_pb.RegisterInventoryServiceServer(srv.s, srv)
+	product_pb.RegisterCatalogServiceServer(srv.s, srv)
+	product_pb.RegisterPricingServiceServer(srv.s, srv)
 
 	grpc_prometheus.Register(srv.s)
 	reflection.Register(srv.s)
 	return srv
 }
 
+// productServiceServer is the server of product.ProductService in saga-pb, served with the catalog messages mirroring the saga-pb ones
+type productServiceServer interface {
+	CheckProducts(ctx context.Context, req *product_pb.CheckProductsRequest) (*product_pb.CheckProductsResponse, error)
+	GetProducts(ctx context.Context, req *product_pb.GetProductsRequest) (*product_pb.Products, error)
+}
+
+// productServiceDesc describes product.ProductService as saga-pb does, except that requests are decoded into the catalog messages,
+// whose fields added after the saga-pb ones carry product variants and categories
+var productServiceDesc = grpc.ServiceDesc{
+	ServiceName: "product.ProductService",
+	HandlerType: (*productServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CheckProducts",
+			Handler:    checkProductsHandler,
+		},
+		{
+			MethodName: "GetProducts",
+			Handler:    getProductsHandler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "product.proto",
+}
+
+func checkProductsHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(product_pb.CheckProductsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(productServiceServer).CheckProducts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/product.ProductService/CheckProducts",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(productServiceServer).CheckProducts(ctx, req.(*product_pb.CheckProductsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func getProductsHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(product_pb.GetProductsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(productServiceServer).GetProducts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/product.ProductService/GetProducts",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(productServiceServer).GetProducts(ctx, req.(*product_pb.GetProductsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Run method starts the grpc server
 func (srv *ProductServer) Run() error {
 	addr := "0.0.0.0:" + srv.Port
@@ -52,16 +52,40 @@ func (m *JWTAuthChecker) JWTAuth() gin.HandlerFunc {
 	}
 }
 
+// StaffAuth authorizes a request authenticated by JWTAuth only if its customer is support staff
+func (m *JWTAuthChecker) StaffAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		customerID, ok := c.Request.Context().Value(conf.CustomerKey).(uint64)
+		if !ok {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		if _, ok := m.staffIDs[customerID]; !ok {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Next()
+	}
+}
+
 // JWTAuthChecker is the jwt authorization middleware type
 type JWTAuthChecker struct {
-	repo   repo.AuthRepository
-	logger *log.Entry
+	repo     repo.AuthRepository
+	staffIDs map[uint64]struct{}
+	logger   *log.Entry
 }
 
 // NewJWTAuthChecker is the factory of JWTAuthChecker
 func NewJWTAuthChecker(config *conf.Config, repo repo.AuthRepository) *JWTAuthChecker {
+	staffIDs := make(map[uint64]struct{})
+	if config.StaffConfig != nil {
+		for _, id := range config.StaffConfig.CustomerIDs {
+			staffIDs[id] = struct{}{}
+		}
+	}
 	return &JWTAuthChecker{
-		repo: repo,
+		repo:     repo,
+		staffIDs: staffIDs,
 		logger: config.Logger.ContextLogger.WithFields(log.Fields{
 			"type": "middleware:JWTAuthChecker",
 		}),
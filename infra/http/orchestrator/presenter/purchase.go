@@ -0,0 +1,28 @@
+package presenter
+
+// PurchaseStatus response payload
+type PurchaseStatus struct {
+	ID          uint64           `json:"id"`
+	CurrentStep string           `json:"current_step"`
+	Status      string           `json:"status"`
+	Error       string           `json:"error,omitempty"`
+	Transitions []SagaTransition `json:"transitions"`
+	CreatedAt   int64            `json:"created_at"`
+	UpdatedAt   int64            `json:"updated_at"`
+}
+
+// SagaTransition payload
+type SagaTransition struct {
+	Step      string `json:"step"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// PurchaseResult event payload
+type PurchaseResult struct {
+	PurchaseID uint64 `json:"purchase_id"`
+	Step       string `json:"step"`
+	Status     string `json:"status"`
+	Timestamp  int64  `json:"timestamp"`
+}
@@ -0,0 +1,144 @@
+package orchestrator
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/minghsu0107/saga-product/config"
+	"github.com/minghsu0107/saga-product/infra/http/orchestrator/presenter"
+	common_presenter "github.com/minghsu0107/saga-product/infra/http/presenter"
+	orchestratorsvc "github.com/minghsu0107/saga-product/service/orchestrator"
+)
+
+// Router wraps http handlers
+type Router struct {
+	purchaseSvc orchestratorsvc.PurchaseService
+	resultSvc   orchestratorsvc.PurchaseResultService
+	keepalive   time.Duration
+}
+
+// NewRouter is a factory for router instance
+func NewRouter(config *config.Config, purchaseSvc orchestratorsvc.PurchaseService, resultSvc orchestratorsvc.PurchaseResultService) *Router {
+	keepalive := time.Duration(config.ResultStreamConfig.KeepaliveSecond) * time.Second
+	if keepalive <= 0 {
+		keepalive = 15 * time.Second
+	}
+	return &Router{
+		purchaseSvc: purchaseSvc,
+		resultSvc:   resultSvc,
+		keepalive:   keepalive,
+	}
+}
+
+// GetPurchase endpoint
+func (r *Router) GetPurchase(c *gin.Context) {
+	customerID, ok := c.Request.Context().Value(config.CustomerKey).(uint64)
+	if !ok {
+		response(c, http.StatusUnauthorized, common_presenter.ErrUnauthorized)
+		return
+	}
+
+	id := c.Param("id")
+	purchaseID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+
+	purchase, err := r.purchaseSvc.GetPurchase(c.Request.Context(), customerID, purchaseID)
+	switch err {
+	case orchestratorsvc.ErrPurchaseNotFound:
+		response(c, http.StatusNotFound, orchestratorsvc.ErrPurchaseNotFound)
+		return
+	case orchestratorsvc.ErrUnauthorized:
+		response(c, http.StatusUnauthorized, common_presenter.ErrUnauthorized)
+		return
+	case nil:
+		transitions := []presenter.SagaTransition{}
+		for _, transition := range *purchase.Transitions {
+			transitions = append(transitions, presenter.SagaTransition{
+				Step:      transition.Step,
+				Status:    transition.Status,
+				Error:     transition.Error,
+				Timestamp: transition.Timestamp.UnixMilli(),
+			})
+		}
+		c.JSON(http.StatusOK, &presenter.PurchaseStatus{
+			ID:          purchase.PurchaseID,
+			CurrentStep: purchase.CurrentStep,
+			Status:      purchase.Status,
+			Error:       purchase.Error,
+			Transitions: transitions,
+			CreatedAt:   purchase.CreatedAt.UnixMilli(),
+			UpdatedAt:   purchase.UpdatedAt.UnixMilli(),
+		})
+	default:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+}
+
+// StreamPurchaseResults endpoint streams purchase results of the customer as server-sent events
+// Each event carries its stream ID, from which a reconnecting client resumes through the Last-Event-ID header;
+// a Last-Event-ID that can no longer be replayed is answered with 410 Gone
+func (r *Router) StreamPurchaseResults(c *gin.Context) {
+	customerID, ok := c.Request.Context().Value(config.CustomerKey).(uint64)
+	if !ok {
+		response(c, http.StatusUnauthorized, common_presenter.ErrUnauthorized)
+		return
+	}
+
+	results, err := r.resultSvc.SubscribePurchaseResults(c.Request.Context(), customerID, c.GetHeader("Last-Event-ID"))
+	switch err {
+	case orchestratorsvc.ErrInvalidLastEventID:
+		response(c, http.StatusBadRequest, orchestratorsvc.ErrInvalidLastEventID)
+		return
+	case orchestratorsvc.ErrLastEventIDExpired:
+		response(c, http.StatusGone, orchestratorsvc.ErrLastEventIDExpired)
+		return
+	case nil:
+	default:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	keepalive := time.NewTicker(r.keepalive)
+	defer keepalive.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				return false
+			}
+			c.Render(-1, sse.Event{
+				Id:    result.ID,
+				Event: "purchase_result",
+				Data: &presenter.PurchaseResult{
+					PurchaseID: result.Result.PurchaseID,
+					Step:       result.Result.Step,
+					Status:     result.Result.Status,
+					Timestamp:  result.Result.Timestamp.UnixMilli(),
+				},
+			})
+			return true
+		case <-keepalive.C:
+			_, err := io.WriteString(w, ": keepalive\n\n")
+			return err == nil
+		}
+	})
+}
+
+func response(c *gin.Context, httpCode int, err error) {
+	message := err.Error()
+	c.JSON(httpCode, common_presenter.ErrResponse{
+		Message: message,
+	})
+}
@@ -0,0 +1,97 @@
+package orchestrator
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	conf "github.com/minghsu0107/saga-product/config"
+	infra_http "github.com/minghsu0107/saga-product/infra/http"
+	"github.com/minghsu0107/saga-product/infra/http/middleware"
+	log "github.com/sirupsen/logrus"
+	metrics "github.com/slok/go-http-metrics/metrics/prometheus"
+	prommiddleware "github.com/slok/go-http-metrics/middleware"
+	ginmiddleware "github.com/slok/go-http-metrics/middleware/gin"
+)
+
+// OrchestratorServer implementation
+type OrchestratorServer struct {
+	App            string
+	Port           string
+	Engine         *gin.Engine
+	Router         *Router
+	svr            *http.Server
+	jwtAuthChecker *middleware.JWTAuthChecker
+}
+
+// NewEngine is a factory for gin engine instance
+// Global Middlewares and api log configurations are registered here
+func NewEngine(config *conf.Config) *gin.Engine {
+	gin.SetMode(config.GinMode)
+	if config.GinMode == "release" {
+		log.SetLevel(log.InfoLevel)
+	} else {
+		log.SetLevel(log.DebugLevel)
+	}
+	gin.DefaultWriter = io.Writer(config.Logger.Writer)
+
+	engine := gin.New()
+	engine.Use(gin.Recovery())
+	engine.Use(middleware.LogMiddleware(config.Logger.ContextLogger))
+	engine.Use(middleware.CORSMiddleware())
+
+	mdlw := prommiddleware.New(prommiddleware.Config{
+		Recorder: metrics.NewRecorder(metrics.Config{
+			Prefix: config.App,
+		}),
+	})
+	engine.Use(ginmiddleware.Handler("", mdlw))
+	return engine
+}
+
+// NewOrchestratorServer is the factory of orchestrator server
+func NewOrchestratorServer(config *conf.Config, engine *gin.Engine, router *Router, jwtAuthChecker *middleware.JWTAuthChecker) infra_http.Server {
+	return &OrchestratorServer{
+		App:            config.App,
+		Port:           config.HTTPPort,
+		Engine:         engine,
+		Router:         router,
+		jwtAuthChecker: jwtAuthChecker,
+	}
+}
+
+// RegisterRoutes method register all endpoints
+func (s *OrchestratorServer) RegisterRoutes() {
+	purchaseGroup := s.Engine.Group("/api/purchase")
+	purchaseGroup.Use(s.jwtAuthChecker.JWTAuth())
+	{
+		purchaseGroup.GET("/:id", s.Router.GetPurchase)
+	}
+	resultGroup := s.Engine.Group("/api/result")
+	resultGroup.Use(s.jwtAuthChecker.JWTAuth())
+	{
+		resultGroup.GET("/stream", s.Router.StreamPurchaseResults)
+	}
+}
+
+// Run is a method for starting server
+func (s *OrchestratorServer) Run() error {
+	s.RegisterRoutes()
+	addr := ":" + s.Port
+	s.svr = &http.Server{
+		Addr:    addr,
+		Handler: infra_http.NewOtelHandler(s.Engine, s.App+"_http"),
+	}
+	log.Infoln("http server listening on ", addr)
+	err := s.svr.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// GracefulStop the server
+func (s *OrchestratorServer) GracefulStop(ctx context.Context) error {
+	return s.svr.Shutdown(ctx)
+}
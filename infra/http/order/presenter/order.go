@@ -1,17 +1,62 @@
 package presenter
 
 // DetailedOrder response payload
+// TotalPrice is the price of all purchased units when the order was created
+// Transitions are the status changes of the order from the earliest
 type DetailedOrder struct {
-	ID             uint64          `json:"id"`
-	PurchasedItems []PurchasedItem `json:"purchased_items"`
+	ID              uint64            `json:"id"`
+	CurrencyCode    string            `json:"currency_code"`
+	PurchasedItems  []PurchasedItem   `json:"purchased_items"`
+	TotalAmount     int64             `json:"total_amount"`
+	TotalPrice      int64             `json:"total_price"`
+	Status          string            `json:"status"`
+	StatusUpdatedAt int64             `json:"status_updated_at"`
+	Transitions     []OrderTransition `json:"transitions"`
+	CreatedAt       int64             `json:"created_at"`
+}
+
+// OrderTransition payload
+type OrderTransition struct {
+	Status    string `json:"status"`
+	Timestamp int64  `json:"timestamp"`
 }
 
 // PurchasedItem payload
+// It is the product, or variant, as it was when the order was created
 type PurchasedItem struct {
 	ProductID   uint64 `json:"product_id"`
+	VariantID   uint64 `json:"variant_id,omitempty"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	BrandName   string `json:"brand_name"`
 	Price       int64  `json:"price"`
 	Amount      int64  `json:"amount"`
 }
+
+// OrderQuery payload
+// Orders created from from until before to, in unix milliseconds, are listed from the latest
+// Cursor is the next_cursor of a previous page
+type OrderQuery struct {
+	Size   int    `form:"size" binding:"required,numeric,min=1,max=100"`
+	Cursor string `form:"cursor" binding:"omitempty,max=256"`
+	From   int64  `form:"from" binding:"omitempty,min=0"`
+	To     int64  `form:"to" binding:"omitempty,min=0"`
+}
+
+// Orders response payload
+// NextCursor is empty on the last page
+type Orders struct {
+	Orders     []OrderSummary `json:"orders"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// OrderSummary payload
+// TotalPrice is the price of all purchased units when the order was created
+type OrderSummary struct {
+	ID          uint64 `json:"id"`
+	ItemCount   int    `json:"item_count"`
+	TotalAmount int64  `json:"total_amount"`
+	TotalPrice  int64  `json:"total_price"`
+	Status      string `json:"status"`
+	CreatedAt   int64  `json:"created_at"`
+}
@@ -3,9 +3,11 @@ package order
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/minghsu0107/saga-product/config"
+	"github.com/minghsu0107/saga-product/domain/model"
 	"github.com/minghsu0107/saga-product/infra/http/order/presenter"
 	common_presenter "github.com/minghsu0107/saga-product/infra/http/presenter"
 	ordersvc "github.com/minghsu0107/saga-product/service/order"
@@ -51,6 +53,7 @@ func (r *Router) GetDetailedOrder(c *gin.Context) {
 		for _, detailedPurchasedItem := range *order.DetailedPurchasedItems {
 			purchasedItems = append(purchasedItems, presenter.PurchasedItem{
 				ProductID:   detailedPurchasedItem.ProductID,
+				VariantID:   detailedPurchasedItem.VariantID,
 				Name:        detailedPurchasedItem.Name,
 				Description: detailedPurchasedItem.Description,
 				BrandName:   detailedPurchasedItem.BrandName,
@@ -58,9 +61,111 @@ func (r *Router) GetDetailedOrder(c *gin.Context) {
 				Amount:      detailedPurchasedItem.Amount,
 			})
 		}
+		transitions := []presenter.OrderTransition{}
+		for _, transition := range *order.Transitions {
+			transitions = append(transitions, presenter.OrderTransition{
+				Status:    string(transition.Status),
+				Timestamp: transition.Timestamp.UnixMilli(),
+			})
+		}
 		c.JSON(http.StatusOK, &presenter.DetailedOrder{
-			ID:             order.ID,
-			PurchasedItems: purchasedItems,
+			ID:              order.ID,
+			CurrencyCode:    order.CurrencyCode,
+			PurchasedItems:  purchasedItems,
+			TotalAmount:     order.TotalAmount,
+			TotalPrice:      order.TotalPrice,
+			Status:          string(order.Status),
+			StatusUpdatedAt: order.StatusUpdatedAt.UnixMilli(),
+			Transitions:     transitions,
+			CreatedAt:       order.CreatedAt.UnixMilli(),
+		})
+	default:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+}
+
+// CancelOrder endpoint
+// The cancellation is accepted and carried out asynchronously; its progress is reported as purchase results
+func (r *Router) CancelOrder(c *gin.Context) {
+	customerID, ok := c.Request.Context().Value(config.CustomerKey).(uint64)
+	if !ok {
+		response(c, http.StatusUnauthorized, common_presenter.ErrUnauthorized)
+		return
+	}
+
+	id := c.Param("id")
+	orderID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+
+	err = r.orderSvc.CancelOrder(c.Request.Context(), customerID, orderID)
+	switch err {
+	case ordersvc.ErrOrderNotFound:
+		response(c, http.StatusNotFound, ordersvc.ErrOrderNotFound)
+		return
+	case ordersvc.ErrUnauthorized:
+		response(c, http.StatusUnauthorized, common_presenter.ErrUnauthorized)
+		return
+	case ordersvc.ErrOrderNotCancellable:
+		response(c, http.StatusConflict, ordersvc.ErrOrderNotCancellable)
+		return
+	case nil:
+		c.Status(http.StatusAccepted)
+	default:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+}
+
+// ListOrders endpoint
+func (r *Router) ListOrders(c *gin.Context) {
+	customerID, ok := c.Request.Context().Value(config.CustomerKey).(uint64)
+	if !ok {
+		response(c, http.StatusUnauthorized, common_presenter.ErrUnauthorized)
+		return
+	}
+	var query presenter.OrderQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	orderQuery := &model.OrderQuery{
+		CustomerID: customerID,
+		Size:       query.Size,
+		Cursor:     query.Cursor,
+	}
+	if query.From > 0 {
+		orderQuery.From = time.UnixMilli(query.From)
+	}
+	if query.To > 0 {
+		orderQuery.To = time.UnixMilli(query.To)
+	}
+	page, err := r.orderSvc.ListOrders(c.Request.Context(), orderQuery)
+	switch err {
+	case ordersvc.ErrInvalidOrderQuery:
+		response(c, http.StatusBadRequest, ordersvc.ErrInvalidOrderQuery)
+		return
+	case ordersvc.ErrInvalidCursor:
+		response(c, http.StatusBadRequest, ordersvc.ErrInvalidCursor)
+		return
+	case nil:
+		orders := []presenter.OrderSummary{}
+		for _, summary := range page.Orders {
+			orders = append(orders, presenter.OrderSummary{
+				ID:          summary.ID,
+				ItemCount:   summary.ItemCount,
+				TotalAmount: summary.TotalAmount,
+				TotalPrice:  summary.TotalPrice,
+				Status:      string(summary.Status),
+				CreatedAt:   summary.CreatedAt.UnixMilli(),
+			})
+		}
+		c.JSON(http.StatusOK, &presenter.Orders{
+			Orders:     orders,
+			NextCursor: page.NextCursor,
 		})
 	default:
 		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
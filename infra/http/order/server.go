@@ -67,6 +67,12 @@ func (s *OrderServer) RegisterRoutes() {
 	orderGroup.Use(s.jwtAuthChecker.JWTAuth())
 	{
 		orderGroup.GET("/:id", s.Router.GetDetailedOrder)
+		orderGroup.POST("/:id/cancel", s.Router.CancelOrder)
+	}
+	ordersGroup := s.Engine.Group("/api/orders")
+	ordersGroup.Use(s.jwtAuthChecker.JWTAuth())
+	{
+		ordersGroup.GET("", s.Router.ListOrders)
 	}
 }
 
@@ -2,7 +2,30 @@ package presenter
 
 // Payment response payload
 type Payment struct {
-	ID           uint64 `json:"id"`
-	CurrencyCode string `json:"currency_code"`
-	Amount       int64  `json:"amount"`
+	ID             uint64 `json:"id"`
+	CurrencyCode   string `json:"currency_code"`
+	Amount         int64  `json:"amount"`
+	RefundedAmount int64  `json:"refunded_amount"`
+}
+
+// RefundRequest payload
+// It is issued by authenticated support staff, who is recorded as the actor of the refund
+type RefundRequest struct {
+	Amount int64  `json:"amount" binding:"required,gt=0"`
+	Reason string `json:"reason" binding:"required,max=256"`
+}
+
+// Refund response payload
+type Refund struct {
+	ID        uint64 `json:"id"`
+	PaymentID uint64 `json:"payment_id"`
+	Amount    int64  `json:"amount"`
+	Reason    string `json:"reason"`
+	Actor     string `json:"actor"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// Refunds response payload
+type Refunds struct {
+	Refunds []Refund `json:"refunds"`
 }
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/minghsu0107/saga-product/config"
+	"github.com/minghsu0107/saga-product/domain/model"
 	"github.com/minghsu0107/saga-product/infra/http/payment/presenter"
 	common_presenter "github.com/minghsu0107/saga-product/infra/http/presenter"
 	paymentsvc "github.com/minghsu0107/saga-product/service/payment"
@@ -48,9 +49,10 @@ func (r *Router) GetPayment(c *gin.Context) {
 		return
 	case nil:
 		c.JSON(http.StatusOK, &presenter.Payment{
-			ID:           payment.ID,
-			CurrencyCode: payment.CurrencyCode,
-			Amount:       payment.Amount,
+			ID:             payment.ID,
+			CurrencyCode:   payment.CurrencyCode,
+			Amount:         payment.Amount,
+			RefundedAmount: payment.RefundedAmount,
 		})
 	default:
 		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
@@ -58,6 +60,104 @@ func (r *Router) GetPayment(c *gin.Context) {
 	}
 }
 
+// ListRefunds endpoint
+func (r *Router) ListRefunds(c *gin.Context) {
+	customerID, ok := c.Request.Context().Value(config.CustomerKey).(uint64)
+	if !ok {
+		response(c, http.StatusUnauthorized, common_presenter.ErrUnauthorized)
+		return
+	}
+
+	paymentID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+
+	refunds, err := r.paymentSvc.ListRefunds(c.Request.Context(), customerID, paymentID)
+	switch err {
+	case paymentsvc.ErrPaymentNotFound:
+		response(c, http.StatusNotFound, paymentsvc.ErrPaymentNotFound)
+		return
+	case paymentsvc.ErrUnauthorized:
+		response(c, http.StatusUnauthorized, common_presenter.ErrUnauthorized)
+		return
+	case nil:
+		presented := []presenter.Refund{}
+		for i := range *refunds {
+			presented = append(presented, *presentRefund(&(*refunds)[i]))
+		}
+		c.JSON(http.StatusOK, &presenter.Refunds{
+			Refunds: presented,
+		})
+	default:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+}
+
+// RefundPayment endpoint
+// It issues a partial or full refund on behalf of the authenticated support staff, and the Idempotency-Key header is required
+// Retrying with the same idempotency key returns the refund issued before with 200 instead of 201
+func (r *Router) RefundPayment(c *gin.Context) {
+	staffID, ok := c.Request.Context().Value(config.CustomerKey).(uint64)
+	if !ok {
+		response(c, http.StatusUnauthorized, common_presenter.ErrUnauthorized)
+		return
+	}
+
+	paymentID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	idempotencyKey := c.GetHeader(config.IdempotencyKeyHeader)
+	var req presenter.RefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil || idempotencyKey == "" {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+
+	issued, refund, err := r.paymentSvc.RefundPayment(c.Request.Context(), &model.Refund{
+		PaymentID:      paymentID,
+		IdempotencyKey: idempotencyKey,
+		Amount:         req.Amount,
+		Reason:         req.Reason,
+		Actor:          strconv.FormatUint(staffID, 10),
+	})
+	switch err {
+	case paymentsvc.ErrInvalidRefund:
+		response(c, http.StatusBadRequest, paymentsvc.ErrInvalidRefund)
+		return
+	case paymentsvc.ErrPaymentNotFound:
+		response(c, http.StatusNotFound, paymentsvc.ErrPaymentNotFound)
+		return
+	case paymentsvc.ErrRefundExceedsBalance, paymentsvc.ErrIdempotencyKeyConflict:
+		response(c, http.StatusConflict, err)
+		return
+	case nil:
+		if issued {
+			c.JSON(http.StatusCreated, presentRefund(refund))
+			return
+		}
+		c.JSON(http.StatusOK, presentRefund(refund))
+	default:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+}
+
+func presentRefund(refund *model.Refund) *presenter.Refund {
+	return &presenter.Refund{
+		ID:        refund.ID,
+		PaymentID: refund.PaymentID,
+		Amount:    refund.Amount,
+		Reason:    refund.Reason,
+		Actor:     refund.Actor,
+		CreatedAt: refund.CreatedAt.UnixMilli(),
+	}
+}
+
 func response(c *gin.Context, httpCode int, err error) {
 	message := err.Error()
 	c.JSON(httpCode, common_presenter.ErrResponse{
@@ -67,6 +67,12 @@ func (s *PaymentServer) RegisterRoutes() {
 	paymentGroup.Use(s.jwtAuthChecker.JWTAuth())
 	{
 		paymentGroup.GET("/:id", s.Router.GetPayment)
+		paymentGroup.GET("/:id/refunds", s.Router.ListRefunds)
+	}
+	staffGroup := s.Engine.Group("/api/payment")
+	staffGroup.Use(s.jwtAuthChecker.JWTAuth(), s.jwtAuthChecker.StaffAuth())
+	{
+		staffGroup.POST("/:id/refund", s.Router.RefundPayment)
 	}
 }
 
@@ -0,0 +1,216 @@
+package product
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/minghsu0107/saga-product/domain/model"
+	"github.com/minghsu0107/saga-product/infra/http/product/presenter"
+)
+
+const (
+	csvFormat    = "csv"
+	ndjsonFormat = "ndjson"
+	// maxImportBytes is the maximum size of an imported file
+	maxImportBytes = 32 << 20
+	// maxNDJSONLineBytes is the maximum size of an imported NDJSON line
+	maxNDJSONLineBytes = 1 << 20
+)
+
+var (
+	// errInvalidImportFile is invalid import file error
+	errInvalidImportFile = errors.New("invalid import file")
+
+	// productColumns are the CSV columns of exported products
+	productColumns = []string{"id", "name", "description", "brand_name", "price", "inventory", "reserved", "archived"}
+	// importColumns are the CSV columns required to import products; other columns are ignored
+	importColumns = []string{"name", "description", "brand_name", "price", "inventory"}
+)
+
+// productRow is a decoded row of an imported file, with either a product or the error decoding it
+type productRow struct {
+	row     int
+	product *model.Product
+	err     error
+}
+
+// decodeProductRows decodes all rows of an imported file
+// A malformed row is reported along with the other rows, while a malformed file fails as a whole
+func decodeProductRows(format string, r io.Reader) ([]productRow, error) {
+	if format == ndjsonFormat {
+		return decodeNDJSONRows(r)
+	}
+	return decodeCSVRows(r)
+}
+
+func decodeCSVRows(r io.Reader) ([]productRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidImportFile, err)
+	}
+	columns := make(map[string]int)
+	for i, column := range header {
+		columns[column] = i
+	}
+	for _, column := range importColumns {
+		if _, ok := columns[column]; !ok {
+			return nil, fmt.Errorf("%w: missing column %s", errInvalidImportFile, column)
+		}
+	}
+
+	var rows []productRow
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rows = append(rows, productRow{row: row, err: parseErr.Err})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidImportFile, err)
+		}
+		if len(record) != len(header) {
+			rows = append(rows, productRow{row: row, err: fmt.Errorf("expected %d fields, got %d", len(header), len(record))})
+			continue
+		}
+		product, err := decodeCSVProduct(record, columns)
+		rows = append(rows, productRow{row: row, product: product, err: err})
+	}
+	return rows, nil
+}
+
+func decodeCSVProduct(record []string, columns map[string]int) (*model.Product, error) {
+	price, err := strconv.ParseInt(record[columns["price"]], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid price: %s", record[columns["price"]])
+	}
+	inventory, err := strconv.ParseInt(record[columns["inventory"]], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid inventory: %s", record[columns["inventory"]])
+	}
+	return &model.Product{
+		Detail: &model.ProductDetail{
+			Name:        record[columns["name"]],
+			Description: record[columns["description"]],
+			BrandName:   record[columns["brand_name"]],
+			Price:       price,
+		},
+		Inventory: inventory,
+	}, nil
+}
+
+func decodeNDJSONRows(r io.Reader) ([]productRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxNDJSONLineBytes)
+	var rows []productRow
+	row := 0
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		row++
+		var record presenter.ProductRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			rows = append(rows, productRow{row: row, err: err})
+			continue
+		}
+		rows = append(rows, productRow{
+			row: row,
+			product: &model.Product{
+				Detail: &model.ProductDetail{
+					Name:        record.Name,
+					Description: record.Description,
+					BrandName:   record.BrandName,
+					Price:       record.Price,
+				},
+				Inventory: record.Inventory,
+			},
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidImportFile, err)
+	}
+	return rows, nil
+}
+
+// productEncoder writes exported products in either format
+type productEncoder struct {
+	format string
+	csv    *csv.Writer
+	json   *json.Encoder
+}
+
+func newProductEncoder(format string, w io.Writer) *productEncoder {
+	if format == ndjsonFormat {
+		return &productEncoder{
+			format: format,
+			json:   json.NewEncoder(w),
+		}
+	}
+	return &productEncoder{
+		format: csvFormat,
+		csv:    csv.NewWriter(w),
+	}
+}
+
+// WriteHeader writes the CSV header line; NDJSON has no header
+func (e *productEncoder) WriteHeader() error {
+	if e.format == ndjsonFormat {
+		return nil
+	}
+	return e.csv.Write(productColumns)
+}
+
+// Write writes the products; CSV lines are buffered until Flush
+func (e *productEncoder) Write(products *[]model.Product) error {
+	for _, product := range *products {
+		if e.format == ndjsonFormat {
+			if err := e.json.Encode(&presenter.ProductRecord{
+				ID:          product.ID,
+				Name:        product.Detail.Name,
+				Description: product.Detail.Description,
+				BrandName:   product.Detail.BrandName,
+				Price:       product.Detail.Price,
+				Inventory:   product.Inventory,
+				Reserved:    product.Reserved,
+				Archived:    product.Detail.Archived,
+			}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := e.csv.Write([]string{
+			strconv.FormatUint(product.ID, 10),
+			product.Detail.Name,
+			product.Detail.Description,
+			product.Detail.BrandName,
+			strconv.FormatInt(product.Detail.Price, 10),
+			strconv.FormatInt(product.Inventory, 10),
+			strconv.FormatInt(product.Reserved, 10),
+			strconv.FormatBool(product.Detail.Archived),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush flushes buffered CSV lines
+func (e *productEncoder) Flush() error {
+	if e.format == ndjsonFormat {
+		return nil
+	}
+	e.csv.Flush()
+	return e.csv.Error()
+}
@@ -2,12 +2,64 @@ package presenter
 
 // Product payload
 type Product struct {
-	ID          uint64 `json:"id"`
+	ID          uint64     `json:"id"`
+	Name        string     `json:"name" binding:"required"`
+	Description string     `json:"description" binding:"required"`
+	BrandName   string     `json:"brand_name" binding:"required"`
+	Price       int64      `json:"price" binding:"required"`
+	Inventory   int64      `json:"inventory" binding:"required"`
+	Reserved    int64      `json:"reserved"`
+	Archived    bool       `json:"archived"`
+	Categories  []Category `json:"categories"`
+	Variants    []Variant  `json:"variants"`
+}
+
+// Variant payload
+type Variant struct {
+	ID        uint64 `json:"id"`
+	ProductID uint64 `json:"product_id"`
+	SKU       string `json:"sku" binding:"required,max=64"`
+	Name      string `json:"name" binding:"required,max=256"`
+	Price     int64  `json:"price" binding:"min=0"`
+	Inventory int64  `json:"inventory" binding:"min=0"`
+	Reserved  int64  `json:"reserved"`
+	Archived  bool   `json:"archived"`
+}
+
+// VariantPatch payload
+type VariantPatch struct {
+	SKU      *string `json:"sku" binding:"omitempty,min=1,max=64"`
+	Name     *string `json:"name" binding:"omitempty,min=1,max=256"`
+	Price    *int64  `json:"price" binding:"omitempty,min=0"`
+	Archived *bool   `json:"archived"`
+}
+
+// VariantCreation response payload
+type VariantCreation struct {
+	ID uint64 `json:"id"`
+}
+
+// Variants response payload
+type Variants struct {
+	Variants []Variant `json:"variants"`
+}
+
+// ProductReplacement payload
+type ProductReplacement struct {
 	Name        string `json:"name" binding:"required"`
 	Description string `json:"description" binding:"required"`
 	BrandName   string `json:"brand_name" binding:"required"`
 	Price       int64  `json:"price" binding:"required"`
-	Inventory   int64  `json:"inventory" binding:"required"`
+	Archived    bool   `json:"archived"`
+}
+
+// ProductPatch payload
+type ProductPatch struct {
+	Name        *string `json:"name" binding:"omitempty,min=1"`
+	Description *string `json:"description" binding:"omitempty,min=1"`
+	BrandName   *string `json:"brand_name" binding:"omitempty,min=1"`
+	Price       *int64  `json:"price" binding:"omitempty,min=1"`
+	Archived    *bool   `json:"archived"`
 }
 
 // ProductCreation response payload
@@ -17,7 +69,8 @@ type ProductCreation struct {
 
 // ProductCatalogs response payload
 type ProductCatalogs struct {
-	Catalogs []Productcatalog `json:"catalogs"`
+	Catalogs   []Productcatalog `json:"catalogs"`
+	NextCursor string           `json:"next_cursor,omitempty"`
 }
 
 // Product catalog payload
@@ -25,11 +78,162 @@ type Productcatalog struct {
 	ID        uint64 `json:"id"`
 	Name      string `json:"name"`
 	Inventory int64  `json:"inventory"`
+	Reserved  int64  `json:"reserved"`
 	Price     int64  `json:"price"`
 }
 
 // Pagination payload
+// Cursor is the next_cursor of a previous page, which replaces offset
 type Pagination struct {
-	Offset int `form:"offset" binding:"numeric,min=0"`
-	Size   int `form:"size" binding:"required,numeric,min=1,max=500"`
+	Offset int    `form:"offset" binding:"numeric,min=0"`
+	Size   int    `form:"size" binding:"required,numeric,min=1,max=500"`
+	Cursor string `form:"cursor" binding:"omitempty,max=256"`
+}
+
+// ProductQuery payload
+type ProductQuery struct {
+	Pagination
+	Keyword    string `form:"q" binding:"omitempty,max=256"`
+	BrandName  string `form:"brand" binding:"omitempty,max=256"`
+	CategoryID uint64 `form:"category"`
+	MinPrice   int64  `form:"min_price" binding:"omitempty,min=0"`
+	MaxPrice   int64  `form:"max_price" binding:"omitempty,min=0"`
+	InStock    bool   `form:"in_stock"`
+	Sort       string `form:"sort" binding:"omitempty,oneof=price name created_at"`
+	Order      string `form:"order" binding:"omitempty,oneof=asc desc"`
+}
+
+// Category payload
+type Category struct {
+	ID       uint64 `json:"id"`
+	ParentID uint64 `json:"parent_id"`
+	Name     string `json:"name" binding:"required,max=256"`
+}
+
+// CategoryPatch payload
+type CategoryPatch struct {
+	Name     *string `json:"name" binding:"omitempty,min=1,max=256"`
+	ParentID *uint64 `json:"parent_id"`
+}
+
+// CategoryCreation response payload
+type CategoryCreation struct {
+	ID uint64 `json:"id"`
+}
+
+// Categories response payload
+type Categories struct {
+	Categories []Category `json:"categories"`
+}
+
+// ProductCategories payload
+// An empty list removes the product from all categories
+type ProductCategories struct {
+	CategoryIDs []uint64 `json:"category_ids" binding:"max=100"`
+}
+
+// InventoryAdjustment payload
+// A non-zero variant_id adjusts the inventory of the variant instead of the product
+type InventoryAdjustment struct {
+	VariantID uint64 `json:"variant_id"`
+	Delta     int64  `json:"delta" binding:"required"`
+	Reason    string `json:"reason" binding:"required,oneof=restock shrinkage correction"`
+	Actor     string `json:"actor" binding:"required"`
+}
+
+// InventoryLedger response payload
+type InventoryLedger struct {
+	Entries []InventoryLedgerEntry `json:"entries"`
+}
+
+// InventoryLedgerEntry payload
+type InventoryLedgerEntry struct {
+	ID        uint64 `json:"id"`
+	ProductID uint64 `json:"product_id"`
+	VariantID uint64 `json:"variant_id"`
+	Delta     int64  `json:"delta"`
+	Reason    string `json:"reason"`
+	Actor     string `json:"actor"`
+	Inventory int64  `json:"inventory"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// PriceQuery payload
+// It looks up the price in effect at a time in unix milliseconds, of the variant if variant_id is not zero
+type PriceQuery struct {
+	VariantID uint64 `form:"variant_id"`
+	At        int64  `form:"at" binding:"required,min=1"`
+}
+
+// Price payload
+// EffectiveAt is the time in unix milliseconds from which the price is charged
+type Price struct {
+	ProductID   uint64 `json:"product_id"`
+	VariantID   uint64 `json:"variant_id"`
+	Price       int64  `json:"price"`
+	EffectiveAt int64  `json:"effective_at"`
+}
+
+// PriceHistory response payload
+type PriceHistory struct {
+	Prices []Price `json:"prices"`
+}
+
+// PriceChange payload
+// A non-zero variant_id changes the price of the variant instead of the product at effective_at in unix milliseconds
+type PriceChange struct {
+	ID          uint64 `json:"id"`
+	VariantID   uint64 `json:"variant_id"`
+	Price       int64  `json:"price" binding:"min=0"`
+	EffectiveAt int64  `json:"effective_at" binding:"required,min=1"`
+}
+
+// PriceChangeCreation response payload
+type PriceChangeCreation struct {
+	ID uint64 `json:"id"`
+}
+
+// PriceChanges response payload
+type PriceChanges struct {
+	Changes []PriceChange `json:"changes"`
+}
+
+// BulkFormat payload
+// The format of bulk imports and exports, which are CSV files with a header line or NDJSON files of product records
+type BulkFormat struct {
+	Format string `form:"format" binding:"omitempty,oneof=csv ndjson"`
+}
+
+// ProductRecord payload
+// It is a line of NDJSON imports and exports; id, reserved and archived are only exported and ignored on import
+type ProductRecord struct {
+	ID          uint64 `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	BrandName   string `json:"brand_name"`
+	Price       int64  `json:"price"`
+	Inventory   int64  `json:"inventory"`
+	Reserved    int64  `json:"reserved"`
+	Archived    bool   `json:"archived"`
+}
+
+// ProductImportReport response payload
+// Rows are numbered from 1, excluding the CSV header line and blank NDJSON lines
+type ProductImportReport struct {
+	Imported int                  `json:"imported"`
+	Failed   int                  `json:"failed"`
+	Products []ImportedProduct    `json:"products"`
+	Errors   []ProductImportError `json:"errors"`
+}
+
+// ImportedProduct payload
+type ImportedProduct struct {
+	Row int    `json:"row"`
+	ID  uint64 `json:"id"`
+}
+
+// ProductImportError payload
+type ProductImportError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
 }
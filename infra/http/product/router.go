@@ -1,8 +1,11 @@
 package product
 
 import (
+	"errors"
 	"net/http"
+	"sort"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/minghsu0107/saga-product/domain/model"
@@ -13,38 +16,57 @@ import (
 
 // Router wraps http handlers
 type Router struct {
-	productSvc productsvc.ProductService
+	productSvc  productsvc.ProductService
+	categorySvc productsvc.CategoryService
 }
 
 // NewRouter is a factory for router instance
-func NewRouter(productSvc productsvc.ProductService) *Router {
+func NewRouter(productSvc productsvc.ProductService, categorySvc productsvc.CategoryService) *Router {
 	return &Router{
-		productSvc: productSvc,
+		productSvc:  productSvc,
+		categorySvc: categorySvc,
 	}
 }
 
 // ListProducts endpoint
 func (r *Router) ListProducts(c *gin.Context) {
-	var pagination presenter.Pagination
-	if err := c.ShouldBindQuery(&pagination); err != nil {
+	var query presenter.ProductQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
 		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
 		return
 	}
-	catalogs, err := r.productSvc.ListProducts(c.Request.Context(), pagination.Offset, pagination.Size)
+	page, err := r.productSvc.ListProducts(c.Request.Context(), &model.ProductQuery{
+		Keyword:    query.Keyword,
+		BrandName:  query.BrandName,
+		CategoryID: query.CategoryID,
+		MinPrice:   query.MinPrice,
+		MaxPrice:   query.MaxPrice,
+		InStock:    query.InStock,
+		SortBy:     model.ProductSortField(query.Sort),
+		Descending: query.Order == "desc",
+		Offset:     query.Offset,
+		Size:       query.Size,
+		Cursor:     query.Cursor,
+	})
 	switch err {
 	case nil:
 		var productCatalogs []presenter.Productcatalog
-		for _, catalog := range *catalogs {
+		for _, catalog := range page.Catalogs {
 			productCatalogs = append(productCatalogs, presenter.Productcatalog{
 				ID:        catalog.ID,
 				Name:      catalog.Name,
 				Inventory: catalog.Inventory,
+				Reserved:  catalog.Reserved,
 				Price:     catalog.Price,
 			})
 		}
 		c.JSON(http.StatusOK, &presenter.ProductCatalogs{
-			Catalogs: productCatalogs,
+			Catalogs:   productCatalogs,
+			NextCursor: page.NextCursor,
 		})
+	case productsvc.ErrInvalidProductQuery, productsvc.ErrInvalidCursor:
+		response(c, http.StatusBadRequest, err)
+		return
 	default:
 		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
 		return
@@ -77,6 +99,10 @@ func (r *Router) GetProduct(c *gin.Context) {
 			BrandName:   product.Detail.BrandName,
 			Price:       product.Detail.Price,
 			Inventory:   product.Inventory,
+			Reserved:    product.Reserved,
+			Archived:    product.Detail.Archived,
+			Categories:  encodeCategories(product.Categories),
+			Variants:    encodeVariants(product.Variants),
 		})
 	default:
 		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
@@ -112,6 +138,709 @@ func (r *Router) CreateProduct(c *gin.Context) {
 	}
 }
 
+// ImportProducts endpoint
+// The file format is given by the format query, or by the content type for NDJSON files
+func (r *Router) ImportProducts(c *gin.Context) {
+	var format presenter.BulkFormat
+	if err := c.ShouldBindQuery(&format); err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	if format.Format == "" {
+		format.Format = csvFormat
+		switch c.ContentType() {
+		case "application/x-ndjson", "application/ndjson":
+			format.Format = ndjsonFormat
+		}
+	}
+	rows, err := decodeProductRows(format.Format, http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes))
+	if err != nil {
+		if errors.Is(err, errInvalidImportFile) {
+			response(c, http.StatusBadRequest, err)
+			return
+		}
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+
+	report := &presenter.ProductImportReport{
+		Products: []presenter.ImportedProduct{},
+		Errors:   []presenter.ProductImportError{},
+	}
+	var products []model.Product
+	var productRows []int
+	for _, row := range rows {
+		if row.err != nil {
+			report.Errors = append(report.Errors, presenter.ProductImportError{
+				Row:   row.row,
+				Error: row.err.Error(),
+			})
+			continue
+		}
+		products = append(products, *row.product)
+		productRows = append(productRows, row.row)
+	}
+	result, err := r.productSvc.ImportProducts(c.Request.Context(), &products)
+	switch err {
+	case productsvc.ErrTooManyProducts:
+		response(c, http.StatusRequestEntityTooLarge, productsvc.ErrTooManyProducts)
+		return
+	case nil:
+	default:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+	for _, importErr := range result.Errors {
+		report.Errors = append(report.Errors, presenter.ProductImportError{
+			Row:   productRows[importErr.Index],
+			Error: importErr.Err.Error(),
+		})
+	}
+	for i, productID := range result.ProductIDs {
+		if productID == 0 {
+			continue
+		}
+		report.Products = append(report.Products, presenter.ImportedProduct{
+			Row: productRows[i],
+			ID:  productID,
+		})
+	}
+	sort.Slice(report.Errors, func(i, j int) bool { return report.Errors[i].Row < report.Errors[j].Row })
+	report.Imported = len(report.Products)
+	report.Failed = len(report.Errors)
+	c.JSON(http.StatusOK, report)
+}
+
+// ExportProducts endpoint
+// Products are streamed in batches, so an error after the first batch can only end the response early
+func (r *Router) ExportProducts(c *gin.Context) {
+	var format presenter.BulkFormat
+	if err := c.ShouldBindQuery(&format); err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	contentType := "text/csv"
+	if format.Format == ndjsonFormat {
+		contentType = "application/x-ndjson"
+	}
+	encoder := newProductEncoder(format.Format, c.Writer)
+	started := false
+	err := r.productSvc.ExportProducts(c.Request.Context(), func(products *[]model.Product) error {
+		if !started {
+			started = true
+			c.Header("Content-Type", contentType)
+			c.Status(http.StatusOK)
+			if err := encoder.WriteHeader(); err != nil {
+				return err
+			}
+		}
+		if err := encoder.Write(products); err != nil {
+			return err
+		}
+		if err := encoder.Flush(); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil {
+		if !started {
+			response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		}
+		return
+	}
+	if !started {
+		c.Header("Content-Type", contentType)
+		c.Status(http.StatusOK)
+		if err := encoder.WriteHeader(); err == nil {
+			encoder.Flush()
+		}
+	}
+}
+
+// ReplaceProduct endpoint
+func (r *Router) ReplaceProduct(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	var product presenter.ProductReplacement
+	if err := c.ShouldBindJSON(&product); err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	r.updateProduct(c, productID, &model.ProductUpdate{
+		Name:        &product.Name,
+		Description: &product.Description,
+		BrandName:   &product.BrandName,
+		Price:       &product.Price,
+		Archived:    &product.Archived,
+	})
+}
+
+// PatchProduct endpoint
+func (r *Router) PatchProduct(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	var patch presenter.ProductPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	r.updateProduct(c, productID, &model.ProductUpdate{
+		Name:        patch.Name,
+		Description: patch.Description,
+		BrandName:   patch.BrandName,
+		Price:       patch.Price,
+		Archived:    patch.Archived,
+	})
+}
+
+func (r *Router) updateProduct(c *gin.Context, productID uint64, update *model.ProductUpdate) {
+	err := r.productSvc.UpdateProduct(c.Request.Context(), productID, update)
+	switch err {
+	case productsvc.ErrProductNotFound:
+		response(c, http.StatusNotFound, productsvc.ErrProductNotFound)
+		return
+	case nil:
+		c.Status(http.StatusNoContent)
+		return
+	default:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+}
+
+// DeleteProduct endpoint
+func (r *Router) DeleteProduct(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	err = r.productSvc.DeleteProduct(c.Request.Context(), productID)
+	switch err {
+	case productsvc.ErrProductNotFound:
+		response(c, http.StatusNotFound, productsvc.ErrProductNotFound)
+		return
+	case nil:
+		c.Status(http.StatusNoContent)
+		return
+	default:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+}
+
+// ListVariants endpoint
+func (r *Router) ListVariants(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	variants, err := r.productSvc.ListVariants(c.Request.Context(), productID)
+	if err != nil {
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+	c.JSON(http.StatusOK, &presenter.Variants{
+		Variants: encodeVariants(*variants),
+	})
+}
+
+// CreateVariant endpoint
+func (r *Router) CreateVariant(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	var variant presenter.Variant
+	if err := c.ShouldBindJSON(&variant); err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	variantID, err := r.productSvc.CreateVariant(c.Request.Context(), &model.ProductVariant{
+		ProductID: productID,
+		SKU:       variant.SKU,
+		Name:      variant.Name,
+		Price:     variant.Price,
+		Inventory: variant.Inventory,
+	})
+	switch err {
+	case productsvc.ErrProductNotFound:
+		response(c, http.StatusNotFound, productsvc.ErrProductNotFound)
+		return
+	case productsvc.ErrInvalidVariant:
+		response(c, http.StatusBadRequest, productsvc.ErrInvalidVariant)
+		return
+	case productsvc.ErrDuplicateSKU:
+		response(c, http.StatusConflict, productsvc.ErrDuplicateSKU)
+		return
+	case nil:
+		c.JSON(http.StatusCreated, &presenter.VariantCreation{
+			ID: variantID,
+		})
+	default:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+}
+
+// PatchVariant endpoint
+func (r *Router) PatchVariant(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	variantID, err := strconv.ParseUint(c.Param("variant_id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	var patch presenter.VariantPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	err = r.productSvc.UpdateVariant(c.Request.Context(), productID, variantID, &model.ProductVariantUpdate{
+		SKU:      patch.SKU,
+		Name:     patch.Name,
+		Price:    patch.Price,
+		Archived: patch.Archived,
+	})
+	switch err {
+	case productsvc.ErrVariantNotFound:
+		response(c, http.StatusNotFound, productsvc.ErrVariantNotFound)
+		return
+	case productsvc.ErrInvalidVariant:
+		response(c, http.StatusBadRequest, productsvc.ErrInvalidVariant)
+		return
+	case productsvc.ErrDuplicateSKU:
+		response(c, http.StatusConflict, productsvc.ErrDuplicateSKU)
+		return
+	case nil:
+		c.Status(http.StatusNoContent)
+	default:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+}
+
+// AdjustInventory endpoint
+func (r *Router) AdjustInventory(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	var adjustment presenter.InventoryAdjustment
+	if err := c.ShouldBindJSON(&adjustment); err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	entry, err := r.productSvc.AdjustInventory(c.Request.Context(), &model.InventoryAdjustment{
+		ProductID: productID,
+		VariantID: adjustment.VariantID,
+		Delta:     adjustment.Delta,
+		Reason:    model.AdjustmentReason(adjustment.Reason),
+		Actor:     adjustment.Actor,
+	})
+	switch err {
+	case productsvc.ErrInvalidAdjustment:
+		response(c, http.StatusBadRequest, productsvc.ErrInvalidAdjustment)
+		return
+	case productsvc.ErrProductNotFound, productsvc.ErrVariantNotFound:
+		response(c, http.StatusNotFound, err)
+		return
+	case productsvc.ErrInsuffientInventory:
+		response(c, http.StatusConflict, productsvc.ErrInsuffientInventory)
+		return
+	case nil:
+		c.JSON(http.StatusCreated, presentInventoryLedgerEntry(entry))
+		return
+	default:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+}
+
+// ListInventoryLedger endpoint
+func (r *Router) ListInventoryLedger(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	var pagination presenter.Pagination
+	if err := c.ShouldBindQuery(&pagination); err != nil || pagination.Cursor != "" {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	ledger, err := r.productSvc.ListInventoryLedger(c.Request.Context(), productID, pagination.Offset, pagination.Size)
+	switch err {
+	case nil:
+		entries := []presenter.InventoryLedgerEntry{}
+		for i := range *ledger {
+			entries = append(entries, *presentInventoryLedgerEntry(&(*ledger)[i]))
+		}
+		c.JSON(http.StatusOK, &presenter.InventoryLedger{
+			Entries: entries,
+		})
+	default:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+}
+
+func presentInventoryLedgerEntry(entry *model.InventoryLedgerEntry) *presenter.InventoryLedgerEntry {
+	return &presenter.InventoryLedgerEntry{
+		ID:        entry.ID,
+		ProductID: entry.ProductID,
+		VariantID: entry.VariantID,
+		Delta:     entry.Delta,
+		Reason:    string(entry.Reason),
+		Actor:     entry.Actor,
+		Inventory: entry.Inventory,
+		CreatedAt: entry.CreatedAt.UnixMilli(),
+	}
+}
+
+// GetPrice endpoint
+func (r *Router) GetPrice(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	var query presenter.PriceQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	prices, err := r.productSvc.GetPricesAt(c.Request.Context(), time.UnixMilli(query.At), &[]model.PurchasedItem{
+		{
+			ProductID: productID,
+			VariantID: query.VariantID,
+		},
+	})
+	switch {
+	case err != nil:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	case len(*prices) == 0:
+		response(c, http.StatusNotFound, productsvc.ErrPriceNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, presentPrice(&(*prices)[0]))
+}
+
+// ListPriceHistory endpoint
+func (r *Router) ListPriceHistory(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	var pagination presenter.Pagination
+	if err := c.ShouldBindQuery(&pagination); err != nil || pagination.Cursor != "" {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	history, err := r.productSvc.ListPriceHistory(c.Request.Context(), productID, pagination.Offset, pagination.Size)
+	switch err {
+	case nil:
+		prices := []presenter.Price{}
+		for i := range *history {
+			prices = append(prices, *presentPrice(&(*history)[i]))
+		}
+		c.JSON(http.StatusOK, &presenter.PriceHistory{
+			Prices: prices,
+		})
+	default:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+}
+
+func presentPrice(price *model.ProductPrice) *presenter.Price {
+	return &presenter.Price{
+		ProductID:   price.ProductID,
+		VariantID:   price.VariantID,
+		Price:       price.Price,
+		EffectiveAt: price.EffectiveAt.UnixMilli(),
+	}
+}
+
+// SchedulePriceChange endpoint
+func (r *Router) SchedulePriceChange(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	var change presenter.PriceChange
+	if err := c.ShouldBindJSON(&change); err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	changeID, err := r.productSvc.SchedulePriceChange(c.Request.Context(), &model.PriceChange{
+		ProductID:   productID,
+		VariantID:   change.VariantID,
+		Price:       change.Price,
+		EffectiveAt: time.UnixMilli(change.EffectiveAt),
+	})
+	switch err {
+	case productsvc.ErrProductNotFound:
+		response(c, http.StatusNotFound, productsvc.ErrProductNotFound)
+		return
+	case productsvc.ErrVariantNotFound:
+		response(c, http.StatusNotFound, productsvc.ErrVariantNotFound)
+		return
+	case productsvc.ErrInvalidPriceChange:
+		response(c, http.StatusBadRequest, productsvc.ErrInvalidPriceChange)
+		return
+	case nil:
+		c.JSON(http.StatusCreated, &presenter.PriceChangeCreation{
+			ID: changeID,
+		})
+	default:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+}
+
+// ListPriceChanges endpoint
+func (r *Router) ListPriceChanges(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	changes, err := r.productSvc.ListPriceChanges(c.Request.Context(), productID)
+	switch err {
+	case nil:
+		presentedChanges := []presenter.PriceChange{}
+		for _, change := range *changes {
+			presentedChanges = append(presentedChanges, presenter.PriceChange{
+				ID:          change.ID,
+				VariantID:   change.VariantID,
+				Price:       change.Price,
+				EffectiveAt: change.EffectiveAt.UnixMilli(),
+			})
+		}
+		c.JSON(http.StatusOK, &presenter.PriceChanges{
+			Changes: presentedChanges,
+		})
+	default:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+}
+
+// CancelPriceChange endpoint
+func (r *Router) CancelPriceChange(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	changeID, err := strconv.ParseUint(c.Param("change_id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	err = r.productSvc.CancelPriceChange(c.Request.Context(), productID, changeID)
+	switch err {
+	case productsvc.ErrPriceChangeNotFound:
+		response(c, http.StatusNotFound, productsvc.ErrPriceChangeNotFound)
+		return
+	case nil:
+		c.Status(http.StatusNoContent)
+	default:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+}
+
+// ListCategories endpoint
+func (r *Router) ListCategories(c *gin.Context) {
+	categories, err := r.categorySvc.ListCategories(c.Request.Context())
+	if err != nil {
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+	c.JSON(http.StatusOK, &presenter.Categories{
+		Categories: encodeCategories(*categories),
+	})
+}
+
+// GetCategory endpoint
+func (r *Router) GetCategory(c *gin.Context) {
+	categoryID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	category, err := r.categorySvc.GetCategory(c.Request.Context(), categoryID)
+	switch err {
+	case productsvc.ErrCategoryNotFound:
+		response(c, http.StatusNotFound, productsvc.ErrCategoryNotFound)
+		return
+	case nil:
+		c.JSON(http.StatusOK, &presenter.Category{
+			ID:       category.ID,
+			ParentID: category.ParentID,
+			Name:     category.Name,
+		})
+	default:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+}
+
+// CreateCategory endpoint
+func (r *Router) CreateCategory(c *gin.Context) {
+	var category presenter.Category
+	if err := c.ShouldBindJSON(&category); err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	categoryID, err := r.categorySvc.CreateCategory(c.Request.Context(), &model.Category{
+		ParentID: category.ParentID,
+		Name:     category.Name,
+	})
+	switch err {
+	case productsvc.ErrInvalidCategory, productsvc.ErrInvalidCategoryParent:
+		response(c, http.StatusBadRequest, err)
+		return
+	case nil:
+		c.JSON(http.StatusCreated, &presenter.CategoryCreation{
+			ID: categoryID,
+		})
+	default:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+}
+
+// PatchCategory endpoint
+func (r *Router) PatchCategory(c *gin.Context) {
+	categoryID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	var patch presenter.CategoryPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	err = r.categorySvc.UpdateCategory(c.Request.Context(), categoryID, &model.CategoryUpdate{
+		Name:     patch.Name,
+		ParentID: patch.ParentID,
+	})
+	switch err {
+	case productsvc.ErrCategoryNotFound:
+		response(c, http.StatusNotFound, productsvc.ErrCategoryNotFound)
+		return
+	case productsvc.ErrInvalidCategory, productsvc.ErrInvalidCategoryParent:
+		response(c, http.StatusBadRequest, err)
+		return
+	case nil:
+		c.Status(http.StatusNoContent)
+	default:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+}
+
+// DeleteCategory endpoint
+func (r *Router) DeleteCategory(c *gin.Context) {
+	categoryID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	err = r.categorySvc.DeleteCategory(c.Request.Context(), categoryID)
+	switch err {
+	case productsvc.ErrCategoryNotFound:
+		response(c, http.StatusNotFound, productsvc.ErrCategoryNotFound)
+		return
+	case productsvc.ErrCategoryHasChildren:
+		response(c, http.StatusConflict, productsvc.ErrCategoryHasChildren)
+		return
+	case nil:
+		c.Status(http.StatusNoContent)
+	default:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+}
+
+// SetProductCategories endpoint
+func (r *Router) SetProductCategories(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	var productCategories presenter.ProductCategories
+	if err := c.ShouldBindJSON(&productCategories); err != nil {
+		response(c, http.StatusBadRequest, common_presenter.ErrInvalidParam)
+		return
+	}
+	err = r.categorySvc.SetProductCategories(c.Request.Context(), productID, productCategories.CategoryIDs)
+	switch err {
+	case productsvc.ErrProductNotFound:
+		response(c, http.StatusNotFound, productsvc.ErrProductNotFound)
+		return
+	case productsvc.ErrCategoryNotFound:
+		response(c, http.StatusBadRequest, productsvc.ErrCategoryNotFound)
+		return
+	case nil:
+		c.Status(http.StatusNoContent)
+	default:
+		response(c, http.StatusInternalServerError, common_presenter.ErrServer)
+		return
+	}
+}
+
+func encodeCategories(categories []model.Category) []presenter.Category {
+	presenterCategories := []presenter.Category{}
+	for _, category := range categories {
+		presenterCategories = append(presenterCategories, presenter.Category{
+			ID:       category.ID,
+			ParentID: category.ParentID,
+			Name:     category.Name,
+		})
+	}
+	return presenterCategories
+}
+
+func encodeVariants(variants []model.ProductVariant) []presenter.Variant {
+	presenterVariants := []presenter.Variant{}
+	for _, variant := range variants {
+		presenterVariants = append(presenterVariants, presenter.Variant{
+			ID:        variant.ID,
+			ProductID: variant.ProductID,
+			SKU:       variant.SKU,
+			Name:      variant.Name,
+			Price:     variant.Price,
+			Inventory: variant.Inventory,
+			Reserved:  variant.Reserved,
+			Archived:  variant.Archived,
+		})
+	}
+	return presenterVariants
+}
+
 func response(c *gin.Context, httpCode int, err error) {
 	message := err.Error()
 	c.JSON(httpCode, common_presenter.ErrResponse{
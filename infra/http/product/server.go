@@ -65,7 +65,28 @@ func (s *ProductServer) RegisterRoutes() {
 	{
 		apiGroup.GET("/product/:id", s.Router.GetProduct)
 		apiGroup.GET("/products", s.Router.ListProducts)
+		apiGroup.POST("/products/import", s.Router.ImportProducts)
+		apiGroup.GET("/products/export", s.Router.ExportProducts)
 		apiGroup.POST("/product", s.Router.CreateProduct)
+		apiGroup.PUT("/product/:id", s.Router.ReplaceProduct)
+		apiGroup.PATCH("/product/:id", s.Router.PatchProduct)
+		apiGroup.DELETE("/product/:id", s.Router.DeleteProduct)
+		apiGroup.GET("/product/:id/variants", s.Router.ListVariants)
+		apiGroup.POST("/product/:id/variant", s.Router.CreateVariant)
+		apiGroup.PATCH("/product/:id/variant/:variant_id", s.Router.PatchVariant)
+		apiGroup.POST("/product/:id/adjustment", s.Router.AdjustInventory)
+		apiGroup.GET("/product/:id/ledger", s.Router.ListInventoryLedger)
+		apiGroup.GET("/product/:id/price", s.Router.GetPrice)
+		apiGroup.GET("/product/:id/prices", s.Router.ListPriceHistory)
+		apiGroup.GET("/product/:id/price-changes", s.Router.ListPriceChanges)
+		apiGroup.POST("/product/:id/price-change", s.Router.SchedulePriceChange)
+		apiGroup.DELETE("/product/:id/price-change/:change_id", s.Router.CancelPriceChange)
+		apiGroup.PUT("/product/:id/categories", s.Router.SetProductCategories)
+		apiGroup.GET("/categories", s.Router.ListCategories)
+		apiGroup.GET("/category/:id", s.Router.GetCategory)
+		apiGroup.POST("/category", s.Router.CreateCategory)
+		apiGroup.PATCH("/category/:id", s.Router.PatchCategory)
+		apiGroup.DELETE("/category/:id", s.Router.DeleteCategory)
 	}
 }
 
@@ -0,0 +1,121 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Job interface
+type Job interface {
+	Run() error
+	GracefulStop() error
+}
+
+// Ticker runs a task periodically until it is stopped
+type Ticker struct {
+	interval time.Duration
+	task     func(ctx context.Context) error
+	logger   *log.Entry
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// NewTicker is the factory of Ticker
+func NewTicker(interval time.Duration, task func(ctx context.Context) error, logger *log.Entry) *Ticker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Ticker{
+		interval: interval,
+		task:     task,
+		logger:   logger,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+}
+
+// Run blocks and runs the task on every tick; a failed run is logged and retried on the next tick
+func (t *Ticker) Run() error {
+	defer close(t.done)
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := t.task(t.ctx); err != nil {
+				t.logger.Error(err)
+			}
+		}
+	}
+}
+
+// GracefulStop stops the ticker and waits for the running task to finish
+func (t *Ticker) GracefulStop() error {
+	t.cancel()
+	<-t.done
+	return nil
+}
+
+// Loop runs a task back to back until it is stopped, for a task that blocks until there is work to do
+// A failed run is logged and retried after a backoff doubling up to maxBackoff, which a successful run resets
+type Loop struct {
+	task       func(ctx context.Context) error
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	logger     *log.Entry
+	ctx        context.Context
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+// NewLoop is the factory of Loop
+func NewLoop(task func(ctx context.Context) error, minBackoff, maxBackoff time.Duration, logger *log.Entry) *Loop {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Loop{
+		task:       task,
+		minBackoff: minBackoff,
+		maxBackoff: maxBackoff,
+		logger:     logger,
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+}
+
+// Run blocks and runs the task again as soon as it returns
+func (l *Loop) Run() error {
+	defer close(l.done)
+	backoff := l.minBackoff
+	for {
+		err := l.task(l.ctx)
+		if l.ctx.Err() != nil {
+			return nil
+		}
+		if err == nil {
+			backoff = l.minBackoff
+			continue
+		}
+		l.logger.Error(err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-l.ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > l.maxBackoff {
+			backoff = l.maxBackoff
+		}
+	}
+}
+
+// GracefulStop stops the loop and waits for the running task to finish
+func (l *Loop) GracefulStop() error {
+	l.cancel()
+	<-l.done
+	return nil
+}
@@ -0,0 +1,26 @@
+package orchestrator
+
+import (
+	"time"
+
+	conf "github.com/minghsu0107/saga-product/config"
+	"github.com/minghsu0107/saga-product/infra/job"
+	"github.com/minghsu0107/saga-product/service/orchestrator"
+	log "github.com/sirupsen/logrus"
+)
+
+// PurchaseResultJob dispatches purchase results from the result stream to their subscribers
+// Each run blocks on the stream until results arrive, so runs follow each other back to back, backing off while the stream fails
+type PurchaseResultJob struct {
+	*job.Loop
+}
+
+// NewPurchaseResultJob factory
+func NewPurchaseResultJob(config *conf.Config, resultSvc orchestrator.PurchaseResultService) *PurchaseResultJob {
+	logger := config.Logger.ContextLogger.WithFields(log.Fields{
+		"type": "job:PurchaseResultJob",
+	})
+	return &PurchaseResultJob{
+		Loop: job.NewLoop(resultSvc.DispatchPurchaseResults, 100*time.Millisecond, 5*time.Second, logger),
+	}
+}
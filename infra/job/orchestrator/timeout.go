@@ -0,0 +1,29 @@
+package orchestrator
+
+import (
+	"time"
+
+	conf "github.com/minghsu0107/saga-product/config"
+	"github.com/minghsu0107/saga-product/infra/job"
+	"github.com/minghsu0107/saga-product/service/orchestrator"
+	log "github.com/sirupsen/logrus"
+)
+
+// SagaTimeoutJob periodically compensates sagas whose current step has timed out
+type SagaTimeoutJob struct {
+	*job.Ticker
+}
+
+// NewSagaTimeoutJob factory
+func NewSagaTimeoutJob(config *conf.Config, orchestratorSvc orchestrator.OrchestratorService) job.Job {
+	logger := config.Logger.ContextLogger.WithFields(log.Fields{
+		"type": "job:SagaTimeoutJob",
+	})
+	interval := time.Duration(config.SagaConfig.TimeoutCheckIntervalSecond) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	return &SagaTimeoutJob{
+		Ticker: job.NewTicker(interval, orchestratorSvc.CompensateExpiredSagas, logger),
+	}
+}
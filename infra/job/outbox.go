@@ -0,0 +1,28 @@
+package job
+
+import (
+	"time"
+
+	conf "github.com/minghsu0107/saga-product/config"
+	"github.com/minghsu0107/saga-product/infra/broker"
+	log "github.com/sirupsen/logrus"
+)
+
+// OutboxRelayJob periodically relays outbox messages to the broker
+type OutboxRelayJob struct {
+	*Ticker
+}
+
+// NewOutboxRelayJob factory
+func NewOutboxRelayJob(config *conf.Config, relay *broker.OutboxRelay) *OutboxRelayJob {
+	logger := config.Logger.ContextLogger.WithFields(log.Fields{
+		"type": "job:OutboxRelayJob",
+	})
+	interval := time.Duration(config.OutboxConfig.RelayIntervalMilli) * time.Millisecond
+	if interval <= 0 {
+		interval = 200 * time.Millisecond
+	}
+	return &OutboxRelayJob{
+		Ticker: NewTicker(interval, relay.Relay, logger),
+	}
+}
@@ -0,0 +1,29 @@
+package product
+
+import (
+	"time"
+
+	conf "github.com/minghsu0107/saga-product/config"
+	"github.com/minghsu0107/saga-product/infra/job"
+	"github.com/minghsu0107/saga-product/service/product"
+	log "github.com/sirupsen/logrus"
+)
+
+// PriceChangeJob periodically applies scheduled price changes that are due
+type PriceChangeJob struct {
+	*job.Ticker
+}
+
+// NewPriceChangeJob factory
+func NewPriceChangeJob(config *conf.Config, productSvc product.ProductService) *PriceChangeJob {
+	logger := config.Logger.ContextLogger.WithFields(log.Fields{
+		"type": "job:PriceChangeJob",
+	})
+	interval := time.Duration(config.PricingConfig.ChangeCheckIntervalMilli) * time.Millisecond
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &PriceChangeJob{
+		Ticker: job.NewTicker(interval, productSvc.ApplyDuePriceChanges, logger),
+	}
+}
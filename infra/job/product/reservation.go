@@ -0,0 +1,29 @@
+package product
+
+import (
+	"time"
+
+	conf "github.com/minghsu0107/saga-product/config"
+	"github.com/minghsu0107/saga-product/infra/job"
+	"github.com/minghsu0107/saga-product/service/product"
+	log "github.com/sirupsen/logrus"
+)
+
+// ReservationExpiryJob periodically releases inventory holds that have expired
+type ReservationExpiryJob struct {
+	*job.Ticker
+}
+
+// NewReservationExpiryJob factory
+func NewReservationExpiryJob(config *conf.Config, sagaProductSvc product.SagaProductService) job.Job {
+	logger := config.Logger.ContextLogger.WithFields(log.Fields{
+		"type": "job:ReservationExpiryJob",
+	})
+	interval := time.Duration(config.ReservationConfig.ExpiryCheckIntervalMilli) * time.Millisecond
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	return &ReservationExpiryJob{
+		Ticker: job.NewTicker(interval, sagaProductSvc.ReleaseExpiredReservations, logger),
+	}
+}
@@ -3,28 +3,36 @@ package infra
 import (
 	"context"
 
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
 	infra_broker "github.com/minghsu0107/saga-product/infra/broker"
 	infra_cache "github.com/minghsu0107/saga-product/infra/cache"
 	infra_grpc "github.com/minghsu0107/saga-product/infra/grpc"
 	grpc_auth "github.com/minghsu0107/saga-product/infra/grpc/auth"
 	grpc_order "github.com/minghsu0107/saga-product/infra/grpc/order"
 	infra_http "github.com/minghsu0107/saga-product/infra/http"
+	infra_job "github.com/minghsu0107/saga-product/infra/job"
+	infra_job_orchestrator "github.com/minghsu0107/saga-product/infra/job/orchestrator"
+	infra_job_product "github.com/minghsu0107/saga-product/infra/job/product"
 	infra_observe "github.com/minghsu0107/saga-product/infra/observe"
 	log "github.com/sirupsen/logrus"
 )
 
 // ProductServer wrapper
 type ProductServer struct {
-	HTTPServer  infra_http.Server
-	GRPCServer  infra_grpc.Server
-	EventRouter infra_broker.EventRouter
-	ObsInjector *infra_observe.ObservabilityInjector
+	HTTPServer     infra_http.Server
+	GRPCServer     infra_grpc.Server
+	EventRouter    infra_broker.EventRouter
+	ReservationJob infra_job.Job
+	PriceJob       *infra_job_product.PriceChangeJob
+	RelayJob       *infra_job.OutboxRelayJob
+	ObsInjector    *infra_observe.ObservabilityInjector
 }
 
 // OrderServer wrapper
 type OrderServer struct {
 	HTTPServer  infra_http.Server
 	EventRouter infra_broker.EventRouter
+	RelayJob    *infra_job.OutboxRelayJob
 	ObsInjector *infra_observe.ObservabilityInjector
 }
 
@@ -32,22 +40,31 @@ type OrderServer struct {
 type PaymentServer struct {
 	HTTPServer  infra_http.Server
 	EventRouter infra_broker.EventRouter
+	RelayJob    *infra_job.OutboxRelayJob
 	ObsInjector *infra_observe.ObservabilityInjector
 }
 
 // OrchestratorServer wrapper
 type OrchestratorServer struct {
+	HTTPServer  infra_http.Server
+	GRPCServer  infra_grpc.Server
 	EventRouter infra_broker.EventRouter
+	TimeoutJob  infra_job.Job
+	RelayJob    *infra_job.OutboxRelayJob
+	ResultJob   *infra_job_orchestrator.PurchaseResultJob
 	ObsInjector *infra_observe.ObservabilityInjector
 }
 
 // NewProductServer factory
-func NewProductServer(httpServer infra_http.Server, grpcServer infra_grpc.Server, eventRouter infra_broker.EventRouter, obsInjector *infra_observe.ObservabilityInjector) *ProductServer {
+func NewProductServer(httpServer infra_http.Server, grpcServer infra_grpc.Server, eventRouter infra_broker.EventRouter, reservationJob infra_job.Job, priceJob *infra_job_product.PriceChangeJob, relayJob *infra_job.OutboxRelayJob, obsInjector *infra_observe.ObservabilityInjector) *ProductServer {
 	return &ProductServer{
-		HTTPServer:  httpServer,
-		GRPCServer:  grpcServer,
-		EventRouter: eventRouter,
-		ObsInjector: obsInjector,
+		HTTPServer:     httpServer,
+		GRPCServer:     grpcServer,
+		EventRouter:    eventRouter,
+		ReservationJob: reservationJob,
+		PriceJob:       priceJob,
+		RelayJob:       relayJob,
+		ObsInjector:    obsInjector,
 	}
 }
 
@@ -74,6 +91,24 @@ func (s *ProductServer) Run() error {
 			log.Fatal(err)
 		}
 	}()
+	go func() {
+		err := s.RelayJob.Run()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+	go func() {
+		err := s.ReservationJob.Run()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+	go func() {
+		err := s.PriceJob.Run()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
 	return nil
 }
 
@@ -84,10 +119,22 @@ func (s *ProductServer) GracefulStop(ctx context.Context, done chan bool) {
 		log.Error(err)
 	}
 	s.GRPCServer.GracefulStop()
+	err = s.ReservationJob.GracefulStop()
+	if err != nil {
+		log.Error(err)
+	}
+	err = s.PriceJob.GracefulStop()
+	if err != nil {
+		log.Error(err)
+	}
 	err = s.EventRouter.GracefulStop()
 	if err != nil {
 		log.Error(err)
 	}
+	err = s.RelayJob.GracefulStop()
+	if err != nil {
+		log.Error(err)
+	}
 
 	if infra_observe.TracerProvider != nil {
 		err = infra_observe.TracerProvider.Shutdown(ctx)
@@ -110,10 +157,11 @@ func (s *ProductServer) GracefulStop(ctx context.Context, done chan bool) {
 }
 
 // NewOrderServer factory
-func NewOrderServer(httpServer infra_http.Server, eventRouter infra_broker.EventRouter, obsInjector *infra_observe.ObservabilityInjector) *OrderServer {
+func NewOrderServer(httpServer infra_http.Server, eventRouter infra_broker.EventRouter, relayJob *infra_job.OutboxRelayJob, obsInjector *infra_observe.ObservabilityInjector) *OrderServer {
 	return &OrderServer{
 		HTTPServer:  httpServer,
 		EventRouter: eventRouter,
+		RelayJob:    relayJob,
 		ObsInjector: obsInjector,
 	}
 }
@@ -135,6 +183,12 @@ func (s *OrderServer) Run() error {
 			log.Fatal(err)
 		}
 	}()
+	go func() {
+		err := s.RelayJob.Run()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
 	return nil
 }
 
@@ -148,6 +202,10 @@ func (s *OrderServer) GracefulStop(ctx context.Context, done chan bool) {
 	if err != nil {
 		log.Error(err)
 	}
+	err = s.RelayJob.GracefulStop()
+	if err != nil {
+		log.Error(err)
+	}
 
 	if infra_observe.TracerProvider != nil {
 		err = infra_observe.TracerProvider.Shutdown(ctx)
@@ -176,10 +234,11 @@ func (s *OrderServer) GracefulStop(ctx context.Context, done chan bool) {
 }
 
 // NewPaymentServer factory
-func NewPaymentServer(httpServer infra_http.Server, eventRouter infra_broker.EventRouter, obsInjector *infra_observe.ObservabilityInjector) *PaymentServer {
+func NewPaymentServer(httpServer infra_http.Server, eventRouter infra_broker.EventRouter, relayJob *infra_job.OutboxRelayJob, obsInjector *infra_observe.ObservabilityInjector) *PaymentServer {
 	return &PaymentServer{
 		HTTPServer:  httpServer,
 		EventRouter: eventRouter,
+		RelayJob:    relayJob,
 		ObsInjector: obsInjector,
 	}
 }
@@ -201,6 +260,12 @@ func (s *PaymentServer) Run() error {
 			log.Fatal(err)
 		}
 	}()
+	go func() {
+		err := s.RelayJob.Run()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
 	return nil
 }
 
@@ -214,6 +279,10 @@ func (s *PaymentServer) GracefulStop(ctx context.Context, done chan bool) {
 	if err != nil {
 		log.Error(err)
 	}
+	err = s.RelayJob.GracefulStop()
+	if err != nil {
+		log.Error(err)
+	}
 
 	if infra_observe.TracerProvider != nil {
 		err = infra_observe.TracerProvider.Shutdown(ctx)
@@ -239,9 +308,14 @@ func (s *PaymentServer) GracefulStop(ctx context.Context, done chan bool) {
 }
 
 // NewOrchestratorServer factory
-func NewOrchestratorServer(eventRouter infra_broker.EventRouter, obsInjector *infra_observe.ObservabilityInjector) *OrchestratorServer {
+func NewOrchestratorServer(httpServer infra_http.Server, grpcServer infra_grpc.Server, eventRouter infra_broker.EventRouter, timeoutJob infra_job.Job, relayJob *infra_job.OutboxRelayJob, resultJob *infra_job_orchestrator.PurchaseResultJob, obsInjector *infra_observe.ObservabilityInjector) *OrchestratorServer {
 	return &OrchestratorServer{
+		HTTPServer:  httpServer,
+		GRPCServer:  grpcServer,
 		EventRouter: eventRouter,
+		TimeoutJob:  timeoutJob,
+		RelayJob:    relayJob,
+		ResultJob:   resultJob,
 		ObsInjector: obsInjector,
 	}
 }
@@ -251,18 +325,65 @@ func (s *OrchestratorServer) Run() error {
 	if err := s.ObsInjector.Register(); err != nil {
 		return err
 	}
+	go func() {
+		err := s.HTTPServer.Run()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+	go func() {
+		err := s.GRPCServer.Run()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
 	go func() {
 		err := s.EventRouter.Run()
 		if err != nil {
 			log.Fatal(err)
 		}
 	}()
+	go func() {
+		err := s.RelayJob.Run()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+	go func() {
+		err := s.TimeoutJob.Run()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+	go func() {
+		err := s.ResultJob.Run()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
 	return nil
 }
 
 // GracefulStop server
 func (s *OrchestratorServer) GracefulStop(ctx context.Context, done chan bool) {
-	err := s.EventRouter.GracefulStop()
+	err := s.HTTPServer.GracefulStop(ctx)
+	if err != nil {
+		log.Error(err)
+	}
+	s.GRPCServer.GracefulStop()
+	err = s.TimeoutJob.GracefulStop()
+	if err != nil {
+		log.Error(err)
+	}
+	err = s.ResultJob.GracefulStop()
+	if err != nil {
+		log.Error(err)
+	}
+	err = s.EventRouter.GracefulStop()
+	if err != nil {
+		log.Error(err)
+	}
+	err = s.RelayJob.GracefulStop()
 	if err != nil {
 		log.Error(err)
 	}
@@ -279,9 +400,111 @@ func (s *OrchestratorServer) GracefulStop(ctx context.Context, done chan bool) {
 	if err = infra_broker.ResultPublisher.Close(); err != nil {
 		log.Error(err)
 	}
+	if err = infra_cache.RedisClient.Close(); err != nil {
+		log.Error(err)
+	}
 	if err = infra_broker.TxSubscriber.Close(); err != nil {
 		log.Error(err)
 	}
+	if err = grpc_auth.AuthClientConn.Conn.Close(); err != nil {
+		log.Error(err)
+	}
+
+	log.Info("gracefully shutdowned")
+	done <- true
+}
+
+// AllInOneServer wrapper runs all services in one process over an in-memory broker
+type AllInOneServer struct {
+	ProductServer      *ProductServer
+	OrderServer        *OrderServer
+	PaymentServer      *PaymentServer
+	OrchestratorServer *OrchestratorServer
+	PubSub             *gochannel.GoChannel
+	ObsInjector        *infra_observe.ObservabilityInjector
+}
+
+// NewAllInOneServer factory
+func NewAllInOneServer(productServer *ProductServer, orderServer *OrderServer, paymentServer *PaymentServer, orchestratorServer *OrchestratorServer, pubSub *gochannel.GoChannel, obsInjector *infra_observe.ObservabilityInjector) *AllInOneServer {
+	return &AllInOneServer{
+		ProductServer:      productServer,
+		OrderServer:        orderServer,
+		PaymentServer:      paymentServer,
+		OrchestratorServer: orchestratorServer,
+		PubSub:             pubSub,
+		ObsInjector:        obsInjector,
+	}
+}
+
+// Run server
+func (s *AllInOneServer) Run() error {
+	if err := s.ObsInjector.Register(); err != nil {
+		return err
+	}
+	if err := s.ProductServer.Run(); err != nil {
+		return err
+	}
+	if err := s.OrderServer.Run(); err != nil {
+		return err
+	}
+	if err := s.PaymentServer.Run(); err != nil {
+		return err
+	}
+	return s.OrchestratorServer.Run()
+}
+
+// GracefulStop server
+// Servers are stopped before the shared connections and the in-memory broker are closed
+func (s *AllInOneServer) GracefulStop(ctx context.Context, done chan bool) {
+	for _, httpServer := range []infra_http.Server{s.ProductServer.HTTPServer, s.OrderServer.HTTPServer, s.PaymentServer.HTTPServer, s.OrchestratorServer.HTTPServer} {
+		if err := httpServer.GracefulStop(ctx); err != nil {
+			log.Error(err)
+		}
+	}
+	s.ProductServer.GRPCServer.GracefulStop()
+	s.OrchestratorServer.GRPCServer.GracefulStop()
+	if err := s.ProductServer.ReservationJob.GracefulStop(); err != nil {
+		log.Error(err)
+	}
+	if err := s.ProductServer.PriceJob.GracefulStop(); err != nil {
+		log.Error(err)
+	}
+	if err := s.OrchestratorServer.TimeoutJob.GracefulStop(); err != nil {
+		log.Error(err)
+	}
+	if err := s.OrchestratorServer.ResultJob.GracefulStop(); err != nil {
+		log.Error(err)
+	}
+	for _, eventRouter := range []infra_broker.EventRouter{s.ProductServer.EventRouter, s.OrderServer.EventRouter, s.PaymentServer.EventRouter, s.OrchestratorServer.EventRouter} {
+		if err := eventRouter.GracefulStop(); err != nil {
+			log.Error(err)
+		}
+	}
+	for _, relayJob := range []*infra_job.OutboxRelayJob{s.ProductServer.RelayJob, s.OrderServer.RelayJob, s.PaymentServer.RelayJob, s.OrchestratorServer.RelayJob} {
+		if err := relayJob.GracefulStop(); err != nil {
+			log.Error(err)
+		}
+	}
+
+	var err error
+	if infra_observe.TracerProvider != nil {
+		err = infra_observe.TracerProvider.Shutdown(ctx)
+		if err != nil {
+			log.Error(err)
+		}
+	}
+	if err = infra_cache.RedisClient.Close(); err != nil {
+		log.Error(err)
+	}
+	if err = s.PubSub.Close(); err != nil {
+		log.Error(err)
+	}
+	if err = grpc_auth.AuthClientConn.Conn.Close(); err != nil {
+		log.Error(err)
+	}
+	if err = grpc_order.ProductClientConn.Conn.Close(); err != nil {
+		log.Error(err)
+	}
 
 	log.Info("gracefully shutdowned")
 	done <- true
@@ -0,0 +1 @@
+protoc *.proto --go_out=plugins=grpc:.
\ No newline at end of file
@@ -19,3 +19,16 @@ func NewSonyFlake() (IDGenerator, error) {
 	}
 	return sf, nil
 }
+
+// NextIDs generates n unique IDs in increasing order
+func NextIDs(sf IDGenerator, n int) ([]uint64, error) {
+	ids := make([]uint64, 0, n)
+	for i := 0; i < n; i++ {
+		id, err := sf.NextID()
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -12,3 +12,20 @@ func Time2pbTimestamp(now time.Time) *timestamp.Timestamp {
 	n := int32(now.Nanosecond())
 	return &timestamp.Timestamp{Seconds: s, Nanos: n}
 }
+
+// Clock is the interface for reading the current time
+type Clock interface {
+	Now() time.Time
+}
+
+type wallClock struct{}
+
+// Now returns the current local time
+func (wallClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewClock returns the wall clock
+func NewClock() Clock {
+	return wallClock{}
+}
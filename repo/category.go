@@ -0,0 +1,304 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	domain_model "github.com/minghsu0107/saga-product/domain/model"
+	"github.com/minghsu0107/saga-product/infra/db/model"
+	"github.com/minghsu0107/saga-product/pkg"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CategoryRepository is the category repository interface
+type CategoryRepository interface {
+	CreateCategory(ctx context.Context, category *domain_model.Category) (uint64, error)
+	GetCategory(ctx context.Context, categoryID uint64) (*domain_model.Category, error)
+	ListCategories(ctx context.Context) (*[]domain_model.Category, error)
+	UpdateCategory(ctx context.Context, categoryID uint64, update *domain_model.CategoryUpdate) error
+	DeleteCategory(ctx context.Context, categoryID uint64) error
+	SetProductCategories(ctx context.Context, productID uint64, categoryIDs []uint64) error
+	GetProductCategories(ctx context.Context, productID uint64) (*[]domain_model.Category, error)
+}
+
+// CategoryRepositoryImpl implements CategoryRepository interface
+type CategoryRepositoryImpl struct {
+	db *gorm.DB
+	sf pkg.IDGenerator
+}
+
+// NewCategoryRepository is the factory of CategoryRepository
+func NewCategoryRepository(db *gorm.DB, sf pkg.IDGenerator) CategoryRepository {
+	return &CategoryRepositoryImpl{
+		db: db,
+		sf: sf,
+	}
+}
+
+// CreateCategory method
+// The parent is locked until the category is created, so that it cannot be moved or deleted in between
+func (repo *CategoryRepositoryImpl) CreateCategory(ctx context.Context, category *domain_model.Category) (uint64, error) {
+	sonyflakeID, err := repo.sf.NextID()
+	if err != nil {
+		return 0, err
+	}
+
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	if err := tx.Error; err != nil {
+		return 0, err
+	}
+
+	path := categoryPath("/", sonyflakeID)
+	if category.ParentID != 0 {
+		var parent model.Category
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("path").Where("id = ?", category.ParentID).First(&parent).Error; err != nil {
+			tx.Rollback()
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return 0, ErrInvalidCategoryParent
+			}
+			return 0, err
+		}
+		path = categoryPath(parent.Path, sonyflakeID)
+	}
+	if err := tx.Create(&model.Category{
+		ID:       sonyflakeID,
+		ParentID: category.ParentID,
+		Name:     category.Name,
+		Path:     path,
+	}).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
+	}
+	return sonyflakeID, nil
+}
+
+// GetCategory method
+func (repo *CategoryRepositoryImpl) GetCategory(ctx context.Context, categoryID uint64) (*domain_model.Category, error) {
+	var category model.Category
+	if err := repo.db.WithContext(ctx).Select("id", "parent_id", "name").Where("id = ?", categoryID).First(&category).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, err
+	}
+	return mapCategory(&category), nil
+}
+
+// ListCategories method
+// Categories are listed in depth-first order, so a parent always precedes its children
+func (repo *CategoryRepositoryImpl) ListCategories(ctx context.Context) (*[]domain_model.Category, error) {
+	var categories []model.Category
+	if err := repo.db.WithContext(ctx).Select("id", "parent_id", "name").Order("path").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return mapCategories(categories), nil
+}
+
+// UpdateCategory method
+// Moving a category moves its whole subtree; a category cannot be moved under itself or its descendants
+func (repo *CategoryRepositoryImpl) UpdateCategory(ctx context.Context, categoryID uint64, update *domain_model.CategoryUpdate) error {
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	if err := tx.Error; err != nil {
+		return err
+	}
+
+	var category model.Category
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "parent_id", "path").Where("id = ?", categoryID).First(&category).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCategoryNotFound
+		}
+		return err
+	}
+	updates := make(map[string]interface{})
+	if update.Name != nil {
+		updates["name"] = *update.Name
+	}
+	if update.ParentID != nil && *update.ParentID != category.ParentID {
+		path := categoryPath("/", categoryID)
+		if *update.ParentID != 0 {
+			var parent model.Category
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("path").Where("id = ?", *update.ParentID).First(&parent).Error; err != nil {
+				tx.Rollback()
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrInvalidCategoryParent
+				}
+				return err
+			}
+			if strings.HasPrefix(parent.Path, category.Path) {
+				tx.Rollback()
+				return ErrInvalidCategoryParent
+			}
+			path = categoryPath(parent.Path, categoryID)
+		}
+		if err := tx.Model(&model.Category{}).Where("path LIKE ?", category.Path+"%").
+			Update("path", gorm.Expr("CONCAT(?, SUBSTRING(path, ?))", path, len(category.Path)+1)).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		updates["parent_id"] = *update.ParentID
+	}
+	if len(updates) > 0 {
+		if err := tx.Model(&model.Category{}).Where("id = ?", categoryID).Updates(updates).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// DeleteCategory method
+// Only a category without children can be deleted, and its product assignments are removed along with it
+func (repo *CategoryRepositoryImpl) DeleteCategory(ctx context.Context, categoryID uint64) error {
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	if err := tx.Error; err != nil {
+		return err
+	}
+
+	var category model.Category
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", categoryID).First(&category).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCategoryNotFound
+		}
+		return err
+	}
+	var children int64
+	if err := tx.Model(&model.Category{}).Where("parent_id = ?", categoryID).Count(&children).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if children > 0 {
+		tx.Rollback()
+		return ErrCategoryHasChildren
+	}
+	if err := tx.Where("category_id = ?", categoryID).Delete(&model.ProductCategory{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Where("id = ?", categoryID).Delete(&model.Category{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// SetProductCategories method
+// It replaces all category assignments of the product
+func (repo *CategoryRepositoryImpl) SetProductCategories(ctx context.Context, productID uint64, categoryIDs []uint64) error {
+	uniqueIDs := make(map[uint64]bool)
+	var assignments []model.ProductCategory
+	for _, categoryID := range categoryIDs {
+		if uniqueIDs[categoryID] {
+			continue
+		}
+		uniqueIDs[categoryID] = true
+		assignments = append(assignments, model.ProductCategory{
+			ProductID:  productID,
+			CategoryID: categoryID,
+		})
+	}
+
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	if err := tx.Error; err != nil {
+		return err
+	}
+
+	var product model.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", productID).First(&product).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrProductNotFound
+		}
+		return err
+	}
+	if len(assignments) > 0 {
+		var count int64
+		if err := tx.Model(&model.Category{}).Where("id IN ?", categoryIDs).Count(&count).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if int(count) != len(assignments) {
+			tx.Rollback()
+			return ErrCategoryNotFound
+		}
+	}
+	if err := tx.Where("product_id = ?", productID).Delete(&model.ProductCategory{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if len(assignments) > 0 {
+		if err := tx.Create(&assignments).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// GetProductCategories method
+func (repo *CategoryRepositoryImpl) GetProductCategories(ctx context.Context, productID uint64) (*[]domain_model.Category, error) {
+	var categories []model.Category
+	if err := repo.db.WithContext(ctx).Select("categories.id", "categories.parent_id", "categories.name").
+		Joins("JOIN product_categories ON product_categories.category_id = categories.id").
+		Where("product_categories.product_id = ?", productID).
+		Order("categories.path").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return mapCategories(categories), nil
+}
+
+func categoryPath(parentPath string, categoryID uint64) string {
+	return pkg.Join(parentPath, strconv.FormatUint(categoryID, 10), "/")
+}
+
+func mapCategory(category *model.Category) *domain_model.Category {
+	return &domain_model.Category{
+		ID:       category.ID,
+		ParentID: category.ParentID,
+		Name:     category.Name,
+	}
+}
+
+func mapCategories(categories []model.Category) *[]domain_model.Category {
+	domainCategories := []domain_model.Category{}
+	for i := range categories {
+		domainCategories = append(domainCategories, *mapCategory(&categories[i]))
+	}
+	return &domainCategories
+}
@@ -7,10 +7,36 @@ var (
 	ErrInsuffientInventory = errors.New("insufficient inventory")
 	// ErrInvalidIdempotency is invalid idempotency error
 	ErrInvalidIdempotency = errors.New("invalid idempotency")
+	// ErrReservationReleased is released inventory reservation error
+	ErrReservationReleased = errors.New("inventory reservation released")
 	// ErrProductNotFound is product not found error
 	ErrProductNotFound = errors.New("product not found")
+	// ErrVariantNotFound is product variant not found error
+	ErrVariantNotFound = errors.New("product variant not found")
+	// ErrDuplicateSKU is duplicate SKU error
+	ErrDuplicateSKU = errors.New("duplicate sku")
+	// ErrPriceNotFound is price not found error
+	ErrPriceNotFound = errors.New("price not found")
+	// ErrPriceChangeNotFound is scheduled price change not found error
+	ErrPriceChangeNotFound = errors.New("price change not found")
+	// ErrCategoryNotFound is category not found error
+	ErrCategoryNotFound = errors.New("category not found")
+	// ErrCategoryHasChildren is deleting category with children error
+	ErrCategoryHasChildren = errors.New("category has children")
+	// ErrInvalidCategoryParent is invalid parent category error
+	ErrInvalidCategoryParent = errors.New("invalid parent category")
 	// ErrOrderNotFound is order not found error
 	ErrOrderNotFound = errors.New("order not found")
+	// ErrInvalidOrderTransition is order status change not allowed error
+	ErrInvalidOrderTransition = errors.New("invalid order status transition")
 	// ErrPaymentNotFound is payment not found error
 	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrRefundExceedsBalance is refunding more than the refundable amount of a payment error
+	ErrRefundExceedsBalance = errors.New("refund exceeds refundable amount")
+	// ErrIdempotencyKeyConflict is reusing an idempotency key for a different refund error
+	ErrIdempotencyKeyConflict = errors.New("idempotency key used by another refund")
+	// ErrSagaInstanceNotFound is saga instance not found error
+	ErrSagaInstanceNotFound = errors.New("saga instance not found")
+	// ErrSagaInstanceChanged is recording transitions on a saga instance that has moved on error
+	ErrSagaInstanceChanged = errors.New("saga instance changed")
 )
@@ -2,6 +2,7 @@ package repo
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"time"
 
@@ -14,23 +15,27 @@ import (
 	domain_model "github.com/minghsu0107/saga-product/domain/model"
 	"github.com/minghsu0107/saga-product/infra/db/model"
 	grpc_order "github.com/minghsu0107/saga-product/infra/grpc/order"
+	product_pb "github.com/minghsu0107/saga-product/pb"
+	"github.com/minghsu0107/saga-product/pkg"
 	"github.com/sony/gobreaker"
 	"golang.org/x/time/rate"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // OrderRepository interface
 type OrderRepository interface {
-	GetOrder(ctx context.Context, orderID uint64) (*domain_model.Order, error)
-	GetDetailedPurchasedItems(ctx context.Context, purchasedItems *[]domain_model.PurchasedItem) (*[]domain_model.DetailedPurchasedItem, error)
-	CreateOrder(ctx context.Context, order *domain_model.Order) error
-	DeleteOrder(ctx context.Context, orderID uint64) error
+	GetDetailedOrder(ctx context.Context, orderID uint64) (*domain_model.DetailedOrder, error)
+	ListCustomerOrders(ctx context.Context, query *domain_model.OrderQuery, beforeID uint64) (*[]domain_model.OrderSummary, error)
+	CreateOrder(ctx context.Context, order *domain_model.Order, processed *domain_model.ProcessedMessage) error
+	UpdateOrderStatus(ctx context.Context, orderID uint64, status domain_model.OrderStatus, processed *domain_model.ProcessedMessage) error
 }
 
 // OrderRepositoryImpl implementation
 type OrderRepositoryImpl struct {
 	db          *gorm.DB
 	getProducts endpoint.Endpoint
+	getPricesAt endpoint.Endpoint
 }
 
 // NewOrderRepository factory
@@ -57,43 +62,100 @@ func NewOrderRepository(config *conf.Config, conn *grpc_order.ProductConn, db *g
 			Timeout: config.ServiceOptions.Timeout,
 		}))(getProducts)
 	}
+
+	var getPricesAt endpoint.Endpoint
+	{
+		svcName := "pricing.PricingService"
+		getPricesAt = grpctransport.NewClient(
+			conn.Conn,
+			svcName,
+			"GetPricesAt",
+			encodeGRPCRequest,
+			decodeGRPCResponse,
+			&product_pb.Prices{},
+			append(options, grpctransport.ClientBefore(grpctransport.SetRequestHeader(ServiceNameHeader, svcName)))...,
+		).Endpoint()
+		getPricesAt = limiter(getPricesAt)
+		getPricesAt = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:    "pricing",
+			Timeout: config.ServiceOptions.Timeout,
+		}))(getPricesAt)
+	}
 	return &OrderRepositoryImpl{
 		db:          db,
 		getProducts: getProducts,
+		getPricesAt: getPricesAt,
 	}
 }
 
-// GetOrder get an order
-func (repo *OrderRepositoryImpl) GetOrder(ctx context.Context, orderID uint64) (*domain_model.Order, error) {
-	var orders []model.Order
-	if err := repo.db.Model(&model.Order{}).Select("id", "product_id", "amount", "customer_id").Where("id = ?", orderID).Order("product_id").Find(&orders).WithContext(ctx).Error; err != nil {
+// GetDetailedOrder get an order together with its items and status transitions
+// Items migrated without snapshots are priced at the time the order was created
+func (repo *OrderRepositoryImpl) GetDetailedOrder(ctx context.Context, orderID uint64) (*domain_model.DetailedOrder, error) {
+	var order model.Order
+	if err := repo.db.WithContext(ctx).Where("id = ?", orderID).First(&order).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrOrderNotFound
 		}
 		return nil, err
 	}
-	if len(orders) == 0 {
-		return nil, ErrOrderNotFound
+	var items []model.OrderItem
+	if err := repo.db.WithContext(ctx).Where("order_id = ?", orderID).Order("product_id, variant_id").Find(&items).Error; err != nil {
+		return nil, err
 	}
-	var purchasedItems []domain_model.PurchasedItem
-	for _, order := range orders {
-		purchasedItems = append(purchasedItems, domain_model.PurchasedItem{
-			ProductID: order.ProductID,
-			Amount:    order.Amount,
+	if err := repo.priceLegacyOrderItems(ctx, &order, items); err != nil {
+		return nil, err
+	}
+	var rows []model.OrderTransition
+	if err := repo.db.WithContext(ctx).Where("order_id = ?", orderID).Order("id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	var detailedPurchasedItems []domain_model.DetailedPurchasedItem
+	for _, item := range items {
+		detailedPurchasedItems = append(detailedPurchasedItems, domain_model.DetailedPurchasedItem{
+			ProductID:   item.ProductID,
+			VariantID:   item.VariantID,
+			Name:        item.Name,
+			Description: item.Description,
+			BrandName:   item.BrandName,
+			Price:       item.Price,
+			Amount:      item.Amount,
+		})
+	}
+	transitions := []domain_model.OrderTransition{}
+	for _, row := range rows {
+		transitions = append(transitions, domain_model.OrderTransition{
+			Status:    domain_model.OrderStatus(row.Status),
+			Timestamp: time.UnixMilli(row.Timestamp),
 		})
 	}
-	return &domain_model.Order{
-		ID:             orders[0].ID,
-		CustomerID:     orders[0].CustomerID,
-		PurchasedItems: &purchasedItems,
+	return &domain_model.DetailedOrder{
+		ID:                     order.ID,
+		CustomerID:             order.CustomerID,
+		CurrencyCode:           order.CurrencyCode,
+		DetailedPurchasedItems: &detailedPurchasedItems,
+		TotalAmount:            order.TotalAmount,
+		TotalPrice:             order.TotalPrice,
+		Status:                 domain_model.OrderStatus(order.Status),
+		StatusUpdatedAt:        time.UnixMilli(order.StatusUpdatedAt),
+		Transitions:            &transitions,
+		CreatedAt:              time.UnixMilli(order.CreatedAt),
 	}, nil
 }
 
-// GetDetailedPurchasedItems get detailed purchased items
-func (repo *OrderRepositoryImpl) GetDetailedPurchasedItems(ctx context.Context, purchasedItems *[]domain_model.PurchasedItem) (*[]domain_model.DetailedPurchasedItem, error) {
+// getDetailedPurchasedItems get detailed purchased items in the same order
+// Items are priced at the given time, falling back to the current price of items without a recorded price at that time;
+// items of products that no longer exist are left without name, description and brand
+func (repo *OrderRepositoryImpl) getDetailedPurchasedItems(ctx context.Context, purchasedItems *[]domain_model.PurchasedItem, at time.Time) (*[]domain_model.DetailedPurchasedItem, error) {
 	var productIDs []uint64
+	var items []pricedItem
 	for _, purchasedItem := range *purchasedItems {
 		productIDs = append(productIDs, purchasedItem.ProductID)
+		items = append(items, pricedItem{
+			productID: purchasedItem.ProductID,
+			variantID: purchasedItem.VariantID,
+			at:        at.UnixMilli(),
+		})
 	}
 	res, err := repo.getProducts(ctx, &pb.GetProductsRequest{
 		ProductIds: productIDs,
@@ -101,44 +163,332 @@ func (repo *OrderRepositoryImpl) GetDetailedPurchasedItems(ctx context.Context,
 	if err != nil {
 		return nil, err
 	}
-	pbProducts := res.(*pb.Products)
+	pbProducts := make(map[uint64]*pb.Product)
+	for _, pbProduct := range res.(*pb.Products).Products {
+		pbProducts[pbProduct.ProductId] = pbProduct
+	}
+	prices, err := repo.lookupPrices(ctx, items)
+	if err != nil {
+		return nil, err
+	}
 	var detailedPurchasedItems []domain_model.DetailedPurchasedItem
-	for i, pbProduct := range pbProducts.Products {
-		detailedPurchasedItems = append(detailedPurchasedItems, domain_model.DetailedPurchasedItem{
-			ProductID:   pbProduct.ProductId,
-			Name:        pbProduct.ProductName,
-			Description: pbProduct.Description,
-			BrandName:   pbProduct.BrandName,
-			Price:       pbProduct.Price,
-			Amount:      (*purchasedItems)[i].Amount,
-		})
+	for i, purchasedItem := range *purchasedItems {
+		detailedPurchasedItem := domain_model.DetailedPurchasedItem{
+			ProductID: purchasedItem.ProductID,
+			VariantID: purchasedItem.VariantID,
+			Amount:    purchasedItem.Amount,
+		}
+		if pbProduct, ok := pbProducts[purchasedItem.ProductID]; ok {
+			detailedPurchasedItem.Name = pbProduct.ProductName
+			detailedPurchasedItem.Description = pbProduct.Description
+			detailedPurchasedItem.BrandName = pbProduct.BrandName
+			detailedPurchasedItem.Price = pbProduct.Price
+		}
+		if price, ok := prices[items[i]]; ok {
+			detailedPurchasedItem.Price = price
+		}
+		detailedPurchasedItems = append(detailedPurchasedItems, detailedPurchasedItem)
 	}
 	return &detailedPurchasedItems, nil
 }
 
-// CreateOrder creates an order
-func (repo *OrderRepositoryImpl) CreateOrder(ctx context.Context, order *domain_model.Order) error {
-	id := order.ID
-	customerID := order.CustomerID
-	var entries []model.Order
-	for _, purchasedItem := range *order.PurchasedItems {
-		entries = append(entries, model.Order{
-			ID:         id,
-			ProductID:  purchasedItem.ProductID,
-			Amount:     purchasedItem.Amount,
-			CustomerID: customerID,
-		})
+// priceLegacyOrderItems fills in order items migrated without snapshots with the products and the prices in effect when the order was created,
+// and totals the price of the order again; neither the items nor the order are written back, so reading an order stays read-only
+func (repo *OrderRepositoryImpl) priceLegacyOrderItems(ctx context.Context, order *model.Order, items []model.OrderItem) error {
+	var purchasedItems []domain_model.PurchasedItem
+	for _, item := range items {
+		if !item.Snapshotted {
+			purchasedItems = append(purchasedItems, domain_model.PurchasedItem{
+				ProductID: item.ProductID,
+				VariantID: item.VariantID,
+				Amount:    item.Amount,
+			})
+		}
+	}
+	if len(purchasedItems) == 0 {
+		return nil
 	}
-	if err := repo.db.Create(&entries).WithContext(ctx).Error; err != nil {
+	detailedPurchasedItems, err := repo.getDetailedPurchasedItems(ctx, &purchasedItems, time.UnixMilli(order.CreatedAt))
+	if err != nil {
 		return err
 	}
+
+	var totalPrice int64
+	j := 0
+	for i := range items {
+		if !items[i].Snapshotted {
+			detailedPurchasedItem := (*detailedPurchasedItems)[j]
+			j++
+			items[i].Name = detailedPurchasedItem.Name
+			items[i].Description = detailedPurchasedItem.Description
+			items[i].BrandName = detailedPurchasedItem.BrandName
+			items[i].Price = detailedPurchasedItem.Price
+		}
+		totalPrice += items[i].Price * items[i].Amount
+	}
+	order.TotalPrice = totalPrice
 	return nil
 }
 
-// DeleteOrder deletes an order
-func (repo *OrderRepositoryImpl) DeleteOrder(ctx context.Context, orderID uint64) error {
-	if err := repo.db.Exec("DELETE FROM orders where id = ?", orderID).Error; err != nil {
+// pricedItem is a purchased product, or variant, priced at a time in unix milliseconds
+type pricedItem struct {
+	productID uint64
+	variantID uint64
+	at        int64
+}
+
+// lookupPrices returns the prices of items in effect at their time, leaving out items without a recorded price at that time
+func (repo *OrderRepositoryImpl) lookupPrices(ctx context.Context, items []pricedItem) (map[pricedItem]int64, error) {
+	prices := make(map[pricedItem]int64)
+	if len(items) == 0 {
+		return prices, nil
+	}
+	var pbItems []*product_pb.PriceItem
+	for _, item := range items {
+		pbItems = append(pbItems, &product_pb.PriceItem{
+			ProductId: item.productID,
+			VariantId: item.variantID,
+			At:        pkg.Time2pbTimestamp(time.UnixMilli(item.at)),
+		})
+	}
+	res, err := repo.getPricesAt(ctx, &product_pb.GetPricesAtRequest{
+		Items: pbItems,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, pbPrice := range res.(*product_pb.Prices).Prices {
+		prices[pricedItem{
+			productID: pbPrice.ProductId,
+			variantID: pbPrice.VariantId,
+			at:        pbPrice.At.AsTime().UnixMilli(),
+		}] = pbPrice.Price
+	}
+	return prices, nil
+}
+
+// ListCustomerOrders lists the orders of a customer before an order ID from the latest
+// Order IDs are sonyflake IDs that increase with time, so orders are listed in the order they are created
+func (repo *OrderRepositoryImpl) ListCustomerOrders(ctx context.Context, query *domain_model.OrderQuery, beforeID uint64) (*[]domain_model.OrderSummary, error) {
+	tx := repo.db.WithContext(ctx).Where("customer_id = ?", query.CustomerID)
+	if !query.From.IsZero() {
+		tx = tx.Where("created_at >= ?", query.From.UnixMilli())
+	}
+	if !query.To.IsZero() {
+		tx = tx.Where("created_at < ?", query.To.UnixMilli())
+	}
+	if beforeID != 0 {
+		tx = tx.Where("id < ?", beforeID)
+	}
+	var orders []model.Order
+	if err := tx.Order("id DESC").Limit(query.Size).Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	summaries := []domain_model.OrderSummary{}
+	if len(orders) == 0 {
+		return &summaries, nil
+	}
+	var orderIDs []uint64
+	for _, order := range orders {
+		orderIDs = append(orderIDs, order.ID)
+	}
+	var counts []orderItemCount
+	if err := repo.db.WithContext(ctx).Model(&model.OrderItem{}).Select("order_id, COUNT(*) AS item_count, SUM(CASE WHEN snapshotted THEN 0 ELSE 1 END) AS unsnapshotted").Where("order_id IN ?", orderIDs).Group("order_id").Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	itemCounts := make(map[uint64]orderItemCount)
+	for _, count := range counts {
+		itemCounts[count.OrderID] = count
+	}
+	for i := range orders {
+		if itemCounts[orders[i].ID].Unsnapshotted == 0 {
+			continue
+		}
+		var items []model.OrderItem
+		if err := repo.db.WithContext(ctx).Where("order_id = ?", orders[i].ID).Find(&items).Error; err != nil {
+			return nil, err
+		}
+		if err := repo.priceLegacyOrderItems(ctx, &orders[i], items); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, order := range orders {
+		summaries = append(summaries, domain_model.OrderSummary{
+			ID:          order.ID,
+			CustomerID:  order.CustomerID,
+			ItemCount:   itemCounts[order.ID].ItemCount,
+			TotalAmount: order.TotalAmount,
+			TotalPrice:  order.TotalPrice,
+			Status:      domain_model.OrderStatus(order.Status),
+			CreatedAt:   time.UnixMilli(order.CreatedAt),
+		})
+	}
+	return &summaries, nil
+}
+
+// orderItemCount counts the items of an order and those migrated without snapshots
+type orderItemCount struct {
+	OrderID       uint64
+	ItemCount     int
+	Unsnapshotted int
+}
+
+// CreateOrder creates a pending order together with the processed command and its reply
+// Its items are snapshotted at the prices the customer checked out at; items without a checkout price are priced when the order was placed
+// If the command has been processed, its original reply is recorded again instead
+func (repo *OrderRepositoryImpl) CreateOrder(ctx context.Context, order *domain_model.Order, processed *domain_model.ProcessedMessage) error {
+	now := time.Now()
+	placedAt := order.PlacedAt
+	if placedAt.IsZero() {
+		placedAt = now
+	}
+	detailedPurchasedItems, err := repo.getDetailedPurchasedItems(ctx, order.PurchasedItems, placedAt)
+	if err != nil {
 		return err
 	}
-	return nil
+	for i, purchasedItem := range *order.PurchasedItems {
+		if purchasedItem.Price != 0 {
+			(*detailedPurchasedItems)[i].Price = purchasedItem.Price
+		}
+	}
+	header := model.Order{
+		ID:              order.ID,
+		CustomerID:      order.CustomerID,
+		CurrencyCode:    order.CurrencyCode,
+		Status:          string(domain_model.OrderPending),
+		StatusUpdatedAt: now.UnixMilli(),
+		CreatedAt:       now.UnixMilli(),
+	}
+	var items []model.OrderItem
+	for _, detailedPurchasedItem := range *detailedPurchasedItems {
+		items = append(items, model.OrderItem{
+			OrderID:     order.ID,
+			ProductID:   detailedPurchasedItem.ProductID,
+			VariantID:   detailedPurchasedItem.VariantID,
+			Amount:      detailedPurchasedItem.Amount,
+			Name:        detailedPurchasedItem.Name,
+			Description: detailedPurchasedItem.Description,
+			BrandName:   detailedPurchasedItem.BrandName,
+			Price:       detailedPurchasedItem.Price,
+			Snapshotted: true,
+			CreatedAt:   now.UnixMilli(),
+		})
+		header.TotalAmount += detailedPurchasedItem.Amount
+		header.TotalPrice += detailedPurchasedItem.Price * detailedPurchasedItem.Amount
+	}
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return err
+	}
+
+	replayed, err := replayProcessedMessage(tx, processed)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if replayed {
+		return tx.Commit().Error
+	}
+	if err := tx.Create(&header).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Create(&items).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Create(&model.OrderTransition{
+		OrderID:   order.ID,
+		Status:    string(domain_model.OrderPending),
+		Timestamp: now.UnixMilli(),
+	}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := createProcessedMessage(tx, processed); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// UpdateOrderStatus changes the status of an order together with the processed command and its reply
+// Changing an order to its current status does nothing, while a change not allowed by domain_model.OrderTransitions fails
+// If the command has been processed, its original reply is recorded again instead
+func (repo *OrderRepositoryImpl) UpdateOrderStatus(ctx context.Context, orderID uint64, status domain_model.OrderStatus, processed *domain_model.ProcessedMessage) error {
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return err
+	}
+
+	replayed, err := replayProcessedMessage(tx, processed)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if replayed {
+		return tx.Commit().Error
+	}
+	var order model.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "status").Where("id = ?", orderID).First(&order).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrOrderNotFound
+		}
+		return err
+	}
+	current := domain_model.OrderStatus(order.Status)
+	if current != status {
+		if !canTransitOrder(current, status) {
+			tx.Rollback()
+			return ErrInvalidOrderTransition
+		}
+		now := time.Now().UnixMilli()
+		if err := tx.Model(&model.Order{}).Where("id = ?", orderID).Updates(map[string]interface{}{
+			"status":            string(status),
+			"status_updated_at": now,
+		}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Create(&model.OrderTransition{
+			OrderID:   orderID,
+			Status:    string(status),
+			Timestamp: now,
+		}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := createProcessedMessage(tx, processed); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func canTransitOrder(from, to domain_model.OrderStatus) bool {
+	for _, next := range domain_model.OrderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,164 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	domain_model "github.com/minghsu0107/saga-product/domain/model"
+	"github.com/minghsu0107/saga-product/infra/db/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OutboxRepository is the outbox interface
+// Messages are relayed in the order they were recorded and deleted once they are published
+type OutboxRepository interface {
+	CreateOutboxMessages(ctx context.Context, messages ...*domain_model.OutboxMessage) error
+	ListOutboxMessages(ctx context.Context, service string, size int) (*[]domain_model.OutboxMessage, error)
+	RelayOutboxMessages(ctx context.Context, service string, size int, publish func(message *domain_model.OutboxMessage) error) (int, error)
+	DeleteOutboxMessages(ctx context.Context, ids []uint64) error
+}
+
+// OutboxRepositoryImpl implements OutboxRepository interface
+type OutboxRepositoryImpl struct {
+	db *gorm.DB
+}
+
+// NewOutboxRepository is the factory of OutboxRepository
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &OutboxRepositoryImpl{
+		db: db,
+	}
+}
+
+// CreateOutboxMessages records messages that are not bound to any business change
+func (repo *OutboxRepositoryImpl) CreateOutboxMessages(ctx context.Context, messages ...*domain_model.OutboxMessage) error {
+	return createOutboxMessages(repo.db.WithContext(ctx), messages)
+}
+
+// ListOutboxMessages lists the oldest messages of a service
+func (repo *OutboxRepositoryImpl) ListOutboxMessages(ctx context.Context, service string, size int) (*[]domain_model.OutboxMessage, error) {
+	var entries []model.Outbox
+	if err := repo.db.WithContext(ctx).Model(&model.Outbox{}).Where("service = ?", service).Order("id").Limit(size).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	var messages []domain_model.OutboxMessage
+	for _, entry := range entries {
+		message, err := mapOutboxMessage(&entry)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, *message)
+	}
+	return &messages, nil
+}
+
+// RelayOutboxMessages claims the oldest messages of a service, publishes them in order and deletes the published ones
+// Claimed messages stay locked until they are deleted, so concurrent relays of the service never publish the same message;
+// a relay that cannot claim the oldest message leaves the outbox to the relay holding it, so that messages keep their order
+// It returns the number of claimed messages, and stops at the first message that fails to be published
+func (repo *OutboxRepositoryImpl) RelayOutboxMessages(ctx context.Context, service string, size int, publish func(message *domain_model.OutboxMessage) error) (int, error) {
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return 0, err
+	}
+
+	var entries []model.Outbox
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).Where("service = ?", service).Order("id").Limit(size).Find(&entries).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if len(entries) == 0 {
+		tx.Rollback()
+		return 0, nil
+	}
+	var oldest model.Outbox
+	if err := tx.Select("id").Where("service = ?", service).Order("id").First(&oldest).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if oldest.ID != entries[0].ID {
+		tx.Rollback()
+		return 0, nil
+	}
+
+	var published []uint64
+	var publishErr error
+	for _, entry := range entries {
+		message, err := mapOutboxMessage(&entry)
+		if err != nil {
+			publishErr = err
+			break
+		}
+		if publishErr = publish(message); publishErr != nil {
+			break
+		}
+		published = append(published, entry.ID)
+	}
+	if len(published) > 0 {
+		if err := tx.Where("id IN ?", published).Delete(&model.Outbox{}).Error; err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
+	}
+	return len(entries), publishErr
+}
+
+// DeleteOutboxMessages deletes relayed messages
+func (repo *OutboxRepositoryImpl) DeleteOutboxMessages(ctx context.Context, ids []uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return repo.db.WithContext(ctx).Where("id IN ?", ids).Delete(&model.Outbox{}).Error
+}
+
+func mapOutboxMessage(entry *model.Outbox) (*domain_model.OutboxMessage, error) {
+	metadata := make(map[string]string)
+	if err := json.Unmarshal([]byte(entry.Metadata), &metadata); err != nil {
+		return nil, err
+	}
+	return &domain_model.OutboxMessage{
+		ID:        entry.ID,
+		Service:   entry.Service,
+		Transport: entry.Transport,
+		Topic:     entry.Topic,
+		UUID:      entry.UUID,
+		Payload:   entry.Payload,
+		Metadata:  metadata,
+	}, nil
+}
+
+// createOutboxMessages records messages with the given db, which is usually the transaction of a business change
+func createOutboxMessages(db *gorm.DB, messages []*domain_model.OutboxMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	var entries []model.Outbox
+	for _, message := range messages {
+		metadata, err := json.Marshal(message.Metadata)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, model.Outbox{
+			Service:   message.Service,
+			Transport: message.Transport,
+			Topic:     message.Topic,
+			UUID:      message.UUID,
+			Payload:   message.Payload,
+			Metadata:  string(metadata),
+		})
+	}
+	return db.Create(&entries).Error
+}
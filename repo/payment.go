@@ -2,18 +2,25 @@ package repo
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"strconv"
+	"time"
 
 	domain_model "github.com/minghsu0107/saga-product/domain/model"
 	"github.com/minghsu0107/saga-product/infra/db/model"
+	"github.com/minghsu0107/saga-product/pkg"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // PaymentRepository interface
 type PaymentRepository interface {
 	GetPayment(ctx context.Context, paymentID uint64) (*domain_model.Payment, error)
-	CreatePayment(ctx context.Context, payment *domain_model.Payment) error
-	DeletePayment(ctx context.Context, paymentID uint64) error
+	CreatePayment(ctx context.Context, payment *domain_model.Payment, processed *domain_model.ProcessedMessage) error
+	RefundPayment(ctx context.Context, refund *domain_model.Refund) (bool, *domain_model.Refund, error)
+	RefundFullPayment(ctx context.Context, paymentID uint64, processed *domain_model.ProcessedMessage) error
+	ListRefunds(ctx context.Context, paymentID uint64) (*[]domain_model.Refund, error)
 }
 
 // PaymentRepositoryImpl implementation
@@ -31,37 +38,211 @@ func NewPaymentRepository(db *gorm.DB) PaymentRepository {
 // GetPayment get an payment
 func (repo *PaymentRepositoryImpl) GetPayment(ctx context.Context, paymentID uint64) (*domain_model.Payment, error) {
 	var payment model.Payment
-	if err := repo.db.Model(&model.Payment{}).Select("id", "customer_id", "currency_code", "amount").Where("id = ?", paymentID).First(&payment).WithContext(ctx).Error; err != nil {
+	if err := repo.db.Model(&model.Payment{}).Select("id", "customer_id", "currency_code", "amount", "refunded_amount").Where("id = ?", paymentID).First(&payment).WithContext(ctx).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrPaymentNotFound
 		}
 		return nil, err
 	}
 	return &domain_model.Payment{
-		ID:           payment.ID,
-		CustomerID:   payment.CustomerID,
-		CurrencyCode: payment.CurrencyCode,
-		Amount:       payment.Amount,
+		ID:             payment.ID,
+		CustomerID:     payment.CustomerID,
+		CurrencyCode:   payment.CurrencyCode,
+		Amount:         payment.Amount,
+		RefundedAmount: payment.RefundedAmount,
 	}, nil
 }
 
-// CreatePayment creates a payment
-func (repo *PaymentRepositoryImpl) CreatePayment(ctx context.Context, payment *domain_model.Payment) error {
-	if err := repo.db.Create(&model.Payment{
+// CreatePayment creates a payment together with the processed command and its reply
+// If the command has been processed, its original reply is recorded again instead
+func (repo *PaymentRepositoryImpl) CreatePayment(ctx context.Context, payment *domain_model.Payment, processed *domain_model.ProcessedMessage) error {
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return err
+	}
+
+	replayed, err := replayProcessedMessage(tx, processed)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if replayed {
+		return tx.Commit().Error
+	}
+	if err := tx.Create(&model.Payment{
 		ID:           payment.ID,
 		CustomerID:   payment.CustomerID,
 		CurrencyCode: payment.CurrencyCode,
 		Amount:       payment.Amount,
-	}).WithContext(ctx).Error; err != nil {
+	}).Error; err != nil {
+		tx.Rollback()
 		return err
 	}
-	return nil
+	if err := createProcessedMessage(tx, processed); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
 }
 
-// DeletePayment deletes an payment
-func (repo *PaymentRepositoryImpl) DeletePayment(ctx context.Context, paymentID uint64) error {
-	if err := repo.db.Exec("DELETE FROM payments where id = ?", paymentID).Error; err != nil {
+// RefundPayment issues a refund of a payment
+// A refund whose idempotency key has been used is not issued again, and the refund issued before is returned
+// The returned boolean indicates whether the refund is issued by this call
+func (repo *PaymentRepositoryImpl) RefundPayment(ctx context.Context, refund *domain_model.Refund) (bool, *domain_model.Refund, error) {
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return false, nil, err
+	}
+
+	payment, err := lockPayment(tx, refund.PaymentID)
+	if err != nil {
+		tx.Rollback()
+		return false, nil, err
+	}
+	var issued model.Refund
+	err = tx.Where("idempotency_key = ?", refund.IdempotencyKey).First(&issued).Error
+	if err == nil {
+		tx.Rollback()
+		if issued.PaymentID != refund.PaymentID || issued.Amount != refund.Amount {
+			return false, nil, ErrIdempotencyKeyConflict
+		}
+		return false, mapRefund(&issued), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		tx.Rollback()
+		return false, nil, err
+	}
+	if refund.Amount > payment.Amount-payment.RefundedAmount {
+		tx.Rollback()
+		return false, nil, ErrRefundExceedsBalance
+	}
+	created, err := createRefund(tx, refund)
+	if err != nil {
+		tx.Rollback()
+		return false, nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return false, nil, err
+	}
+	return true, mapRefund(created), nil
+}
+
+// RefundFullPayment refunds the whole refundable amount of a payment together with the processed command and its reply
+// Nothing is refunded if the payment does not exist or has been fully refunded
+// If the command has been processed, its original reply is recorded again instead
+func (repo *PaymentRepositoryImpl) RefundFullPayment(ctx context.Context, paymentID uint64, processed *domain_model.ProcessedMessage) error {
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return err
+	}
+
+	replayed, err := replayProcessedMessage(tx, processed)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if replayed {
+		return tx.Commit().Error
+	}
+	payment, err := lockPayment(tx, paymentID)
+	if err != nil && !errors.Is(err, ErrPaymentNotFound) {
+		tx.Rollback()
+		return err
+	}
+	if err == nil && payment.Amount > payment.RefundedAmount {
+		if _, err := createRefund(tx, &domain_model.Refund{
+			PaymentID:      paymentID,
+			IdempotencyKey: pkg.Join("rollback:", strconv.FormatUint(paymentID, 10)),
+			Amount:         payment.Amount - payment.RefundedAmount,
+			Reason:         "rollback",
+			Actor:          "saga",
+		}); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := createProcessedMessage(tx, processed); err != nil {
+		tx.Rollback()
 		return err
 	}
-	return nil
+	return tx.Commit().Error
+}
+
+// ListRefunds lists refunds of a payment from the oldest
+func (repo *PaymentRepositoryImpl) ListRefunds(ctx context.Context, paymentID uint64) (*[]domain_model.Refund, error) {
+	var refunds []model.Refund
+	if err := repo.db.WithContext(ctx).Where("payment_id = ?", paymentID).Order("id").Find(&refunds).Error; err != nil {
+		return nil, err
+	}
+	var domainRefunds []domain_model.Refund
+	for i := range refunds {
+		domainRefunds = append(domainRefunds, *mapRefund(&refunds[i]))
+	}
+	return &domainRefunds, nil
+}
+
+func lockPayment(tx *gorm.DB, paymentID uint64) (*model.Payment, error) {
+	var payment model.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&model.Payment{}).Select("id", "amount", "refunded_amount").Where("id = ?", paymentID).First(&payment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPaymentNotFound
+		}
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// createRefund records a refund and adds its amount to the refunded amount of the payment
+// The payment should be locked by the transaction
+func createRefund(tx *gorm.DB, refund *domain_model.Refund) (*model.Refund, error) {
+	created := model.Refund{
+		PaymentID:      refund.PaymentID,
+		IdempotencyKey: refund.IdempotencyKey,
+		Amount:         refund.Amount,
+		Reason:         refund.Reason,
+		Actor:          refund.Actor,
+	}
+	if err := tx.Create(&created).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&model.Payment{}).Where("id = ?", refund.PaymentID).Update("refunded_amount", gorm.Expr("refunded_amount + ?", refund.Amount)).Error; err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func mapRefund(refund *model.Refund) *domain_model.Refund {
+	return &domain_model.Refund{
+		ID:             refund.ID,
+		PaymentID:      refund.PaymentID,
+		IdempotencyKey: refund.IdempotencyKey,
+		Amount:         refund.Amount,
+		Reason:         refund.Reason,
+		Actor:          refund.Actor,
+		CreatedAt:      time.UnixMilli(refund.CreatedAt),
+	}
 }
@@ -0,0 +1,104 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	domain_model "github.com/minghsu0107/saga-product/domain/model"
+	"github.com/minghsu0107/saga-product/infra/db/model"
+	"gorm.io/gorm"
+)
+
+// ProcessedMessageRepository is the processed message store interface
+// Saga handlers consult it so that a redelivered command is answered with its original reply instead of being executed again
+type ProcessedMessageRepository interface {
+	RecordProcessedMessage(ctx context.Context, processed *domain_model.ProcessedMessage) error
+}
+
+// ProcessedMessageRepositoryImpl implements ProcessedMessageRepository interface
+type ProcessedMessageRepositoryImpl struct {
+	db *gorm.DB
+}
+
+// NewProcessedMessageRepository is the factory of ProcessedMessageRepository
+func NewProcessedMessageRepository(db *gorm.DB) ProcessedMessageRepository {
+	return &ProcessedMessageRepositoryImpl{
+		db: db,
+	}
+}
+
+// RecordProcessedMessage records a command that is processed without any change, such as a failed command, together with its reply
+// If the command has been processed, its original reply is recorded to the outbox again instead
+func (repo *ProcessedMessageRepositoryImpl) RecordProcessedMessage(ctx context.Context, processed *domain_model.ProcessedMessage) error {
+	return recordProcessedMessage(repo.db.WithContext(ctx), processed)
+}
+
+func recordProcessedMessage(db *gorm.DB, processed *domain_model.ProcessedMessage) error {
+	tx := db.Begin(&sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return err
+	}
+
+	replayed, err := replayProcessedMessage(tx, processed)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if !replayed {
+		if err := createProcessedMessage(tx, processed); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// replayProcessedMessage records the original reply to the outbox again if the command has been processed
+// Concurrent deliveries of the same command both see it unprocessed, but only one of them can record it
+// since the processed message is keyed by the message UUID and purchase ID
+func replayProcessedMessage(tx *gorm.DB, processed *domain_model.ProcessedMessage) (bool, error) {
+	if processed == nil {
+		return false, nil
+	}
+	var entry model.ProcessedMessage
+	if err := tx.Model(&model.ProcessedMessage{}).Where("uuid = ? AND purchase_id = ?", processed.UUID, processed.PurchaseID).First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	var reply domain_model.OutboxMessage
+	if err := json.Unmarshal([]byte(entry.Reply), &reply); err != nil {
+		return false, err
+	}
+	return true, createOutboxMessages(tx, []*domain_model.OutboxMessage{&reply})
+}
+
+// createProcessedMessage records the processed command together with its reply
+func createProcessedMessage(tx *gorm.DB, processed *domain_model.ProcessedMessage) error {
+	if processed == nil {
+		return nil
+	}
+	reply, err := json.Marshal(processed.Reply)
+	if err != nil {
+		return err
+	}
+	if err := tx.Create(&model.ProcessedMessage{
+		UUID:       processed.UUID,
+		PurchaseID: processed.PurchaseID,
+		Reply:      string(reply),
+	}).Error; err != nil {
+		return err
+	}
+	return createOutboxMessages(tx, []*domain_model.OutboxMessage{processed.Reply})
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"time"
 
 	domain_model "github.com/minghsu0107/saga-product/domain/model"
 	"github.com/minghsu0107/saga-product/infra/db/model"
@@ -14,30 +15,60 @@ import (
 	"gorm.io/gorm/clause"
 )
 
+// ProductCatalogRepository is the product catalog repository interface
+type ProductCatalogRepository interface {
+	ListProducts(ctx context.Context, query *domain_model.ProductQuery, afterID uint64) (*[]ProductCatalog, error)
+}
+
 // ProductRepository is the product repository interface
 type ProductRepository interface {
+	ProductCatalogRepository
 	CheckProduct(ctx context.Context, cartItem *domain_model.CartItem) (*ProductStatus, error)
-	ListProducts(ctx context.Context, offset, size int) (*[]ProductCatalog, error)
 	GetProductDetail(ctx context.Context, productID uint64) (*ProductDetail, error)
-	GetProductInventory(ctx context.Context, productID uint64) (int64, error)
+	GetProductInventory(ctx context.Context, productID uint64) (*ProductInventory, error)
+	GetProductInventories(ctx context.Context, productIDs []uint64) (map[uint64]*ProductInventory, error)
 	CreateProduct(ctx context.Context, product *domain_model.Product) (uint64, error)
-	UpdateProductInventory(ctx context.Context, idempotencyKey uint64, purchasedItems *[]domain_model.PurchasedItem) error
-	RollbackProductInventory(ctx context.Context, idempotencyKey uint64) (bool, *[]domain_model.Idempotency, error)
+	CreateProducts(ctx context.Context, products *[]domain_model.Product) ([]uint64, error)
+	ExportProducts(ctx context.Context, batchSize int, fn func(products *[]domain_model.Product) error) error
+	UpdateProduct(ctx context.Context, productID uint64, update *domain_model.ProductUpdate) error
+	DeleteProduct(ctx context.Context, productID uint64) error
+	CreateProductVariant(ctx context.Context, variant *domain_model.ProductVariant) (uint64, error)
+	ListProductVariants(ctx context.Context, productID uint64) (*[]domain_model.ProductVariant, error)
+	UpdateProductVariant(ctx context.Context, productID, variantID uint64, update *domain_model.ProductVariantUpdate) error
+	AdjustProductInventory(ctx context.Context, adjustment *domain_model.InventoryAdjustment) (*domain_model.InventoryLedgerEntry, error)
+	ListInventoryLedger(ctx context.Context, productID uint64, offset, size int) (*[]domain_model.InventoryLedgerEntry, error)
+	GetPriceAt(ctx context.Context, productID, variantID uint64, at time.Time) (*domain_model.ProductPrice, error)
+	ListPriceHistory(ctx context.Context, productID uint64, offset, size int) (*[]domain_model.ProductPrice, error)
+	SchedulePriceChange(ctx context.Context, change *domain_model.PriceChange) (uint64, error)
+	ListPriceChanges(ctx context.Context, productID uint64) (*[]domain_model.PriceChange, error)
+	CancelPriceChange(ctx context.Context, productID, changeID uint64) error
+	ListDuePriceChanges(ctx context.Context, now time.Time, size int) ([]uint64, error)
+	ApplyPriceChange(ctx context.Context, changeID uint64, now time.Time) (*domain_model.PriceChange, error)
+	UpdateProductInventory(ctx context.Context, idempotencyKey uint64, purchasedItems *[]domain_model.PurchasedItem, expiresAt time.Time, processed *domain_model.ProcessedMessage) (bool, error)
+	RollbackProductInventory(ctx context.Context, idempotencyKey uint64, processed *domain_model.ProcessedMessage) (bool, *[]domain_model.Idempotency, error)
+	ConfirmProductInventory(ctx context.Context, idempotencyKey uint64, processed *domain_model.ProcessedMessage) (bool, *[]domain_model.Idempotency, error)
+	ListExpiredReservations(ctx context.Context, now time.Time, size int) ([]uint64, error)
+	ReleaseExpiredReservation(ctx context.Context, idempotencyKey uint64, now time.Time) (*[]domain_model.Idempotency, error)
 }
 
 // ProductStatus select schema
 type ProductStatus struct {
 	ProductID uint64
+	VariantID uint64
 	Price     int64
 	Exist     bool
 }
 
 type productCheck struct {
-	Price int64
+	Price    int64
+	Archived bool
 }
 
-type productInventory struct {
+// ProductInventory select schema
+// Inventory is the available quantity, and Reserved is the quantity held for ongoing purchases
+type ProductInventory struct {
 	Inventory int64
+	Reserved  int64
 }
 
 // ProductCatalog select schema
@@ -45,6 +76,7 @@ type ProductCatalog struct {
 	ID        uint64
 	Name      string
 	Inventory int64
+	Reserved  int64
 	Price     int64
 }
 
@@ -54,8 +86,12 @@ type ProductDetail struct {
 	Description string
 	BrandName   string
 	Price       int64
+	Archived    bool
 }
 
+// productBatchSize is the number of products inserted by a single statement
+const productBatchSize = 500
+
 // ProductRepositoryImpl implements ProductRepository interface
 type ProductRepositoryImpl struct {
 	db *gorm.DB
@@ -71,30 +107,85 @@ func NewProductRepository(db *gorm.DB, sf pkg.IDGenerator) ProductRepository {
 }
 
 // CheckProducts method
+// Archived and deleted products do not exist for sale, and neither do archived variants or the variants of such products
+// A variant is sold at its own price instead of the product price
 func (repo *ProductRepositoryImpl) CheckProduct(ctx context.Context, cartItem *domain_model.CartItem) (*ProductStatus, error) {
 	var check productCheck
 	productID := cartItem.ProductID
-	if err := repo.db.Model(&model.Product{}).Select("price").Where("id = ?", productID).First(&check).WithContext(ctx).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return &ProductStatus{
-				ProductID: productID,
-				Price:     0,
-				Exist:     false,
-			}, nil
-		}
+	err := repo.db.WithContext(ctx).Model(&model.Product{}).Select("price", "archived").Where("id = ?", productID).First(&check).Error
+	if err == nil && !check.Archived && cartItem.VariantID != 0 {
+		err = repo.db.WithContext(ctx).Model(&model.ProductVariant{}).Select("price", "archived").Where("id = ? AND product_id = ?", cartItem.VariantID, productID).First(&check).Error
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, err
 	}
+	if err != nil || check.Archived {
+		return &ProductStatus{
+			ProductID: productID,
+			VariantID: cartItem.VariantID,
+			Price:     0,
+			Exist:     false,
+		}, nil
+	}
 	return &ProductStatus{
 		ProductID: productID,
+		VariantID: cartItem.VariantID,
 		Price:     check.Price,
 		Exist:     true,
 	}, nil
 }
 
 // ListProducts method
-func (repo *ProductRepositoryImpl) ListProducts(ctx context.Context, offset, size int) (*[]ProductCatalog, error) {
+// Archived products are not listed, and the keyword is matched against the full-text index of name and description
+// A category matches the products assigned to it or to any of its descendants
+// A non-zero afterID lists the products following it in product ID order instead of skipping query.Offset rows
+func (repo *ProductRepositoryImpl) ListProducts(ctx context.Context, query *domain_model.ProductQuery, afterID uint64) (*[]ProductCatalog, error) {
+	tx := repo.db.WithContext(ctx).Model(&model.Product{}).Select("id", "name", "inventory", "reserved", "price").Where("archived = ?", false)
+	if query.Keyword != "" {
+		tx = tx.Where("MATCH (name, description) AGAINST (? IN NATURAL LANGUAGE MODE)", query.Keyword)
+	}
+	if query.BrandName != "" {
+		tx = tx.Where("brand_name = ?", query.BrandName)
+	}
+	if query.CategoryID > 0 {
+		subtree := repo.db.Model(&model.Category{}).Select("CONCAT(path, '%')").Where("id = ?", query.CategoryID)
+		tx = tx.Where("id IN (?)", repo.db.Model(&model.ProductCategory{}).Select("product_categories.product_id").
+			Joins("JOIN categories ON categories.id = product_categories.category_id").
+			Where("categories.path LIKE (?)", subtree))
+	}
+	if query.MinPrice > 0 {
+		tx = tx.Where("price >= ?", query.MinPrice)
+	}
+	if query.MaxPrice > 0 {
+		tx = tx.Where("price <= ?", query.MaxPrice)
+	}
+	if query.InStock {
+		tx = tx.Where("inventory > ?", 0)
+	}
+	offset := query.Offset
+	if afterID > 0 {
+		offset = 0
+		if query.Descending {
+			tx = tx.Where("id < ?", afterID)
+		} else {
+			tx = tx.Where("id > ?", afterID)
+		}
+	}
+	if query.SortBy == domain_model.SortByRelevance && query.Keyword != "" {
+		tx = tx.Clauses(clause.OrderBy{
+			Expression: clause.Expr{
+				SQL:  "MATCH (name, description) AGAINST (? IN NATURAL LANGUAGE MODE) DESC, id",
+				Vars: []interface{}{query.Keyword},
+			},
+		})
+	} else {
+		if query.SortBy != domain_model.SortByRelevance {
+			tx = tx.Order(clause.OrderByColumn{Column: clause.Column{Name: string(query.SortBy)}, Desc: query.Descending})
+		}
+		tx = tx.Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}, Desc: query.Descending})
+	}
 	var catalogs []ProductCatalog
-	if err := paginate(repo.db, offset, size).Model(&model.Product{}).Select("id", "name", "inventory", "price").Find(&catalogs).Error; err != nil {
+	if err := paginate(tx, offset, query.Size).Find(&catalogs).Error; err != nil {
 		return nil, err
 	}
 	return &catalogs, nil
@@ -103,7 +194,7 @@ func (repo *ProductRepositoryImpl) ListProducts(ctx context.Context, offset, siz
 // GetProductDetails method
 func (repo *ProductRepositoryImpl) GetProductDetail(ctx context.Context, productID uint64) (*ProductDetail, error) {
 	var productDetail ProductDetail
-	if err := repo.db.Model(&model.Product{}).Select("name", "description", "brand_name", "price").Where("id = ?", productID).First(&productDetail).WithContext(ctx).Error; err != nil {
+	if err := repo.db.WithContext(ctx).Model(&model.Product{}).Select("name", "description", "brand_name", "price", "archived").Where("id = ?", productID).First(&productDetail).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrProductNotFound
 		}
@@ -113,50 +204,228 @@ func (repo *ProductRepositoryImpl) GetProductDetail(ctx context.Context, product
 }
 
 // GetProductInventory method
-func (repo *ProductRepositoryImpl) GetProductInventory(ctx context.Context, productID uint64) (int64, error) {
-	var productInventory productInventory
-	if err := repo.db.Model(&model.Product{}).Select("inventory").Where("id = ?", productID).First(&productInventory).WithContext(ctx).Error; err != nil {
+func (repo *ProductRepositoryImpl) GetProductInventory(ctx context.Context, productID uint64) (*ProductInventory, error) {
+	var productInventory ProductInventory
+	if err := repo.db.WithContext(ctx).Model(&model.Product{}).Select("inventory", "reserved").Where("id = ?", productID).First(&productInventory).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return 0, ErrProductNotFound
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+	return &productInventory, nil
+}
+
+// GetProductInventories method
+// It gets the inventory of the products in a single query; products not found are left out
+func (repo *ProductRepositoryImpl) GetProductInventories(ctx context.Context, productIDs []uint64) (map[uint64]*ProductInventory, error) {
+	var rows []struct {
+		ID        uint64
+		Inventory int64
+		Reserved  int64
+	}
+	if err := repo.db.WithContext(ctx).Model(&model.Product{}).Select("id", "inventory", "reserved").Where("id IN ?", productIDs).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	inventories := make(map[uint64]*ProductInventory, len(rows))
+	for _, row := range rows {
+		inventories[row.ID] = &ProductInventory{
+			Inventory: row.Inventory,
+			Reserved:  row.Reserved,
 		}
-		return 0, err
 	}
-	return productInventory.Inventory, nil
+	return inventories, nil
 }
 
 // CreateProduct method
+// The price of the product is recorded in the price history as effective from now
 func (repo *ProductRepositoryImpl) CreateProduct(ctx context.Context, product *domain_model.Product) (uint64, error) {
 	sonyflakeID, err := repo.sf.NextID()
 	if err != nil {
 		return 0, err
 	}
-	if err := repo.db.Create(&model.Product{
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	if err := tx.Error; err != nil {
+		return 0, err
+	}
+
+	if err := tx.Create(&model.Product{
 		ID:          sonyflakeID,
 		Name:        product.Detail.Name,
 		Description: product.Detail.Description,
 		BrandName:   product.Detail.BrandName,
 		Inventory:   product.Inventory,
 		Price:       product.Detail.Price,
-	}).WithContext(ctx).Error; err != nil {
+	}).Error; err != nil {
+		tx.Rollback()
 		return 0, err
 	}
-	return sonyflakeID, nil
+	if err := recordPrice(tx, sonyflakeID, 0, product.Detail.Price, time.Now()); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return sonyflakeID, tx.Commit().Error
 }
 
-// UpdateProductInventory method
-func (repo *ProductRepositoryImpl) UpdateProductInventory(ctx context.Context, idempotencyKey uint64, purchasedItems *[]domain_model.PurchasedItem) error {
-	var err error
-	var idempotency model.Idempotency
-	err = repo.db.Model(&model.Idempotency{}).Where("id = ?", idempotencyKey).First(&idempotency).Error
-	if err == nil {
-		return ErrInvalidIdempotency
+// CreateProducts method
+// Products are inserted in batches within a single transaction, so either all or none of them are created
+func (repo *ProductRepositoryImpl) CreateProducts(ctx context.Context, products *[]domain_model.Product) ([]uint64, error) {
+	if len(*products) == 0 {
+		return []uint64{}, nil
 	}
-	if !errors.Is(err, gorm.ErrRecordNotFound) {
+	sonyflakeIDs, err := pkg.NextIDs(repo.sf, len(*products))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixMilli()
+	var rows []model.Product
+	var prices []model.PriceHistory
+	for i, product := range *products {
+		rows = append(rows, model.Product{
+			ID:          sonyflakeIDs[i],
+			Name:        product.Detail.Name,
+			Description: product.Detail.Description,
+			BrandName:   product.Detail.BrandName,
+			Inventory:   product.Inventory,
+			Price:       product.Detail.Price,
+		})
+		prices = append(prices, model.PriceHistory{
+			ProductID:   sonyflakeIDs[i],
+			Price:       product.Detail.Price,
+			EffectiveAt: now,
+		})
+	}
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	if err := tx.Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.CreateInBatches(&rows, productBatchSize).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.CreateInBatches(&prices, productBatchSize).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return sonyflakeIDs, tx.Commit().Error
+}
+
+// ExportProducts method
+// Products, including archived ones, are read in batches in product ID order and passed to fn, which stops the export by returning an error
+func (repo *ProductRepositoryImpl) ExportProducts(ctx context.Context, batchSize int, fn func(products *[]domain_model.Product) error) error {
+	var rows []model.Product
+	return repo.db.WithContext(ctx).Select("id", "name", "description", "brand_name", "price", "inventory", "reserved", "archived").
+		FindInBatches(&rows, batchSize, func(tx *gorm.DB, batch int) error {
+			var products []domain_model.Product
+			for _, row := range rows {
+				products = append(products, domain_model.Product{
+					ID: row.ID,
+					Detail: &domain_model.ProductDetail{
+						Name:        row.Name,
+						Description: row.Description,
+						BrandName:   row.BrandName,
+						Price:       row.Price,
+						Archived:    row.Archived,
+					},
+					Inventory: row.Inventory,
+					Reserved:  row.Reserved,
+				})
+			}
+			return fn(&products)
+		}).Error
+}
+
+// UpdateProduct method
+// A changed price is recorded in the price history as effective from now
+func (repo *ProductRepositoryImpl) UpdateProduct(ctx context.Context, productID uint64, update *domain_model.ProductUpdate) error {
+	updates := make(map[string]interface{})
+	if update.Name != nil {
+		updates["name"] = *update.Name
+	}
+	if update.Description != nil {
+		updates["description"] = *update.Description
+	}
+	if update.BrandName != nil {
+		updates["brand_name"] = *update.BrandName
+	}
+	if update.Price != nil {
+		updates["price"] = *update.Price
+	}
+	if update.Archived != nil {
+		updates["archived"] = *update.Archived
+	}
+
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
 		return err
 	}
 
-	sort.Slice(*purchasedItems, func(i, j int) bool { return (*purchasedItems)[i].ProductID < (*purchasedItems)[j].ProductID })
-	tx := repo.db.Begin(&sql.TxOptions{
+	var product model.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&model.Product{}).Select("id", "price").Where("id = ?", productID).First(&product).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrProductNotFound
+		}
+		return err
+	}
+	if len(updates) > 0 {
+		if err := tx.Model(&model.Product{}).Where("id = ?", productID).Updates(updates).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if update.Price != nil && *update.Price != product.Price {
+		if err := recordPrice(tx, productID, 0, *update.Price, time.Now()); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// DeleteProduct method
+// The product is soft-deleted so that the inventory of ongoing purchases can still be rollbacked
+func (repo *ProductRepositoryImpl) DeleteProduct(ctx context.Context, productID uint64) error {
+	result := repo.db.WithContext(ctx).Where("id = ?", productID).Delete(&model.Product{})
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return ErrProductNotFound
+	}
+	return nil
+}
+
+// CreateProductVariant method
+// SKUs are unique across all products
+func (repo *ProductRepositoryImpl) CreateProductVariant(ctx context.Context, variant *domain_model.ProductVariant) (uint64, error) {
+	sonyflakeID, err := repo.sf.NextID()
+	if err != nil {
+		return 0, err
+	}
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
 		Isolation: sql.LevelReadCommitted,
 	})
 	defer func() {
@@ -164,57 +433,588 @@ func (repo *ProductRepositoryImpl) UpdateProductInventory(ctx context.Context, i
 			tx.Rollback()
 		}
 	}()
+	if err := tx.Error; err != nil {
+		return 0, err
+	}
+
+	var product model.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", variant.ProductID).First(&product).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrProductNotFound
+		}
+		return 0, err
+	}
+	if err := checkSKU(tx, variant.SKU, 0); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := tx.Create(&model.ProductVariant{
+		ID:        sonyflakeID,
+		ProductID: variant.ProductID,
+		SKU:       variant.SKU,
+		Name:      variant.Name,
+		Price:     variant.Price,
+		Inventory: variant.Inventory,
+	}).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := recordPrice(tx, variant.ProductID, sonyflakeID, variant.Price, time.Now()); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return sonyflakeID, tx.Commit().Error
+}
+
+// ListProductVariants method
+func (repo *ProductRepositoryImpl) ListProductVariants(ctx context.Context, productID uint64) (*[]domain_model.ProductVariant, error) {
+	var variants []model.ProductVariant
+	if err := repo.db.WithContext(ctx).Where("product_id = ?", productID).Order("id").Find(&variants).Error; err != nil {
+		return nil, err
+	}
+	domainVariants := []domain_model.ProductVariant{}
+	for _, variant := range variants {
+		domainVariants = append(domainVariants, domain_model.ProductVariant{
+			ID:        variant.ID,
+			ProductID: variant.ProductID,
+			SKU:       variant.SKU,
+			Name:      variant.Name,
+			Price:     variant.Price,
+			Inventory: variant.Inventory,
+			Reserved:  variant.Reserved,
+			Archived:  variant.Archived,
+		})
+	}
+	return &domainVariants, nil
+}
+
+// UpdateProductVariant method
+// The inventory of a variant is only changed by inventory adjustments and purchases
+// A changed price is recorded in the price history as effective from now
+func (repo *ProductRepositoryImpl) UpdateProductVariant(ctx context.Context, productID, variantID uint64, update *domain_model.ProductVariantUpdate) error {
+	updates := make(map[string]interface{})
+	if update.SKU != nil {
+		updates["sku"] = *update.SKU
+	}
+	if update.Name != nil {
+		updates["name"] = *update.Name
+	}
+	if update.Price != nil {
+		updates["price"] = *update.Price
+	}
+	if update.Archived != nil {
+		updates["archived"] = *update.Archived
+	}
 
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
 	if err := tx.Error; err != nil {
 		return err
 	}
 
-	for _, purchasedItem := range *purchasedItems {
-		var productInventory productInventory
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&model.Product{}).Select("inventory").Where("id = ?", purchasedItem.ProductID).First(&productInventory).Error; err != nil {
+	var variant model.ProductVariant
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "price").Where("id = ? AND product_id = ?", variantID, productID).First(&variant).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrVariantNotFound
+		}
+		return err
+	}
+	if update.SKU != nil {
+		if err := checkSKU(tx, *update.SKU, variantID); err != nil {
 			tx.Rollback()
 			return err
 		}
-		if productInventory.Inventory < purchasedItem.Amount {
+	}
+	if len(updates) > 0 {
+		if err := tx.Model(&model.ProductVariant{}).Where("id = ?", variantID).Updates(updates).Error; err != nil {
 			tx.Rollback()
-			return ErrInsuffientInventory
+			return err
 		}
-		if err := tx.Model(&model.Product{}).Where("id = ?", purchasedItem.ProductID).Update("inventory", gorm.Expr("inventory - ?", purchasedItem.Amount)).Error; err != nil {
+	}
+	if update.Price != nil && *update.Price != variant.Price {
+		if err := recordPrice(tx, productID, variantID, *update.Price, time.Now()); err != nil {
 			tx.Rollback()
 			return err
 		}
 	}
+	return tx.Commit().Error
+}
+
+// checkSKU fails if the SKU is taken by any variant other than variantID
+func checkSKU(tx *gorm.DB, sku string, variantID uint64) error {
+	var count int64
+	if err := tx.Model(&model.ProductVariant{}).Where("sku = ? AND id <> ?", sku, variantID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrDuplicateSKU
+	}
+	return nil
+}
+
+// inventoryOf scopes tx to the row holding the inventory and price of a product, or of its variant if variantID is not zero
+func inventoryOf(tx *gorm.DB, productID, variantID uint64) *gorm.DB {
+	if variantID == 0 {
+		return tx.Model(&model.Product{}).Where("id = ?", productID)
+	}
+	return tx.Model(&model.ProductVariant{}).Where("id = ? AND product_id = ?", variantID, productID)
+}
+
+// AdjustProductInventory method
+// The inventory change and its ledger entry are written in the same transaction
+func (repo *ProductRepositoryImpl) AdjustProductInventory(ctx context.Context, adjustment *domain_model.InventoryAdjustment) (*domain_model.InventoryLedgerEntry, error) {
+	sonyflakeID, err := repo.sf.NextID()
+	if err != nil {
+		return nil, err
+	}
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return nil, err
+	}
+
+	var productInventory ProductInventory
+	if err := inventoryOf(tx.Clauses(clause.Locking{Strength: "UPDATE"}), adjustment.ProductID, adjustment.VariantID).Select("inventory").First(&productInventory).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if adjustment.VariantID != 0 {
+				return nil, ErrVariantNotFound
+			}
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+	inventory := productInventory.Inventory + adjustment.Delta
+	if inventory < 0 {
+		tx.Rollback()
+		return nil, ErrInsuffientInventory
+	}
+	if err := inventoryOf(tx, adjustment.ProductID, adjustment.VariantID).Update("inventory", gorm.Expr("inventory + ?", adjustment.Delta)).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	entry := model.InventoryLedger{
+		ID:        sonyflakeID,
+		ProductID: adjustment.ProductID,
+		VariantID: adjustment.VariantID,
+		Delta:     adjustment.Delta,
+		Reason:    string(adjustment.Reason),
+		Actor:     adjustment.Actor,
+		Inventory: inventory,
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return mapInventoryLedgerEntry(&entry), nil
+}
+
+// ListInventoryLedger method
+// Entries are listed from the oldest to the latest
+func (repo *ProductRepositoryImpl) ListInventoryLedger(ctx context.Context, productID uint64, offset, size int) (*[]domain_model.InventoryLedgerEntry, error) {
+	var entries []model.InventoryLedger
+	if err := paginate(repo.db.WithContext(ctx), offset, size).Where("product_id = ?", productID).Order("id").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	var ledger []domain_model.InventoryLedgerEntry
+	for i := range entries {
+		ledger = append(ledger, *mapInventoryLedgerEntry(&entries[i]))
+	}
+	return &ledger, nil
+}
+
+func mapInventoryLedgerEntry(entry *model.InventoryLedger) *domain_model.InventoryLedgerEntry {
+	return &domain_model.InventoryLedgerEntry{
+		ID:        entry.ID,
+		ProductID: entry.ProductID,
+		VariantID: entry.VariantID,
+		Delta:     entry.Delta,
+		Reason:    domain_model.AdjustmentReason(entry.Reason),
+		Actor:     entry.Actor,
+		Inventory: entry.Inventory,
+		CreatedAt: time.UnixMilli(entry.CreatedAt),
+	}
+}
+
+// recordPrice appends the price of a product, or of its variant, taking effect at effectiveAt to the price history
+func recordPrice(tx *gorm.DB, productID, variantID uint64, price int64, effectiveAt time.Time) error {
+	return tx.Create(&model.PriceHistory{
+		ProductID:   productID,
+		VariantID:   variantID,
+		Price:       price,
+		EffectiveAt: effectiveAt.UnixMilli(),
+	}).Error
+}
+
+// lockPrice locks the product, or its variant, and returns its current price
+func lockPrice(tx *gorm.DB, productID, variantID uint64) (int64, error) {
+	var check productCheck
+	if err := inventoryOf(tx.Clauses(clause.Locking{Strength: "UPDATE"}), productID, variantID).Select("price").First(&check).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if variantID != 0 {
+				return 0, ErrVariantNotFound
+			}
+			return 0, ErrProductNotFound
+		}
+		return 0, err
+	}
+	return check.Price, nil
+}
+
+// GetPriceAt method
+// It returns the price of the product, or of its variant, that was in effect at the given time
+func (repo *ProductRepositoryImpl) GetPriceAt(ctx context.Context, productID, variantID uint64, at time.Time) (*domain_model.ProductPrice, error) {
+	var entry model.PriceHistory
+	if err := repo.db.WithContext(ctx).Where("product_id = ? AND variant_id = ? AND effective_at <= ?", productID, variantID, at.UnixMilli()).
+		Order("effective_at DESC, id DESC").First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPriceNotFound
+		}
+		return nil, err
+	}
+	return mapPriceHistory(&entry), nil
+}
+
+// ListPriceHistory method
+// Prices of the product and its variants are listed from the oldest to the latest
+func (repo *ProductRepositoryImpl) ListPriceHistory(ctx context.Context, productID uint64, offset, size int) (*[]domain_model.ProductPrice, error) {
+	var entries []model.PriceHistory
+	if err := paginate(repo.db.WithContext(ctx), offset, size).Where("product_id = ?", productID).Order("effective_at, id").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	prices := []domain_model.ProductPrice{}
+	for i := range entries {
+		prices = append(prices, *mapPriceHistory(&entries[i]))
+	}
+	return &prices, nil
+}
+
+func mapPriceHistory(entry *model.PriceHistory) *domain_model.ProductPrice {
+	return &domain_model.ProductPrice{
+		ProductID:   entry.ProductID,
+		VariantID:   entry.VariantID,
+		Price:       entry.Price,
+		EffectiveAt: time.UnixMilli(entry.EffectiveAt),
+	}
+}
+
+// SchedulePriceChange method
+func (repo *ProductRepositoryImpl) SchedulePriceChange(ctx context.Context, change *domain_model.PriceChange) (uint64, error) {
+	sonyflakeID, err := repo.sf.NextID()
+	if err != nil {
+		return 0, err
+	}
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	if err := tx.Error; err != nil {
+		return 0, err
+	}
+
+	if _, err := lockPrice(tx, change.ProductID, change.VariantID); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := tx.Create(&model.ScheduledPriceChange{
+		ID:          sonyflakeID,
+		ProductID:   change.ProductID,
+		VariantID:   change.VariantID,
+		Price:       change.Price,
+		EffectiveAt: change.EffectiveAt.UnixMilli(),
+	}).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return sonyflakeID, tx.Commit().Error
+}
+
+// ListPriceChanges method
+// Scheduled changes of the product and its variants are listed in the order they take effect
+func (repo *ProductRepositoryImpl) ListPriceChanges(ctx context.Context, productID uint64) (*[]domain_model.PriceChange, error) {
+	var changes []model.ScheduledPriceChange
+	if err := repo.db.WithContext(ctx).Where("product_id = ?", productID).Order("effective_at, id").Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	domainChanges := []domain_model.PriceChange{}
+	for i := range changes {
+		domainChanges = append(domainChanges, *mapPriceChange(&changes[i]))
+	}
+	return &domainChanges, nil
+}
+
+// CancelPriceChange method
+func (repo *ProductRepositoryImpl) CancelPriceChange(ctx context.Context, productID, changeID uint64) error {
+	result := repo.db.WithContext(ctx).Where("id = ? AND product_id = ?", changeID, productID).Delete(&model.ScheduledPriceChange{})
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return ErrPriceChangeNotFound
+	}
+	return nil
+}
+
+// ListDuePriceChanges method
+// It lists the IDs of the scheduled changes that take effect at or before now, in the order they take effect
+func (repo *ProductRepositoryImpl) ListDuePriceChanges(ctx context.Context, now time.Time, size int) ([]uint64, error) {
+	var changeIDs []uint64
+	if err := repo.db.WithContext(ctx).Model(&model.ScheduledPriceChange{}).Where("effective_at <= ?", now.UnixMilli()).
+		Order("effective_at, id").Limit(size).Pluck("id", &changeIDs).Error; err != nil {
+		return nil, err
+	}
+	return changeIDs, nil
+}
+
+// ApplyPriceChange method
+// It sets the price of the due change and records it in the price history as effective from now, when it is actually charged
+// It returns the applied change, or nil if the change has been applied or cancelled in the meantime,
+// or if its product or variant has been deleted, in which case the change is dropped
+func (repo *ProductRepositoryImpl) ApplyPriceChange(ctx context.Context, changeID uint64, now time.Time) (*domain_model.PriceChange, error) {
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	if err := tx.Error; err != nil {
+		return nil, err
+	}
+
+	var change model.ScheduledPriceChange
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", changeID).First(&change).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if change.EffectiveAt > now.UnixMilli() {
+		tx.Rollback()
+		return nil, nil
+	}
+	if err := tx.Delete(&model.ScheduledPriceChange{}, changeID).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	price, err := lockPrice(tx, change.ProductID, change.VariantID)
+	if errors.Is(err, ErrProductNotFound) || errors.Is(err, ErrVariantNotFound) {
+		return nil, tx.Commit().Error
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if price != change.Price {
+		if err := inventoryOf(tx, change.ProductID, change.VariantID).Update("price", change.Price).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := recordPrice(tx, change.ProductID, change.VariantID, change.Price, now); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	return mapPriceChange(&change), tx.Commit().Error
+}
+
+func mapPriceChange(change *model.ScheduledPriceChange) *domain_model.PriceChange {
+	return &domain_model.PriceChange{
+		ID:          change.ID,
+		ProductID:   change.ProductID,
+		VariantID:   change.VariantID,
+		Price:       change.Price,
+		EffectiveAt: time.UnixMilli(change.EffectiveAt),
+	}
+}
+
+// UpdateProductInventory method
+// The purchased amount of each product or variant is moved from its available inventory to its reserved inventory, held until expiresAt
+// Products and variants are locked in the order of their product and variant IDs to avoid deadlocks between purchases
+// The processed command and its reply are recorded in the same transaction as the inventory change
+// If the command has been processed, its original reply is recorded again and the inventory is left untouched, in which case true is returned
+func (repo *ProductRepositoryImpl) UpdateProductInventory(ctx context.Context, idempotencyKey uint64, purchasedItems *[]domain_model.PurchasedItem, expiresAt time.Time, processed *domain_model.ProcessedMessage) (bool, error) {
+	sort.Slice(*purchasedItems, func(i, j int) bool {
+		a, b := (*purchasedItems)[i], (*purchasedItems)[j]
+		if a.ProductID != b.ProductID {
+			return a.ProductID < b.ProductID
+		}
+		return a.VariantID < b.VariantID
+	})
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return false, err
+	}
+
+	replayed, err := replayProcessedMessage(tx, processed)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if replayed {
+		return true, tx.Commit().Error
+	}
+
+	var idempotency model.Idempotency
+	err = tx.Model(&model.Idempotency{}).Where("id = ?", idempotencyKey).First(&idempotency).Error
+	if err == nil {
+		tx.Rollback()
+		return false, ErrInvalidIdempotency
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		tx.Rollback()
+		return false, err
+	}
+
+	for _, purchasedItem := range *purchasedItems {
+		var productInventory ProductInventory
+		if err := inventoryOf(tx.Clauses(clause.Locking{Strength: "UPDATE"}), purchasedItem.ProductID, purchasedItem.VariantID).Select("inventory").First(&productInventory).Error; err != nil {
+			tx.Rollback()
+			return false, err
+		}
+		if productInventory.Inventory < purchasedItem.Amount {
+			tx.Rollback()
+			return false, ErrInsuffientInventory
+		}
+		if err := inventoryOf(tx, purchasedItem.ProductID, purchasedItem.VariantID).Updates(map[string]interface{}{
+			"inventory": gorm.Expr("inventory - ?", purchasedItem.Amount),
+			"reserved":  gorm.Expr("reserved + ?", purchasedItem.Amount),
+		}).Error; err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
 
 	var idempotencies []model.Idempotency
 	for _, purchasedItem := range *purchasedItems {
 		idempotencies = append(idempotencies, model.Idempotency{
 			ID:         idempotencyKey,
 			ProductID:  purchasedItem.ProductID,
+			VariantID:  purchasedItem.VariantID,
 			Amount:     purchasedItem.Amount,
 			Rollbacked: false,
+			ExpiresAt:  expiresAt.UnixMilli(),
 		})
 	}
-	if err := tx.Model(&model.Idempotency{}).Create(&idempotencies).WithContext(ctx).Error; err != nil {
+	if err := tx.Model(&model.Idempotency{}).Create(&idempotencies).Error; err != nil {
 		tx.Rollback()
-		return err
+		return false, err
 	}
-	return tx.Commit().Error
+	if err := createProcessedMessage(tx, processed); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	return false, tx.Commit().Error
 }
 
 // RollbackProductInventory method
-func (repo *ProductRepositoryImpl) RollbackProductInventory(ctx context.Context, idempotencyKey uint64) (bool, *[]domain_model.Idempotency, error) {
+// A held inventory is released back to the available inventory, and a confirmed inventory is given back
+// The processed command and its reply are recorded in the same transaction as the inventory change, or on their own if the inventory has been rollbacked
+// If the command has been processed, its original reply is recorded again and the inventory is left untouched
+// Inventory is also given back to products deleted during the purchase
+// A hold released on expiry is marked as rollbacked by the saga, so that it is not reserved again by a late confirmation
+func (repo *ProductRepositoryImpl) RollbackProductInventory(ctx context.Context, idempotencyKey uint64, processed *domain_model.ProcessedMessage) (bool, *[]domain_model.Idempotency, error) {
 	var idempotencies []model.Idempotency
-	if err := repo.db.Model(&model.Idempotency{}).Select("product_id", "amount", "rollbacked").Where("id = ?", idempotencyKey).Order("product_id").Find(&idempotencies).Error; err != nil {
+	if err := repo.db.WithContext(ctx).Model(&model.Idempotency{}).Select("product_id", "variant_id", "amount", "rollbacked", "expired").Where("id = ?", idempotencyKey).Order("product_id, variant_id").Find(&idempotencies).Error; err != nil {
 		return false, nil, err
 	}
 	if len(idempotencies) == 0 {
 		return false, nil, fmt.Errorf("idempotency key not found: %v", idempotencyKey)
 	}
+	if idempotencies[0].Rollbacked && !idempotencies[0].Expired {
+		return true, nil, recordProcessedMessage(repo.db.WithContext(ctx), processed)
+	}
+
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return false, nil, err
+	}
+
+	replayed, err := replayProcessedMessage(tx, processed)
+	if err != nil {
+		tx.Rollback()
+		return false, nil, err
+	}
+	if replayed {
+		return true, nil, tx.Commit().Error
+	}
+
+	// the hold may have expired in the meantime
+	idempotencies, err = lockIdempotencies(tx, idempotencyKey)
+	if err != nil {
+		tx.Rollback()
+		return false, nil, err
+	}
 	if idempotencies[0].Rollbacked {
-		return true, nil, nil
+		if err := tx.Model(&model.Idempotency{}).Where("id = ?", idempotencyKey).Update("expired", false).Error; err != nil {
+			tx.Rollback()
+			return false, nil, err
+		}
+		if err := createProcessedMessage(tx, processed); err != nil {
+			tx.Rollback()
+			return false, nil, err
+		}
+		return true, nil, tx.Commit().Error
 	}
+	if err := releaseIdempotencies(tx, idempotencyKey, idempotencies); err != nil {
+		tx.Rollback()
+		return false, nil, err
+	}
+	if err := createProcessedMessage(tx, processed); err != nil {
+		tx.Rollback()
+		return false, nil, err
+	}
+	return false, mapIdempotencies(idempotencyKey, idempotencies), tx.Commit().Error
+}
 
-	tx := repo.db.Begin(&sql.TxOptions{
+// ConfirmProductInventory method
+// The held inventory of a purchase is confirmed and leaves the reserved inventory for good
+// A hold that expired before its purchase succeeded is taken from the available inventory again, in which case true is returned,
+// or fails with ErrInsuffientInventory if the inventory has been sold in the meantime;
+// an oversold hold is then released for good, and the failed command is recorded together with its reply in processed
+// It returns the confirmed holds, or nil if they have been confirmed before
+func (repo *ProductRepositoryImpl) ConfirmProductInventory(ctx context.Context, idempotencyKey uint64, processed *domain_model.ProcessedMessage) (bool, *[]domain_model.Idempotency, error) {
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
 		Isolation: sql.LevelReadCommitted,
 	})
 	defer func() {
@@ -227,30 +1027,173 @@ func (repo *ProductRepositoryImpl) RollbackProductInventory(ctx context.Context,
 		return false, nil, err
 	}
 
-	for _, idempotency := range idempotencies {
-		var productInventory productInventory
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&model.Product{}).Select("inventory").Where("id = ?", idempotency.ProductID).First(&productInventory).Error; err != nil {
+	idempotencies, err := lockIdempotencies(tx, idempotencyKey)
+	if err != nil {
+		tx.Rollback()
+		return false, nil, err
+	}
+	if idempotencies[0].Confirmed {
+		tx.Rollback()
+		return false, nil, nil
+	}
+	if idempotencies[0].Rollbacked {
+		if !idempotencies[0].Expired {
+			tx.Rollback()
+			return false, nil, ErrReservationReleased
+		}
+		if err := tx.SavePoint("reserve").Error; err != nil {
 			tx.Rollback()
 			return false, nil, err
 		}
-		if err := tx.Model(&model.Product{}).Where("id = ?", idempotency.ProductID).Update("inventory", gorm.Expr("inventory + ?", idempotency.Amount)).Error; err != nil {
+		if err := reserveIdempotencies(tx, idempotencyKey, idempotencies); err != nil {
+			if !errors.Is(err, ErrInsuffientInventory) {
+				tx.Rollback()
+				return false, nil, err
+			}
+			if err := tx.RollbackTo("reserve").Error; err != nil {
+				tx.Rollback()
+				return false, nil, err
+			}
+			if err := tx.Model(&model.Idempotency{}).Where("id = ?", idempotencyKey).Update("expired", false).Error; err != nil {
+				tx.Rollback()
+				return false, nil, err
+			}
+			if err := createProcessedMessage(tx, processed); err != nil {
+				tx.Rollback()
+				return false, nil, err
+			}
+			if err := tx.Commit().Error; err != nil {
+				return false, nil, err
+			}
+			return false, nil, ErrInsuffientInventory
+		}
+		return true, mapIdempotencies(idempotencyKey, idempotencies), tx.Commit().Error
+	}
+	for _, idempotency := range idempotencies {
+		if err := inventoryOf(tx.Unscoped(), idempotency.ProductID, idempotency.VariantID).Update("reserved", gorm.Expr("reserved - ?", idempotency.Amount)).Error; err != nil {
 			tx.Rollback()
 			return false, nil, err
 		}
 	}
-	if err := tx.Model(&model.Idempotency{}).Where("id = ?", idempotencyKey).Update("rollbacked", true).WithContext(ctx).Error; err != nil {
+	if err := tx.Model(&model.Idempotency{}).Where("id = ?", idempotencyKey).Update("confirmed", true).Error; err != nil {
 		tx.Rollback()
 		return false, nil, err
 	}
+	return false, mapIdempotencies(idempotencyKey, idempotencies), tx.Commit().Error
+}
+
+// ListExpiredReservations method
+// It lists the idempotency keys of the holds that are neither confirmed nor released before now
+func (repo *ProductRepositoryImpl) ListExpiredReservations(ctx context.Context, now time.Time, size int) ([]uint64, error) {
+	var idempotencyKeys []uint64
+	if err := repo.db.WithContext(ctx).Model(&model.Idempotency{}).Distinct("id").
+		Where("rollbacked = ? AND confirmed = ? AND expires_at > 0 AND expires_at <= ?", false, false, now.UnixMilli()).
+		Order("id").Limit(size).Pluck("id", &idempotencyKeys).Error; err != nil {
+		return nil, err
+	}
+	return idempotencyKeys, nil
+}
+
+// ReleaseExpiredReservation method
+// It releases the expired hold of the idempotency key back to the available inventory
+// It returns the released holds, or nil if the hold has been confirmed, released or extended in the meantime
+func (repo *ProductRepositoryImpl) ReleaseExpiredReservation(ctx context.Context, idempotencyKey uint64, now time.Time) (*[]domain_model.Idempotency, error) {
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return nil, err
+	}
+
+	idempotencies, err := lockIdempotencies(tx, idempotencyKey)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	hold := idempotencies[0]
+	if hold.Rollbacked || hold.Confirmed || hold.ExpiresAt == 0 || hold.ExpiresAt > now.UnixMilli() {
+		tx.Rollback()
+		return nil, nil
+	}
+	if err := releaseIdempotencies(tx, idempotencyKey, idempotencies); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Model(&model.Idempotency{}).Where("id = ?", idempotencyKey).Update("expired", true).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return mapIdempotencies(idempotencyKey, idempotencies), tx.Commit().Error
+}
+
+// lockIdempotencies locks the idempotencies of a key in product and variant order, the same order in which products and variants are locked
+func lockIdempotencies(tx *gorm.DB, idempotencyKey uint64) ([]model.Idempotency, error) {
+	var idempotencies []model.Idempotency
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&model.Idempotency{}).Where("id = ?", idempotencyKey).Order("product_id, variant_id").Find(&idempotencies).Error; err != nil {
+		return nil, err
+	}
+	if len(idempotencies) == 0 {
+		return nil, fmt.Errorf("idempotency key not found: %v", idempotencyKey)
+	}
+	return idempotencies, nil
+}
+
+// releaseIdempotencies gives the inventory of idempotencies back and marks them as rollbacked
+// Inventory that is still held also leaves the reserved inventory
+func releaseIdempotencies(tx *gorm.DB, idempotencyKey uint64, idempotencies []model.Idempotency) error {
+	for _, idempotency := range idempotencies {
+		updates := map[string]interface{}{
+			"inventory": gorm.Expr("inventory + ?", idempotency.Amount),
+		}
+		if !idempotency.Confirmed {
+			updates["reserved"] = gorm.Expr("reserved - ?", idempotency.Amount)
+		}
+		if err := inventoryOf(tx.Unscoped(), idempotency.ProductID, idempotency.VariantID).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Model(&model.Idempotency{}).Where("id = ?", idempotencyKey).Update("rollbacked", true).Error
+}
+
+// reserveIdempotencies takes the inventory of idempotencies released on expiry from the available inventory again and marks them as confirmed
+func reserveIdempotencies(tx *gorm.DB, idempotencyKey uint64, idempotencies []model.Idempotency) error {
+	for _, idempotency := range idempotencies {
+		var productInventory ProductInventory
+		if err := inventoryOf(tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}), idempotency.ProductID, idempotency.VariantID).Select("inventory").First(&productInventory).Error; err != nil {
+			return err
+		}
+		if productInventory.Inventory < idempotency.Amount {
+			return ErrInsuffientInventory
+		}
+		if err := inventoryOf(tx.Unscoped(), idempotency.ProductID, idempotency.VariantID).Update("inventory", gorm.Expr("inventory - ?", idempotency.Amount)).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Model(&model.Idempotency{}).Where("id = ?", idempotencyKey).Updates(map[string]interface{}{
+		"rollbacked": false,
+		"expired":    false,
+		"confirmed":  true,
+	}).Error
+}
+
+func mapIdempotencies(idempotencyKey uint64, idempotencies []model.Idempotency) *[]domain_model.Idempotency {
 	var domainIdempotencies []domain_model.Idempotency
 	for _, idempotency := range idempotencies {
 		domainIdempotencies = append(domainIdempotencies, domain_model.Idempotency{
 			ID:        idempotencyKey,
 			ProductID: idempotency.ProductID,
+			VariantID: idempotency.VariantID,
 			Amount:    idempotency.Amount,
+			Confirmed: idempotency.Confirmed,
 		})
 	}
-	return false, &domainIdempotencies, tx.Commit().Error
+	return &domainIdempotencies
 }
 
 func paginate(db *gorm.DB, offset, size int) *gorm.DB {
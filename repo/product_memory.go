@@ -0,0 +1,167 @@
+package repo
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	domain_model "github.com/minghsu0107/saga-product/domain/model"
+)
+
+type catalogEntry struct {
+	product domain_model.Product
+	seq     uint64
+}
+
+// InMemoryProductCatalogRepository implements ProductCatalogRepository interface in memory
+// It is a test double of the MySQL catalog search; a keyword matches a product when any of its words
+// appears in the product name or description, and relevance is the number of matched words
+type InMemoryProductCatalogRepository struct {
+	mu                sync.RWMutex
+	entries           map[uint64]catalogEntry
+	seq               uint64
+	categoryParents   map[uint64]uint64
+	productCategories map[uint64][]uint64
+}
+
+// NewInMemoryProductCatalogRepository is the factory of InMemoryProductCatalogRepository
+func NewInMemoryProductCatalogRepository() *InMemoryProductCatalogRepository {
+	return &InMemoryProductCatalogRepository{
+		entries:           make(map[uint64]catalogEntry),
+		categoryParents:   make(map[uint64]uint64),
+		productCategories: make(map[uint64][]uint64),
+	}
+}
+
+// SaveCategory creates or replaces a category
+func (repo *InMemoryProductCatalogRepository) SaveCategory(category *domain_model.Category) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.categoryParents[category.ID] = category.ParentID
+}
+
+// SetProductCategories replaces all category assignments of a product
+func (repo *InMemoryProductCatalogRepository) SetProductCategories(productID uint64, categoryIDs ...uint64) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.productCategories[productID] = categoryIDs
+}
+
+// inCategory tells whether the product is assigned to the category or any of its descendants
+func (repo *InMemoryProductCatalogRepository) inCategory(productID, categoryID uint64) bool {
+	for _, assigned := range repo.productCategories[productID] {
+		for id := assigned; id != 0; id = repo.categoryParents[id] {
+			if id == categoryID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SaveProduct creates or replaces a product; products are created in the order they are first saved
+func (repo *InMemoryProductCatalogRepository) SaveProduct(product *domain_model.Product) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	detail := *product.Detail
+	entry, ok := repo.entries[product.ID]
+	if !ok {
+		repo.seq++
+		entry.seq = repo.seq
+	}
+	entry.product = *product
+	entry.product.Detail = &detail
+	repo.entries[product.ID] = entry
+}
+
+// ListProducts method
+func (repo *InMemoryProductCatalogRepository) ListProducts(ctx context.Context, query *domain_model.ProductQuery, afterID uint64) (*[]ProductCatalog, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	terms := searchTerms(query.Keyword)
+	type match struct {
+		entry     catalogEntry
+		relevance int
+	}
+	var matches []match
+	for _, entry := range repo.entries {
+		product := entry.product
+		if product.Detail.Archived ||
+			(query.BrandName != "" && product.Detail.BrandName != query.BrandName) ||
+			(query.CategoryID > 0 && !repo.inCategory(product.ID, query.CategoryID)) ||
+			(query.MinPrice > 0 && product.Detail.Price < query.MinPrice) ||
+			(query.MaxPrice > 0 && product.Detail.Price > query.MaxPrice) ||
+			(query.InStock && product.Inventory <= 0) ||
+			(afterID > 0 && !query.Descending && product.ID <= afterID) ||
+			(afterID > 0 && query.Descending && product.ID >= afterID) {
+			continue
+		}
+		relevance := 0
+		if len(terms) > 0 {
+			words := make(map[string]bool)
+			for _, word := range searchTerms(product.Detail.Name + " " + product.Detail.Description) {
+				words[word] = true
+			}
+			for _, term := range terms {
+				if words[term] {
+					relevance++
+				}
+			}
+			if relevance == 0 {
+				continue
+			}
+		}
+		matches = append(matches, match{entry, relevance})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if query.SortBy == domain_model.SortByRelevance && len(terms) > 0 {
+			if a.relevance != b.relevance {
+				return a.relevance > b.relevance
+			}
+			return a.entry.product.ID < b.entry.product.ID
+		}
+		var less, greater bool
+		switch query.SortBy {
+		case domain_model.SortByPrice:
+			less, greater = a.entry.product.Detail.Price < b.entry.product.Detail.Price, a.entry.product.Detail.Price > b.entry.product.Detail.Price
+		case domain_model.SortByName:
+			less, greater = a.entry.product.Detail.Name < b.entry.product.Detail.Name, a.entry.product.Detail.Name > b.entry.product.Detail.Name
+		case domain_model.SortByCreatedAt:
+			less, greater = a.entry.seq < b.entry.seq, a.entry.seq > b.entry.seq
+		}
+		if !less && !greater {
+			less = a.entry.product.ID < b.entry.product.ID
+		}
+		if query.Descending {
+			return !less
+		}
+		return less
+	})
+
+	offset := query.Offset
+	if afterID > 0 {
+		offset = 0
+	}
+	catalogs := []ProductCatalog{}
+	for i := offset; i < len(matches) && len(catalogs) < query.Size; i++ {
+		product := matches[i].entry.product
+		catalogs = append(catalogs, ProductCatalog{
+			ID:        product.ID,
+			Name:      product.Detail.Name,
+			Inventory: product.Inventory,
+			Reserved:  product.Reserved,
+			Price:     product.Detail.Price,
+		})
+	}
+	return &catalogs, nil
+}
+
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"context"
+
+	conf "github.com/minghsu0107/saga-product/config"
+	domain_model "github.com/minghsu0107/saga-product/domain/model"
+	"github.com/minghsu0107/saga-product/infra/cache"
+	"github.com/minghsu0107/saga-product/repo"
+	"github.com/sirupsen/logrus"
+)
+
+// CategoryRepoCache interface
+type CategoryRepoCache interface {
+	CreateCategory(ctx context.Context, category *domain_model.Category) (uint64, error)
+	GetCategory(ctx context.Context, categoryID uint64) (*domain_model.Category, error)
+	ListCategories(ctx context.Context) (*[]domain_model.Category, error)
+	UpdateCategory(ctx context.Context, categoryID uint64, update *domain_model.CategoryUpdate) error
+	DeleteCategory(ctx context.Context, categoryID uint64) error
+	SetProductCategories(ctx context.Context, productID uint64, categoryIDs []uint64) error
+	GetProductCategories(ctx context.Context, productID uint64) (*[]domain_model.Category, error)
+}
+
+// CategoryRepoCacheImpl implementation
+// Categories are not cached, but changes to the taxonomy or to product assignments
+// bump the catalog version because they change which products a category listing matches
+type CategoryRepoCacheImpl struct {
+	categoryRepo repo.CategoryRepository
+	rc           cache.RedisCache
+	logger       *logrus.Entry
+}
+
+// NewCategoryRepoCache is the factory of CategoryRepoCache
+func NewCategoryRepoCache(config *conf.Config, repo repo.CategoryRepository, rc cache.RedisCache) CategoryRepoCache {
+	return &CategoryRepoCacheImpl{
+		categoryRepo: repo,
+		rc:           rc,
+		logger:       config.Logger.ContextLogger.WithField("type", "cache:CategoryRepoCache"),
+	}
+}
+
+func (c *CategoryRepoCacheImpl) CreateCategory(ctx context.Context, category *domain_model.Category) (uint64, error) {
+	return c.categoryRepo.CreateCategory(ctx, category)
+}
+
+func (c *CategoryRepoCacheImpl) GetCategory(ctx context.Context, categoryID uint64) (*domain_model.Category, error) {
+	return c.categoryRepo.GetCategory(ctx, categoryID)
+}
+
+func (c *CategoryRepoCacheImpl) ListCategories(ctx context.Context) (*[]domain_model.Category, error) {
+	return c.categoryRepo.ListCategories(ctx)
+}
+
+func (c *CategoryRepoCacheImpl) UpdateCategory(ctx context.Context, categoryID uint64, update *domain_model.CategoryUpdate) error {
+	if err := c.categoryRepo.UpdateCategory(ctx, categoryID, update); err != nil {
+		return err
+	}
+	if update.ParentID != nil {
+		c.logError(bumpCatalogVersion(ctx, c.rc))
+	}
+	return nil
+}
+
+func (c *CategoryRepoCacheImpl) DeleteCategory(ctx context.Context, categoryID uint64) error {
+	if err := c.categoryRepo.DeleteCategory(ctx, categoryID); err != nil {
+		return err
+	}
+	c.logError(bumpCatalogVersion(ctx, c.rc))
+	return nil
+}
+
+func (c *CategoryRepoCacheImpl) SetProductCategories(ctx context.Context, productID uint64, categoryIDs []uint64) error {
+	if err := c.categoryRepo.SetProductCategories(ctx, productID, categoryIDs); err != nil {
+		return err
+	}
+	c.logError(bumpCatalogVersion(ctx, c.rc))
+	return nil
+}
+
+func (c *CategoryRepoCacheImpl) GetProductCategories(ctx context.Context, productID uint64) (*[]domain_model.Category, error) {
+	return c.categoryRepo.GetProductCategories(ctx, productID)
+}
+
+func (c *CategoryRepoCacheImpl) logError(err error) {
+	if err == nil {
+		return
+	}
+	c.logger.Error(err.Error())
+}
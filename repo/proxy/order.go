@@ -2,6 +2,9 @@ package proxy
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
 	"strconv"
 
 	conf "github.com/minghsu0107/saga-product/config"
@@ -19,10 +22,10 @@ var (
 
 // OrderRepoCache interface
 type OrderRepoCache interface {
-	GetOrder(ctx context.Context, orderID uint64) (*domain_model.Order, error)
-	GetDetailedPurchasedItems(ctx context.Context, purchasedItems *[]domain_model.PurchasedItem) (*[]domain_model.DetailedPurchasedItem, error)
-	CreateOrder(ctx context.Context, order *domain_model.Order) error
-	DeleteOrder(ctx context.Context, orderID uint64) error
+	GetDetailedOrder(ctx context.Context, orderID uint64) (*domain_model.DetailedOrder, error)
+	ListCustomerOrders(ctx context.Context, query *domain_model.OrderQuery, beforeID uint64) (*[]domain_model.OrderSummary, error)
+	CreateOrder(ctx context.Context, order *domain_model.Order, processed *domain_model.ProcessedMessage) error
+	UpdateOrderStatus(ctx context.Context, orderID uint64, status domain_model.OrderStatus, processed *domain_model.ProcessedMessage) error
 }
 
 // OrderRepoCacheImpl implementation
@@ -78,7 +81,7 @@ func NewOrderRepoCache(config *conf.Config, repo repo.OrderRepository, rc cache.
 	return &orderRepoCache, nil
 }
 
-func (c *OrderRepoCacheImpl) GetOrder(ctx context.Context, orderID uint64) (*domain_model.Order, error) {
+func (c *OrderRepoCacheImpl) GetDetailedOrder(ctx context.Context, orderID uint64) (*domain_model.DetailedOrder, error) {
 	if c.useCuckoo {
 		exist, err := c.rc.CFExist(ctx, orderCuckooFilter, orderID)
 		c.logError(err)
@@ -93,15 +96,15 @@ func (c *OrderRepoCacheImpl) GetOrder(ctx context.Context, orderID uint64) (*dom
 		}
 	}
 
-	order := &domain_model.Order{}
-	key := pkg.Join("order:", strconv.FormatUint(orderID, 10))
+	order := &domain_model.DetailedOrder{}
+	key := detailedOrderKey(orderID)
 
 	ok, err := c.rc.Get(ctx, key, order)
 	if ok && err == nil {
 		return order, nil
 	}
 
-	order, err = c.orderRepo.GetOrder(ctx, orderID)
+	order, err = c.orderRepo.GetDetailedOrder(ctx, orderID)
 	if err != nil {
 		return nil, err
 	}
@@ -109,29 +112,90 @@ func (c *OrderRepoCacheImpl) GetOrder(ctx context.Context, orderID uint64) (*dom
 	return order, nil
 }
 
-func (c *OrderRepoCacheImpl) GetDetailedPurchasedItems(ctx context.Context, purchasedItems *[]domain_model.PurchasedItem) (*[]domain_model.DetailedPurchasedItem, error) {
-	return c.orderRepo.GetDetailedPurchasedItems(ctx, purchasedItems)
+// ListCustomerOrders method
+// Pages are cached under the order list version of the customer, which is bumped whenever an order of the customer is created or changes its status
+func (c *OrderRepoCacheImpl) ListCustomerOrders(ctx context.Context, query *domain_model.OrderQuery, beforeID uint64) (*[]domain_model.OrderSummary, error) {
+	var version int64
+	_, err := c.rc.Get(ctx, orderListVersionKey(query.CustomerID), &version)
+	if err != nil {
+		c.logError(err)
+		return c.orderRepo.ListCustomerOrders(ctx, query, beforeID)
+	}
+	key, err := orderListPageKey(version, query, beforeID)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := &[]domain_model.OrderSummary{}
+	ok, err := c.rc.Get(ctx, key, summaries)
+	c.logError(err)
+	if ok && err == nil {
+		return summaries, nil
+	}
+	summaries, err = c.orderRepo.ListCustomerOrders(ctx, query, beforeID)
+	if err != nil {
+		return nil, err
+	}
+	c.logError(c.rc.Set(ctx, key, summaries))
+	return summaries, nil
 }
 
-func (c *OrderRepoCacheImpl) CreateOrder(ctx context.Context, order *domain_model.Order) error {
+type orderListPage struct {
+	Query    *domain_model.OrderQuery
+	BeforeID uint64
+}
+
+func orderListPageKey(version int64, query *domain_model.OrderQuery, beforeID uint64) (string, error) {
+	payload, err := json.Marshal(&orderListPage{
+		Query:    query,
+		BeforeID: beforeID,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum(payload)
+	return pkg.Join("orderlist:", strconv.FormatUint(query.CustomerID, 10), ":", strconv.FormatInt(version, 10), ":", hex.EncodeToString(sum[:])), nil
+}
+
+func orderListVersionKey(customerID uint64) string {
+	return pkg.Join("orderlistversion:", strconv.FormatUint(customerID, 10))
+}
+
+// bumpOrderListVersion makes all cached order list pages of the customer obsolete
+func (c *OrderRepoCacheImpl) bumpOrderListVersion(ctx context.Context, customerID uint64) {
+	c.logError(c.rc.IncrBy(ctx, orderListVersionKey(customerID), 1))
+}
+
+func detailedOrderKey(orderID uint64) string {
+	return pkg.Join("detailedorder:", strconv.FormatUint(orderID, 10))
+}
+
+func (c *OrderRepoCacheImpl) CreateOrder(ctx context.Context, order *domain_model.Order, processed *domain_model.ProcessedMessage) error {
 	if c.useCuckoo {
 		c.logError(c.rc.CFAdd(ctx, orderCuckooFilter, order.ID))
 	} else {
 		c.logError(c.rc.BFAdd(ctx, orderBloomFilter, order.ID))
 	}
-	return c.orderRepo.CreateOrder(ctx, order)
+	if err := c.orderRepo.CreateOrder(ctx, order, processed); err != nil {
+		return err
+	}
+	c.bumpOrderListVersion(ctx, order.CustomerID)
+	return nil
 }
 
-func (c *OrderRepoCacheImpl) DeleteOrder(ctx context.Context, orderID uint64) error {
-	err := c.orderRepo.DeleteOrder(ctx, orderID)
-	if err != nil {
+// UpdateOrderStatus method
+// The cached order is invalidated, and the cached order list pages of its customer are made obsolete
+func (c *OrderRepoCacheImpl) UpdateOrderStatus(ctx context.Context, orderID uint64, status domain_model.OrderStatus, processed *domain_model.ProcessedMessage) error {
+	if err := c.orderRepo.UpdateOrderStatus(ctx, orderID, status, processed); err != nil {
 		return err
 	}
-	key := pkg.Join("order:", strconv.FormatUint(orderID, 10))
-	c.logError(c.rc.Delete(ctx, key))
-	if c.useCuckoo {
-		c.logError(c.rc.CFDel(ctx, orderCuckooFilter, orderID))
+	c.logError(c.rc.Delete(ctx, detailedOrderKey(orderID)))
+	order, err := c.orderRepo.GetDetailedOrder(ctx, orderID)
+	if err != nil {
+		c.logError(err)
+		return nil
 	}
+	c.bumpOrderListVersion(ctx, order.CustomerID)
 	return nil
 }
 
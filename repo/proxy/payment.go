@@ -20,8 +20,10 @@ var (
 // PaymentRepoCache interface
 type PaymentRepoCache interface {
 	GetPayment(ctx context.Context, paymentID uint64) (*domain_model.Payment, error)
-	CreatePayment(ctx context.Context, payment *domain_model.Payment) error
-	DeletePayment(ctx context.Context, paymentID uint64) error
+	CreatePayment(ctx context.Context, payment *domain_model.Payment, processed *domain_model.ProcessedMessage) error
+	RefundPayment(ctx context.Context, refund *domain_model.Refund) (bool, *domain_model.Refund, error)
+	RefundFullPayment(ctx context.Context, paymentID uint64, processed *domain_model.ProcessedMessage) error
+	ListRefunds(ctx context.Context, paymentID uint64) (*[]domain_model.Refund, error)
 }
 
 // PaymentRepoCacheImpl implementation
@@ -93,7 +95,7 @@ func (c *PaymentRepoCacheImpl) GetPayment(ctx context.Context, paymentID uint64)
 	}
 
 	payment := &domain_model.Payment{}
-	key := pkg.Join("payment:", strconv.FormatUint(paymentID, 10))
+	key := paymentKey(paymentID)
 
 	ok, err := c.rc.Get(ctx, key, payment)
 	c.logError(err)
@@ -110,28 +112,42 @@ func (c *PaymentRepoCacheImpl) GetPayment(ctx context.Context, paymentID uint64)
 	return payment, nil
 }
 
-func (c *PaymentRepoCacheImpl) CreatePayment(ctx context.Context, payment *domain_model.Payment) error {
+func (c *PaymentRepoCacheImpl) CreatePayment(ctx context.Context, payment *domain_model.Payment, processed *domain_model.ProcessedMessage) error {
 	if c.useCuckoo {
 		c.logError(c.rc.CFAdd(ctx, paymentCuckooFilter, payment.ID))
 	} else {
 		c.logError(c.rc.BFAdd(ctx, paymentBloomFilter, payment.ID))
 	}
-	return c.paymentRepo.CreatePayment(ctx, payment)
+	return c.paymentRepo.CreatePayment(ctx, payment, processed)
 }
 
-func (c *PaymentRepoCacheImpl) DeletePayment(ctx context.Context, paymentID uint64) error {
-	err := c.paymentRepo.DeletePayment(ctx, paymentID)
+func (c *PaymentRepoCacheImpl) RefundPayment(ctx context.Context, refund *domain_model.Refund) (bool, *domain_model.Refund, error) {
+	issued, created, err := c.paymentRepo.RefundPayment(ctx, refund)
 	if err != nil {
-		return err
+		return false, nil, err
 	}
-	key := pkg.Join("payment:", strconv.FormatUint(paymentID, 10))
-	c.logError(c.rc.Delete(ctx, key))
-	if c.useCuckoo {
-		c.logError(c.rc.CFDel(ctx, paymentCuckooFilter, paymentID))
+	if issued {
+		c.logError(c.rc.Delete(ctx, paymentKey(refund.PaymentID)))
+	}
+	return issued, created, nil
+}
+
+func (c *PaymentRepoCacheImpl) RefundFullPayment(ctx context.Context, paymentID uint64, processed *domain_model.ProcessedMessage) error {
+	if err := c.paymentRepo.RefundFullPayment(ctx, paymentID, processed); err != nil {
+		return err
 	}
+	c.logError(c.rc.Delete(ctx, paymentKey(paymentID)))
 	return nil
 }
 
+func (c *PaymentRepoCacheImpl) ListRefunds(ctx context.Context, paymentID uint64) (*[]domain_model.Refund, error) {
+	return c.paymentRepo.ListRefunds(ctx, paymentID)
+}
+
+func paymentKey(paymentID uint64) string {
+	return pkg.Join("payment:", strconv.FormatUint(paymentID, 10))
+}
+
 func (c *PaymentRepoCacheImpl) logError(err error) {
 	if err == nil {
 		return
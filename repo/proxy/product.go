@@ -2,13 +2,19 @@ package proxy
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
 	"strconv"
+	"time"
 
 	conf "github.com/minghsu0107/saga-product/config"
 	domain_model "github.com/minghsu0107/saga-product/domain/model"
 	"github.com/minghsu0107/saga-product/infra/cache"
 	"github.com/minghsu0107/saga-product/pkg"
 	"github.com/minghsu0107/saga-product/repo"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/sirupsen/logrus"
 )
 
@@ -16,17 +22,44 @@ var (
 	productBloomFilter  = "productbloom"
 	productCuckooFilter = "productcuckoo"
 	dummyItem           = "dummy"
+	catalogVersionKey   = "productcatalogversion"
+
+	catalogCacheRequests = promauto.NewCounterVec(prom.CounterOpts{
+		Namespace: "product",
+		Subsystem: "catalog_cache",
+		Name:      "requests_total",
+		Help:      "Number of product catalog listings by cache result (local_hit, redis_hit or miss).",
+	}, []string{"result"})
 )
 
 // ProductRepoCache interface
 type ProductRepoCache interface {
 	CheckProduct(ctx context.Context, cartItem *domain_model.CartItem) (*repo.ProductStatus, error)
-	ListProducts(ctx context.Context, offset, size int) (*[]repo.ProductCatalog, error)
+	ListProducts(ctx context.Context, query *domain_model.ProductQuery, afterID uint64) (*[]repo.ProductCatalog, error)
 	GetProductDetail(ctx context.Context, productID uint64) (*repo.ProductDetail, error)
-	GetProductInventory(ctx context.Context, productID uint64) (int64, error)
+	GetProductInventory(ctx context.Context, productID uint64) (*repo.ProductInventory, error)
 	CreateProduct(ctx context.Context, product *domain_model.Product) (uint64, error)
-	UpdateProductInventory(ctx context.Context, idempotencyKey uint64, purchasedItems *[]domain_model.PurchasedItem) error
-	RollbackProductInventory(ctx context.Context, idempotencyKey uint64) error
+	CreateProducts(ctx context.Context, products *[]domain_model.Product) ([]uint64, error)
+	ExportProducts(ctx context.Context, batchSize int, fn func(products *[]domain_model.Product) error) error
+	UpdateProduct(ctx context.Context, productID uint64, update *domain_model.ProductUpdate) error
+	DeleteProduct(ctx context.Context, productID uint64) error
+	CreateProductVariant(ctx context.Context, variant *domain_model.ProductVariant) (uint64, error)
+	ListProductVariants(ctx context.Context, productID uint64) (*[]domain_model.ProductVariant, error)
+	UpdateProductVariant(ctx context.Context, productID, variantID uint64, update *domain_model.ProductVariantUpdate) error
+	AdjustProductInventory(ctx context.Context, adjustment *domain_model.InventoryAdjustment) (*domain_model.InventoryLedgerEntry, error)
+	ListInventoryLedger(ctx context.Context, productID uint64, offset, size int) (*[]domain_model.InventoryLedgerEntry, error)
+	GetPriceAt(ctx context.Context, productID, variantID uint64, at time.Time) (*domain_model.ProductPrice, error)
+	ListPriceHistory(ctx context.Context, productID uint64, offset, size int) (*[]domain_model.ProductPrice, error)
+	SchedulePriceChange(ctx context.Context, change *domain_model.PriceChange) (uint64, error)
+	ListPriceChanges(ctx context.Context, productID uint64) (*[]domain_model.PriceChange, error)
+	CancelPriceChange(ctx context.Context, productID, changeID uint64) error
+	ListDuePriceChanges(ctx context.Context, now time.Time, size int) ([]uint64, error)
+	ApplyPriceChange(ctx context.Context, changeID uint64, now time.Time) (*domain_model.PriceChange, error)
+	UpdateProductInventory(ctx context.Context, idempotencyKey uint64, purchasedItems *[]domain_model.PurchasedItem, expiresAt time.Time, processed *domain_model.ProcessedMessage) error
+	RollbackProductInventory(ctx context.Context, idempotencyKey uint64, processed *domain_model.ProcessedMessage) error
+	ConfirmProductInventory(ctx context.Context, idempotencyKey uint64, processed *domain_model.ProcessedMessage) (bool, error)
+	ListExpiredReservations(ctx context.Context, now time.Time, size int) ([]uint64, error)
+	ReleaseExpiredReservation(ctx context.Context, idempotencyKey uint64, now time.Time) (bool, error)
 }
 
 // ProductRepoCacheImpl implementation
@@ -83,7 +116,12 @@ func NewProductRepoCache(config *conf.Config, repo repo.ProductRepository, lc ca
 	return &productRepoCache, nil
 }
 
+// CheckProduct method
+// Variants are checked against the database, since their statuses depend on both the product and the variant
 func (c *ProductRepoCacheImpl) CheckProduct(ctx context.Context, cartItem *domain_model.CartItem) (*repo.ProductStatus, error) {
+	if cartItem.VariantID != 0 {
+		return c.productRepo.CheckProduct(ctx, cartItem)
+	}
 	status := &repo.ProductStatus{}
 	key := pkg.Join("productcheck:", strconv.FormatUint(cartItem.ProductID, 10))
 
@@ -144,8 +182,154 @@ func (c *ProductRepoCacheImpl) CheckProduct(ctx context.Context, cartItem *domai
 	return status, nil
 }
 
-func (c *ProductRepoCacheImpl) ListProducts(ctx context.Context, offset, size int) (*[]repo.ProductCatalog, error) {
-	return c.productRepo.ListProducts(ctx, offset, size)
+// ListProducts method
+// Pages are cached without inventory under the current catalog version, which is bumped whenever a product changes,
+// so that a change makes every cached page of all instances obsolete at once
+// The inventory of a cached page is read from the inventory cache, which holds, confirmations and releases adjust in place;
+// pages of in-stock products depend on inventory and are not cached
+func (c *ProductRepoCacheImpl) ListProducts(ctx context.Context, query *domain_model.ProductQuery, afterID uint64) (*[]repo.ProductCatalog, error) {
+	if query.InStock {
+		return c.productRepo.ListProducts(ctx, query, afterID)
+	}
+	var version int64
+	_, err := c.rc.Get(ctx, catalogVersionKey, &version)
+	if err != nil {
+		c.logError(err)
+		catalogCacheRequests.WithLabelValues("miss").Inc()
+		return c.productRepo.ListProducts(ctx, query, afterID)
+	}
+	key, err := catalogPageKey(version, query, afterID)
+	if err != nil {
+		return nil, err
+	}
+
+	catalogs := &[]repo.ProductCatalog{}
+	ok, err := c.lc.Get(key, catalogs)
+	c.logError(err)
+	if ok && err == nil {
+		catalogCacheRequests.WithLabelValues("local_hit").Inc()
+		return c.fillCatalogInventory(ctx, catalogs)
+	}
+
+	ok, err = c.rc.Get(ctx, key, catalogs)
+	c.logError(err)
+	if ok && err == nil {
+		catalogCacheRequests.WithLabelValues("redis_hit").Inc()
+		c.logError(c.lc.Set(key, catalogs))
+		return c.fillCatalogInventory(ctx, catalogs)
+	}
+
+	// get lock (request coalescing)
+	mutex := c.rc.GetMutex(pkg.Join("mutex:", key))
+	if err := mutex.Lock(); err != nil {
+		return nil, err
+	}
+	defer mutex.Unlock()
+
+	ok, err = c.rc.Get(ctx, key, catalogs)
+	c.logError(err)
+	if ok && err == nil {
+		catalogCacheRequests.WithLabelValues("redis_hit").Inc()
+		c.logError(c.lc.Set(key, catalogs))
+		return c.fillCatalogInventory(ctx, catalogs)
+	}
+	catalogCacheRequests.WithLabelValues("miss").Inc()
+	catalogs, err = c.productRepo.ListProducts(ctx, query, afterID)
+	if err != nil {
+		return nil, err
+	}
+
+	page := make([]repo.ProductCatalog, len(*catalogs))
+	for i, catalog := range *catalogs {
+		catalog.Inventory, catalog.Reserved = 0, 0
+		page[i] = catalog
+	}
+	c.logError(c.rc.Set(ctx, key, &page))
+	return catalogs, nil
+}
+
+// fillCatalogInventory sets the inventory of each product on a cached page
+// The cached inventory of the page is read in a single pipeline, and the products missing in the cache are read from the database in a single query and cached
+func (c *ProductRepoCacheImpl) fillCatalogInventory(ctx context.Context, catalogs *[]repo.ProductCatalog) (*[]repo.ProductCatalog, error) {
+	if len(*catalogs) == 0 {
+		return catalogs, nil
+	}
+	keys := make([]string, 0, 2*len(*catalogs))
+	dsts := make([]interface{}, 0, 2*len(*catalogs))
+	for i := range *catalogs {
+		productID := strconv.FormatUint((*catalogs)[i].ID, 10)
+		keys = append(keys, pkg.Join("productinventory:", productID), pkg.Join("productreserved:", productID))
+		dsts = append(dsts, &(*catalogs)[i].Inventory, &(*catalogs)[i].Reserved)
+	}
+	exists, err := c.rc.MGet(ctx, keys, dsts)
+	c.logError(err)
+
+	var missedIDs []uint64
+	for i := range *catalogs {
+		if err != nil || !exists[2*i] || !exists[2*i+1] {
+			missedIDs = append(missedIDs, (*catalogs)[i].ID)
+		}
+	}
+	if len(missedIDs) == 0 {
+		return catalogs, nil
+	}
+	inventories, err := c.productRepo.GetProductInventories(ctx, missedIDs)
+	if err != nil {
+		return nil, err
+	}
+	var cmds []cache.RedisCmd
+	for i := range *catalogs {
+		inventory, ok := inventories[(*catalogs)[i].ID]
+		if !ok {
+			continue
+		}
+		(*catalogs)[i].Inventory = inventory.Inventory
+		(*catalogs)[i].Reserved = inventory.Reserved
+		productID := strconv.FormatUint((*catalogs)[i].ID, 10)
+		cmds = append(cmds, cache.RedisCmd{
+			OpType: cache.SET,
+			Payload: cache.RedisSetPayload{
+				Key: pkg.Join("productinventory:", productID),
+				Val: inventory.Inventory,
+			},
+		}, cache.RedisCmd{
+			OpType: cache.SET,
+			Payload: cache.RedisSetPayload{
+				Key: pkg.Join("productreserved:", productID),
+				Val: inventory.Reserved,
+			},
+		})
+	}
+	if len(cmds) > 0 {
+		c.logError(c.rc.ExecPipeLine(ctx, &cmds))
+	}
+	return catalogs, nil
+}
+
+type catalogPage struct {
+	Query   *domain_model.ProductQuery
+	AfterID uint64
+}
+
+func catalogPageKey(version int64, query *domain_model.ProductQuery, afterID uint64) (string, error) {
+	payload, err := json.Marshal(&catalogPage{
+		Query:   query,
+		AfterID: afterID,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum(payload)
+	return pkg.Join("productcatalog:", strconv.FormatInt(version, 10), ":", hex.EncodeToString(sum[:])), nil
+}
+
+func (c *ProductRepoCacheImpl) bumpCatalogVersion(ctx context.Context) {
+	c.logError(bumpCatalogVersion(ctx, c.rc))
+}
+
+// bumpCatalogVersion makes all cached catalog pages obsolete
+func bumpCatalogVersion(ctx context.Context, rc cache.RedisCache) error {
+	return rc.IncrBy(ctx, catalogVersionKey, 1)
 }
 
 func (c *ProductRepoCacheImpl) GetProductDetail(ctx context.Context, productID uint64) (*repo.ProductDetail, error) {
@@ -201,53 +385,82 @@ func (c *ProductRepoCacheImpl) GetProductDetail(ctx context.Context, productID u
 	return detail, nil
 }
 
-func (c *ProductRepoCacheImpl) GetProductInventory(ctx context.Context, productID uint64) (int64, error) {
+// GetProductInventory method
+// The available and reserved inventory are cached under their own keys so that both can be adjusted in place
+func (c *ProductRepoCacheImpl) GetProductInventory(ctx context.Context, productID uint64) (*repo.ProductInventory, error) {
 	if c.useCuckoo {
 		exist, err := c.rc.CFExist(ctx, productCuckooFilter, productID)
 		c.logError(err)
 		if !exist && err == nil {
-			return 0, repo.ErrProductNotFound
+			return nil, repo.ErrProductNotFound
 		}
 	} else {
 		exist, err := c.rc.BFExist(ctx, productBloomFilter, productID)
 		c.logError(err)
 		if !exist && err == nil {
-			return 0, repo.ErrProductNotFound
+			return nil, repo.ErrProductNotFound
 		}
 	}
 
-	var redisInventory int64
-	key := pkg.Join("productinventory:", strconv.FormatUint(productID, 10))
+	inventoryKey := pkg.Join("productinventory:", strconv.FormatUint(productID, 10))
+	reservedKey := pkg.Join("productreserved:", strconv.FormatUint(productID, 10))
 
-	ok, err := c.rc.Get(ctx, key, &redisInventory)
-	c.logError(err)
-	if ok && err == nil {
-		c.logError(c.lc.Set(key, &redisInventory))
-		return redisInventory, nil
+	inventory, ok := c.getCachedInventory(ctx, inventoryKey, reservedKey)
+	if ok {
+		return inventory, nil
 	}
 
 	// get lock (request coalescing)
-	mutex := c.rc.GetMutex(pkg.Join("mutex:", key))
+	mutex := c.rc.GetMutex(pkg.Join("mutex:", inventoryKey))
 	if err := mutex.Lock(); err != nil {
-		return 0, err
+		return nil, err
 	}
 	defer mutex.Unlock()
 
-	ok, err = c.rc.Get(ctx, key, &redisInventory)
-	c.logError(err)
-	if ok && err == nil {
-		return redisInventory, nil
+	inventory, ok = c.getCachedInventory(ctx, inventoryKey, reservedKey)
+	if ok {
+		return inventory, nil
 	}
-	var inventory int64
-	inventory, err = c.productRepo.GetProductInventory(ctx, productID)
+	inventory, err := c.productRepo.GetProductInventory(ctx, productID)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	c.logError(c.rc.Set(ctx, key, &inventory))
+	cmds := []cache.RedisCmd{
+		{
+			OpType: cache.SET,
+			Payload: cache.RedisSetPayload{
+				Key: inventoryKey,
+				Val: inventory.Inventory,
+			},
+		},
+		{
+			OpType: cache.SET,
+			Payload: cache.RedisSetPayload{
+				Key: reservedKey,
+				Val: inventory.Reserved,
+			},
+		},
+	}
+	c.logError(c.rc.ExecPipeLine(ctx, &cmds))
 	return inventory, nil
 }
 
+func (c *ProductRepoCacheImpl) getCachedInventory(ctx context.Context, inventoryKey, reservedKey string) (*repo.ProductInventory, bool) {
+	inventory := &repo.ProductInventory{}
+	ok, err := c.rc.Get(ctx, inventoryKey, &inventory.Inventory)
+	c.logError(err)
+	if !ok || err != nil {
+		return nil, false
+	}
+	ok, err = c.rc.Get(ctx, reservedKey, &inventory.Reserved)
+	c.logError(err)
+	if !ok || err != nil {
+		return nil, false
+	}
+	return inventory, true
+}
+
 func (c *ProductRepoCacheImpl) CreateProduct(ctx context.Context, product *domain_model.Product) (uint64, error) {
 	productID, err := c.productRepo.CreateProduct(ctx, product)
 	if err != nil {
@@ -258,25 +471,163 @@ func (c *ProductRepoCacheImpl) CreateProduct(ctx context.Context, product *domai
 	} else {
 		c.logError(c.rc.BFAdd(ctx, productBloomFilter, productID))
 	}
+	c.bumpCatalogVersion(ctx)
 	return productID, nil
 }
 
-func (c *ProductRepoCacheImpl) UpdateProductInventory(ctx context.Context, idempotencyKey uint64, purchasedItems *[]domain_model.PurchasedItem) error {
-	err := c.productRepo.UpdateProductInventory(ctx, idempotencyKey, purchasedItems)
+// CreateProducts method
+// The created products are added to the filter in a single pipeline
+func (c *ProductRepoCacheImpl) CreateProducts(ctx context.Context, products *[]domain_model.Product) ([]uint64, error) {
+	productIDs, err := c.productRepo.CreateProducts(ctx, products)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if len(productIDs) == 0 {
+		return productIDs, nil
+	}
+	opType, filter := cache.BFADD, productBloomFilter
+	if c.useCuckoo {
+		opType, filter = cache.CFADD, productCuckooFilter
 	}
 	var cmds []cache.RedisCmd
-	for _, purchasedItem := range *purchasedItems {
-		key := pkg.Join("productinventory:", strconv.FormatUint(purchasedItem.ProductID, 10))
+	for _, productID := range productIDs {
 		cmds = append(cmds, cache.RedisCmd{
-			OpType: cache.INCRBYX,
-			Payload: cache.RedisIncrByXPayload{
-				Key: key,
-				Val: -purchasedItem.Amount,
+			OpType: opType,
+			Payload: cache.RedisFilterAddPayload{
+				Key:  filter,
+				Item: productID,
 			},
 		})
 	}
+	c.logError(c.rc.ExecPipeLine(ctx, &cmds))
+	c.bumpCatalogVersion(ctx)
+	return productIDs, nil
+}
+
+func (c *ProductRepoCacheImpl) ExportProducts(ctx context.Context, batchSize int, fn func(products *[]domain_model.Product) error) error {
+	return c.productRepo.ExportProducts(ctx, batchSize, fn)
+}
+
+// UpdateProduct method
+// Cached details and statuses of the product are invalidated after the update;
+// local caches of other instances expire on their own
+func (c *ProductRepoCacheImpl) UpdateProduct(ctx context.Context, productID uint64, update *domain_model.ProductUpdate) error {
+	if err := c.productRepo.UpdateProduct(ctx, productID, update); err != nil {
+		return err
+	}
+	c.invalidate(ctx, productID, "productdetail:", "productcheck:")
+	c.bumpCatalogVersion(ctx)
+	return nil
+}
+
+// DeleteProduct method
+// All cached entries of the product are invalidated and the product is removed from the cuckoo filter;
+// a bloom filter does not support deletion, so the product keeps passing it and is found missing in the database
+func (c *ProductRepoCacheImpl) DeleteProduct(ctx context.Context, productID uint64) error {
+	if err := c.productRepo.DeleteProduct(ctx, productID); err != nil {
+		return err
+	}
+	if c.useCuckoo {
+		c.logError(c.rc.CFDel(ctx, productCuckooFilter, productID))
+	}
+	c.invalidate(ctx, productID, "productdetail:", "productcheck:", "productinventory:", "productreserved:")
+	c.bumpCatalogVersion(ctx)
+	return nil
+}
+
+// CreateProductVariant method
+// Cached catalog pages are made obsolete as on any other change of the product
+func (c *ProductRepoCacheImpl) CreateProductVariant(ctx context.Context, variant *domain_model.ProductVariant) (uint64, error) {
+	variantID, err := c.productRepo.CreateProductVariant(ctx, variant)
+	if err != nil {
+		return 0, err
+	}
+	c.bumpCatalogVersion(ctx)
+	return variantID, nil
+}
+
+func (c *ProductRepoCacheImpl) ListProductVariants(ctx context.Context, productID uint64) (*[]domain_model.ProductVariant, error) {
+	return c.productRepo.ListProductVariants(ctx, productID)
+}
+
+// UpdateProductVariant method
+// Cached catalog pages are made obsolete as on any other change of the product
+func (c *ProductRepoCacheImpl) UpdateProductVariant(ctx context.Context, productID, variantID uint64, update *domain_model.ProductVariantUpdate) error {
+	if err := c.productRepo.UpdateProductVariant(ctx, productID, variantID, update); err != nil {
+		return err
+	}
+	c.bumpCatalogVersion(ctx)
+	return nil
+}
+
+// AdjustProductInventory method
+// The cached inventory is adjusted in the same way as a purchase changes it
+func (c *ProductRepoCacheImpl) AdjustProductInventory(ctx context.Context, adjustment *domain_model.InventoryAdjustment) (*domain_model.InventoryLedgerEntry, error) {
+	entry, err := c.productRepo.AdjustProductInventory(ctx, adjustment)
+	if err != nil {
+		return nil, err
+	}
+	cmds := incrInventoryCmds(adjustment.ProductID, adjustment.VariantID, adjustment.Delta, 0)
+	c.logError(c.rc.ExecPipeLine(ctx, &cmds))
+	return entry, nil
+}
+
+func (c *ProductRepoCacheImpl) ListInventoryLedger(ctx context.Context, productID uint64, offset, size int) (*[]domain_model.InventoryLedgerEntry, error) {
+	return c.productRepo.ListInventoryLedger(ctx, productID, offset, size)
+}
+
+func (c *ProductRepoCacheImpl) GetPriceAt(ctx context.Context, productID, variantID uint64, at time.Time) (*domain_model.ProductPrice, error) {
+	return c.productRepo.GetPriceAt(ctx, productID, variantID, at)
+}
+
+func (c *ProductRepoCacheImpl) ListPriceHistory(ctx context.Context, productID uint64, offset, size int) (*[]domain_model.ProductPrice, error) {
+	return c.productRepo.ListPriceHistory(ctx, productID, offset, size)
+}
+
+func (c *ProductRepoCacheImpl) SchedulePriceChange(ctx context.Context, change *domain_model.PriceChange) (uint64, error) {
+	return c.productRepo.SchedulePriceChange(ctx, change)
+}
+
+func (c *ProductRepoCacheImpl) ListPriceChanges(ctx context.Context, productID uint64) (*[]domain_model.PriceChange, error) {
+	return c.productRepo.ListPriceChanges(ctx, productID)
+}
+
+func (c *ProductRepoCacheImpl) CancelPriceChange(ctx context.Context, productID, changeID uint64) error {
+	return c.productRepo.CancelPriceChange(ctx, productID, changeID)
+}
+
+func (c *ProductRepoCacheImpl) ListDuePriceChanges(ctx context.Context, now time.Time, size int) ([]uint64, error) {
+	return c.productRepo.ListDuePriceChanges(ctx, now, size)
+}
+
+// ApplyPriceChange method
+// The cached price of a product is invalidated in the same way as a product update; variant prices are not cached
+func (c *ProductRepoCacheImpl) ApplyPriceChange(ctx context.Context, changeID uint64, now time.Time) (*domain_model.PriceChange, error) {
+	change, err := c.productRepo.ApplyPriceChange(ctx, changeID, now)
+	if err != nil {
+		return nil, err
+	}
+	if change != nil && change.VariantID == 0 {
+		c.invalidate(ctx, change.ProductID, "productdetail:", "productcheck:")
+		c.bumpCatalogVersion(ctx)
+	}
+	return change, nil
+}
+
+// UpdateProductInventory method
+// The cached inventory is moved to the cached reserved inventory along with the hold
+func (c *ProductRepoCacheImpl) UpdateProductInventory(ctx context.Context, idempotencyKey uint64, purchasedItems *[]domain_model.PurchasedItem, expiresAt time.Time, processed *domain_model.ProcessedMessage) error {
+	replayed, err := c.productRepo.UpdateProductInventory(ctx, idempotencyKey, purchasedItems, expiresAt, processed)
+	if err != nil {
+		return err
+	}
+	if replayed {
+		return nil
+	}
+	var cmds []cache.RedisCmd
+	for _, purchasedItem := range *purchasedItems {
+		cmds = append(cmds, incrInventoryCmds(purchasedItem.ProductID, purchasedItem.VariantID, -purchasedItem.Amount, purchasedItem.Amount)...)
+	}
 	if len(cmds) > 0 {
 		c.logError(c.rc.ExecPipeLine(ctx, &cmds))
 	}
@@ -284,32 +635,118 @@ func (c *ProductRepoCacheImpl) UpdateProductInventory(ctx context.Context, idemp
 }
 
 // RollbackProductInventory method
-func (c *ProductRepoCacheImpl) RollbackProductInventory(ctx context.Context, idempotencyKey uint64) error {
+func (c *ProductRepoCacheImpl) RollbackProductInventory(ctx context.Context, idempotencyKey uint64, processed *domain_model.ProcessedMessage) error {
 	var err error
 	var rollbacked bool
 	var idempotencies *[]domain_model.Idempotency
-	rollbacked, idempotencies, err = c.productRepo.RollbackProductInventory(ctx, idempotencyKey)
+	rollbacked, idempotencies, err = c.productRepo.RollbackProductInventory(ctx, idempotencyKey, processed)
 	if err != nil {
 		return err
 	}
 	if rollbacked {
 		return nil
 	}
+	c.releaseInventory(ctx, idempotencies)
+	return nil
+}
+
+// ConfirmProductInventory method
+// It returns whether a hold released on expiry is taken from the available inventory again
+func (c *ProductRepoCacheImpl) ConfirmProductInventory(ctx context.Context, idempotencyKey uint64, processed *domain_model.ProcessedMessage) (bool, error) {
+	reserved, idempotencies, err := c.productRepo.ConfirmProductInventory(ctx, idempotencyKey, processed)
+	if err != nil {
+		return false, err
+	}
+	if idempotencies == nil {
+		return false, nil
+	}
 	var cmds []cache.RedisCmd
 	for _, idempotency := range *idempotencies {
-		key := pkg.Join("productinventory:", strconv.FormatUint(idempotency.ProductID, 10))
+		if reserved {
+			cmds = append(cmds, incrInventoryCmds(idempotency.ProductID, idempotency.VariantID, -idempotency.Amount, 0)...)
+			continue
+		}
+		cmds = append(cmds, incrInventoryCmds(idempotency.ProductID, idempotency.VariantID, 0, -idempotency.Amount)...)
+	}
+	if len(cmds) > 0 {
+		c.logError(c.rc.ExecPipeLine(ctx, &cmds))
+	}
+	return reserved, nil
+}
+
+func (c *ProductRepoCacheImpl) ListExpiredReservations(ctx context.Context, now time.Time, size int) ([]uint64, error) {
+	return c.productRepo.ListExpiredReservations(ctx, now, size)
+}
+
+// ReleaseExpiredReservation method
+// It returns whether the hold is released
+func (c *ProductRepoCacheImpl) ReleaseExpiredReservation(ctx context.Context, idempotencyKey uint64, now time.Time) (bool, error) {
+	idempotencies, err := c.productRepo.ReleaseExpiredReservation(ctx, idempotencyKey, now)
+	if err != nil {
+		return false, err
+	}
+	if idempotencies == nil {
+		return false, nil
+	}
+	c.releaseInventory(ctx, idempotencies)
+	return true, nil
+}
+
+// releaseInventory gives the released inventory back to the cached inventory
+func (c *ProductRepoCacheImpl) releaseInventory(ctx context.Context, idempotencies *[]domain_model.Idempotency) {
+	var cmds []cache.RedisCmd
+	for _, idempotency := range *idempotencies {
+		var reserved int64
+		if !idempotency.Confirmed {
+			reserved = -idempotency.Amount
+		}
+		cmds = append(cmds, incrInventoryCmds(idempotency.ProductID, idempotency.VariantID, idempotency.Amount, reserved)...)
+	}
+	if len(cmds) > 0 {
+		c.logError(c.rc.ExecPipeLine(ctx, &cmds))
+	}
+}
+
+// incrInventoryCmds adjusts the cached inventory of a product; variant inventory is not cached
+func incrInventoryCmds(productID, variantID uint64, inventory, reserved int64) []cache.RedisCmd {
+	var cmds []cache.RedisCmd
+	if variantID != 0 {
+		return cmds
+	}
+	if inventory != 0 {
 		cmds = append(cmds, cache.RedisCmd{
 			OpType: cache.INCRBYX,
 			Payload: cache.RedisIncrByXPayload{
-				Key: key,
-				Val: idempotency.Amount,
+				Key: pkg.Join("productinventory:", strconv.FormatUint(productID, 10)),
+				Val: inventory,
 			},
 		})
 	}
-	if len(cmds) > 0 {
-		c.logError(c.rc.ExecPipeLine(ctx, &cmds))
+	if reserved != 0 {
+		cmds = append(cmds, cache.RedisCmd{
+			OpType: cache.INCRBYX,
+			Payload: cache.RedisIncrByXPayload{
+				Key: pkg.Join("productreserved:", strconv.FormatUint(productID, 10)),
+				Val: reserved,
+			},
+		})
 	}
-	return nil
+	return cmds
+}
+
+func (c *ProductRepoCacheImpl) invalidate(ctx context.Context, productID uint64, prefixes ...string) {
+	var cmds []cache.RedisCmd
+	for _, prefix := range prefixes {
+		key := pkg.Join(prefix, strconv.FormatUint(productID, 10))
+		c.logError(c.lc.Delete(key))
+		cmds = append(cmds, cache.RedisCmd{
+			OpType: cache.DELETE,
+			Payload: cache.RedisDeletePayload{
+				Key: key,
+			},
+		})
+	}
+	c.logError(c.rc.ExecPipeLine(ctx, &cmds))
 }
 
 func (c *ProductRepoCacheImpl) logError(err error) {
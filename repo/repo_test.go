@@ -2,15 +2,18 @@ package repo
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sort"
 	"testing"
 	"time"
 
-	conf "github.com/minghsu0107/saga-product/config"
-	grpc_order "github.com/minghsu0107/saga-product/infra/grpc/order"
+	pb "github.com/minghsu0107/saga-pb"
+	product_pb "github.com/minghsu0107/saga-product/pb"
 	"github.com/minghsu0107/saga-product/pkg"
 
 	domain_model "github.com/minghsu0107/saga-product/domain/model"
+	infra_db "github.com/minghsu0107/saga-product/infra/db"
 	"github.com/minghsu0107/saga-product/infra/db/model"
 
 	. "github.com/onsi/ginkgo"
@@ -18,12 +21,37 @@ import (
 )
 
 var (
-	productRepo ProductRepository
-	orderRepo   OrderRepository
-	paymentRepo PaymentRepository
-	sf          pkg.IDGenerator
+	productRepo          ProductRepository
+	categoryRepo         CategoryRepository
+	orderRepo            OrderRepository
+	paymentRepo          PaymentRepository
+	sagaRepo             SagaRepository
+	outboxRepo           OutboxRepository
+	processedMessageRepo ProcessedMessageRepository
+	sf                   pkg.IDGenerator
 )
 
+// getFakeProducts returns products priced at ten times their IDs
+func getFakeProducts(ctx context.Context, request interface{}) (interface{}, error) {
+	var products []*pb.Product
+	for _, productID := range request.(*pb.GetProductsRequest).ProductIds {
+		products = append(products, &pb.Product{
+			ProductId:   productID,
+			ProductName: fmt.Sprintf("product %d", productID),
+			BrandName:   "brand",
+			Price:       int64(productID) * 10,
+		})
+	}
+	return &pb.Products{
+		Products: products,
+	}, nil
+}
+
+// getNoPrices returns no recorded price
+func getNoPrices(ctx context.Context, request interface{}) (interface{}, error) {
+	return &product_pb.Prices{}, nil
+}
+
 func TestRepo(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "repo suite")
@@ -36,21 +64,23 @@ var _ = BeforeSuite(func() {
 	if err != nil {
 		panic(err)
 	}
-	config := conf.Config{
-		ServiceOptions: &conf.ServiceOptions{
-			Rps:     100,
-			Timeout: time.Minute,
-		},
-	}
 	productRepo = NewProductRepository(db, sf)
-	orderRepo = NewOrderRepository(&config, new(grpc_order.ProductConn), db)
+	categoryRepo = NewCategoryRepository(db, sf)
+	orderRepo = &OrderRepositoryImpl{
+		db:          db,
+		getProducts: getFakeProducts,
+		getPricesAt: getNoPrices,
+	}
 	paymentRepo = NewPaymentRepository(db)
-	db.Migrator().DropTable(&model.Product{}, &model.Idempotency{}, &model.Order{}, &model.Payment{})
-	db.AutoMigrate(&model.Product{}, &model.Idempotency{}, &model.Order{}, &model.Payment{})
+	sagaRepo = NewSagaRepository(db)
+	outboxRepo = NewOutboxRepository(db)
+	processedMessageRepo = NewProcessedMessageRepository(db)
+	db.Migrator().DropTable(&model.Product{}, &model.ProductVariant{}, &model.Idempotency{}, &model.InventoryLedger{}, &model.PriceHistory{}, &model.ScheduledPriceChange{}, &model.Category{}, &model.ProductCategory{}, &model.Order{}, &model.OrderItem{}, &model.OrderTransition{}, &model.Payment{}, &model.Refund{}, &model.SagaInstance{}, &model.SagaTransition{}, &model.Outbox{}, &model.ProcessedMessage{})
+	db.AutoMigrate(&model.Product{}, &model.ProductVariant{}, &model.Idempotency{}, &model.InventoryLedger{}, &model.PriceHistory{}, &model.ScheduledPriceChange{}, &model.Category{}, &model.ProductCategory{}, &model.Order{}, &model.OrderItem{}, &model.OrderTransition{}, &model.Payment{}, &model.Refund{}, &model.SagaInstance{}, &model.SagaTransition{}, &model.Outbox{}, &model.ProcessedMessage{})
 })
 
 var _ = AfterSuite(func() {
-	db.Migrator().DropTable(&model.Product{}, &model.Idempotency{})
+	db.Migrator().DropTable(&model.Product{}, &model.ProductVariant{}, &model.Idempotency{}, &model.InventoryLedger{}, &model.PriceHistory{}, &model.ScheduledPriceChange{}, &model.Category{}, &model.ProductCategory{})
 	sqlDB, err := db.DB()
 	if err != nil {
 		panic(err)
@@ -98,7 +128,10 @@ var _ = Describe("test repo", func() {
 			By("should list proudcts", func() {
 				offset := 0
 				size := 100
-				catalogs, err := productRepo.ListProducts(context.Background(), offset, size)
+				catalogs, err := productRepo.ListProducts(context.Background(), &domain_model.ProductQuery{
+					Offset: offset,
+					Size:   size,
+				}, 0)
 				Expect(err).To(BeNil())
 				Expect(len(*catalogs)).To(Equal(len(products)))
 
@@ -146,12 +179,26 @@ var _ = Describe("test repo", func() {
 				for i, productCatalog := range productCatalogs {
 					inventory, err := productRepo.GetProductInventory(context.Background(), productCatalog.ID)
 					Expect(err).To(BeNil())
-					Expect(inventory).To(Equal(products[i].Inventory))
+					Expect(inventory).To(Equal(&ProductInventory{
+						Inventory: products[i].Inventory,
+					}))
+				}
+				productIDs := []uint64{0}
+				for _, productCatalog := range productCatalogs {
+					productIDs = append(productIDs, productCatalog.ID)
+				}
+				inventories, err := productRepo.GetProductInventories(context.Background(), productIDs)
+				Expect(err).To(BeNil())
+				Expect(len(inventories)).To(Equal(len(productCatalogs)))
+				for i, productCatalog := range productCatalogs {
+					Expect(inventories[productCatalog.ID]).To(Equal(&ProductInventory{
+						Inventory: products[i].Inventory,
+					}))
 				}
 			})
 			By("update inventory case", func() {
 				var idempotencyKey uint64 = 1
-				By("should update product inventory", func() {
+				By("should hold product inventory", func() {
 					for _, cartItem := range cartItems {
 						productID := cartItem.ProductID
 						amount := cartItem.Amount
@@ -160,17 +207,20 @@ var _ = Describe("test repo", func() {
 							Amount:    amount,
 						})
 					}
-					err := productRepo.UpdateProductInventory(context.Background(), idempotencyKey, &purchasedItems)
+					_, err := productRepo.UpdateProductInventory(context.Background(), idempotencyKey, &purchasedItems, time.Now().Add(time.Minute), nil)
 					Expect(err).To(BeNil())
 
 					for i, productCatalog := range productCatalogs {
 						inventory, err := productRepo.GetProductInventory(context.Background(), productCatalog.ID)
 						Expect(err).To(BeNil())
-						Expect(inventory).To(Equal(productCatalog.Inventory - purchasedItems[i].Amount))
+						Expect(inventory).To(Equal(&ProductInventory{
+							Inventory: productCatalog.Inventory - purchasedItems[i].Amount,
+							Reserved:  purchasedItems[i].Amount,
+						}))
 					}
 				})
 				By("should not violate idempotency when updating product inventory again", func() {
-					err := productRepo.UpdateProductInventory(context.Background(), idempotencyKey, &purchasedItems)
+					_, err := productRepo.UpdateProductInventory(context.Background(), idempotencyKey, &purchasedItems, time.Now().Add(time.Minute), nil)
 					Expect(err).To(Equal(ErrInvalidIdempotency))
 				})
 				By("should fail if inventory is not enough", func() {
@@ -182,12 +232,12 @@ var _ = Describe("test repo", func() {
 							Amount:    1000,
 						})
 					}
-					err := productRepo.UpdateProductInventory(context.Background(), idempotencyKey, &purchasedItems)
+					_, err := productRepo.UpdateProductInventory(context.Background(), idempotencyKey, &purchasedItems, time.Now().Add(time.Minute), nil)
 					Expect(err).To(Equal(ErrInsuffientInventory))
 				})
 				By("should rollback inventory", func() {
 					idempotencyKey = 1
-					rollbacked, idempotencies, err := productRepo.RollbackProductInventory(context.Background(), idempotencyKey)
+					rollbacked, idempotencies, err := productRepo.RollbackProductInventory(context.Background(), idempotencyKey, nil)
 					Expect(err).To(BeNil())
 					Expect(rollbacked).To(BeFalse())
 
@@ -205,18 +255,676 @@ var _ = Describe("test repo", func() {
 				})
 				By("should not rollback inventory again", func() {
 					idempotencyKey = 1
-					rollbacked, _, err := productRepo.RollbackProductInventory(context.Background(), idempotencyKey)
+					rollbacked, _, err := productRepo.RollbackProductInventory(context.Background(), idempotencyKey, nil)
 					Expect(err).To(BeNil())
 					Expect(rollbacked).To(BeTrue())
 				})
 			})
 		})
+		var _ = It("should update, archive and delete product", func() {
+			productID, err := productRepo.CreateProduct(context.Background(), &domain_model.Product{
+				Detail: &domain_model.ProductDetail{
+					Name:        "lifecycle",
+					Description: "lifecycle product",
+					BrandName:   "mybrand",
+					Price:       50,
+				},
+				Inventory: 10,
+			})
+			Expect(err).To(BeNil())
+
+			By("should update product detail", func() {
+				name := "renamed"
+				var price int64 = 60
+				err := productRepo.UpdateProduct(context.Background(), productID, &domain_model.ProductUpdate{
+					Name:  &name,
+					Price: &price,
+				})
+				Expect(err).To(BeNil())
+				detail, err := productRepo.GetProductDetail(context.Background(), productID)
+				Expect(err).To(BeNil())
+				Expect(detail).To(Equal(&ProductDetail{
+					Name:        name,
+					Description: "lifecycle product",
+					BrandName:   "mybrand",
+					Price:       price,
+				}))
+			})
+			By("should not sell archived product", func() {
+				archived := true
+				err := productRepo.UpdateProduct(context.Background(), productID, &domain_model.ProductUpdate{
+					Archived: &archived,
+				})
+				Expect(err).To(BeNil())
+				status, err := productRepo.CheckProduct(context.Background(), &domain_model.CartItem{ProductID: productID})
+				Expect(err).To(BeNil())
+				Expect(status.Exist).To(BeFalse())
+				detail, err := productRepo.GetProductDetail(context.Background(), productID)
+				Expect(err).To(BeNil())
+				Expect(detail.Archived).To(BeTrue())
+				catalogs, err := productRepo.ListProducts(context.Background(), &domain_model.ProductQuery{Size: 500}, 0)
+				Expect(err).To(BeNil())
+				for _, catalog := range *catalogs {
+					Expect(catalog.ID).NotTo(Equal(productID))
+				}
+			})
+			By("should rollback inventory of deleted product", func() {
+				var idempotencyKey uint64 = 3
+				_, err := productRepo.UpdateProductInventory(context.Background(), idempotencyKey, &[]domain_model.PurchasedItem{
+					{
+						ProductID: productID,
+						Amount:    4,
+					},
+				}, time.Now().Add(time.Minute), nil)
+				Expect(err).To(BeNil())
+				Expect(productRepo.DeleteProduct(context.Background(), productID)).To(BeNil())
+
+				status, err := productRepo.CheckProduct(context.Background(), &domain_model.CartItem{ProductID: productID})
+				Expect(err).To(BeNil())
+				Expect(status.Exist).To(BeFalse())
+				_, err = productRepo.GetProductDetail(context.Background(), productID)
+				Expect(err).To(Equal(ErrProductNotFound))
+
+				rollbacked, _, err := productRepo.RollbackProductInventory(context.Background(), idempotencyKey, nil)
+				Expect(err).To(BeNil())
+				Expect(rollbacked).To(BeFalse())
+			})
+			By("should not update or delete deleted product", func() {
+				name := "deleted"
+				err := productRepo.UpdateProduct(context.Background(), productID, &domain_model.ProductUpdate{
+					Name: &name,
+				})
+				Expect(err).To(Equal(ErrProductNotFound))
+				Expect(productRepo.DeleteProduct(context.Background(), productID)).To(Equal(ErrProductNotFound))
+			})
+		})
+		var _ = It("should confirm or expire inventory holds", func() {
+			productID, err := productRepo.CreateProduct(context.Background(), &domain_model.Product{
+				Detail: &domain_model.ProductDetail{
+					Name:        "reservation",
+					Description: "reservation product",
+					BrandName:   "mybrand",
+					Price:       20,
+				},
+				Inventory: 10,
+			})
+			Expect(err).To(BeNil())
+			now := time.Now()
+			var confirmedKey, expiredKey, rollbackedKey uint64 = 4, 5, 6
+			for _, idempotencyKey := range []uint64{confirmedKey, expiredKey, rollbackedKey} {
+				_, err := productRepo.UpdateProductInventory(context.Background(), idempotencyKey, &[]domain_model.PurchasedItem{
+					{
+						ProductID: productID,
+						Amount:    3,
+					},
+				}, now.Add(time.Minute), nil)
+				Expect(err).To(BeNil())
+			}
+
+			By("should confirm hold", func() {
+				reserved, idempotencies, err := productRepo.ConfirmProductInventory(context.Background(), confirmedKey, nil)
+				Expect(err).To(BeNil())
+				Expect(reserved).To(BeFalse())
+				Expect(idempotencies).To(Equal(&[]domain_model.Idempotency{
+					{
+						ID:        confirmedKey,
+						ProductID: productID,
+						Amount:    3,
+					},
+				}))
+				_, idempotencies, err = productRepo.ConfirmProductInventory(context.Background(), confirmedKey, nil)
+				Expect(err).To(BeNil())
+				Expect(idempotencies).To(BeNil())
+				inventory, err := productRepo.GetProductInventory(context.Background(), productID)
+				Expect(err).To(BeNil())
+				Expect(inventory).To(Equal(&ProductInventory{
+					Inventory: 1,
+					Reserved:  6,
+				}))
+			})
+			By("should release expired hold", func() {
+				idempotencyKeys, err := productRepo.ListExpiredReservations(context.Background(), now, 10)
+				Expect(err).To(BeNil())
+				Expect(idempotencyKeys).To(BeEmpty())
+				idempotencyKeys, err = productRepo.ListExpiredReservations(context.Background(), now.Add(time.Minute), 10)
+				Expect(err).To(BeNil())
+				Expect(idempotencyKeys).To(Equal([]uint64{expiredKey, rollbackedKey}))
+
+				for _, idempotencyKey := range idempotencyKeys {
+					idempotencies, err := productRepo.ReleaseExpiredReservation(context.Background(), idempotencyKey, now.Add(time.Minute))
+					Expect(err).To(BeNil())
+					Expect(len(*idempotencies)).To(Equal(1))
+					idempotencies, err = productRepo.ReleaseExpiredReservation(context.Background(), idempotencyKey, now.Add(time.Minute))
+					Expect(err).To(BeNil())
+					Expect(idempotencies).To(BeNil())
+				}
+				inventory, err := productRepo.GetProductInventory(context.Background(), productID)
+				Expect(err).To(BeNil())
+				Expect(inventory).To(Equal(&ProductInventory{
+					Inventory: 7,
+					Reserved:  0,
+				}))
+			})
+			By("should not confirm expired hold rollbacked by the saga", func() {
+				rollbacked, _, err := productRepo.RollbackProductInventory(context.Background(), rollbackedKey, nil)
+				Expect(err).To(BeNil())
+				Expect(rollbacked).To(BeTrue())
+				_, _, err = productRepo.ConfirmProductInventory(context.Background(), rollbackedKey, nil)
+				Expect(err).To(Equal(ErrReservationReleased))
+			})
+			By("should reserve expired hold again on confirmation", func() {
+				reserved, idempotencies, err := productRepo.ConfirmProductInventory(context.Background(), expiredKey, nil)
+				Expect(err).To(BeNil())
+				Expect(reserved).To(BeTrue())
+				Expect(len(*idempotencies)).To(Equal(1))
+				_, idempotencies, err = productRepo.ConfirmProductInventory(context.Background(), expiredKey, nil)
+				Expect(err).To(BeNil())
+				Expect(idempotencies).To(BeNil())
+				inventory, err := productRepo.GetProductInventory(context.Background(), productID)
+				Expect(err).To(BeNil())
+				Expect(inventory).To(Equal(&ProductInventory{
+					Inventory: 4,
+					Reserved:  0,
+				}))
+			})
+			By("should give confirmed inventory back on rollback", func() {
+				for _, idempotencyKey := range []uint64{confirmedKey, expiredKey} {
+					rollbacked, idempotencies, err := productRepo.RollbackProductInventory(context.Background(), idempotencyKey, nil)
+					Expect(err).To(BeNil())
+					Expect(rollbacked).To(BeFalse())
+					Expect((*idempotencies)[0].Confirmed).To(BeTrue())
+				}
+				inventory, err := productRepo.GetProductInventory(context.Background(), productID)
+				Expect(err).To(BeNil())
+				Expect(inventory).To(Equal(&ProductInventory{
+					Inventory: 10,
+					Reserved:  0,
+				}))
+			})
+			By("should release oversold expired hold for good", func() {
+				var oversoldKey, otherKey uint64 = 7, 8
+				_, err := productRepo.UpdateProductInventory(context.Background(), oversoldKey, &[]domain_model.PurchasedItem{
+					{
+						ProductID: productID,
+						Amount:    8,
+					},
+				}, now.Add(time.Minute), nil)
+				Expect(err).To(BeNil())
+				_, err = productRepo.ReleaseExpiredReservation(context.Background(), oversoldKey, now.Add(time.Minute))
+				Expect(err).To(BeNil())
+				_, err = productRepo.UpdateProductInventory(context.Background(), otherKey, &[]domain_model.PurchasedItem{
+					{
+						ProductID: productID,
+						Amount:    5,
+					},
+				}, now.Add(time.Minute), nil)
+				Expect(err).To(BeNil())
+
+				_, _, err = productRepo.ConfirmProductInventory(context.Background(), oversoldKey, nil)
+				Expect(err).To(Equal(ErrInsuffientInventory))
+				_, _, err = productRepo.ConfirmProductInventory(context.Background(), oversoldKey, nil)
+				Expect(err).To(Equal(ErrReservationReleased))
+				inventory, err := productRepo.GetProductInventory(context.Background(), productID)
+				Expect(err).To(BeNil())
+				Expect(inventory).To(Equal(&ProductInventory{
+					Inventory: 5,
+					Reserved:  5,
+				}))
+			})
+		})
+		var _ = It("should adjust inventory with ledger", func() {
+			productID, err := productRepo.CreateProduct(context.Background(), &domain_model.Product{
+				Detail: &domain_model.ProductDetail{
+					Name:        "ledger",
+					Description: "ledger product",
+					BrandName:   "mybrand",
+					Price:       30,
+				},
+				Inventory: 5,
+			})
+			Expect(err).To(BeNil())
+
+			By("should adjust inventory", func() {
+				entry, err := productRepo.AdjustProductInventory(context.Background(), &domain_model.InventoryAdjustment{
+					ProductID: productID,
+					Delta:     10,
+					Reason:    domain_model.InventoryRestock,
+					Actor:     "warehouse",
+				})
+				Expect(err).To(BeNil())
+				Expect(entry.Inventory).To(Equal(int64(15)))
+				_, err = productRepo.AdjustProductInventory(context.Background(), &domain_model.InventoryAdjustment{
+					ProductID: productID,
+					Delta:     -3,
+					Reason:    domain_model.InventoryShrinkage,
+					Actor:     "warehouse",
+				})
+				Expect(err).To(BeNil())
+				inventory, err := productRepo.GetProductInventory(context.Background(), productID)
+				Expect(err).To(BeNil())
+				Expect(inventory.Inventory).To(Equal(int64(12)))
+			})
+			By("should not adjust inventory below zero", func() {
+				_, err := productRepo.AdjustProductInventory(context.Background(), &domain_model.InventoryAdjustment{
+					ProductID: productID,
+					Delta:     -13,
+					Reason:    domain_model.InventoryCorrection,
+					Actor:     "auditor",
+				})
+				Expect(err).To(Equal(ErrInsuffientInventory))
+				_, err = productRepo.AdjustProductInventory(context.Background(), &domain_model.InventoryAdjustment{
+					ProductID: 1,
+					Delta:     1,
+					Reason:    domain_model.InventoryRestock,
+					Actor:     "warehouse",
+				})
+				Expect(err).To(Equal(ErrProductNotFound))
+			})
+			By("should list inventory ledger in order", func() {
+				ledger, err := productRepo.ListInventoryLedger(context.Background(), productID, 0, 10)
+				Expect(err).To(BeNil())
+				Expect(len(*ledger)).To(Equal(2))
+				Expect((*ledger)[0].Delta).To(Equal(int64(10)))
+				Expect((*ledger)[0].Reason).To(Equal(domain_model.InventoryRestock))
+				Expect((*ledger)[0].Actor).To(Equal("warehouse"))
+				Expect((*ledger)[1].Delta).To(Equal(int64(-3)))
+				Expect((*ledger)[1].Inventory).To(Equal(int64(12)))
+			})
+		})
+		var _ = It("should hold variant inventory", func() {
+			productID, err := productRepo.CreateProduct(context.Background(), &domain_model.Product{
+				Detail: &domain_model.ProductDetail{
+					Name:        "variant",
+					Description: "variant product",
+					BrandName:   "mybrand",
+					Price:       40,
+				},
+				Inventory: 10,
+			})
+			Expect(err).To(BeNil())
+			var variantIDs []uint64
+			for _, sku := range []string{"variant-red", "variant-blue"} {
+				variantID, err := productRepo.CreateProductVariant(context.Background(), &domain_model.ProductVariant{
+					ProductID: productID,
+					SKU:       sku,
+					Name:      sku,
+					Price:     50,
+					Inventory: 5,
+				})
+				Expect(err).To(BeNil())
+				variantIDs = append(variantIDs, variantID)
+			}
+
+			By("should reject duplicate skus", func() {
+				_, err := productRepo.CreateProductVariant(context.Background(), &domain_model.ProductVariant{
+					ProductID: productID,
+					SKU:       "variant-red",
+					Name:      "red",
+				})
+				Expect(err).To(Equal(ErrDuplicateSKU))
+				sku := "variant-red"
+				err = productRepo.UpdateProductVariant(context.Background(), productID, variantIDs[1], &domain_model.ProductVariantUpdate{
+					SKU: &sku,
+				})
+				Expect(err).To(Equal(ErrDuplicateSKU))
+				err = productRepo.UpdateProductVariant(context.Background(), 1, variantIDs[1], &domain_model.ProductVariantUpdate{})
+				Expect(err).To(Equal(ErrVariantNotFound))
+			})
+			By("should check variant price", func() {
+				status, err := productRepo.CheckProduct(context.Background(), &domain_model.CartItem{
+					ProductID: productID,
+					VariantID: variantIDs[0],
+				})
+				Expect(err).To(BeNil())
+				Expect(status).To(Equal(&ProductStatus{
+					ProductID: productID,
+					VariantID: variantIDs[0],
+					Price:     50,
+					Exist:     true,
+				}))
+				archived := true
+				Expect(productRepo.UpdateProductVariant(context.Background(), productID, variantIDs[0], &domain_model.ProductVariantUpdate{
+					Archived: &archived,
+				})).To(BeNil())
+				status, err = productRepo.CheckProduct(context.Background(), &domain_model.CartItem{
+					ProductID: productID,
+					VariantID: variantIDs[0],
+				})
+				Expect(err).To(BeNil())
+				Expect(status.Exist).To(BeFalse())
+			})
+			By("should hold and release variant inventory", func() {
+				var idempotencyKey uint64 = 9
+				_, err := productRepo.UpdateProductInventory(context.Background(), idempotencyKey, &[]domain_model.PurchasedItem{
+					{
+						ProductID: productID,
+						VariantID: variantIDs[1],
+						Amount:    2,
+					},
+					{
+						ProductID: productID,
+						Amount:    1,
+					},
+					{
+						ProductID: productID,
+						VariantID: variantIDs[0],
+						Amount:    3,
+					},
+				}, time.Now().Add(time.Hour), nil)
+				Expect(err).To(BeNil())
+				variants, err := productRepo.ListProductVariants(context.Background(), productID)
+				Expect(err).To(BeNil())
+				Expect((*variants)[0].Inventory).To(Equal(int64(2)))
+				Expect((*variants)[0].Reserved).To(Equal(int64(3)))
+				Expect((*variants)[1].Inventory).To(Equal(int64(3)))
+				Expect((*variants)[1].Reserved).To(Equal(int64(2)))
+				inventory, err := productRepo.GetProductInventory(context.Background(), productID)
+				Expect(err).To(BeNil())
+				Expect(inventory.Inventory).To(Equal(int64(9)))
+
+				_, err = productRepo.UpdateProductInventory(context.Background(), 10, &[]domain_model.PurchasedItem{
+					{
+						ProductID: productID,
+						VariantID: variantIDs[1],
+						Amount:    4,
+					},
+				}, time.Now().Add(time.Hour), nil)
+				Expect(err).To(Equal(ErrInsuffientInventory))
+
+				rollbacked, idempotencies, err := productRepo.RollbackProductInventory(context.Background(), idempotencyKey, nil)
+				Expect(err).To(BeNil())
+				Expect(rollbacked).To(BeFalse())
+				Expect(len(*idempotencies)).To(Equal(3))
+				Expect((*idempotencies)[0].VariantID).To(Equal(uint64(0)))
+				variants, err = productRepo.ListProductVariants(context.Background(), productID)
+				Expect(err).To(BeNil())
+				for _, variant := range *variants {
+					Expect(variant.Inventory).To(Equal(int64(5)))
+					Expect(variant.Reserved).To(Equal(int64(0)))
+				}
+			})
+			By("should adjust variant inventory", func() {
+				entry, err := productRepo.AdjustProductInventory(context.Background(), &domain_model.InventoryAdjustment{
+					ProductID: productID,
+					VariantID: variantIDs[1],
+					Delta:     5,
+					Reason:    domain_model.InventoryRestock,
+					Actor:     "warehouse",
+				})
+				Expect(err).To(BeNil())
+				Expect(entry.VariantID).To(Equal(variantIDs[1]))
+				Expect(entry.Inventory).To(Equal(int64(10)))
+			})
+		})
+		var _ = It("should search products", func() {
+			var productIDs []uint64
+			for _, product := range []domain_model.Product{
+				{
+					Detail: &domain_model.ProductDetail{
+						Name:        "wireless keyboard",
+						Description: "compact keyboard with bluetooth",
+						BrandName:   "searchbrand",
+						Price:       300,
+					},
+					Inventory: 0,
+				},
+				{
+					Detail: &domain_model.ProductDetail{
+						Name:        "wireless mouse",
+						Description: "ergonomic mouse",
+						BrandName:   "searchbrand",
+						Price:       100,
+					},
+					Inventory: 5,
+				},
+				{
+					Detail: &domain_model.ProductDetail{
+						Name:        "mechanical keyboard",
+						Description: "wired keyboard",
+						BrandName:   "searchbrand",
+						Price:       500,
+					},
+					Inventory: 5,
+				},
+			} {
+				product := product
+				productID, err := productRepo.CreateProduct(context.Background(), &product)
+				Expect(err).To(BeNil())
+				productIDs = append(productIDs, productID)
+			}
+			listIDs := func(query *domain_model.ProductQuery, afterID ...uint64) []uint64 {
+				query.BrandName = "searchbrand"
+				query.Size = 10
+				var after uint64
+				if len(afterID) > 0 {
+					after = afterID[0]
+				}
+				catalogs, err := productRepo.ListProducts(context.Background(), query, after)
+				Expect(err).To(BeNil())
+				var ids []uint64
+				for _, catalog := range *catalogs {
+					ids = append(ids, catalog.ID)
+				}
+				return ids
+			}
+
+			By("should filter products", func() {
+				Expect(listIDs(&domain_model.ProductQuery{})).To(Equal(productIDs))
+				Expect(listIDs(&domain_model.ProductQuery{MinPrice: 100, MaxPrice: 300})).To(Equal(productIDs[:2]))
+				Expect(listIDs(&domain_model.ProductQuery{InStock: true})).To(Equal(productIDs[1:]))
+			})
+			By("should sort products", func() {
+				Expect(listIDs(&domain_model.ProductQuery{SortBy: domain_model.SortByPrice})).To(Equal([]uint64{productIDs[1], productIDs[0], productIDs[2]}))
+				Expect(listIDs(&domain_model.ProductQuery{SortBy: domain_model.SortByName, Descending: true})).To(Equal([]uint64{productIDs[1], productIDs[0], productIDs[2]}))
+				Expect(listIDs(&domain_model.ProductQuery{SortBy: domain_model.SortByCreatedAt, Descending: true, Offset: 1})).To(Equal([]uint64{productIDs[1], productIDs[0]}))
+			})
+			By("should search products by keyword", func() {
+				Expect(listIDs(&domain_model.ProductQuery{Keyword: "keyboard"})).To(ConsistOf(productIDs[0], productIDs[2]))
+				Expect(listIDs(&domain_model.ProductQuery{Keyword: "ergonomic"})).To(Equal(productIDs[1:2]))
+				Expect(listIDs(&domain_model.ProductQuery{Keyword: "keyboard", InStock: true})).To(Equal(productIDs[2:]))
+			})
+			By("should list products after a product ID", func() {
+				Expect(listIDs(&domain_model.ProductQuery{Offset: 1}, productIDs[0])).To(Equal(productIDs[1:]))
+				Expect(listIDs(&domain_model.ProductQuery{Descending: true}, productIDs[2])).To(Equal([]uint64{productIDs[1], productIDs[0]}))
+				Expect(listIDs(&domain_model.ProductQuery{InStock: true}, productIDs[1])).To(Equal(productIDs[2:]))
+			})
+		})
+		var _ = It("should record price history and apply scheduled price changes", func() {
+			productID, err := productRepo.CreateProduct(context.Background(), &domain_model.Product{
+				Detail: &domain_model.ProductDetail{
+					Name:        "priced",
+					Description: "priced product",
+					BrandName:   "mybrand",
+					Price:       100,
+				},
+				Inventory: 10,
+			})
+			Expect(err).To(BeNil())
+			created := time.Now()
+			By("should record price updates", func() {
+				time.Sleep(10 * time.Millisecond)
+				price := int64(120)
+				Expect(productRepo.UpdateProduct(context.Background(), productID, &domain_model.ProductUpdate{Price: &price})).To(BeNil())
+				history, err := productRepo.ListPriceHistory(context.Background(), productID, 0, 10)
+				Expect(err).To(BeNil())
+				Expect(len(*history)).To(Equal(2))
+				Expect((*history)[0].Price).To(Equal(int64(100)))
+				Expect((*history)[1].Price).To(Equal(int64(120)))
+
+				priceAt, err := productRepo.GetPriceAt(context.Background(), productID, 0, created)
+				Expect(err).To(BeNil())
+				Expect(priceAt.Price).To(Equal(int64(100)))
+				priceAt, err = productRepo.GetPriceAt(context.Background(), productID, 0, time.Now())
+				Expect(err).To(BeNil())
+				Expect(priceAt.Price).To(Equal(int64(120)))
+				_, err = productRepo.GetPriceAt(context.Background(), productID, 0, created.Add(-time.Hour))
+				Expect(err).To(Equal(ErrPriceNotFound))
+			})
+			By("should apply due price changes", func() {
+				now := time.Now()
+				changeID, err := productRepo.SchedulePriceChange(context.Background(), &domain_model.PriceChange{
+					ProductID:   productID,
+					Price:       80,
+					EffectiveAt: now.Add(time.Minute),
+				})
+				Expect(err).To(BeNil())
+				cancelledID, err := productRepo.SchedulePriceChange(context.Background(), &domain_model.PriceChange{
+					ProductID:   productID,
+					Price:       90,
+					EffectiveAt: now.Add(time.Minute),
+				})
+				Expect(err).To(BeNil())
+				Expect(productRepo.CancelPriceChange(context.Background(), productID, cancelledID)).To(BeNil())
+				Expect(productRepo.CancelPriceChange(context.Background(), productID, cancelledID)).To(Equal(ErrPriceChangeNotFound))
+				_, err = productRepo.SchedulePriceChange(context.Background(), &domain_model.PriceChange{
+					ProductID:   productID,
+					VariantID:   1,
+					Price:       90,
+					EffectiveAt: now.Add(time.Minute),
+				})
+				Expect(err).To(Equal(ErrVariantNotFound))
+
+				changeIDs, err := productRepo.ListDuePriceChanges(context.Background(), now, 10)
+				Expect(err).To(BeNil())
+				Expect(changeIDs).NotTo(ContainElement(changeID))
+				change, err := productRepo.ApplyPriceChange(context.Background(), changeID, now)
+				Expect(err).To(BeNil())
+				Expect(change).To(BeNil())
+
+				applyAt := now.Add(2 * time.Minute)
+				changeIDs, err = productRepo.ListDuePriceChanges(context.Background(), applyAt, 10)
+				Expect(err).To(BeNil())
+				Expect(changeIDs).To(ContainElement(changeID))
+				change, err = productRepo.ApplyPriceChange(context.Background(), changeID, applyAt)
+				Expect(err).To(BeNil())
+				Expect(change.Price).To(Equal(int64(80)))
+				change, err = productRepo.ApplyPriceChange(context.Background(), changeID, applyAt)
+				Expect(err).To(BeNil())
+				Expect(change).To(BeNil())
+
+				detail, err := productRepo.GetProductDetail(context.Background(), productID)
+				Expect(err).To(BeNil())
+				Expect(detail.Price).To(Equal(int64(80)))
+				priceAt, err := productRepo.GetPriceAt(context.Background(), productID, 0, applyAt)
+				Expect(err).To(BeNil())
+				Expect(priceAt.Price).To(Equal(int64(80)))
+				changes, err := productRepo.ListPriceChanges(context.Background(), productID)
+				Expect(err).To(BeNil())
+				Expect(*changes).To(BeEmpty())
+			})
+		})
+		var _ = It("should create and export products in batches", func() {
+			products := make([]domain_model.Product, 3)
+			for i := range products {
+				products[i] = domain_model.Product{
+					Detail: &domain_model.ProductDetail{
+						Name:        fmt.Sprintf("bulk%d", i),
+						Description: "bulk",
+						BrandName:   "bulk",
+						Price:       int64(10 * (i + 1)),
+					},
+					Inventory: int64(i),
+				}
+			}
+			productIDs, err := productRepo.CreateProducts(context.Background(), &products)
+			Expect(err).To(BeNil())
+			Expect(productIDs).To(HaveLen(3))
+
+			exported := make(map[uint64]domain_model.Product)
+			batches := 0
+			err = productRepo.ExportProducts(context.Background(), 2, func(products *[]domain_model.Product) error {
+				Expect(len(*products)).To(BeNumerically("<=", 2))
+				batches++
+				for _, product := range *products {
+					exported[product.ID] = product
+				}
+				return nil
+			})
+			Expect(err).To(BeNil())
+			Expect(batches).To(BeNumerically(">=", 2))
+			for i, productID := range productIDs {
+				Expect(exported).To(HaveKey(productID))
+				Expect(exported[productID].Detail.Name).To(Equal(products[i].Detail.Name))
+				Expect(exported[productID].Inventory).To(Equal(products[i].Inventory))
+			}
+		})
+	})
+	var _ = Describe("category repo", func() {
+		var _ = It("should manage category taxonomy", func() {
+			rootID, err := categoryRepo.CreateCategory(context.Background(), &domain_model.Category{Name: "peripherals"})
+			Expect(err).To(BeNil())
+			keyboardsID, err := categoryRepo.CreateCategory(context.Background(), &domain_model.Category{ParentID: rootID, Name: "keyboards"})
+			Expect(err).To(BeNil())
+			mechanicalID, err := categoryRepo.CreateCategory(context.Background(), &domain_model.Category{ParentID: keyboardsID, Name: "mechanical"})
+			Expect(err).To(BeNil())
+			otherID, err := categoryRepo.CreateCategory(context.Background(), &domain_model.Category{Name: "other"})
+			Expect(err).To(BeNil())
+			_, err = categoryRepo.CreateCategory(context.Background(), &domain_model.Category{ParentID: 1, Name: "orphan"})
+			Expect(err).To(Equal(ErrInvalidCategoryParent))
+
+			productID, err := productRepo.CreateProduct(context.Background(), &domain_model.Product{
+				Detail: &domain_model.ProductDetail{
+					Name:        "categorized",
+					Description: "categorized product",
+					BrandName:   "categorybrand",
+					Price:       10,
+				},
+				Inventory: 1,
+			})
+			Expect(err).To(BeNil())
+			listIDs := func(categoryID uint64) []uint64 {
+				catalogs, err := productRepo.ListProducts(context.Background(), &domain_model.ProductQuery{
+					CategoryID: categoryID,
+					Size:       10,
+				}, 0)
+				Expect(err).To(BeNil())
+				var ids []uint64
+				for _, catalog := range *catalogs {
+					ids = append(ids, catalog.ID)
+				}
+				return ids
+			}
+
+			By("should assign product to categories", func() {
+				Expect(categoryRepo.SetProductCategories(context.Background(), productID, []uint64{mechanicalID, mechanicalID})).To(BeNil())
+				categories, err := categoryRepo.GetProductCategories(context.Background(), productID)
+				Expect(err).To(BeNil())
+				Expect(*categories).To(Equal([]domain_model.Category{{ID: mechanicalID, ParentID: keyboardsID, Name: "mechanical"}}))
+				Expect(categoryRepo.SetProductCategories(context.Background(), productID, []uint64{1})).To(Equal(ErrCategoryNotFound))
+				Expect(categoryRepo.SetProductCategories(context.Background(), 1, []uint64{mechanicalID})).To(Equal(ErrProductNotFound))
+			})
+			By("should list products by category subtree", func() {
+				Expect(listIDs(rootID)).To(Equal([]uint64{productID}))
+				Expect(listIDs(keyboardsID)).To(Equal([]uint64{productID}))
+				Expect(listIDs(otherID)).To(BeEmpty())
+			})
+			By("should move category subtree", func() {
+				Expect(categoryRepo.UpdateCategory(context.Background(), keyboardsID, &domain_model.CategoryUpdate{ParentID: &mechanicalID})).To(Equal(ErrInvalidCategoryParent))
+				name := "keyboards and keypads"
+				Expect(categoryRepo.UpdateCategory(context.Background(), keyboardsID, &domain_model.CategoryUpdate{Name: &name, ParentID: &otherID})).To(BeNil())
+				Expect(listIDs(rootID)).To(BeEmpty())
+				Expect(listIDs(otherID)).To(Equal([]uint64{productID}))
+				categories, err := categoryRepo.ListCategories(context.Background())
+				Expect(err).To(BeNil())
+				Expect(*categories).To(ContainElement(domain_model.Category{ID: keyboardsID, ParentID: otherID, Name: name}))
+			})
+			By("should delete leaf category only", func() {
+				Expect(categoryRepo.DeleteCategory(context.Background(), keyboardsID)).To(Equal(ErrCategoryHasChildren))
+				Expect(categoryRepo.DeleteCategory(context.Background(), mechanicalID)).To(BeNil())
+				_, err := categoryRepo.GetCategory(context.Background(), mechanicalID)
+				Expect(err).To(Equal(ErrCategoryNotFound))
+				categories, err := categoryRepo.GetProductCategories(context.Background(), productID)
+				Expect(err).To(BeNil())
+				Expect(*categories).To(BeEmpty())
+			})
+		})
 	})
 	var _ = Describe("order repo", func() {
 		var orderID uint64 = 1
 		order := domain_model.Order{
-			ID:         orderID,
-			CustomerID: 3,
+			ID:           orderID,
+			CustomerID:   3,
+			CurrencyCode: "NT",
 			PurchasedItems: &[]domain_model.PurchasedItem{
 				{
 					ProductID: 1,
@@ -230,20 +938,174 @@ var _ = Describe("test repo", func() {
 		}
 		var _ = It("should do order dao", func() {
 			By("should create order", func() {
-				err := orderRepo.CreateOrder(context.Background(), &order)
+				err := orderRepo.CreateOrder(context.Background(), &order, nil)
 				Expect(err).To(BeNil())
 			})
 			By("should retrieve order", func() {
-				retrievedOrder, err := orderRepo.GetOrder(context.Background(), orderID)
+				retrievedOrder, err := orderRepo.GetDetailedOrder(context.Background(), orderID)
 				Expect(err).To(BeNil())
-				Expect(retrievedOrder).To(Equal(&order))
+				Expect(retrievedOrder.CustomerID).To(Equal(order.CustomerID))
+				Expect(retrievedOrder.CurrencyCode).To(Equal("NT"))
+				Expect(*retrievedOrder.DetailedPurchasedItems).To(Equal([]domain_model.DetailedPurchasedItem{
+					{ProductID: 1, Name: "product 1", BrandName: "brand", Price: 10, Amount: 5},
+					{ProductID: 2, Name: "product 2", BrandName: "brand", Price: 20, Amount: 5},
+				}))
+				Expect(retrievedOrder.TotalAmount).To(Equal(int64(10)))
+				Expect(retrievedOrder.TotalPrice).To(Equal(int64(150)))
+				Expect(retrievedOrder.CreatedAt).NotTo(BeZero())
+				Expect(retrievedOrder.Status).To(Equal(domain_model.OrderPending))
+				Expect(retrievedOrder.StatusUpdatedAt).To(Equal(retrievedOrder.CreatedAt))
+				Expect(*retrievedOrder.Transitions).To(HaveLen(1))
+			})
+			By("should snapshot the checkout prices of order items", func() {
+				err := orderRepo.CreateOrder(context.Background(), &domain_model.Order{
+					ID:           4,
+					CustomerID:   6,
+					CurrencyCode: "NT",
+					PurchasedItems: &[]domain_model.PurchasedItem{
+						{ProductID: 1, Amount: 2, Price: 8},
+						{ProductID: 2, Amount: 1},
+					},
+					PlacedAt: time.Now().Add(-time.Minute),
+				}, nil)
+				Expect(err).To(BeNil())
+				retrievedOrder, err := orderRepo.GetDetailedOrder(context.Background(), 4)
+				Expect(err).To(BeNil())
+				Expect(*retrievedOrder.DetailedPurchasedItems).To(Equal([]domain_model.DetailedPurchasedItem{
+					{ProductID: 1, Name: "product 1", BrandName: "brand", Price: 8, Amount: 2},
+					{ProductID: 2, Name: "product 2", BrandName: "brand", Price: 20, Amount: 1},
+				}))
+				Expect(retrievedOrder.TotalPrice).To(Equal(int64(36)))
 			})
-			By("should delete order", func() {
-				err := orderRepo.DeleteOrder(context.Background(), orderID)
+			By("should price migrated order items when read", func() {
+				err := db.Create(&model.Order{ID: 2, CustomerID: 5, TotalAmount: 2, Status: string(domain_model.OrderConfirmed)}).Error
+				Expect(err).To(BeNil())
+				err = db.Create(&model.OrderItem{OrderID: 2, ProductID: 3, Amount: 2}).Error
 				Expect(err).To(BeNil())
 
-				_, err = orderRepo.GetOrder(context.Background(), orderID)
-				Expect(err).NotTo(BeNil())
+				summaries, err := orderRepo.ListCustomerOrders(context.Background(), &domain_model.OrderQuery{CustomerID: 5, Size: 10}, 0)
+				Expect(err).To(BeNil())
+				Expect(*summaries).To(HaveLen(1))
+				Expect((*summaries)[0].ItemCount).To(Equal(1))
+				Expect((*summaries)[0].TotalPrice).To(Equal(int64(60)))
+
+				var item model.OrderItem
+				err = db.Where("order_id = ?", 2).First(&item).Error
+				Expect(err).To(BeNil())
+				Expect(item.Snapshotted).To(BeFalse())
+				Expect(item.Price).To(BeZero())
+			})
+			By("should list no orders of other customers", func() {
+				summaries, err := orderRepo.ListCustomerOrders(context.Background(), &domain_model.OrderQuery{CustomerID: 4, Size: 10}, 0)
+				Expect(err).To(BeNil())
+				Expect(*summaries).To(BeEmpty())
+				summaries, err = orderRepo.ListCustomerOrders(context.Background(), &domain_model.OrderQuery{CustomerID: 3, Size: 10}, orderID)
+				Expect(err).To(BeNil())
+				Expect(*summaries).To(BeEmpty())
+			})
+			By("should cancel order", func() {
+				err := orderRepo.UpdateOrderStatus(context.Background(), orderID, domain_model.OrderCancelled, nil)
+				Expect(err).To(BeNil())
+				err = orderRepo.UpdateOrderStatus(context.Background(), orderID, domain_model.OrderCancelled, nil)
+				Expect(err).To(BeNil())
+
+				retrievedOrder, err := orderRepo.GetDetailedOrder(context.Background(), orderID)
+				Expect(err).To(BeNil())
+				Expect(retrievedOrder.Status).To(Equal(domain_model.OrderCancelled))
+				Expect(*retrievedOrder.Transitions).To(HaveLen(2))
+				Expect((*retrievedOrder.Transitions)[1].Status).To(Equal(domain_model.OrderCancelled))
+			})
+			By("should not confirm cancelled order", func() {
+				err := orderRepo.UpdateOrderStatus(context.Background(), orderID, domain_model.OrderConfirmed, nil)
+				Expect(err).To(Equal(ErrInvalidOrderTransition))
+				err = orderRepo.UpdateOrderStatus(context.Background(), 3, domain_model.OrderCancelled, nil)
+				Expect(err).To(Equal(ErrOrderNotFound))
+			})
+		})
+		var _ = It("should migrate orders of the former layout", func() {
+			createLegacyOrders := func(table string) {
+				err := db.Exec("CREATE TABLE " + table + " (id bigint unsigned NOT NULL, product_id bigint unsigned NOT NULL, amount bigint NOT NULL, customer_id bigint unsigned NOT NULL, updated_at bigint, created_at bigint, PRIMARY KEY (id, product_id))").Error
+				Expect(err).To(BeNil())
+				err = db.Exec("INSERT INTO " + table + " (id, product_id, amount, customer_id, updated_at, created_at) VALUES (10, 4, 2, 7, 2000, 1000), (10, 5, 3, 7, 3000, 1000), (11, 4, 1, 8, 4000, 4000)").Error
+				Expect(err).To(BeNil())
+			}
+			err := db.Migrator().DropTable(&model.Order{}, &model.OrderItem{}, &model.OrderTransition{})
+			Expect(err).To(BeNil())
+			createLegacyOrders("orders")
+
+			By("should copy legacy orders into order headers, items and transitions", func() {
+				err := infra_db.NewMigrator(db, "order").Migrate()
+				Expect(err).To(BeNil())
+				Expect(db.Migrator().HasTable("legacy_orders")).To(BeFalse())
+
+				var orders []model.Order
+				err = db.Order("id").Find(&orders).Error
+				Expect(err).To(BeNil())
+				Expect(orders).To(HaveLen(2))
+				Expect(orders[0].CustomerID).To(Equal(uint64(7)))
+				Expect(orders[0].TotalAmount).To(Equal(int64(5)))
+				Expect(orders[0].TotalPrice).To(BeZero())
+				Expect(orders[0].Status).To(Equal(string(domain_model.OrderConfirmed)))
+				Expect(orders[0].StatusUpdatedAt).To(Equal(int64(3000)))
+				Expect(orders[0].CreatedAt).To(Equal(int64(1000)))
+				Expect(orders[1].CustomerID).To(Equal(uint64(8)))
+				Expect(orders[1].TotalAmount).To(Equal(int64(1)))
+
+				var items []model.OrderItem
+				err = db.Where("order_id = ?", 10).Order("product_id").Find(&items).Error
+				Expect(err).To(BeNil())
+				Expect(items).To(HaveLen(2))
+				Expect(items[0].ProductID).To(Equal(uint64(4)))
+				Expect(items[0].Amount).To(Equal(int64(2)))
+				Expect(items[0].Snapshotted).To(BeFalse())
+				Expect(items[1].ProductID).To(Equal(uint64(5)))
+				Expect(items[1].Amount).To(Equal(int64(3)))
+
+				var transitions []model.OrderTransition
+				err = db.Order("order_id").Find(&transitions).Error
+				Expect(err).To(BeNil())
+				Expect(transitions).To(HaveLen(2))
+				Expect(transitions[0].OrderID).To(Equal(uint64(10)))
+				Expect(transitions[0].Status).To(Equal(string(domain_model.OrderConfirmed)))
+				Expect(transitions[0].Timestamp).To(Equal(int64(3000)))
+			})
+			By("should resume a migration interrupted before dropping legacy orders", func() {
+				createLegacyOrders("legacy_orders")
+				err := infra_db.NewMigrator(db, "order").Migrate()
+				Expect(err).To(BeNil())
+				Expect(db.Migrator().HasTable("legacy_orders")).To(BeFalse())
+
+				var count int64
+				err = db.Model(&model.Order{}).Count(&count).Error
+				Expect(err).To(BeNil())
+				Expect(count).To(Equal(int64(2)))
+				err = db.Model(&model.OrderItem{}).Count(&count).Error
+				Expect(err).To(BeNil())
+				Expect(count).To(Equal(int64(3)))
+				err = db.Model(&model.OrderTransition{}).Count(&count).Error
+				Expect(err).To(BeNil())
+				Expect(count).To(Equal(int64(2)))
+			})
+			By("should price migrated order items and total price without writing them", func() {
+				retrievedOrder, err := orderRepo.GetDetailedOrder(context.Background(), 10)
+				Expect(err).To(BeNil())
+				Expect(*retrievedOrder.DetailedPurchasedItems).To(Equal([]domain_model.DetailedPurchasedItem{
+					{ProductID: 4, Name: "product 4", BrandName: "brand", Price: 40, Amount: 2},
+					{ProductID: 5, Name: "product 5", BrandName: "brand", Price: 50, Amount: 3},
+				}))
+				Expect(retrievedOrder.TotalAmount).To(Equal(int64(5)))
+				Expect(retrievedOrder.TotalPrice).To(Equal(int64(230)))
+				Expect(retrievedOrder.Status).To(Equal(domain_model.OrderConfirmed))
+				Expect(*retrievedOrder.Transitions).To(HaveLen(1))
+
+				var order model.Order
+				err = db.Where("id = ?", 10).First(&order).Error
+				Expect(err).To(BeNil())
+				Expect(order.TotalPrice).To(BeZero())
+				var items []model.OrderItem
+				err = db.Where("order_id = ? AND snapshotted = ?", 10, false).Find(&items).Error
+				Expect(err).To(BeNil())
+				Expect(items).To(HaveLen(2))
 			})
 		})
 	})
@@ -257,7 +1119,7 @@ var _ = Describe("test repo", func() {
 		}
 		var _ = It("should do payment dao", func() {
 			By("should create payment", func() {
-				err := paymentRepo.CreatePayment(context.Background(), &payment)
+				err := paymentRepo.CreatePayment(context.Background(), &payment, nil)
 				Expect(err).To(BeNil())
 			})
 			By("should retrieve payment", func() {
@@ -265,12 +1127,347 @@ var _ = Describe("test repo", func() {
 				Expect(err).To(BeNil())
 				Expect(retrievedPayment).To(Equal(&payment))
 			})
-			By("should delete payment", func() {
-				err := paymentRepo.DeletePayment(context.Background(), paymentID)
+			By("should issue a partial refund once for an idempotency key", func() {
+				refund := domain_model.Refund{
+					PaymentID:      paymentID,
+					IdempotencyKey: "partial",
+					Amount:         30,
+					Reason:         "damaged",
+					Actor:          "staff",
+				}
+				issued, created, err := paymentRepo.RefundPayment(context.Background(), &refund)
+				Expect(err).To(BeNil())
+				Expect(issued).To(BeTrue())
+				Expect(created.Amount).To(Equal(int64(30)))
+
+				issued, replayed, err := paymentRepo.RefundPayment(context.Background(), &refund)
 				Expect(err).To(BeNil())
+				Expect(issued).To(BeFalse())
+				Expect(replayed).To(Equal(created))
 
-				_, err = paymentRepo.GetPayment(context.Background(), paymentID)
+				refund.Amount = 40
+				_, _, err = paymentRepo.RefundPayment(context.Background(), &refund)
+				Expect(err).To(Equal(ErrIdempotencyKeyConflict))
+
+				retrievedPayment, err := paymentRepo.GetPayment(context.Background(), paymentID)
+				Expect(err).To(BeNil())
+				Expect(retrievedPayment.RefundedAmount).To(Equal(int64(30)))
+			})
+			By("should not refund more than the refundable amount", func() {
+				_, _, err := paymentRepo.RefundPayment(context.Background(), &domain_model.Refund{
+					PaymentID:      paymentID,
+					IdempotencyKey: "exceeded",
+					Amount:         71,
+					Reason:         "damaged",
+					Actor:          "staff",
+				})
+				Expect(err).To(Equal(ErrRefundExceedsBalance))
+				_, _, err = paymentRepo.RefundPayment(context.Background(), &domain_model.Refund{
+					PaymentID:      3,
+					IdempotencyKey: "missing",
+					Amount:         1,
+					Reason:         "damaged",
+					Actor:          "staff",
+				})
+				Expect(err).To(Equal(ErrPaymentNotFound))
+			})
+			By("should refund the rest of payment when it is rollbacked", func() {
+				err := paymentRepo.RefundFullPayment(context.Background(), paymentID, nil)
+				Expect(err).To(BeNil())
+				err = paymentRepo.RefundFullPayment(context.Background(), paymentID, nil)
+				Expect(err).To(BeNil())
+
+				retrievedPayment, err := paymentRepo.GetPayment(context.Background(), paymentID)
+				Expect(err).To(BeNil())
+				Expect(retrievedPayment.RefundedAmount).To(Equal(payment.Amount))
+				refunds, err := paymentRepo.ListRefunds(context.Background(), paymentID)
+				Expect(err).To(BeNil())
+				Expect(len(*refunds)).To(Equal(2))
+				Expect((*refunds)[1].Amount).To(Equal(int64(70)))
+				Expect((*refunds)[1].Reason).To(Equal("rollback"))
+			})
+		})
+	})
+	var _ = Describe("saga repo", func() {
+		var purchaseID uint64 = 1
+		instance := domain_model.SagaInstance{
+			PurchaseID:    purchaseID,
+			CustomerID:    3,
+			CorrelationID: "correlation",
+			CurrentStep:   "UPDATE_PRODUCT_INVENTORY",
+			Status:        "STATUS_EXUCUTE",
+		}
+		var _ = It("should do saga dao", func() {
+			By("should create saga instance", func() {
+				err := sagaRepo.CreateSagaInstance(context.Background(), &instance)
+				Expect(err).To(BeNil())
+				err = sagaRepo.CreateSagaInstance(context.Background(), &instance)
+				Expect(err).To(BeNil())
+			})
+			By("should record transitions", func() {
+				transitions := []domain_model.SagaTransition{
+					{
+						Step:      "UPDATE_PRODUCT_INVENTORY",
+						Status:    "STATUS_EXUCUTE",
+						Timestamp: time.UnixMilli(1000),
+					},
+					{
+						Step:      "UPDATE_PRODUCT_INVENTORY",
+						Status:    "STATUS_SUCCESS",
+						Timestamp: time.UnixMilli(2000),
+					},
+					{
+						Step:      "CREATE_ORDER",
+						Status:    "STATUS_EXUCUTE",
+						Timestamp: time.UnixMilli(3000),
+					},
+				}
+				for _, transition := range transitions {
+					var deadline time.Time
+					if transition.Status == "STATUS_EXUCUTE" {
+						deadline = transition.Timestamp.Add(time.Second)
+					}
+					err := sagaRepo.RecordTransitions(context.Background(), purchaseID, nil, &[]domain_model.SagaTransition{transition}, deadline)
+					Expect(err).To(BeNil())
+				}
+				retrievedTransitions, err := sagaRepo.GetSagaTransitions(context.Background(), purchaseID)
+				Expect(err).To(BeNil())
+				Expect(retrievedTransitions).To(Equal(&transitions))
+			})
+			By("should retrieve saga instance", func() {
+				retrievedInstance, err := sagaRepo.GetSagaInstance(context.Background(), purchaseID)
+				Expect(err).To(BeNil())
+				Expect(retrievedInstance.CorrelationID).To(Equal(instance.CorrelationID))
+				Expect(retrievedInstance.CurrentStep).To(Equal("CREATE_ORDER"))
+				Expect(retrievedInstance.Status).To(Equal("STATUS_EXUCUTE"))
+				Expect(retrievedInstance.Steps).To(Equal(&[]domain_model.SagaStep{
+					{
+						Step:      "UPDATE_PRODUCT_INVENTORY",
+						Status:    "STATUS_SUCCESS",
+						UpdatedAt: time.UnixMilli(2000),
+					},
+					{
+						Step:      "CREATE_ORDER",
+						Status:    "STATUS_EXUCUTE",
+						UpdatedAt: time.UnixMilli(3000),
+					},
+				}))
+
+				Expect(retrievedInstance.Deadline).To(Equal(time.UnixMilli(4000)))
+
+				_, err = sagaRepo.GetSagaInstance(context.Background(), 2)
+				Expect(err).To(Equal(ErrSagaInstanceNotFound))
+			})
+			By("should record transitions of expired saga instance only once", func() {
+				expiredInstances, err := sagaRepo.ListExpiredSagaInstances(context.Background(), time.UnixMilli(3999), 10)
+				Expect(err).To(BeNil())
+				Expect(len(*expiredInstances)).To(Equal(0))
+
+				expiredInstances, err = sagaRepo.ListExpiredSagaInstances(context.Background(), time.UnixMilli(4000), 10)
+				Expect(err).To(BeNil())
+				Expect(len(*expiredInstances)).To(Equal(1))
+				Expect((*expiredInstances)[0].PurchaseID).To(Equal(purchaseID))
+				Expect((*expiredInstances)[0].CurrentStep).To(Equal("CREATE_ORDER"))
+
+				from := &domain_model.SagaState{
+					CurrentStep: "CREATE_ORDER",
+					Status:      "STATUS_EXUCUTE",
+					Deadline:    time.UnixMilli(4000),
+				}
+				timeout := []domain_model.SagaTransition{
+					{
+						Step:      "CREATE_ORDER",
+						Status:    "STATUS_TIMEOUT",
+						Timestamp: time.UnixMilli(4000),
+					},
+				}
+				err = sagaRepo.RecordTransitions(context.Background(), purchaseID, from, &timeout, time.Time{})
+				Expect(err).To(BeNil())
+				err = sagaRepo.RecordTransitions(context.Background(), purchaseID, from, &timeout, time.Time{})
+				Expect(err).To(Equal(ErrSagaInstanceChanged))
+
+				retrievedTransitions, err := sagaRepo.GetSagaTransitions(context.Background(), purchaseID)
+				Expect(err).To(BeNil())
+				Expect(len(*retrievedTransitions)).To(Equal(4))
+				expiredInstances, err = sagaRepo.ListExpiredSagaInstances(context.Background(), time.UnixMilli(4000), 10)
+				Expect(err).To(BeNil())
+				Expect(len(*expiredInstances)).To(Equal(0))
+			})
+		})
+	})
+	var _ = Describe("outbox repo", func() {
+		newOutboxMessage := func(service, uuid string) *domain_model.OutboxMessage {
+			return &domain_model.OutboxMessage{
+				Service:   service,
+				Transport: domain_model.OutboxTransportTx,
+				Topic:     "reply",
+				UUID:      uuid,
+				Payload:   []byte(uuid),
+				Metadata: map[string]string{
+					"handler": "test",
+				},
+			}
+		}
+		var _ = It("should do outbox dao", func() {
+			var ids []uint64
+			By("should list outbox messages of a service in order", func() {
+				err := outboxRepo.CreateOutboxMessages(context.Background(), newOutboxMessage("outbox", "1"), newOutboxMessage("other", "2"), newOutboxMessage("outbox", "3"))
+				Expect(err).To(BeNil())
+
+				messages, err := outboxRepo.ListOutboxMessages(context.Background(), "outbox", 10)
+				Expect(err).To(BeNil())
+				Expect(len(*messages)).To(Equal(2))
+				for i, uuid := range []string{"1", "3"} {
+					message := (*messages)[i]
+					Expect(message.UUID).To(Equal(uuid))
+					Expect(message.Payload).To(Equal([]byte(uuid)))
+					Expect(message.Transport).To(Equal(domain_model.OutboxTransportTx))
+					Expect(message.Topic).To(Equal("reply"))
+					Expect(message.Metadata).To(Equal(map[string]string{"handler": "test"}))
+					ids = append(ids, message.ID)
+				}
+				Expect(ids[0] < ids[1]).To(BeTrue())
+
+				messages, err = outboxRepo.ListOutboxMessages(context.Background(), "outbox", 1)
+				Expect(err).To(BeNil())
+				Expect(len(*messages)).To(Equal(1))
+			})
+			By("should delete relayed outbox messages", func() {
+				err := outboxRepo.DeleteOutboxMessages(context.Background(), ids)
+				Expect(err).To(BeNil())
+				messages, err := outboxRepo.ListOutboxMessages(context.Background(), "outbox", 10)
+				Expect(err).To(BeNil())
+				Expect(len(*messages)).To(Equal(0))
+				messages, err = outboxRepo.ListOutboxMessages(context.Background(), "other", 10)
+				Expect(err).To(BeNil())
+				Expect(len(*messages)).To(Equal(1))
+			})
+		})
+		var _ = It("should relay claimed outbox messages in order", func() {
+			err := outboxRepo.CreateOutboxMessages(context.Background(), newOutboxMessage("relay", "1"), newOutboxMessage("relay", "2"), newOutboxMessage("relay", "3"))
+			Expect(err).To(BeNil())
+
+			var published []string
+			claimed, err := outboxRepo.RelayOutboxMessages(context.Background(), "relay", 10, func(message *domain_model.OutboxMessage) error {
+				if message.UUID == "2" {
+					return errors.New("broker unavailable")
+				}
+				published = append(published, message.UUID)
+				return nil
+			})
+			Expect(err).NotTo(BeNil())
+			Expect(claimed).To(Equal(3))
+			Expect(published).To(Equal([]string{"1"}))
+			messages, err := outboxRepo.ListOutboxMessages(context.Background(), "relay", 10)
+			Expect(err).To(BeNil())
+			Expect(len(*messages)).To(Equal(2))
+
+			claimed, err = outboxRepo.RelayOutboxMessages(context.Background(), "relay", 1, func(message *domain_model.OutboxMessage) error {
+				published = append(published, message.UUID)
+				return nil
+			})
+			Expect(err).To(BeNil())
+			Expect(claimed).To(Equal(1))
+			Expect(published).To(Equal([]string{"1", "2"}))
+			messages, err = outboxRepo.ListOutboxMessages(context.Background(), "relay", 10)
+			Expect(err).To(BeNil())
+			Expect(len(*messages)).To(Equal(1))
+			Expect((*messages)[0].UUID).To(Equal("3"))
+		})
+		var _ = It("should record outbox messages together with business changes", func() {
+			payment := domain_model.Payment{
+				ID:           2,
+				CustomerID:   3,
+				CurrencyCode: "NT",
+				Amount:       100,
+			}
+			By("should record outbox message when the change is committed", func() {
+				err := paymentRepo.CreatePayment(context.Background(), &payment, &domain_model.ProcessedMessage{
+					UUID:       "create",
+					PurchaseID: payment.ID,
+					Reply:      newOutboxMessage("tx", "created"),
+				})
+				Expect(err).To(BeNil())
+				messages, err := outboxRepo.ListOutboxMessages(context.Background(), "tx", 10)
+				Expect(err).To(BeNil())
+				Expect(len(*messages)).To(Equal(1))
+				Expect((*messages)[0].UUID).To(Equal("created"))
+			})
+			By("should not record outbox message when the change is rollbacked", func() {
+				err := paymentRepo.CreatePayment(context.Background(), &payment, &domain_model.ProcessedMessage{
+					UUID:       "duplicate",
+					PurchaseID: payment.ID,
+					Reply:      newOutboxMessage("tx", "duplicated"),
+				})
 				Expect(err).NotTo(BeNil())
+				messages, err := outboxRepo.ListOutboxMessages(context.Background(), "tx", 10)
+				Expect(err).To(BeNil())
+				Expect(len(*messages)).To(Equal(1))
+			})
+			By("should replay the original reply when the command is redelivered", func() {
+				err := paymentRepo.CreatePayment(context.Background(), &payment, &domain_model.ProcessedMessage{
+					UUID:       "create",
+					PurchaseID: payment.ID,
+					Reply:      newOutboxMessage("tx", "redelivered"),
+				})
+				Expect(err).To(BeNil())
+				messages, err := outboxRepo.ListOutboxMessages(context.Background(), "tx", 10)
+				Expect(err).To(BeNil())
+				Expect(len(*messages)).To(Equal(2))
+				Expect((*messages)[1].UUID).To(Equal("created"))
+				Expect((*messages)[1].Payload).To(Equal([]byte("created")))
+			})
+			By("should replay the original reply of a command processed without changes", func() {
+				processed := &domain_model.ProcessedMessage{
+					UUID:       "rollback",
+					PurchaseID: payment.ID,
+					Reply:      newOutboxMessage("failed", "failed"),
+				}
+				Expect(processedMessageRepo.RecordProcessedMessage(context.Background(), processed)).To(BeNil())
+				err := paymentRepo.RefundFullPayment(context.Background(), payment.ID, processed)
+				Expect(err).To(BeNil())
+				retrievedPayment, err := paymentRepo.GetPayment(context.Background(), payment.ID)
+				Expect(err).To(BeNil())
+				Expect(retrievedPayment.RefundedAmount).To(Equal(int64(0)))
+				messages, err := outboxRepo.ListOutboxMessages(context.Background(), "failed", 10)
+				Expect(err).To(BeNil())
+				Expect(len(*messages)).To(Equal(2))
+			})
+			By("should record outbox messages together with saga transitions", func() {
+				var purchaseID uint64 = 2
+				err := sagaRepo.CreateSagaInstance(context.Background(), &domain_model.SagaInstance{
+					PurchaseID:    purchaseID,
+					CustomerID:    3,
+					CorrelationID: "correlation",
+					CurrentStep:   "UPDATE_PRODUCT_INVENTORY",
+					Status:        "STATUS_EXUCUTE",
+				})
+				Expect(err).To(BeNil())
+				err = sagaRepo.RecordTransitions(context.Background(), purchaseID, nil, &[]domain_model.SagaTransition{
+					{
+						Step:      "UPDATE_PRODUCT_INVENTORY",
+						Status:    "STATUS_FAILED",
+						Error:     "insufficient inventory",
+						Timestamp: time.UnixMilli(1000),
+					},
+					{
+						Step:      "UPDATE_PRODUCT_INVENTORY",
+						Status:    "STATUS_ROLLBACKED",
+						Timestamp: time.UnixMilli(1000),
+					},
+				}, time.Time{}, newOutboxMessage("saga", "failed"), newOutboxMessage("saga", "rollbacked"))
+				Expect(err).To(BeNil())
+
+				retrievedInstance, err := sagaRepo.GetSagaInstance(context.Background(), purchaseID)
+				Expect(err).To(BeNil())
+				Expect(retrievedInstance.Status).To(Equal("STATUS_ROLLBACKED"))
+				retrievedTransitions, err := sagaRepo.GetSagaTransitions(context.Background(), purchaseID)
+				Expect(err).To(BeNil())
+				Expect((*retrievedTransitions)[0].Error).To(Equal("insufficient inventory"))
+				messages, err := outboxRepo.ListOutboxMessages(context.Background(), "saga", 10)
+				Expect(err).To(BeNil())
+				Expect(len(*messages)).To(Equal(2))
+				Expect((*messages)[1].UUID).To(Equal("rollbacked"))
 			})
 		})
 	})
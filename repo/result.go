@@ -0,0 +1,189 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	conf "github.com/minghsu0107/saga-product/config"
+	"github.com/minghsu0107/saga-product/domain/event"
+	saga_pb "github.com/minghsu0107/saga-product/pb"
+	"github.com/redis/go-redis/v9"
+)
+
+// PurchaseResultRepository is the purchase result stream interface
+// Results are identified by stream IDs of the form <millisecondsTime>-<sequenceNumber>, which increase along the stream
+type PurchaseResultRepository interface {
+	GetFirstPurchaseResultID(ctx context.Context) (string, error)
+	GetLastPurchaseResultID(ctx context.Context) (string, error)
+	ListPurchaseResults(ctx context.Context, afterID string, size int64) (*[]event.StreamedPurchaseResult, error)
+	ReadPurchaseResults(ctx context.Context, afterID string, size int64, block time.Duration) (*[]event.StreamedPurchaseResult, error)
+}
+
+// PurchaseResultRepositoryImpl reads purchase results from the redis stream to which the orchestrator publishes
+type PurchaseResultRepositoryImpl struct {
+	client redis.UniversalClient
+}
+
+// NewPurchaseResultRepository is the factory of PurchaseResultRepository
+func NewPurchaseResultRepository(client redis.UniversalClient) PurchaseResultRepository {
+	return &PurchaseResultRepositoryImpl{
+		client: client,
+	}
+}
+
+// GetFirstPurchaseResultID gets the ID of the oldest result not trimmed from the stream, or 0-0 if the stream is empty
+func (repo *PurchaseResultRepositoryImpl) GetFirstPurchaseResultID(ctx context.Context) (string, error) {
+	entries, err := repo.client.XRangeN(ctx, conf.PurchaseResultTopic, "-", "+", 1).Result()
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "0-0", nil
+	}
+	return entries[0].ID, nil
+}
+
+// GetLastPurchaseResultID gets the ID of the latest result, or 0-0 if the stream is empty
+func (repo *PurchaseResultRepositoryImpl) GetLastPurchaseResultID(ctx context.Context) (string, error) {
+	entries, err := repo.client.XRevRangeN(ctx, conf.PurchaseResultTopic, "+", "-", 1).Result()
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "0-0", nil
+	}
+	return entries[0].ID, nil
+}
+
+// ListPurchaseResults lists results after the given ID without blocking
+func (repo *PurchaseResultRepositoryImpl) ListPurchaseResults(ctx context.Context, afterID string, size int64) (*[]event.StreamedPurchaseResult, error) {
+	entries, err := repo.client.XRangeN(ctx, conf.PurchaseResultTopic, afterID, "+", size+1).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > 0 && entries[0].ID == afterID {
+		entries = entries[1:]
+	}
+	if int64(len(entries)) > size {
+		entries = entries[:size]
+	}
+	return decodePurchaseResults(entries)
+}
+
+// ReadPurchaseResults reads results after the given ID, blocking until any result arrives or the block duration elapses
+func (repo *PurchaseResultRepositoryImpl) ReadPurchaseResults(ctx context.Context, afterID string, size int64, block time.Duration) (*[]event.StreamedPurchaseResult, error) {
+	streams, err := repo.client.XRead(ctx, &redis.XReadArgs{
+		Streams: []string{conf.PurchaseResultTopic, afterID},
+		Count:   size,
+		Block:   block,
+	}).Result()
+	if err == redis.Nil {
+		return &[]event.StreamedPurchaseResult{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var entries []redis.XMessage
+	for _, stream := range streams {
+		entries = append(entries, stream.Messages...)
+	}
+	return decodePurchaseResults(entries)
+}
+
+// ParseStreamID parses a stream ID into its millisecond time and sequence number
+func ParseStreamID(id string) (uint64, uint64, error) {
+	parts := strings.Split(id, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid stream ID: %s", id)
+	}
+	ms, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid stream ID: %s", id)
+	}
+	seq, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid stream ID: %s", id)
+	}
+	return ms, seq, nil
+}
+
+// StreamIDAfter reports whether stream ID a comes after stream ID b; both must be valid
+func StreamIDAfter(a, b string) bool {
+	aMs, aSeq, _ := ParseStreamID(a)
+	bMs, bSeq, _ := ParseStreamID(b)
+	return aMs > bMs || (aMs == bMs && aSeq > bSeq)
+}
+
+func decodePurchaseResults(entries []redis.XMessage) (*[]event.StreamedPurchaseResult, error) {
+	results := []event.StreamedPurchaseResult{}
+	for _, entry := range entries {
+		payload, ok := entry.Values["payload"].(string)
+		if !ok {
+			return nil, errors.New("purchase result without payload")
+		}
+		result, err := decodePurchaseResult([]byte(payload))
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, event.StreamedPurchaseResult{
+			ID:     entry.ID,
+			Result: result,
+		})
+	}
+	return &results, nil
+}
+
+func decodePurchaseResult(payload []byte) (*event.PurchaseResult, error) {
+	var result saga_pb.PurchaseResult
+	if err := json.Unmarshal(payload, &result); err != nil {
+		return nil, err
+	}
+	return &event.PurchaseResult{
+		CustomerID: result.CustomerId,
+		PurchaseID: result.PurchaseId,
+		Step:       getPurchaseStep(result.Step),
+		Status:     getPurchaseStatus(result.Status),
+		Error:      result.Error,
+		Timestamp:  result.Timestamp.AsTime(),
+	}, nil
+}
+
+func getPurchaseStep(step saga_pb.PurchaseResultStep) string {
+	switch step {
+	case saga_pb.PurchaseResultStep_STEP_UPDATE_PRODUCT_INVENTORY:
+		return event.StepUpdateProductInventory
+	case saga_pb.PurchaseResultStep_STEP_CREATE_ORDER:
+		return event.StepCreateOrder
+	case saga_pb.PurchaseResultStep_STEP_CREATE_PAYMENT:
+		return event.StepCreatePayment
+	case saga_pb.PurchaseResultStep_STEP_CANCEL_ORDER:
+		return event.StepCancelOrder
+	case saga_pb.PurchaseResultStep_STEP_REFUND_PAYMENT:
+		return event.StepRefundPayment
+	case saga_pb.PurchaseResultStep_STEP_RESTOCK_PRODUCT_INVENTORY:
+		return event.StepRestockProductInventory
+	}
+	return ""
+}
+
+func getPurchaseStatus(status saga_pb.PurchaseResultStatus) string {
+	switch status {
+	case saga_pb.PurchaseResultStatus_STATUS_EXUCUTE:
+		return event.StatusExecute
+	case saga_pb.PurchaseResultStatus_STATUS_SUCCESS:
+		return event.StatusSucess
+	case saga_pb.PurchaseResultStatus_STATUS_FAILED:
+		return event.StatusFailed
+	case saga_pb.PurchaseResultStatus_STATUS_ROLLBACKED:
+		return event.StatusRollbacked
+	case saga_pb.PurchaseResultStatus_STATUS_ROLLBACK_FAIL:
+		return event.StatusRollbackFailed
+	case saga_pb.PurchaseResultStatus_STATUS_TIMEOUT:
+		return event.StatusTimeout
+	}
+	return ""
+}
@@ -0,0 +1,141 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/minghsu0107/saga-product/domain/event"
+)
+
+// InMemoryPurchaseResultRepository implements PurchaseResultRepository over an in-memory stream
+// It also implements message.Publisher, standing in for the redis stream when all services run in one process
+type InMemoryPurchaseResultRepository struct {
+	mu       sync.Mutex
+	results  []event.StreamedPurchaseResult
+	maxlen   int
+	lastMs   uint64
+	lastSeq  uint64
+	appended chan struct{}
+}
+
+// NewInMemoryPurchaseResultRepository is the factory of InMemoryPurchaseResultRepository
+// The stream keeps at most maxlen results, or all results if maxlen is not positive
+func NewInMemoryPurchaseResultRepository(maxlen int64) *InMemoryPurchaseResultRepository {
+	return &InMemoryPurchaseResultRepository{
+		maxlen:   int(maxlen),
+		appended: make(chan struct{}),
+	}
+}
+
+// Publish appends purchase results to the stream
+func (repo *InMemoryPurchaseResultRepository) Publish(topic string, messages ...*message.Message) error {
+	var results []*event.PurchaseResult
+	for _, msg := range messages {
+		result, err := decodePurchaseResult(msg.Payload)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for _, result := range results {
+		ms := uint64(time.Now().UnixMilli())
+		if ms > repo.lastMs {
+			repo.lastMs, repo.lastSeq = ms, 0
+		} else {
+			repo.lastSeq++
+		}
+		repo.results = append(repo.results, event.StreamedPurchaseResult{
+			ID:     fmt.Sprintf("%d-%d", repo.lastMs, repo.lastSeq),
+			Result: result,
+		})
+	}
+	if repo.maxlen > 0 && len(repo.results) > repo.maxlen {
+		repo.results = append([]event.StreamedPurchaseResult(nil), repo.results[len(repo.results)-repo.maxlen:]...)
+	}
+	close(repo.appended)
+	repo.appended = make(chan struct{})
+	return nil
+}
+
+// Close closes the publisher; the stream can still be read
+func (repo *InMemoryPurchaseResultRepository) Close() error {
+	return nil
+}
+
+// GetFirstPurchaseResultID gets the ID of the oldest result not trimmed from the stream, or 0-0 if the stream is empty
+func (repo *InMemoryPurchaseResultRepository) GetFirstPurchaseResultID(ctx context.Context) (string, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if len(repo.results) == 0 {
+		return "0-0", nil
+	}
+	return repo.results[0].ID, nil
+}
+
+// GetLastPurchaseResultID gets the ID of the latest result, or 0-0 if the stream is empty
+func (repo *InMemoryPurchaseResultRepository) GetLastPurchaseResultID(ctx context.Context) (string, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if len(repo.results) == 0 {
+		return "0-0", nil
+	}
+	return repo.results[len(repo.results)-1].ID, nil
+}
+
+// ListPurchaseResults lists results after the given ID without blocking
+func (repo *InMemoryPurchaseResultRepository) ListPurchaseResults(ctx context.Context, afterID string, size int64) (*[]event.StreamedPurchaseResult, error) {
+	if _, _, err := ParseStreamID(afterID); err != nil {
+		return nil, err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	results, _ := repo.listPurchaseResults(afterID, size)
+	return results, nil
+}
+
+// ReadPurchaseResults reads results after the given ID, blocking until any result arrives or the block duration elapses
+// A zero block duration blocks until any result arrives
+func (repo *InMemoryPurchaseResultRepository) ReadPurchaseResults(ctx context.Context, afterID string, size int64, block time.Duration) (*[]event.StreamedPurchaseResult, error) {
+	if _, _, err := ParseStreamID(afterID); err != nil {
+		return nil, err
+	}
+	var timeout <-chan time.Time
+	if block > 0 {
+		timer := time.NewTimer(block)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		repo.mu.Lock()
+		results, appended := repo.listPurchaseResults(afterID, size)
+		repo.mu.Unlock()
+		if len(*results) > 0 {
+			return results, nil
+		}
+		select {
+		case <-appended:
+		case <-timeout:
+			return results, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (repo *InMemoryPurchaseResultRepository) listPurchaseResults(afterID string, size int64) (*[]event.StreamedPurchaseResult, chan struct{}) {
+	results := []event.StreamedPurchaseResult{}
+	for _, result := range repo.results {
+		if int64(len(results)) == size {
+			break
+		}
+		if StreamIDAfter(result.ID, afterID) {
+			results = append(results, result)
+		}
+	}
+	return &results, repo.appended
+}
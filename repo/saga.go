@@ -0,0 +1,205 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	domain_model "github.com/minghsu0107/saga-product/domain/model"
+	"github.com/minghsu0107/saga-product/infra/db/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SagaRepository is the saga log interface
+type SagaRepository interface {
+	CreateSagaInstance(ctx context.Context, instance *domain_model.SagaInstance) error
+	GetSagaInstance(ctx context.Context, purchaseID uint64) (*domain_model.SagaInstance, error)
+	RecordTransitions(ctx context.Context, purchaseID uint64, from *domain_model.SagaState, transitions *[]domain_model.SagaTransition, deadline time.Time, outbox ...*domain_model.OutboxMessage) error
+	GetSagaTransitions(ctx context.Context, purchaseID uint64) (*[]domain_model.SagaTransition, error)
+	ListExpiredSagaInstances(ctx context.Context, now time.Time, size int) (*[]domain_model.SagaInstance, error)
+}
+
+// SagaRepositoryImpl implements SagaRepository interface
+type SagaRepositoryImpl struct {
+	db *gorm.DB
+}
+
+// NewSagaRepository is the factory of SagaRepository
+func NewSagaRepository(db *gorm.DB) SagaRepository {
+	return &SagaRepositoryImpl{
+		db: db,
+	}
+}
+
+// CreateSagaInstance creates a saga instance; it is a no-op if the instance already exists
+func (repo *SagaRepositoryImpl) CreateSagaInstance(ctx context.Context, instance *domain_model.SagaInstance) error {
+	return repo.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&model.SagaInstance{
+		PurchaseID:    instance.PurchaseID,
+		CustomerID:    instance.CustomerID,
+		CorrelationID: instance.CorrelationID,
+		CurrentStep:   instance.CurrentStep,
+		Status:        instance.Status,
+	}).Error
+}
+
+// GetSagaInstance gets a saga instance together with the latest status of each step
+func (repo *SagaRepositoryImpl) GetSagaInstance(ctx context.Context, purchaseID uint64) (*domain_model.SagaInstance, error) {
+	var instance model.SagaInstance
+	if err := repo.db.WithContext(ctx).Model(&model.SagaInstance{}).Where("purchase_id = ?", purchaseID).First(&instance).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSagaInstanceNotFound
+		}
+		return nil, err
+	}
+	transitions, err := repo.GetSagaTransitions(ctx, purchaseID)
+	if err != nil {
+		return nil, err
+	}
+	return &domain_model.SagaInstance{
+		PurchaseID:    instance.PurchaseID,
+		CustomerID:    instance.CustomerID,
+		CorrelationID: instance.CorrelationID,
+		CurrentStep:   instance.CurrentStep,
+		Status:        instance.Status,
+		Steps:         getSagaSteps(transitions),
+		Deadline:      fromDeadline(instance.Deadline),
+		CreatedAt:     time.UnixMilli(instance.CreatedAt),
+		UpdatedAt:     time.UnixMilli(instance.UpdatedAt),
+	}, nil
+}
+
+// RecordTransitions appends transitions to the saga log and moves the saga instance to the last one
+// Outbox messages are recorded in the same transaction
+// A zero deadline means the step entered by the last transition never times out
+// If from is given, the transitions are only recorded if the saga instance is still in that state, otherwise ErrSagaInstanceChanged is returned
+func (repo *SagaRepositoryImpl) RecordTransitions(ctx context.Context, purchaseID uint64, from *domain_model.SagaState, transitions *[]domain_model.SagaTransition, deadline time.Time, outbox ...*domain_model.OutboxMessage) error {
+	if len(*transitions) == 0 {
+		return createOutboxMessages(repo.db.WithContext(ctx), outbox)
+	}
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return err
+	}
+
+	var entries []model.SagaTransition
+	for _, transition := range *transitions {
+		entries = append(entries, model.SagaTransition{
+			PurchaseID: purchaseID,
+			Step:       transition.Step,
+			Status:     transition.Status,
+			Error:      transition.Error,
+			Timestamp:  transition.Timestamp.UnixMilli(),
+		})
+	}
+	if err := tx.Create(&entries).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	last := (*transitions)[len(*transitions)-1]
+	query := tx.Model(&model.SagaInstance{}).Where("purchase_id = ?", purchaseID)
+	if from != nil {
+		query = query.Where("current_step = ? AND status = ? AND deadline = ?", from.CurrentStep, from.Status, toDeadline(from.Deadline))
+	}
+	result := query.Updates(map[string]interface{}{
+		"current_step": last.Step,
+		"status":       last.Status,
+		"deadline":     toDeadline(deadline),
+	})
+	if err := result.Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if from != nil && result.RowsAffected == 0 {
+		tx.Rollback()
+		return ErrSagaInstanceChanged
+	}
+	if err := createOutboxMessages(tx, outbox); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// GetSagaTransitions gets all transitions of a saga instance in the order they were recorded
+func (repo *SagaRepositoryImpl) GetSagaTransitions(ctx context.Context, purchaseID uint64) (*[]domain_model.SagaTransition, error) {
+	var transitions []model.SagaTransition
+	if err := repo.db.WithContext(ctx).Model(&model.SagaTransition{}).Select("step", "status", "error", "timestamp").Where("purchase_id = ?", purchaseID).Order("id").Find(&transitions).Error; err != nil {
+		return nil, err
+	}
+	var domainTransitions []domain_model.SagaTransition
+	for _, transition := range transitions {
+		domainTransitions = append(domainTransitions, domain_model.SagaTransition{
+			Step:      transition.Step,
+			Status:    transition.Status,
+			Error:     transition.Error,
+			Timestamp: time.UnixMilli(transition.Timestamp),
+		})
+	}
+	return &domainTransitions, nil
+}
+
+// ListExpiredSagaInstances lists saga instances whose current step has passed its deadline
+func (repo *SagaRepositoryImpl) ListExpiredSagaInstances(ctx context.Context, now time.Time, size int) (*[]domain_model.SagaInstance, error) {
+	var instances []model.SagaInstance
+	if err := repo.db.WithContext(ctx).Model(&model.SagaInstance{}).Where("deadline > 0 AND deadline <= ?", now.UnixMilli()).Order("deadline").Limit(size).Find(&instances).Error; err != nil {
+		return nil, err
+	}
+	var domainInstances []domain_model.SagaInstance
+	for _, instance := range instances {
+		domainInstances = append(domainInstances, domain_model.SagaInstance{
+			PurchaseID:    instance.PurchaseID,
+			CustomerID:    instance.CustomerID,
+			CorrelationID: instance.CorrelationID,
+			CurrentStep:   instance.CurrentStep,
+			Status:        instance.Status,
+			Deadline:      fromDeadline(instance.Deadline),
+			CreatedAt:     time.UnixMilli(instance.CreatedAt),
+			UpdatedAt:     time.UnixMilli(instance.UpdatedAt),
+		})
+	}
+	return &domainInstances, nil
+}
+
+func toDeadline(deadline time.Time) int64 {
+	if deadline.IsZero() {
+		return 0
+	}
+	return deadline.UnixMilli()
+}
+
+func fromDeadline(deadline int64) time.Time {
+	if deadline == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(deadline)
+}
+
+// getSagaSteps folds ordered transitions into the latest status of each step
+func getSagaSteps(transitions *[]domain_model.SagaTransition) *[]domain_model.SagaStep {
+	var steps []domain_model.SagaStep
+	indexes := make(map[string]int)
+	for _, transition := range *transitions {
+		step := domain_model.SagaStep{
+			Step:      transition.Step,
+			Status:    transition.Status,
+			UpdatedAt: transition.Timestamp,
+		}
+		if i, ok := indexes[transition.Step]; ok {
+			steps[i] = step
+			continue
+		}
+		indexes[transition.Step] = len(steps)
+		steps = append(steps, step)
+	}
+	return &steps
+}
@@ -0,0 +1,192 @@
+package repo
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	domain_model "github.com/minghsu0107/saga-product/domain/model"
+)
+
+// InMemorySagaRepository implements SagaRepository and OutboxRepository interfaces in memory
+// It is meant for tests and single-process setups where durability is not required
+type InMemorySagaRepository struct {
+	mu          sync.RWMutex
+	relayMu     sync.Mutex
+	instances   map[uint64]domain_model.SagaInstance
+	transitions map[uint64][]domain_model.SagaTransition
+	outbox      []domain_model.OutboxMessage
+	outboxID    uint64
+}
+
+// NewInMemorySagaRepository is the factory of InMemorySagaRepository
+func NewInMemorySagaRepository() *InMemorySagaRepository {
+	return &InMemorySagaRepository{
+		instances:   make(map[uint64]domain_model.SagaInstance),
+		transitions: make(map[uint64][]domain_model.SagaTransition),
+	}
+}
+
+// CreateSagaInstance creates a saga instance; it is a no-op if the instance already exists
+func (repo *InMemorySagaRepository) CreateSagaInstance(ctx context.Context, instance *domain_model.SagaInstance) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if _, ok := repo.instances[instance.PurchaseID]; ok {
+		return nil
+	}
+	now := time.Now()
+	repo.instances[instance.PurchaseID] = domain_model.SagaInstance{
+		PurchaseID:    instance.PurchaseID,
+		CustomerID:    instance.CustomerID,
+		CorrelationID: instance.CorrelationID,
+		CurrentStep:   instance.CurrentStep,
+		Status:        instance.Status,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	return nil
+}
+
+// GetSagaInstance gets a saga instance together with the latest status of each step
+func (repo *InMemorySagaRepository) GetSagaInstance(ctx context.Context, purchaseID uint64) (*domain_model.SagaInstance, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	instance, ok := repo.instances[purchaseID]
+	if !ok {
+		return nil, ErrSagaInstanceNotFound
+	}
+	transitions := repo.copyTransitions(purchaseID)
+	instance.Steps = getSagaSteps(transitions)
+	return &instance, nil
+}
+
+// RecordTransitions appends transitions to the saga log and moves the saga instance to the last one
+// Outbox messages are recorded atomically with the transitions
+// A zero deadline means the step entered by the last transition never times out
+// If from is given, the transitions are only recorded if the saga instance is still in that state, otherwise ErrSagaInstanceChanged is returned
+func (repo *InMemorySagaRepository) RecordTransitions(ctx context.Context, purchaseID uint64, from *domain_model.SagaState, transitions *[]domain_model.SagaTransition, deadline time.Time, outbox ...*domain_model.OutboxMessage) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if len(*transitions) > 0 {
+		instance, ok := repo.instances[purchaseID]
+		if from != nil && (!ok || instance.CurrentStep != from.CurrentStep || instance.Status != from.Status || !instance.Deadline.Equal(from.Deadline)) {
+			return ErrSagaInstanceChanged
+		}
+		repo.transitions[purchaseID] = append(repo.transitions[purchaseID], *transitions...)
+		if ok {
+			last := (*transitions)[len(*transitions)-1]
+			instance.CurrentStep = last.Step
+			instance.Status = last.Status
+			instance.Deadline = deadline
+			instance.UpdatedAt = time.Now()
+			repo.instances[purchaseID] = instance
+		}
+	}
+	repo.appendOutboxMessages(outbox)
+	return nil
+}
+
+// GetSagaTransitions gets all transitions of a saga instance in the order they were recorded
+func (repo *InMemorySagaRepository) GetSagaTransitions(ctx context.Context, purchaseID uint64) (*[]domain_model.SagaTransition, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	return repo.copyTransitions(purchaseID), nil
+}
+
+// ListExpiredSagaInstances lists saga instances whose current step has passed its deadline
+func (repo *InMemorySagaRepository) ListExpiredSagaInstances(ctx context.Context, now time.Time, size int) (*[]domain_model.SagaInstance, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	var instances []domain_model.SagaInstance
+	for _, instance := range repo.instances {
+		if instance.Deadline.IsZero() || instance.Deadline.After(now) {
+			continue
+		}
+		instances = append(instances, instance)
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].Deadline.Before(instances[j].Deadline) })
+	if len(instances) > size {
+		instances = instances[:size]
+	}
+	return &instances, nil
+}
+
+// CreateOutboxMessages records messages that are not bound to any saga transition
+func (repo *InMemorySagaRepository) CreateOutboxMessages(ctx context.Context, messages ...*domain_model.OutboxMessage) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.appendOutboxMessages(messages)
+	return nil
+}
+
+// ListOutboxMessages lists the oldest messages of a service
+func (repo *InMemorySagaRepository) ListOutboxMessages(ctx context.Context, service string, size int) (*[]domain_model.OutboxMessage, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	var messages []domain_model.OutboxMessage
+	for _, message := range repo.outbox {
+		if len(messages) == size {
+			break
+		}
+		if message.Service == service {
+			messages = append(messages, message)
+		}
+	}
+	return &messages, nil
+}
+
+// RelayOutboxMessages publishes the oldest messages of a service in order and deletes the published ones
+// Relays take turns, so that concurrent relays never publish the same message
+func (repo *InMemorySagaRepository) RelayOutboxMessages(ctx context.Context, service string, size int, publish func(message *domain_model.OutboxMessage) error) (int, error) {
+	repo.relayMu.Lock()
+	defer repo.relayMu.Unlock()
+	messages, err := repo.ListOutboxMessages(ctx, service, size)
+	if err != nil {
+		return 0, err
+	}
+	var published []uint64
+	for i := range *messages {
+		if err = publish(&(*messages)[i]); err != nil {
+			break
+		}
+		published = append(published, (*messages)[i].ID)
+	}
+	if deleteErr := repo.DeleteOutboxMessages(ctx, published); deleteErr != nil {
+		return 0, deleteErr
+	}
+	return len(*messages), err
+}
+
+// DeleteOutboxMessages deletes relayed messages
+func (repo *InMemorySagaRepository) DeleteOutboxMessages(ctx context.Context, ids []uint64) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	deleted := make(map[uint64]bool)
+	for _, id := range ids {
+		deleted[id] = true
+	}
+	var outbox []domain_model.OutboxMessage
+	for _, message := range repo.outbox {
+		if !deleted[message.ID] {
+			outbox = append(outbox, message)
+		}
+	}
+	repo.outbox = outbox
+	return nil
+}
+
+func (repo *InMemorySagaRepository) appendOutboxMessages(messages []*domain_model.OutboxMessage) {
+	for _, message := range messages {
+		repo.outboxID++
+		entry := *message
+		entry.ID = repo.outboxID
+		repo.outbox = append(repo.outbox, entry)
+	}
+}
+
+func (repo *InMemorySagaRepository) copyTransitions(purchaseID uint64) *[]domain_model.SagaTransition {
+	var transitions []domain_model.SagaTransition
+	transitions = append(transitions, repo.transitions[purchaseID]...)
+	return &transitions
+}
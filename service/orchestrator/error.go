@@ -0,0 +1,16 @@
+package orchestrator
+
+import (
+	"errors"
+)
+
+var (
+	// ErrUnauthorized is unauthorized error
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrPurchaseNotFound is purchase not found error
+	ErrPurchaseNotFound = errors.New("purchase not found")
+	// ErrInvalidLastEventID is invalid last event ID error
+	ErrInvalidLastEventID = errors.New("invalid last event ID")
+	// ErrLastEventIDExpired is last event ID expired error
+	ErrLastEventIDExpired = errors.New("last event ID has been trimmed or is out of the replay window")
+)
@@ -3,6 +3,7 @@ package orchestrator
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -14,57 +15,138 @@ import (
 	"github.com/minghsu0107/saga-product/domain/event"
 	"github.com/minghsu0107/saga-product/domain/model"
 	"github.com/minghsu0107/saga-product/infra/broker"
+	saga_pb "github.com/minghsu0107/saga-product/pb"
 	"github.com/minghsu0107/saga-product/pkg"
+	"github.com/minghsu0107/saga-product/repo"
 	log "github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel"
 )
 
-type MessageType int
-
-const (
-	TX_MSG MessageType = iota
-	RESULT_MSG
-)
-
 // OrchestratorServiceImpl implementation
 type OrchestratorServiceImpl struct {
-	txPublisher     broker.NATSPublisher
-	resultPublisher broker.RedisPublisher
-	logger          *log.Entry
+	service    string
+	sagaRepo   repo.SagaRepository
+	definition *SagaDefinition
+	clock      pkg.Clock
+	logger     *log.Entry
+}
+
+// PurchaseServiceImpl implementation
+type PurchaseServiceImpl struct {
+	sagaRepo repo.SagaRepository
+	logger   *log.Entry
+}
+
+// sagaUpdate collects the transitions of a saga and the commands and results they publish
+// It is recorded atomically to the saga log and its outbox, from which the messages are relayed to the broker
+// If from is set, the update is only recorded if the saga instance is still in that state
+type sagaUpdate struct {
+	purchaseID  uint64
+	from        *model.SagaState
+	transitions []model.SagaTransition
+	messages    []*model.OutboxMessage
+	deadline    time.Time
 }
 
+var expiredSagaBatchSize = 100
+
 // NewOrchestratorService factory
-func NewOrchestratorService(config *conf.Config, txPublisher broker.NATSPublisher, resultPublisher broker.RedisPublisher) (OrchestratorService, error) {
+func NewOrchestratorService(config *conf.Config, sagaRepo repo.SagaRepository, definition *SagaDefinition, clock pkg.Clock) (OrchestratorService, error) {
+	if len(definition.Steps) == 0 {
+		return nil, errors.New("saga definition has no step")
+	}
 	return &OrchestratorServiceImpl{
-		txPublisher:     txPublisher,
-		resultPublisher: resultPublisher,
+		service:    config.App,
+		sagaRepo:   sagaRepo,
+		definition: definition,
+		clock:      clock,
 		logger: config.Logger.ContextLogger.WithFields(log.Fields{
 			"type": "service:OrchestratorService",
 		}),
 	}, nil
 }
 
-// StartTransaction starts the first transaction, which is UpdateProductInventory
+// NewPurchaseService factory
+func NewPurchaseService(config *conf.Config, sagaRepo repo.SagaRepository) PurchaseService {
+	return &PurchaseServiceImpl{
+		sagaRepo: sagaRepo,
+		logger: config.Logger.ContextLogger.WithFields(log.Fields{
+			"type": "service:PurchaseService",
+		}),
+	}
+}
+
+// GetPurchase gets the current step and the transition history of a purchase from the saga log
+// The failure reason is the error of the latest failed transition
+func (svc *PurchaseServiceImpl) GetPurchase(ctx context.Context, customerID, purchaseID uint64) (*model.PurchaseStatus, error) {
+	instance, err := svc.sagaRepo.GetSagaInstance(ctx, purchaseID)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		if errors.Is(err, repo.ErrSagaInstanceNotFound) {
+			return nil, ErrPurchaseNotFound
+		}
+		return nil, err
+	}
+
+	if customerID != instance.CustomerID {
+		return nil, ErrUnauthorized
+	}
+
+	transitions, err := svc.sagaRepo.GetSagaTransitions(ctx, purchaseID)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return nil, err
+	}
+	var reason string
+	for _, transition := range *transitions {
+		if transition.Error != "" {
+			reason = transition.Error
+		}
+	}
+	return &model.PurchaseStatus{
+		PurchaseID:  instance.PurchaseID,
+		CustomerID:  instance.CustomerID,
+		CurrentStep: instance.CurrentStep,
+		Status:      instance.Status,
+		Error:       reason,
+		Transitions: transitions,
+		CreatedAt:   instance.CreatedAt,
+		UpdatedAt:   instance.UpdatedAt,
+	}, nil
+}
+
+// StartTransaction starts the first step of the saga
+// A redelivered purchase whose saga has already started is ignored
 func (svc *OrchestratorServiceImpl) StartTransaction(parentCtx context.Context, purchase *model.Purchase, correlationID string) error {
 	tr := otel.Tracer("startTransaction")
 	ctx, span := tr.Start(parentCtx, "event.StartTransaction")
 	defer span.End()
 
-	cmd := encodeDomainPurchase(purchase)
-	payload, err := json.Marshal(cmd)
-	if err != nil {
+	if err := svc.sagaRepo.CreateSagaInstance(ctx, &model.SagaInstance{
+		PurchaseID:    purchase.ID,
+		CustomerID:    purchase.Order.CustomerID,
+		CorrelationID: correlationID,
+		CurrentStep:   svc.definition.Steps[0].Name,
+		Status:        event.StatusExecute,
+	}); err != nil {
 		return err
 	}
-	msg := message.NewMessage(watermill.NewUUID(), payload)
-	svc.publishPurchaseResult(ctx, &event.PurchaseResult{
-		CustomerID: purchase.Order.CustomerID,
-		PurchaseID: purchase.ID,
-		Step:       event.StepUpdateProductInventory,
-		Status:     event.StatusExecute,
-	}, correlationID)
-	middleware.SetCorrelationID(correlationID, msg)
-	svc.logger.Infof("update product inventory %v", purchase.ID)
-	return svc.publishMessage(ctx, conf.UpdateProductInventoryTopic, msg, TX_MSG)
+	update := &sagaUpdate{
+		purchaseID: purchase.ID,
+		from: &model.SagaState{
+			CurrentStep: svc.definition.Steps[0].Name,
+			Status:      event.StatusExecute,
+		},
+	}
+	if err := svc.executeStep(ctx, update, 0, purchase, correlationID); err != nil {
+		return err
+	}
+	err := svc.commit(ctx, update)
+	if errors.Is(err, repo.ErrSagaInstanceChanged) {
+		svc.logger.Infof("saga of purchase %v has already started", purchase.ID)
+		return nil
+	}
+	return err
 }
 
 // HandleReply handles reply events
@@ -74,287 +156,538 @@ func (svc *OrchestratorServiceImpl) HandleReply(parentCtx context.Context, msg *
 	defer span.End()
 
 	handler := msg.Metadata.Get(conf.HandlerHeader)
-	switch handler {
-	case conf.UpdateProductInventoryHandler:
-		resp, err := decodeCreatePurchaseResponse(msg.Payload)
+	if i, ok := svc.definition.confirmationIndex(handler); ok {
+		resp, err := decodeConfirmResponse(msg.Payload)
 		if err != nil {
 			return err
 		}
-		if resp.Success {
-			return svc.createOrder(ctx, resp.Purchase, correlationID)
+		for {
+			err := svc.handleConfirmationReply(ctx, i, resp, correlationID)
+			if !errors.Is(err, repo.ErrSagaInstanceChanged) {
+				return err
+			}
+			svc.logger.Infof("saga of purchase %v has moved on while handling reply of %s, handle it again", resp.PurchaseID, handler)
 		}
-		svc.logger.Error(resp.Error)
-		return svc.rollbackProductInventory(ctx, resp.Purchase.Order.CustomerID, resp.Purchase.ID, correlationID)
-	case conf.RollbackProductInventoryHandler:
+	}
+	i, compensation, ok := svc.definition.replyIndex(handler)
+	if !ok {
+		return fmt.Errorf("unkown handler: %s", handler)
+	}
+	step := svc.definition.Steps[i]
+	if compensation {
 		resp, err := decodeRollbackResponse(msg.Payload)
 		if err != nil {
 			return err
 		}
-		svc.publishRollbackResult(ctx, event.StepUpdateProductInventory, resp, correlationID)
-	case conf.CreateOrderHandler:
-		resp, err := decodeCreatePurchaseResponse(msg.Payload)
-		if err != nil {
-			return err
+		for {
+			err := svc.handleRollbackReply(ctx, step.Name, handler, resp, correlationID)
+			if !errors.Is(err, repo.ErrSagaInstanceChanged) {
+				return err
+			}
+			svc.logger.Infof("saga of purchase %v has moved on while handling reply of %s, handle it again", resp.PurchaseID, handler)
 		}
-		if resp.Success {
-			return svc.createPayment(ctx, resp.Purchase, correlationID)
-		}
-		svc.logger.Error(resp.Error)
-		return svc.rollbackFromOrder(ctx, resp.Purchase.Order.CustomerID, resp.Purchase.ID, correlationID)
-	case conf.RollbackOrderHandler:
-		resp, err := decodeRollbackResponse(msg.Payload)
-		if err != nil {
+	}
+
+	resp, err := decodeCreatePurchaseResponse(msg.Payload)
+	if err != nil {
+		return err
+	}
+	for {
+		err := svc.handleStepReply(ctx, i, resp, correlationID)
+		if !errors.Is(err, repo.ErrSagaInstanceChanged) {
 			return err
 		}
-		svc.publishRollbackResult(ctx, event.StepCreateOrder, resp, correlationID)
-	case conf.CreatePaymentHandler:
-		resp, err := decodeCreatePurchaseResponse(msg.Payload)
-		if err != nil {
+		svc.logger.Infof("saga of purchase %v has moved on while handling reply of step %s, handle it again", resp.Purchase.ID, step.Name)
+	}
+}
+
+// handleRollbackReply handles the reply of a rollback command, which is either the compensation of the given step or a cancellation step
+func (svc *OrchestratorServiceImpl) handleRollbackReply(ctx context.Context, step, handler string, resp *model.RollbackResponse, correlationID string) error {
+	update := &sagaUpdate{
+		purchaseID: resp.PurchaseID,
+	}
+	cancellation, err := svc.handleCancellationReply(ctx, update, handler, resp, correlationID)
+	if err != nil {
+		return err
+	}
+	if !cancellation {
+		if err := svc.addRollbackResult(ctx, update, step, resp, correlationID); err != nil {
 			return err
 		}
-		if resp.Success {
-			svc.publishPurchaseResult(ctx, &event.PurchaseResult{
-				CustomerID: resp.Purchase.Order.CustomerID,
-				PurchaseID: resp.Purchase.ID,
-				Step:       event.StepCreatePayment,
-				Status:     event.StatusSucess,
-			}, correlationID)
+	}
+	return svc.commit(ctx, update)
+}
 
-			return nil
+// handleStepReply handles the reply of the i-th step with respect to the saga log
+// The update is conditioned on the state the reply is checked against, so that a concurrent timeout or reply of the same step is never recorded twice
+func (svc *OrchestratorServiceImpl) handleStepReply(ctx context.Context, i int, resp *model.CreatePurchaseResponse, correlationID string) error {
+	update := &sagaUpdate{
+		purchaseID: resp.Purchase.ID,
+	}
+	expected, err := svc.expectReply(ctx, update, i, resp, correlationID)
+	if err != nil {
+		return err
+	}
+	if expected {
+		switch {
+		case !resp.Success:
+			svc.logger.Error(resp.Error)
+			err = svc.failStep(ctx, update, i, event.StatusFailed, resp.Error, resp.Purchase.Order.CustomerID, resp.Purchase.ID, correlationID)
+		case i == len(svc.definition.Steps)-1:
+			err = svc.complete(ctx, update, resp.Purchase, correlationID)
+		default:
+			err = svc.executeStep(ctx, update, i+1, resp.Purchase, correlationID)
 		}
-		svc.logger.Error(resp.Error)
-		return svc.rollbackFromPayment(ctx, resp.Purchase.Order.CustomerID, resp.Purchase.ID, correlationID)
-	case conf.RollbackPaymentHandler:
-		resp, err := decodeRollbackResponse(msg.Payload)
 		if err != nil {
 			return err
 		}
-		svc.publishRollbackResult(ctx, event.StepCreatePayment, resp, correlationID)
-	default:
-		return fmt.Errorf("unkown handler: %s", handler)
 	}
-	return nil
+	return svc.commit(ctx, update)
 }
 
-func (svc *OrchestratorServiceImpl) rollbackProductInventory(ctx context.Context, customerID, purchaseID uint64, correlationID string) error {
-	svc.logger.Infof("rollback product inventory %v", purchaseID)
-	svc.publishPurchaseResult(ctx, &event.PurchaseResult{
-		CustomerID: customerID,
-		PurchaseID: purchaseID,
-		Step:       event.StepUpdateProductInventory,
-		Status:     event.StatusFailed,
-	}, correlationID)
-
-	cmd := &pb.RollbackCmd{
-		PurchaseId: purchaseID,
-		Timestamp:  pkg.Time2pbTimestamp(time.Now()),
+// handleConfirmationReply cancels a succeeded saga whose confirmation of the i-th step has failed
+// The failure is recorded as a failed transition of the step, so that the cancellation skips the steps undoing it;
+// a saga that is being cancelled or has been cancelled is left as it is
+func (svc *OrchestratorServiceImpl) handleConfirmationReply(ctx context.Context, i int, resp *model.ConfirmResponse, correlationID string) error {
+	step := svc.definition.Steps[i]
+	if resp.Success {
+		return nil
 	}
-	payload, err := json.Marshal(cmd)
+	instance, err := svc.sagaRepo.GetSagaInstance(ctx, resp.PurchaseID)
 	if err != nil {
+		if errors.Is(err, repo.ErrSagaInstanceNotFound) {
+			svc.logger.Errorf("confirmation of step %s failed for unknown purchase %v", step.Name, resp.PurchaseID)
+			return nil
+		}
 		return err
 	}
-	msg := message.NewMessage(watermill.NewUUID(), payload)
-	middleware.SetCorrelationID(correlationID, msg)
-
-	svc.publishPurchaseResult(ctx, &event.PurchaseResult{
-		CustomerID: customerID,
-		PurchaseID: purchaseID,
-		Step:       event.StepUpdateProductInventory,
-		Status:     event.StatusRollbacked,
-	}, correlationID)
-
-	return svc.publishMessage(ctx, conf.RollbackProductInventoryTopic, msg, TX_MSG)
+	svc.logger.Errorf("confirmation of step %s failed for purchase %v: %s", step.Name, resp.PurchaseID, resp.Error)
+	if instance.CurrentStep != svc.definition.Steps[len(svc.definition.Steps)-1].Name || instance.Status != event.StatusSucess {
+		svc.logger.Infof("ignore failed confirmation of purchase %v at step %s with status %s", resp.PurchaseID, instance.CurrentStep, instance.Status)
+		return nil
+	}
+	update := &sagaUpdate{
+		purchaseID: resp.PurchaseID,
+		from: &model.SagaState{
+			CurrentStep: instance.CurrentStep,
+			Status:      instance.Status,
+			Deadline:    instance.Deadline,
+		},
+	}
+	if err := svc.addPurchaseResult(ctx, update, &event.PurchaseResult{
+		CustomerID: instance.CustomerID,
+		PurchaseID: resp.PurchaseID,
+		Step:       step.Name,
+		Status:     event.StatusFailed,
+		Error:      resp.Error,
+	}, correlationID); err != nil {
+		return err
+	}
+	failed := map[string]bool{
+		step.Name: true,
+	}
+	if next := svc.nextCancellationStep(failed, 0); next < len(svc.definition.CancellationSteps) {
+		if err := svc.startCancellationStep(ctx, update, next, instance.CustomerID, resp.PurchaseID, correlationID); err != nil {
+			return err
+		}
+	}
+	return svc.commit(ctx, update)
 }
 
-func (svc *OrchestratorServiceImpl) rollbackFromOrder(ctx context.Context, customerID, purchaseID uint64, correlationID string) error {
-	svc.publishPurchaseResult(ctx, &event.PurchaseResult{
-		CustomerID: customerID,
-		PurchaseID: purchaseID,
-		Step:       event.StepCreateOrder,
-		Status:     event.StatusFailed,
-	}, correlationID)
+// CancelPurchase starts the cancellation steps of a succeeded saga on request of its customer
+// A saga whose order could not be cancelled may be cancelled again, while cancelling a saga that has not succeeded,
+// such as one that is in progress or is being cancelled, does nothing
+// The cancellation is conditioned on the succeeded state that has been read, so that concurrent cancellations start it only once
+func (svc *OrchestratorServiceImpl) CancelPurchase(parentCtx context.Context, customerID, purchaseID uint64, correlationID string) error {
+	tr := otel.Tracer("cancelPurchase")
+	ctx, span := tr.Start(parentCtx, "event.CancelPurchase")
+	defer span.End()
 
-	var err error
-	svc.publishPurchaseResult(ctx, &event.PurchaseResult{
-		CustomerID: customerID,
-		PurchaseID: purchaseID,
-		Step:       event.StepCreateOrder,
-		Status:     event.StatusRollbacked,
-	}, correlationID)
-	if err = svc.rollbackOrder(ctx, customerID, purchaseID, correlationID); err != nil {
-		svc.logger.Error(err)
+	instance, err := svc.sagaRepo.GetSagaInstance(ctx, purchaseID)
+	if err != nil {
+		if errors.Is(err, repo.ErrSagaInstanceNotFound) {
+			svc.logger.Errorf("cancel unknown purchase %v", purchaseID)
+			return nil
+		}
+		return err
 	}
-
-	svc.publishPurchaseResult(ctx, &event.PurchaseResult{
-		CustomerID: customerID,
-		PurchaseID: purchaseID,
-		Step:       event.StepUpdateProductInventory,
-		Status:     event.StatusRollbacked,
-	}, correlationID)
-	if err = svc.rollbackProductInventory(ctx, customerID, purchaseID, correlationID); err != nil {
-		svc.logger.Error(err)
+	if instance.CustomerID != customerID {
+		svc.logger.Errorf("customer %v cancels purchase %v of another customer", customerID, purchaseID)
+		return nil
+	}
+	succeeded := instance.CurrentStep == svc.definition.Steps[len(svc.definition.Steps)-1].Name && instance.Status == event.StatusSucess
+	notCancelled := instance.CurrentStep == svc.definition.CancellationSteps[0].Name && instance.Status == event.StatusFailed
+	if !succeeded && !notCancelled {
+		svc.logger.Infof("ignore cancellation of purchase %v at step %s with status %s", purchaseID, instance.CurrentStep, instance.Status)
+		return nil
+	}
+	update := &sagaUpdate{
+		purchaseID: purchaseID,
+		from: &model.SagaState{
+			CurrentStep: instance.CurrentStep,
+			Status:      instance.Status,
+			Deadline:    instance.Deadline,
+		},
+	}
+	if next := svc.nextCancellationStep(failedSteps(instance), 0); next < len(svc.definition.CancellationSteps) {
+		if err := svc.startCancellationStep(ctx, update, next, customerID, purchaseID, correlationID); err != nil {
+			return err
+		}
+	}
+	err = svc.commit(ctx, update)
+	if errors.Is(err, repo.ErrSagaInstanceChanged) {
+		svc.logger.Infof("ignore cancellation of purchase %v that is being cancelled", purchaseID)
+		return nil
 	}
 	return err
 }
 
-func (svc *OrchestratorServiceImpl) rollbackFromPayment(ctx context.Context, customerID, purchaseID uint64, correlationID string) error {
-	svc.publishPurchaseResult(ctx, &event.PurchaseResult{
-		CustomerID: customerID,
-		PurchaseID: purchaseID,
-		Step:       event.StepCreatePayment,
-		Status:     event.StatusFailed,
-	}, correlationID)
+// CompensateExpiredSagas compensates sagas whose current step has not replied before its deadline
+// and executes failed cancellation steps again once their retry is due
+func (svc *OrchestratorServiceImpl) CompensateExpiredSagas(parentCtx context.Context) error {
+	tr := otel.Tracer("compensateExpiredSagas")
+	ctx, span := tr.Start(parentCtx, "event.CompensateExpiredSagas")
+	defer span.End()
 
-	var err error
-	svc.publishPurchaseResult(ctx, &event.PurchaseResult{
-		CustomerID: customerID,
-		PurchaseID: purchaseID,
-		Step:       event.StepCreatePayment,
-		Status:     event.StatusRollbacked,
-	}, correlationID)
-	if err = svc.rollbackPayment(ctx, customerID, purchaseID, correlationID); err != nil {
-		svc.logger.Error(err)
+	instances, err := svc.sagaRepo.ListExpiredSagaInstances(ctx, svc.clock.Now(), expiredSagaBatchSize)
+	if err != nil {
+		return err
 	}
-
-	svc.publishPurchaseResult(ctx, &event.PurchaseResult{
-		CustomerID: customerID,
-		PurchaseID: purchaseID,
-		Step:       event.StepCreateOrder,
-		Status:     event.StatusRollbacked,
-	}, correlationID)
-	if err = svc.rollbackOrder(ctx, customerID, purchaseID, correlationID); err != nil {
-		svc.logger.Error(err)
+	for _, instance := range *instances {
+		if _, ok := svc.definition.cancellationIndex(instance.CurrentStep); ok {
+			if err := svc.retryCancellationStep(ctx, &instance); err != nil {
+				return err
+			}
+			continue
+		}
+		i, ok := svc.definition.stepIndex(instance.CurrentStep)
+		if !ok {
+			return fmt.Errorf("unkown step: %s", instance.CurrentStep)
+		}
+		update := &sagaUpdate{
+			purchaseID: instance.PurchaseID,
+			from: &model.SagaState{
+				CurrentStep: instance.CurrentStep,
+				Status:      instance.Status,
+				Deadline:    instance.Deadline,
+			},
+		}
+		reason := fmt.Sprintf("step %s timed out", instance.CurrentStep)
+		if err := svc.failStep(ctx, update, i, event.StatusTimeout, reason, instance.CustomerID, instance.PurchaseID, instance.CorrelationID); err != nil {
+			return err
+		}
+		err := svc.commit(ctx, update)
+		if errors.Is(err, repo.ErrSagaInstanceChanged) {
+			svc.logger.Infof("step %s of purchase %v has moved on or been compensated by another orchestrator", instance.CurrentStep, instance.PurchaseID)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		svc.logger.Errorf("%s for purchase %v", reason, instance.PurchaseID)
 	}
+	return nil
+}
 
-	svc.publishPurchaseResult(ctx, &event.PurchaseResult{
-		CustomerID: customerID,
-		PurchaseID: purchaseID,
-		Step:       event.StepUpdateProductInventory,
-		Status:     event.StatusRollbacked,
-	}, correlationID)
-	if err = svc.rollbackProductInventory(ctx, customerID, purchaseID, correlationID); err != nil {
-		svc.logger.Error(err)
+// retryCancellationStep executes the failed cancellation step of an expired saga again
+func (svc *OrchestratorServiceImpl) retryCancellationStep(ctx context.Context, instance *model.SagaInstance) error {
+	i, _ := svc.definition.cancellationIndex(instance.CurrentStep)
+	update := &sagaUpdate{
+		purchaseID: instance.PurchaseID,
+		from: &model.SagaState{
+			CurrentStep: instance.CurrentStep,
+			Status:      instance.Status,
+			Deadline:    instance.Deadline,
+		},
+	}
+	svc.logger.Infof("retry step %s of purchase %v", instance.CurrentStep, instance.PurchaseID)
+	if err := svc.startCancellationStep(ctx, update, i, instance.CustomerID, instance.PurchaseID, instance.CorrelationID); err != nil {
+		return err
+	}
+	err := svc.commit(ctx, update)
+	if errors.Is(err, repo.ErrSagaInstanceChanged) {
+		svc.logger.Infof("step %s of purchase %v has been retried by another orchestrator", instance.CurrentStep, instance.PurchaseID)
+		return nil
 	}
 	return err
 }
 
-func (svc *OrchestratorServiceImpl) createOrder(ctx context.Context, purchase *model.Purchase, correlationID string) error {
-	svc.logger.Infof("create order %v", purchase.ID)
-	svc.publishPurchaseResult(ctx, &event.PurchaseResult{
+// expectReply checks the saga log to see whether the saga is still waiting for the reply of the i-th step
+// If so, the update is conditioned on the state of the saga that has been read
+// A late successful reply to a saga that has been compensated, for example after a timeout, triggers the compensation of the step again
+// since its compensation command may have overtaken the step itself
+func (svc *OrchestratorServiceImpl) expectReply(ctx context.Context, update *sagaUpdate, i int, resp *model.CreatePurchaseResponse, correlationID string) (bool, error) {
+	step := svc.definition.Steps[i]
+	instance, err := svc.sagaRepo.GetSagaInstance(ctx, resp.Purchase.ID)
+	if err != nil {
+		if errors.Is(err, repo.ErrSagaInstanceNotFound) {
+			return true, nil
+		}
+		return false, err
+	}
+	if instance.CurrentStep == step.Name && instance.Status == event.StatusExecute {
+		update.from = &model.SagaState{
+			CurrentStep: instance.CurrentStep,
+			Status:      instance.Status,
+			Deadline:    instance.Deadline,
+		}
+		return true, nil
+	}
+	switch instance.Status {
+	case event.StatusFailed, event.StatusTimeout, event.StatusRollbacked, event.StatusRollbackFailed:
+		if !resp.Success || step.CompensationTopic == "" {
+			return false, nil
+		}
+		svc.logger.Infof("compensate late reply of step %s for purchase %v", step.Name, resp.Purchase.ID)
+		return false, svc.addRollbackCmd(ctx, update, step.CompensationTopic, resp.Purchase.Order.CustomerID, resp.Purchase.ID, correlationID)
+	}
+	svc.logger.Infof("ignore duplicated reply of step %s for purchase %v", step.Name, resp.Purchase.ID)
+	return false, nil
+}
+
+// executeStep marks the previous step as succeeded and publishes the command of the i-th step
+func (svc *OrchestratorServiceImpl) executeStep(ctx context.Context, update *sagaUpdate, i int, purchase *model.Purchase, correlationID string) error {
+	step := svc.definition.Steps[i]
+	svc.logger.Infof("execute step %s of purchase %v", step.Name, purchase.ID)
+	if i > 0 {
+		if err := svc.addPurchaseResult(ctx, update, &event.PurchaseResult{
+			CustomerID: purchase.Order.CustomerID,
+			PurchaseID: purchase.ID,
+			Step:       svc.definition.Steps[i-1].Name,
+			Status:     event.StatusSucess,
+		}, correlationID); err != nil {
+			return err
+		}
+	}
+	if err := svc.addPurchaseResult(ctx, update, &event.PurchaseResult{
 		CustomerID: purchase.Order.CustomerID,
 		PurchaseID: purchase.ID,
-		Step:       event.StepUpdateProductInventory,
-		Status:     event.StatusSucess,
-	}, correlationID)
-
-	cmd := encodeDomainPurchase(purchase)
-	payload, err := json.Marshal(cmd)
-	if err != nil {
+		Step:       step.Name,
+		Status:     event.StatusExecute,
+	}, correlationID); err != nil {
 		return err
 	}
-	msg := message.NewMessage(watermill.NewUUID(), payload)
-	middleware.SetCorrelationID(correlationID, msg)
+	return svc.addMessage(ctx, update, model.OutboxTransportTx, step.CommandTopic, encodeDomainPurchase(purchase), correlationID)
+}
 
-	svc.publishPurchaseResult(ctx, &event.PurchaseResult{
+// complete marks the last step as succeeded and confirms the steps that require confirmation
+func (svc *OrchestratorServiceImpl) complete(ctx context.Context, update *sagaUpdate, purchase *model.Purchase, correlationID string) error {
+	if err := svc.addPurchaseResult(ctx, update, &event.PurchaseResult{
 		CustomerID: purchase.Order.CustomerID,
 		PurchaseID: purchase.ID,
-		Step:       event.StepCreateOrder,
-		Status:     event.StatusExecute,
-	}, correlationID)
+		Step:       svc.definition.Steps[len(svc.definition.Steps)-1].Name,
+		Status:     event.StatusSucess,
+	}, correlationID); err != nil {
+		return err
+	}
+	for _, step := range svc.definition.Steps {
+		if step.ConfirmationTopic == "" {
+			continue
+		}
+		if err := svc.addMessage(ctx, update, model.OutboxTransportTx, step.ConfirmationTopic, &saga_pb.ConfirmCmd{
+			PurchaseId: purchase.ID,
+			Timestamp:  pkg.Time2pbTimestamp(svc.clock.Now()),
+		}, correlationID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	return svc.publishMessage(ctx, conf.CreateOrderTopic, msg, TX_MSG)
+// nextCancellationStep returns the index of the first cancellation step from the i-th one that does not undo a failed step,
+// or the number of cancellation steps if there is none
+func (svc *OrchestratorServiceImpl) nextCancellationStep(failed map[string]bool, i int) int {
+	for ; i < len(svc.definition.CancellationSteps); i++ {
+		if !failed[svc.definition.CancellationSteps[i].Undoes] {
+			break
+		}
+	}
+	return i
 }
 
-func (svc *OrchestratorServiceImpl) rollbackOrder(ctx context.Context, customerID, purchaseID uint64, correlationID string) error {
-	svc.logger.Infof("rollback order %v", purchaseID)
-	cmd := &pb.RollbackCmd{
-		PurchaseId: purchaseID,
-		Timestamp:  pkg.Time2pbTimestamp(time.Now()),
+// failedSteps returns the steps of a saga whose latest status is failed, such as a step whose confirmation has failed
+func failedSteps(instance *model.SagaInstance) map[string]bool {
+	failed := make(map[string]bool)
+	if instance.Steps == nil {
+		return failed
 	}
-	payload, err := json.Marshal(cmd)
-	if err != nil {
+	for _, step := range *instance.Steps {
+		if step.Status == event.StatusFailed {
+			failed[step.Step] = true
+		}
+	}
+	return failed
+}
+
+// startCancellationStep publishes the command of the i-th cancellation step
+func (svc *OrchestratorServiceImpl) startCancellationStep(ctx context.Context, update *sagaUpdate, i int, customerID, purchaseID uint64, correlationID string) error {
+	step := svc.definition.CancellationSteps[i]
+	if err := svc.addPurchaseResult(ctx, update, &event.PurchaseResult{
+		CustomerID: customerID,
+		PurchaseID: purchaseID,
+		Step:       step.Name,
+		Status:     event.StatusExecute,
+	}, correlationID); err != nil {
 		return err
 	}
-	msg := message.NewMessage(watermill.NewUUID(), payload)
-	middleware.SetCorrelationID(correlationID, msg)
-	return svc.publishMessage(ctx, conf.RollbackOrderTopic, msg, TX_MSG)
+	return svc.addRollbackCmd(ctx, update, step.CommandTopic, customerID, purchaseID, correlationID)
 }
 
-func (svc *OrchestratorServiceImpl) createPayment(ctx context.Context, purchase *model.Purchase, correlationID string) error {
-	svc.logger.Infof("create payment %v", purchase.ID)
-	svc.publishPurchaseResult(ctx, &event.PurchaseResult{
-		CustomerID: purchase.Order.CustomerID,
-		PurchaseID: purchase.ID,
-		Step:       event.StepCreateOrder,
+// handleCancellationReply handles the reply if it belongs to the cancellation step that the saga is executing
+// Cancellation steps reuse the rollback commands of compensations, so their replies are told apart by the current step of the saga
+// The update is conditioned on the state of the saga that has been read, so that a duplicated reply is never recorded twice
+func (svc *OrchestratorServiceImpl) handleCancellationReply(ctx context.Context, update *sagaUpdate, handler string, resp *model.RollbackResponse, correlationID string) (bool, error) {
+	instance, err := svc.sagaRepo.GetSagaInstance(ctx, resp.PurchaseID)
+	if err != nil {
+		if errors.Is(err, repo.ErrSagaInstanceNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	i, ok := svc.definition.cancellationIndex(instance.CurrentStep)
+	if !ok || svc.definition.CancellationSteps[i].ReplyHandler != handler {
+		return false, nil
+	}
+	step := svc.definition.CancellationSteps[i]
+	if instance.Status != event.StatusExecute {
+		svc.logger.Infof("ignore duplicated reply of step %s for purchase %v", step.Name, resp.PurchaseID)
+		return true, nil
+	}
+	update.from = &model.SagaState{
+		CurrentStep: instance.CurrentStep,
+		Status:      instance.Status,
+		Deadline:    instance.Deadline,
+	}
+	if !resp.Success {
+		svc.logger.Error(resp.Error)
+		if step.RetryInterval > 0 {
+			svc.logger.Infof("retry step %s of purchase %v in %v", step.Name, resp.PurchaseID, step.RetryInterval)
+		}
+		return true, svc.addPurchaseResult(ctx, update, &event.PurchaseResult{
+			CustomerID: instance.CustomerID,
+			PurchaseID: resp.PurchaseID,
+			Step:       step.Name,
+			Status:     event.StatusFailed,
+			Error:      resp.Error,
+		}, correlationID)
+	}
+	if err := svc.addPurchaseResult(ctx, update, &event.PurchaseResult{
+		CustomerID: instance.CustomerID,
+		PurchaseID: resp.PurchaseID,
+		Step:       step.Name,
 		Status:     event.StatusSucess,
-	}, correlationID)
+	}, correlationID); err != nil {
+		return true, err
+	}
+	next := svc.nextCancellationStep(failedSteps(instance), i+1)
+	if next == len(svc.definition.CancellationSteps) {
+		return true, nil
+	}
+	return true, svc.startCancellationStep(ctx, update, next, instance.CustomerID, resp.PurchaseID, correlationID)
+}
 
-	cmd := encodeDomainPurchase(purchase)
-	payload, err := json.Marshal(cmd)
-	if err != nil {
+// failStep marks the i-th step as failed or timed out for the given reason and compensates it
+func (svc *OrchestratorServiceImpl) failStep(ctx context.Context, update *sagaUpdate, i int, status, reason string, customerID, purchaseID uint64, correlationID string) error {
+	if err := svc.addPurchaseResult(ctx, update, &event.PurchaseResult{
+		CustomerID: customerID,
+		PurchaseID: purchaseID,
+		Step:       svc.definition.Steps[i].Name,
+		Status:     status,
+		Error:      reason,
+	}, correlationID); err != nil {
 		return err
 	}
-	msg := message.NewMessage(watermill.NewUUID(), payload)
-	middleware.SetCorrelationID(correlationID, msg)
+	return svc.compensate(ctx, update, i, customerID, purchaseID, correlationID)
+}
 
-	svc.publishPurchaseResult(ctx, &event.PurchaseResult{
-		CustomerID: purchase.Order.CustomerID,
-		PurchaseID: purchase.ID,
-		Step:       event.StepCreatePayment,
-		Status:     event.StatusExecute,
+// compensate rollbacks the i-th step and all steps before it in reverse order
+func (svc *OrchestratorServiceImpl) compensate(ctx context.Context, update *sagaUpdate, i int, customerID, purchaseID uint64, correlationID string) error {
+	for ; i >= 0; i-- {
+		step := svc.definition.Steps[i]
+		if step.CompensationTopic == "" {
+			continue
+		}
+		svc.logger.Infof("compensate step %s of purchase %v", step.Name, purchaseID)
+		if err := svc.addPurchaseResult(ctx, update, &event.PurchaseResult{
+			CustomerID: customerID,
+			PurchaseID: purchaseID,
+			Step:       step.Name,
+			Status:     event.StatusRollbacked,
+		}, correlationID); err != nil {
+			return err
+		}
+		if err := svc.addRollbackCmd(ctx, update, step.CompensationTopic, customerID, purchaseID, correlationID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (svc *OrchestratorServiceImpl) addRollbackCmd(ctx context.Context, update *sagaUpdate, topic string, customerID, purchaseID uint64, correlationID string) error {
+	return svc.addMessage(ctx, update, model.OutboxTransportTx, topic, &pb.RollbackCmd{
+		CustomerId: customerID,
+		PurchaseId: purchaseID,
+		Timestamp:  pkg.Time2pbTimestamp(svc.clock.Now()),
 	}, correlationID)
+}
 
-	return svc.publishMessage(ctx, conf.CreatePaymentTopic, msg, TX_MSG)
+func (svc *OrchestratorServiceImpl) addRollbackResult(ctx context.Context, update *sagaUpdate, step string, rollbackResponse *model.RollbackResponse, correlationID string) error {
+	if rollbackResponse.Success {
+		return nil
+	}
+	svc.logger.Error(rollbackResponse.Error)
+	return svc.addPurchaseResult(ctx, update, &event.PurchaseResult{
+		CustomerID: rollbackResponse.CustomerID,
+		PurchaseID: rollbackResponse.PurchaseID,
+		Step:       step,
+		Status:     event.StatusRollbackFailed,
+		Error:      rollbackResponse.Error,
+	}, correlationID)
 }
 
-func (svc *OrchestratorServiceImpl) rollbackPayment(ctx context.Context, customerID, purchaseID uint64, correlationID string) error {
-	svc.logger.Infof("rollback payment %v", purchaseID)
-	cmd := &pb.RollbackCmd{
-		PurchaseId: purchaseID,
-		Timestamp:  pkg.Time2pbTimestamp(time.Now()),
+// addPurchaseResult appends the transition of a purchase result to the saga log and publishes the result
+// Executing a step arms its timeout and failing a cancellation step arms its retry, which are disarmed by any later transition
+func (svc *OrchestratorServiceImpl) addPurchaseResult(ctx context.Context, update *sagaUpdate, purchaseResult *event.PurchaseResult, correlationID string) error {
+	if purchaseResult.Timestamp.IsZero() {
+		purchaseResult.Timestamp = svc.clock.Now()
 	}
-	payload, err := json.Marshal(cmd)
-	if err != nil {
-		return err
+	update.deadline = time.Time{}
+	if i, ok := svc.definition.stepIndex(purchaseResult.Step); ok && purchaseResult.Status == event.StatusExecute {
+		if timeout := svc.definition.Steps[i].Timeout; timeout > 0 {
+			update.deadline = purchaseResult.Timestamp.Add(timeout)
+		}
 	}
-	msg := message.NewMessage(watermill.NewUUID(), payload)
-	middleware.SetCorrelationID(correlationID, msg)
-	return svc.publishMessage(ctx, conf.RollbackPaymentTopic, msg, TX_MSG)
+	if i, ok := svc.definition.cancellationIndex(purchaseResult.Step); ok && purchaseResult.Status == event.StatusFailed {
+		if retryInterval := svc.definition.CancellationSteps[i].RetryInterval; retryInterval > 0 {
+			update.deadline = purchaseResult.Timestamp.Add(retryInterval)
+		}
+	}
+	update.transitions = append(update.transitions, model.SagaTransition{
+		Step:      purchaseResult.Step,
+		Status:    purchaseResult.Status,
+		Error:     purchaseResult.Error,
+		Timestamp: purchaseResult.Timestamp,
+	})
+	return svc.addMessage(ctx, update, model.OutboxTransportResult, conf.PurchaseResultTopic, encodeDomainPurchaseResult(purchaseResult), correlationID)
 }
 
-func (svc *OrchestratorServiceImpl) publishPurchaseResult(ctx context.Context, purchaseResult *event.PurchaseResult, correlationID string) {
-	result := encodeDomainPurchaseResult(purchaseResult)
-	payload, err := json.Marshal(result)
+func (svc *OrchestratorServiceImpl) addMessage(ctx context.Context, update *sagaUpdate, transport, topic string, v interface{}, correlationID string) error {
+	payload, err := json.Marshal(v)
 	if err != nil {
-		svc.logger.Error(err)
+		return err
 	}
 	msg := message.NewMessage(watermill.NewUUID(), payload)
 	middleware.SetCorrelationID(correlationID, msg)
-	if err := svc.publishMessage(ctx, conf.PurchaseResultTopic, msg, RESULT_MSG); err != nil {
-		svc.logger.Error(err)
-	}
-}
-
-func (svc *OrchestratorServiceImpl) publishMessage(ctx context.Context, topic string, msg *message.Message, messageType MessageType) error {
 	broker.SetSpanContext(ctx, msg)
-	switch messageType {
-	case TX_MSG:
-		return svc.txPublisher.Publish(topic, msg)
-	case RESULT_MSG:
-		return svc.resultPublisher.Publish(topic, msg)
-	default:
-		return fmt.Errorf("unkown message type: %v", messageType)
-	}
+	update.messages = append(update.messages, broker.NewOutboxMessage(svc.service, transport, topic, msg))
+	return nil
 }
 
-func (svc *OrchestratorServiceImpl) publishRollbackResult(ctx context.Context, step string, rollbackResponse *model.RollbackResponse, correlationID string) {
-	if !rollbackResponse.Success {
-		svc.logger.Error(rollbackResponse.Error)
-		svc.publishPurchaseResult(ctx, &event.PurchaseResult{
-			CustomerID: rollbackResponse.CustomerID,
-			PurchaseID: rollbackResponse.PurchaseID,
-			Step:       step,
-			Status:     event.StatusRollbackFailed,
-		}, correlationID)
+// commit records the update to the saga log
+// It returns repo.ErrSagaInstanceChanged if the saga instance is no longer in the state the update is conditioned on
+func (svc *OrchestratorServiceImpl) commit(ctx context.Context, update *sagaUpdate) error {
+	if len(update.transitions) == 0 && len(update.messages) == 0 {
+		return nil
 	}
+	return svc.sagaRepo.RecordTransitions(ctx, update.purchaseID, update.from, &update.transitions, update.deadline, update.messages...)
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/minghsu0107/saga-product/domain/event"
 	"github.com/minghsu0107/saga-product/domain/model"
 )
 
@@ -11,4 +12,17 @@ import (
 type OrchestratorService interface {
 	StartTransaction(ctx context.Context, purchase *model.Purchase, correlationID string) error
 	HandleReply(ctx context.Context, msg *message.Message, correlationID string) error
+	CancelPurchase(ctx context.Context, customerID, purchaseID uint64, correlationID string) error
+	CompensateExpiredSagas(ctx context.Context) error
+}
+
+// PurchaseService interface
+type PurchaseService interface {
+	GetPurchase(ctx context.Context, customerID, purchaseID uint64) (*model.PurchaseStatus, error)
+}
+
+// PurchaseResultService interface
+type PurchaseResultService interface {
+	SubscribePurchaseResults(ctx context.Context, customerID uint64, lastEventID string) (<-chan *event.StreamedPurchaseResult, error)
+	DispatchPurchaseResults(ctx context.Context) error
 }
@@ -0,0 +1,828 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+	pb "github.com/minghsu0107/saga-pb"
+	conf "github.com/minghsu0107/saga-product/config"
+	"github.com/minghsu0107/saga-product/domain/event"
+	"github.com/minghsu0107/saga-product/domain/model"
+	"github.com/minghsu0107/saga-product/infra/broker"
+	saga_pb "github.com/minghsu0107/saga-product/pb"
+	"github.com/minghsu0107/saga-product/repo"
+	log "github.com/sirupsen/logrus"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// racingSagaRepo runs race once right after a saga instance is read, as if it happened concurrently
+type racingSagaRepo struct {
+	*repo.InMemorySagaRepository
+	race func()
+}
+
+func (r *racingSagaRepo) GetSagaInstance(ctx context.Context, purchaseID uint64) (*model.SagaInstance, error) {
+	instance, err := r.InMemorySagaRepository.GetSagaInstance(ctx, purchaseID)
+	if race := r.race; race != nil {
+		r.race = nil
+		race()
+	}
+	return instance, err
+}
+
+var (
+	config = &conf.Config{
+		App: "orchestrator",
+		SagaConfig: &conf.SagaConfig{
+			UpdateProductInventoryTimeoutSecond: 10,
+			CreateOrderTimeoutSecond:            20,
+			CreatePaymentTimeoutSecond:          30,
+			CancellationRetryIntervalSecond:     5,
+		},
+		OutboxConfig: &conf.OutboxConfig{
+			BatchSize: 10,
+		},
+		ResultStreamConfig: &conf.ResultStreamConfig{
+			SubscriberBufferSize: 2,
+			ReadBatchSize:        2,
+			ReadBlockMilli:       10,
+		},
+		Logger: &conf.Logger{
+			Writer: ioutil.Discard,
+			ContextLogger: log.NewEntry(&log.Logger{
+				Out:       ioutil.Discard,
+				Formatter: new(log.TextFormatter),
+				Level:     log.DebugLevel,
+			}),
+		},
+	}
+	clock    *fakeClock
+	pubSub   *gochannel.GoChannel
+	sagaRepo *repo.InMemorySagaRepository
+	relay    *broker.OutboxRelay
+	svc      OrchestratorService
+)
+
+func TestOrchestrator(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "orchestrator suite")
+}
+
+var _ = BeforeEach(func() {
+	clock = &fakeClock{
+		now: time.UnixMilli(1000),
+	}
+	pubSub = gochannel.NewGoChannel(gochannel.Config{
+		Persistent: true,
+	}, watermill.NopLogger{})
+	sagaRepo = repo.NewInMemorySagaRepository()
+	relay = broker.NewOrchestratorOutboxRelay(config, sagaRepo, pubSub, pubSub)
+	var err error
+	svc, err = NewOrchestratorService(config, sagaRepo, NewPurchaseSagaDefinition(config), clock)
+	if err != nil {
+		panic(err)
+	}
+})
+
+var _ = AfterEach(func() {
+	pubSub.Close()
+})
+
+func newPurchase(purchaseID uint64) *model.Purchase {
+	return &model.Purchase{
+		ID: purchaseID,
+		Order: &model.Order{
+			ID:         purchaseID,
+			CustomerID: 1,
+			PurchasedItems: &[]model.PurchasedItem{
+				{
+					ProductID: 2,
+					Amount:    3,
+				},
+			},
+		},
+		Payment: &model.Payment{
+			ID:           purchaseID,
+			CurrencyCode: "NT",
+			Amount:       100,
+		},
+	}
+}
+
+func newReply(handler string, purchase *model.Purchase, success bool) *message.Message {
+	var reason string
+	if !success {
+		reason = handler + " failed"
+	}
+	payload, err := json.Marshal(&pb.CreatePurchaseResponse{
+		PurchaseId: purchase.ID,
+		Purchase: &pb.Purchase{
+			Order: &pb.Order{
+				CustomerId: purchase.Order.CustomerID,
+			},
+			Payment: &pb.Payment{
+				CurrencyCode: purchase.Payment.CurrencyCode,
+				Amount:       purchase.Payment.Amount,
+			},
+		},
+		Success: success,
+		Error:   reason,
+	})
+	if err != nil {
+		panic(err)
+	}
+	msg := message.NewMessage(watermill.NewUUID(), payload)
+	msg.Metadata.Set(conf.HandlerHeader, handler)
+	return msg
+}
+
+func newRollbackReply(handler string, purchase *model.Purchase, success bool) *message.Message {
+	var reason string
+	if !success {
+		reason = handler + " failed"
+	}
+	payload, err := json.Marshal(&pb.RollbackResponse{
+		CustomerId: purchase.Order.CustomerID,
+		PurchaseId: purchase.ID,
+		Success:    success,
+		Error:      reason,
+	})
+	if err != nil {
+		panic(err)
+	}
+	msg := message.NewMessage(watermill.NewUUID(), payload)
+	msg.Metadata.Set(conf.HandlerHeader, handler)
+	return msg
+}
+
+// complete runs all steps of the purchase saga successfully
+func complete(purchase *model.Purchase) {
+	err := svc.StartTransaction(context.Background(), purchase, "correlation")
+	Expect(err).To(BeNil())
+	for _, handler := range []string{conf.UpdateProductInventoryHandler, conf.CreateOrderHandler, conf.CreatePaymentHandler} {
+		err = svc.HandleReply(context.Background(), newReply(handler, purchase, true), "correlation")
+		Expect(err).To(BeNil())
+	}
+}
+
+func receive(topic string, n int) []*message.Message {
+	if err := relay.Relay(context.Background()); err != nil {
+		panic(err)
+	}
+	messages, err := pubSub.Subscribe(context.Background(), topic)
+	if err != nil {
+		panic(err)
+	}
+	var received []*message.Message
+	for len(received) < n {
+		select {
+		case msg := <-messages:
+			msg.Ack()
+			received = append(received, msg)
+		case <-time.After(time.Second):
+			Fail("timed out waiting for messages on " + topic)
+		}
+	}
+	return received
+}
+
+func receiveResults(n int) []*saga_pb.PurchaseResult {
+	var results []*saga_pb.PurchaseResult
+	for _, msg := range receive(conf.PurchaseResultTopic, n) {
+		var result saga_pb.PurchaseResult
+		if err := json.Unmarshal(msg.Payload, &result); err != nil {
+			panic(err)
+		}
+		results = append(results, &result)
+	}
+	return results
+}
+
+var _ = Describe("saga timeout", func() {
+	var _ = It("should not compensate sagas before the deadline", func() {
+		purchase := newPurchase(1)
+		err := svc.StartTransaction(context.Background(), purchase, "correlation")
+		Expect(err).To(BeNil())
+
+		clock.Advance(9 * time.Second)
+		err = svc.CompensateExpiredSagas(context.Background())
+		Expect(err).To(BeNil())
+		instance, err := sagaRepo.GetSagaInstance(context.Background(), purchase.ID)
+		Expect(err).To(BeNil())
+		Expect(instance.Status).To(Equal(event.StatusExecute))
+		Expect(instance.Deadline).To(Equal(time.UnixMilli(11000)))
+	})
+	var _ = It("should compensate a stalled payment", func() {
+		purchase := newPurchase(2)
+		err := svc.StartTransaction(context.Background(), purchase, "correlation")
+		Expect(err).To(BeNil())
+		err = svc.HandleReply(context.Background(), newReply(conf.UpdateProductInventoryHandler, purchase, true), "correlation")
+		Expect(err).To(BeNil())
+		err = svc.HandleReply(context.Background(), newReply(conf.CreateOrderHandler, purchase, true), "correlation")
+		Expect(err).To(BeNil())
+
+		clock.Advance(30 * time.Second)
+		err = svc.CompensateExpiredSagas(context.Background())
+		Expect(err).To(BeNil())
+		err = svc.CompensateExpiredSagas(context.Background())
+		Expect(err).To(BeNil())
+
+		var statuses []saga_pb.PurchaseResultStatus
+		for _, result := range receiveResults(9) {
+			if result.Step == saga_pb.PurchaseResultStep_STEP_CREATE_PAYMENT {
+				statuses = append(statuses, result.Status)
+			}
+		}
+		Expect(statuses).To(ConsistOf(saga_pb.PurchaseResultStatus_STATUS_EXUCUTE, saga_pb.PurchaseResultStatus_STATUS_TIMEOUT, saga_pb.PurchaseResultStatus_STATUS_ROLLBACKED))
+
+		transitions, err := sagaRepo.GetSagaTransitions(context.Background(), purchase.ID)
+		Expect(err).To(BeNil())
+		Expect((*transitions)[5:]).To(Equal([]model.SagaTransition{
+			{
+				Step:      event.StepCreatePayment,
+				Status:    event.StatusTimeout,
+				Error:     "step CREATE_PAYMENT timed out",
+				Timestamp: time.UnixMilli(31000),
+			},
+			{
+				Step:      event.StepCreatePayment,
+				Status:    event.StatusRollbacked,
+				Timestamp: time.UnixMilli(31000),
+			},
+			{
+				Step:      event.StepCreateOrder,
+				Status:    event.StatusRollbacked,
+				Timestamp: time.UnixMilli(31000),
+			},
+			{
+				Step:      event.StepUpdateProductInventory,
+				Status:    event.StatusRollbacked,
+				Timestamp: time.UnixMilli(31000),
+			},
+		}))
+
+		Expect(len(receive(conf.RollbackPaymentTopic, 1))).To(Equal(1))
+		Expect(len(receive(conf.RollbackOrderTopic, 1))).To(Equal(1))
+		Expect(len(receive(conf.RollbackProductInventoryTopic, 1))).To(Equal(1))
+
+		instance, err := sagaRepo.GetSagaInstance(context.Background(), purchase.ID)
+		Expect(err).To(BeNil())
+		Expect(instance.Status).To(Equal(event.StatusRollbacked))
+		Expect(instance.Deadline.IsZero()).To(BeTrue())
+	})
+	var _ = It("should compensate a late reply after timeout", func() {
+		purchase := newPurchase(3)
+		err := svc.StartTransaction(context.Background(), purchase, "correlation")
+		Expect(err).To(BeNil())
+
+		clock.Advance(10 * time.Second)
+		err = svc.CompensateExpiredSagas(context.Background())
+		Expect(err).To(BeNil())
+		err = svc.HandleReply(context.Background(), newReply(conf.UpdateProductInventoryHandler, purchase, true), "correlation")
+		Expect(err).To(BeNil())
+
+		var statuses []saga_pb.PurchaseResultStatus
+		for _, result := range receiveResults(3) {
+			statuses = append(statuses, result.Status)
+		}
+		Expect(statuses).To(ConsistOf(saga_pb.PurchaseResultStatus_STATUS_EXUCUTE, saga_pb.PurchaseResultStatus_STATUS_TIMEOUT, saga_pb.PurchaseResultStatus_STATUS_ROLLBACKED))
+		Expect(len(receive(conf.RollbackProductInventoryTopic, 2))).To(Equal(2))
+
+		transitions, err := sagaRepo.GetSagaTransitions(context.Background(), purchase.ID)
+		Expect(err).To(BeNil())
+		Expect(len(*transitions)).To(Equal(3))
+		Expect((*transitions)[2].Status).To(Equal(event.StatusRollbacked))
+	})
+	var _ = It("should not move on a saga that times out while its reply is handled", func() {
+		racingRepo := &racingSagaRepo{
+			InMemorySagaRepository: sagaRepo,
+		}
+		racingSvc, err := NewOrchestratorService(config, racingRepo, NewPurchaseSagaDefinition(config), clock)
+		Expect(err).To(BeNil())
+		purchase := newPurchase(11)
+		err = racingSvc.StartTransaction(context.Background(), purchase, "correlation")
+		Expect(err).To(BeNil())
+
+		clock.Advance(10 * time.Second)
+		racingRepo.race = func() {
+			Expect(svc.CompensateExpiredSagas(context.Background())).To(BeNil())
+		}
+		err = racingSvc.HandleReply(context.Background(), newReply(conf.UpdateProductInventoryHandler, purchase, true), "correlation")
+		Expect(err).To(BeNil())
+
+		transitions, err := sagaRepo.GetSagaTransitions(context.Background(), purchase.ID)
+		Expect(err).To(BeNil())
+		var statuses []string
+		for _, transition := range *transitions {
+			statuses = append(statuses, transition.Step+":"+transition.Status)
+		}
+		Expect(statuses).To(Equal([]string{
+			event.StepUpdateProductInventory + ":" + event.StatusExecute,
+			event.StepUpdateProductInventory + ":" + event.StatusTimeout,
+			event.StepUpdateProductInventory + ":" + event.StatusRollbacked,
+		}))
+		Expect(len(receive(conf.RollbackProductInventoryTopic, 2))).To(Equal(2))
+	})
+})
+
+var _ = Describe("saga definition", func() {
+	var _ = It("should execute declared steps", func() {
+		definition := &SagaDefinition{
+			Steps: []SagaStep{
+				{
+					Name:                     event.StepCreateOrder,
+					CommandTopic:             conf.CreateOrderTopic,
+					ReplyHandler:             conf.CreateOrderHandler,
+					CompensationTopic:        conf.RollbackOrderTopic,
+					CompensationReplyHandler: conf.RollbackOrderHandler,
+				},
+				{
+					Name:         event.StepCreatePayment,
+					CommandTopic: conf.CreatePaymentTopic,
+					ReplyHandler: conf.CreatePaymentHandler,
+				},
+			},
+		}
+		svc, err := NewOrchestratorService(config, sagaRepo, definition, clock)
+		Expect(err).To(BeNil())
+
+		purchase := newPurchase(4)
+		err = svc.StartTransaction(context.Background(), purchase, "correlation")
+		Expect(err).To(BeNil())
+		err = svc.HandleReply(context.Background(), newReply(conf.UpdateProductInventoryHandler, purchase, true), "correlation")
+		Expect(err).NotTo(BeNil())
+		err = svc.HandleReply(context.Background(), newReply(conf.CreateOrderHandler, purchase, true), "correlation")
+		Expect(err).To(BeNil())
+		err = svc.HandleReply(context.Background(), newReply(conf.CreatePaymentHandler, purchase, false), "correlation")
+		Expect(err).To(BeNil())
+
+		Expect(len(receive(conf.CreateOrderTopic, 1))).To(Equal(1))
+		Expect(len(receive(conf.CreatePaymentTopic, 1))).To(Equal(1))
+		Expect(len(receive(conf.RollbackOrderTopic, 1))).To(Equal(1))
+
+		transitions, err := sagaRepo.GetSagaTransitions(context.Background(), purchase.ID)
+		Expect(err).To(BeNil())
+		var statuses []string
+		for _, transition := range *transitions {
+			statuses = append(statuses, transition.Step+":"+transition.Status)
+		}
+		Expect(statuses).To(Equal([]string{
+			event.StepCreateOrder + ":" + event.StatusExecute,
+			event.StepCreateOrder + ":" + event.StatusSucess,
+			event.StepCreatePayment + ":" + event.StatusExecute,
+			event.StepCreatePayment + ":" + event.StatusFailed,
+			event.StepCreateOrder + ":" + event.StatusRollbacked,
+		}))
+	})
+	var _ = It("should confirm steps once the saga succeeds", func() {
+		purchase := newPurchase(7)
+		err := svc.StartTransaction(context.Background(), purchase, "correlation")
+		Expect(err).To(BeNil())
+		for _, handler := range []string{conf.UpdateProductInventoryHandler, conf.CreateOrderHandler} {
+			err = svc.HandleReply(context.Background(), newReply(handler, purchase, true), "correlation")
+			Expect(err).To(BeNil())
+		}
+		err = svc.HandleReply(context.Background(), newReply(conf.CreatePaymentHandler, purchase, true), "correlation")
+		Expect(err).To(BeNil())
+
+		confirmations := receive(conf.ConfirmProductInventoryTopic, 1)
+		var cmd saga_pb.ConfirmCmd
+		Expect(json.Unmarshal(confirmations[0].Payload, &cmd)).To(BeNil())
+		Expect(cmd.PurchaseId).To(Equal(purchase.ID))
+
+		confirmations = receive(conf.ConfirmOrderTopic, 1)
+		Expect(json.Unmarshal(confirmations[0].Payload, &cmd)).To(BeNil())
+		Expect(cmd.PurchaseId).To(Equal(purchase.ID))
+	})
+	var _ = It("should cancel a succeeded purchase step by step", func() {
+		purchase := newPurchase(9)
+		complete(purchase)
+		err := svc.CancelPurchase(context.Background(), purchase.Order.CustomerID, purchase.ID, "cancellation")
+		Expect(err).To(BeNil())
+
+		for _, step := range []struct {
+			topic   string
+			handler string
+		}{
+			{conf.RollbackOrderTopic, conf.RollbackOrderHandler},
+			{conf.RollbackPaymentTopic, conf.RollbackPaymentHandler},
+			{conf.RollbackProductInventoryTopic, conf.RollbackProductInventoryHandler},
+		} {
+			cmds := receive(step.topic, 1)
+			var cmd pb.RollbackCmd
+			Expect(json.Unmarshal(cmds[0].Payload, &cmd)).To(BeNil())
+			Expect(cmd.PurchaseId).To(Equal(purchase.ID))
+			Expect(cmd.CustomerId).To(Equal(purchase.Order.CustomerID))
+			err = svc.HandleReply(context.Background(), newRollbackReply(step.handler, purchase, true), "cancellation")
+			Expect(err).To(BeNil())
+		}
+
+		transitions, err := sagaRepo.GetSagaTransitions(context.Background(), purchase.ID)
+		Expect(err).To(BeNil())
+		var statuses []string
+		for _, transition := range (*transitions)[6:] {
+			statuses = append(statuses, transition.Step+":"+transition.Status)
+		}
+		Expect(statuses).To(Equal([]string{
+			event.StepCancelOrder + ":" + event.StatusExecute,
+			event.StepCancelOrder + ":" + event.StatusSucess,
+			event.StepRefundPayment + ":" + event.StatusExecute,
+			event.StepRefundPayment + ":" + event.StatusSucess,
+			event.StepRestockProductInventory + ":" + event.StatusExecute,
+			event.StepRestockProductInventory + ":" + event.StatusSucess,
+		}))
+		err = svc.CancelPurchase(context.Background(), purchase.Order.CustomerID, purchase.ID, "cancellation")
+		Expect(err).To(BeNil())
+		transitions, err = sagaRepo.GetSagaTransitions(context.Background(), purchase.ID)
+		Expect(err).To(BeNil())
+		Expect(*transitions).To(HaveLen(12))
+	})
+	var _ = It("should cancel an oversold purchase without restocking its inventory", func() {
+		purchase := newPurchase(15)
+		complete(purchase)
+		payload, err := json.Marshal(&saga_pb.ConfirmResponse{
+			PurchaseId: purchase.ID,
+			Success:    false,
+			Error:      "insufficient inventory",
+		})
+		Expect(err).To(BeNil())
+		newConfirmReply := func() *message.Message {
+			msg := message.NewMessage(watermill.NewUUID(), payload)
+			msg.Metadata.Set(conf.HandlerHeader, conf.ConfirmProductInventoryHandler)
+			return msg
+		}
+		err = svc.HandleReply(context.Background(), newConfirmReply(), "correlation")
+		Expect(err).To(BeNil())
+		err = svc.HandleReply(context.Background(), newConfirmReply(), "correlation")
+		Expect(err).To(BeNil())
+		for _, handler := range []string{conf.RollbackOrderHandler, conf.RollbackPaymentHandler} {
+			err = svc.HandleReply(context.Background(), newRollbackReply(handler, purchase, true), "correlation")
+			Expect(err).To(BeNil())
+		}
+
+		transitions, err := sagaRepo.GetSagaTransitions(context.Background(), purchase.ID)
+		Expect(err).To(BeNil())
+		var statuses []string
+		for _, transition := range (*transitions)[6:] {
+			statuses = append(statuses, transition.Step+":"+transition.Status)
+		}
+		Expect(statuses).To(Equal([]string{
+			event.StepUpdateProductInventory + ":" + event.StatusFailed,
+			event.StepCancelOrder + ":" + event.StatusExecute,
+			event.StepCancelOrder + ":" + event.StatusSucess,
+			event.StepRefundPayment + ":" + event.StatusExecute,
+			event.StepRefundPayment + ":" + event.StatusSucess,
+		}))
+		Expect((*transitions)[6].Error).To(Equal("insufficient inventory"))
+		instance, err := sagaRepo.GetSagaInstance(context.Background(), purchase.ID)
+		Expect(err).To(BeNil())
+		Expect(instance.CurrentStep).To(Equal(event.StepRefundPayment))
+		Expect(instance.Status).To(Equal(event.StatusSucess))
+		Expect(instance.Deadline.IsZero()).To(BeTrue())
+	})
+	var _ = It("should only cancel succeeded purchases of the customer and stop at a failed step", func() {
+		purchase := newPurchase(10)
+		err := svc.StartTransaction(context.Background(), purchase, "correlation")
+		Expect(err).To(BeNil())
+		err = svc.CancelPurchase(context.Background(), purchase.Order.CustomerID, purchase.ID, "cancellation")
+		Expect(err).To(BeNil())
+		transitions, err := sagaRepo.GetSagaTransitions(context.Background(), purchase.ID)
+		Expect(err).To(BeNil())
+		Expect(*transitions).To(HaveLen(1))
+
+		for _, handler := range []string{conf.UpdateProductInventoryHandler, conf.CreateOrderHandler, conf.CreatePaymentHandler} {
+			err = svc.HandleReply(context.Background(), newReply(handler, purchase, true), "correlation")
+			Expect(err).To(BeNil())
+		}
+		err = svc.CancelPurchase(context.Background(), purchase.Order.CustomerID+1, purchase.ID, "cancellation")
+		Expect(err).To(BeNil())
+		err = svc.CancelPurchase(context.Background(), purchase.Order.CustomerID, purchase.ID, "cancellation")
+		Expect(err).To(BeNil())
+		err = svc.HandleReply(context.Background(), newRollbackReply(conf.RollbackOrderHandler, purchase, false), "cancellation")
+		Expect(err).To(BeNil())
+
+		purchaseSvc := NewPurchaseService(config, sagaRepo)
+		status, err := purchaseSvc.GetPurchase(context.Background(), purchase.Order.CustomerID, purchase.ID)
+		Expect(err).To(BeNil())
+		Expect(status.CurrentStep).To(Equal(event.StepCancelOrder))
+		Expect(status.Status).To(Equal(event.StatusFailed))
+		Expect(status.Error).To(Equal(conf.RollbackOrderHandler + " failed"))
+		Expect(*status.Transitions).To(HaveLen(8))
+
+		err = svc.CancelPurchase(context.Background(), purchase.Order.CustomerID, purchase.ID, "cancellation")
+		Expect(err).To(BeNil())
+		status, err = purchaseSvc.GetPurchase(context.Background(), purchase.Order.CustomerID, purchase.ID)
+		Expect(err).To(BeNil())
+		Expect(status.CurrentStep).To(Equal(event.StepCancelOrder))
+		Expect(status.Status).To(Equal(event.StatusExecute))
+	})
+	var _ = It("should retry refunding and restocking a purchase whose order has been cancelled", func() {
+		purchase := newPurchase(13)
+		complete(purchase)
+		err := svc.CancelPurchase(context.Background(), purchase.Order.CustomerID, purchase.ID, "cancellation")
+		Expect(err).To(BeNil())
+		err = svc.HandleReply(context.Background(), newRollbackReply(conf.RollbackOrderHandler, purchase, true), "cancellation")
+		Expect(err).To(BeNil())
+		err = svc.HandleReply(context.Background(), newRollbackReply(conf.RollbackPaymentHandler, purchase, false), "cancellation")
+		Expect(err).To(BeNil())
+
+		instance, err := sagaRepo.GetSagaInstance(context.Background(), purchase.ID)
+		Expect(err).To(BeNil())
+		Expect(instance.CurrentStep).To(Equal(event.StepRefundPayment))
+		Expect(instance.Status).To(Equal(event.StatusFailed))
+		Expect(instance.Deadline).To(Equal(time.UnixMilli(6000)))
+
+		clock.Advance(4 * time.Second)
+		Expect(svc.CompensateExpiredSagas(context.Background())).To(BeNil())
+		Expect(len(receive(conf.RollbackPaymentTopic, 1))).To(Equal(1))
+		clock.Advance(time.Second)
+		Expect(svc.CompensateExpiredSagas(context.Background())).To(BeNil())
+		Expect(svc.CompensateExpiredSagas(context.Background())).To(BeNil())
+		Expect(len(receive(conf.RollbackPaymentTopic, 2))).To(Equal(2))
+
+		err = svc.HandleReply(context.Background(), newRollbackReply(conf.RollbackPaymentHandler, purchase, true), "cancellation")
+		Expect(err).To(BeNil())
+		err = svc.HandleReply(context.Background(), newRollbackReply(conf.RollbackProductInventoryHandler, purchase, true), "cancellation")
+		Expect(err).To(BeNil())
+
+		transitions, err := sagaRepo.GetSagaTransitions(context.Background(), purchase.ID)
+		Expect(err).To(BeNil())
+		var statuses []string
+		for _, transition := range (*transitions)[6:] {
+			statuses = append(statuses, transition.Step+":"+transition.Status)
+		}
+		Expect(statuses).To(Equal([]string{
+			event.StepCancelOrder + ":" + event.StatusExecute,
+			event.StepCancelOrder + ":" + event.StatusSucess,
+			event.StepRefundPayment + ":" + event.StatusExecute,
+			event.StepRefundPayment + ":" + event.StatusFailed,
+			event.StepRefundPayment + ":" + event.StatusExecute,
+			event.StepRefundPayment + ":" + event.StatusSucess,
+			event.StepRestockProductInventory + ":" + event.StatusExecute,
+			event.StepRestockProductInventory + ":" + event.StatusSucess,
+		}))
+	})
+	var _ = It("should start cancelling a purchase only once on concurrent cancellations", func() {
+		racingRepo := &racingSagaRepo{
+			InMemorySagaRepository: sagaRepo,
+		}
+		racingSvc, err := NewOrchestratorService(config, racingRepo, NewPurchaseSagaDefinition(config), clock)
+		Expect(err).To(BeNil())
+		purchase := newPurchase(12)
+		complete(purchase)
+
+		racingRepo.race = func() {
+			Expect(svc.CancelPurchase(context.Background(), purchase.Order.CustomerID, purchase.ID, "cancellation")).To(BeNil())
+		}
+		err = racingSvc.CancelPurchase(context.Background(), purchase.Order.CustomerID, purchase.ID, "cancellation")
+		Expect(err).To(BeNil())
+
+		transitions, err := sagaRepo.GetSagaTransitions(context.Background(), purchase.ID)
+		Expect(err).To(BeNil())
+		Expect(*transitions).To(HaveLen(7))
+		Expect((*transitions)[6].Step).To(Equal(event.StepCancelOrder))
+	})
+	var _ = It("should not restart a saga on a redelivered purchase", func() {
+		purchase := newPurchase(14)
+		err := svc.StartTransaction(context.Background(), purchase, "correlation")
+		Expect(err).To(BeNil())
+		err = svc.HandleReply(context.Background(), newReply(conf.UpdateProductInventoryHandler, purchase, true), "correlation")
+		Expect(err).To(BeNil())
+
+		err = svc.StartTransaction(context.Background(), purchase, "correlation")
+		Expect(err).To(BeNil())
+
+		instance, err := sagaRepo.GetSagaInstance(context.Background(), purchase.ID)
+		Expect(err).To(BeNil())
+		Expect(instance.CurrentStep).To(Equal(event.StepCreateOrder))
+		Expect(instance.Status).To(Equal(event.StatusExecute))
+		transitions, err := sagaRepo.GetSagaTransitions(context.Background(), purchase.ID)
+		Expect(err).To(BeNil())
+		var statuses []string
+		for _, transition := range *transitions {
+			statuses = append(statuses, transition.Step+":"+transition.Status)
+		}
+		Expect(statuses).To(Equal([]string{
+			event.StepUpdateProductInventory + ":" + event.StatusExecute,
+			event.StepUpdateProductInventory + ":" + event.StatusSucess,
+			event.StepCreateOrder + ":" + event.StatusExecute,
+		}))
+	})
+	var _ = It("should carry product variants and checkout prices through the steps", func() {
+		purchase := newPurchase(8)
+		(*purchase.Order.PurchasedItems)[0].VariantID = 5
+		(*purchase.Order.PurchasedItems)[0].Price = 30
+		purchase.Order.PlacedAt = time.UnixMilli(1000).UTC()
+		err := svc.StartTransaction(context.Background(), purchase, "correlation")
+		Expect(err).To(BeNil())
+
+		cmds := receive(conf.UpdateProductInventoryTopic, 1)
+		decoded, pbPurchase, err := broker.DecodeCreatePurchaseCmd(cmds[0].Payload)
+		Expect(err).To(BeNil())
+		Expect(*decoded.Order.PurchasedItems).To(Equal(*purchase.Order.PurchasedItems))
+
+		payload, err := json.Marshal(&saga_pb.CreatePurchaseResponse{
+			PurchaseId: purchase.ID,
+			Purchase:   pbPurchase,
+			Success:    true,
+		})
+		Expect(err).To(BeNil())
+		reply := message.NewMessage(watermill.NewUUID(), payload)
+		reply.Metadata.Set(conf.HandlerHeader, conf.UpdateProductInventoryHandler)
+		err = svc.HandleReply(context.Background(), reply, "correlation")
+		Expect(err).To(BeNil())
+
+		cmds = receive(conf.CreateOrderTopic, 1)
+		decoded, _, err = broker.DecodeCreatePurchaseCmd(cmds[0].Payload)
+		Expect(err).To(BeNil())
+		Expect(*decoded.Order.PurchasedItems).To(Equal(*purchase.Order.PurchasedItems))
+		Expect(decoded.Order.PlacedAt).To(Equal(purchase.Order.PlacedAt))
+
+		var legacy pb.CreatePurchaseCmd
+		Expect(json.Unmarshal(cmds[0].Payload, &legacy)).To(BeNil())
+		Expect(legacy.Purchase.Order.PurchasedItems[0].ProductId).To(Equal(uint64(2)))
+	})
+})
+
+var _ = Describe("purchase query", func() {
+	var _ = It("should get the status of a purchase from the saga log", func() {
+		purchase := newPurchase(5)
+		err := svc.StartTransaction(context.Background(), purchase, "correlation")
+		Expect(err).To(BeNil())
+		err = svc.HandleReply(context.Background(), newReply(conf.UpdateProductInventoryHandler, purchase, true), "correlation")
+		Expect(err).To(BeNil())
+		err = svc.HandleReply(context.Background(), newReply(conf.CreateOrderHandler, purchase, false), "correlation")
+		Expect(err).To(BeNil())
+
+		purchaseSvc := NewPurchaseService(config, sagaRepo)
+		status, err := purchaseSvc.GetPurchase(context.Background(), purchase.Order.CustomerID, purchase.ID)
+		Expect(err).To(BeNil())
+		Expect(status.PurchaseID).To(Equal(purchase.ID))
+		Expect(status.CurrentStep).To(Equal(event.StepUpdateProductInventory))
+		Expect(status.Status).To(Equal(event.StatusRollbacked))
+		Expect(status.Error).To(Equal(conf.CreateOrderHandler + " failed"))
+		var statuses []string
+		for _, transition := range *status.Transitions {
+			statuses = append(statuses, transition.Step+":"+transition.Status)
+		}
+		Expect(statuses).To(Equal([]string{
+			event.StepUpdateProductInventory + ":" + event.StatusExecute,
+			event.StepUpdateProductInventory + ":" + event.StatusSucess,
+			event.StepCreateOrder + ":" + event.StatusExecute,
+			event.StepCreateOrder + ":" + event.StatusFailed,
+			event.StepCreateOrder + ":" + event.StatusRollbacked,
+			event.StepUpdateProductInventory + ":" + event.StatusRollbacked,
+		}))
+	})
+	var _ = It("should not get the purchase of another customer", func() {
+		purchase := newPurchase(6)
+		err := svc.StartTransaction(context.Background(), purchase, "correlation")
+		Expect(err).To(BeNil())
+
+		purchaseSvc := NewPurchaseService(config, sagaRepo)
+		_, err = purchaseSvc.GetPurchase(context.Background(), purchase.Order.CustomerID+1, purchase.ID)
+		Expect(err).To(Equal(ErrUnauthorized))
+		_, err = purchaseSvc.GetPurchase(context.Background(), purchase.Order.CustomerID, 100)
+		Expect(err).To(Equal(ErrPurchaseNotFound))
+	})
+})
+
+var _ = Describe("purchase result stream", func() {
+	var (
+		resultRepo *repo.InMemoryPurchaseResultRepository
+		resultSvc  PurchaseResultService
+		ctx        context.Context
+		cancel     context.CancelFunc
+	)
+	BeforeEach(func() {
+		resultRepo = repo.NewInMemoryPurchaseResultRepository(0)
+		resultSvc = NewPurchaseResultService(config, resultRepo)
+		ctx, cancel = context.WithCancel(context.Background())
+	})
+	AfterEach(func() {
+		cancel()
+	})
+
+	publishResult := func(customerID, purchaseID uint64) {
+		payload, err := json.Marshal(encodeDomainPurchaseResult(&event.PurchaseResult{
+			CustomerID: customerID,
+			PurchaseID: purchaseID,
+			Step:       event.StepCreatePayment,
+			Status:     event.StatusSucess,
+			Timestamp:  time.Now(),
+		}))
+		Expect(err).To(BeNil())
+		Expect(resultRepo.Publish(conf.PurchaseResultTopic, message.NewMessage(watermill.NewUUID(), payload))).To(BeNil())
+	}
+	receivePurchaseIDs := func(results <-chan *event.StreamedPurchaseResult, n int) []uint64 {
+		var purchaseIDs []uint64
+		for len(purchaseIDs) < n {
+			select {
+			case result, ok := <-results:
+				Expect(ok).To(BeTrue())
+				purchaseIDs = append(purchaseIDs, result.Result.PurchaseID)
+			case <-time.After(time.Second):
+				Fail("timed out waiting for purchase results")
+			}
+		}
+		return purchaseIDs
+	}
+
+	var _ = It("should only deliver the results of the subscribed customer", func() {
+		Expect(resultSvc.DispatchPurchaseResults(ctx)).To(BeNil())
+		results, err := resultSvc.SubscribePurchaseResults(ctx, 1, "")
+		Expect(err).To(BeNil())
+
+		publishResult(2, 10)
+		publishResult(1, 11)
+		Expect(resultSvc.DispatchPurchaseResults(ctx)).To(BeNil())
+		Expect(receivePurchaseIDs(results, 1)).To(Equal([]uint64{11}))
+	})
+	var _ = It("should replay results after the last event ID", func() {
+		publishResult(1, 20)
+		lastEventID, err := resultRepo.GetLastPurchaseResultID(ctx)
+		Expect(err).To(BeNil())
+		publishResult(1, 21)
+		publishResult(2, 22)
+		publishResult(1, 23)
+		Expect(resultSvc.DispatchPurchaseResults(ctx)).To(BeNil())
+
+		results, err := resultSvc.SubscribePurchaseResults(ctx, 1, lastEventID)
+		Expect(err).To(BeNil())
+		Expect(receivePurchaseIDs(results, 2)).To(Equal([]uint64{21, 23}))
+
+		publishResult(1, 24)
+		Expect(resultSvc.DispatchPurchaseResults(ctx)).To(BeNil())
+		Expect(receivePurchaseIDs(results, 1)).To(Equal([]uint64{24}))
+	})
+	var _ = It("should drop a subscriber that falls behind", func() {
+		Expect(resultSvc.DispatchPurchaseResults(ctx)).To(BeNil())
+		results, err := resultSvc.SubscribePurchaseResults(ctx, 1, "")
+		Expect(err).To(BeNil())
+
+		for purchaseID := uint64(30); purchaseID < 40; purchaseID++ {
+			publishResult(1, purchaseID)
+		}
+		for i := 0; i < 5; i++ {
+			Expect(resultSvc.DispatchPurchaseResults(ctx)).To(BeNil())
+		}
+		Eventually(func() bool {
+			for {
+				select {
+				case _, ok := <-results:
+					if !ok {
+						return true
+					}
+				default:
+					return false
+				}
+			}
+		}).Should(BeTrue())
+	})
+	var _ = It("should reject an invalid last event ID", func() {
+		_, err := resultSvc.SubscribePurchaseResults(ctx, 1, "invalid")
+		Expect(err).To(Equal(ErrInvalidLastEventID))
+	})
+	var _ = It("should not replay from a last event ID out of the replay window or trimmed", func() {
+		_, err := resultSvc.SubscribePurchaseResults(ctx, 1, "1000-0")
+		Expect(err).To(Equal(ErrLastEventIDExpired))
+
+		resultRepo = repo.NewInMemoryPurchaseResultRepository(2)
+		resultSvc = NewPurchaseResultService(config, resultRepo)
+		publishResult(1, 50)
+		lastEventID, err := resultRepo.GetLastPurchaseResultID(ctx)
+		Expect(err).To(BeNil())
+		publishResult(1, 51)
+		_, err = resultSvc.SubscribePurchaseResults(ctx, 1, lastEventID)
+		Expect(err).To(BeNil())
+		publishResult(1, 52)
+		_, err = resultSvc.SubscribePurchaseResults(ctx, 1, lastEventID)
+		Expect(err).To(Equal(ErrLastEventIDExpired))
+	})
+})
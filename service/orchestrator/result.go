@@ -0,0 +1,206 @@
+package orchestrator
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	conf "github.com/minghsu0107/saga-product/config"
+	"github.com/minghsu0107/saga-product/domain/event"
+	"github.com/minghsu0107/saga-product/repo"
+	log "github.com/sirupsen/logrus"
+)
+
+// PurchaseResultServiceImpl fans purchase results out from the result stream to the subscribers of each customer
+// Results are dispatched to a bounded buffer of each subscriber without blocking, so a slow subscriber never holds up the others;
+// once its buffer is full the subscriber is dropped, and the client resumes from its last event after reconnecting
+type PurchaseResultServiceImpl struct {
+	resultRepo  repo.PurchaseResultRepository
+	bufferSize  int
+	batchSize   int64
+	block       time.Duration
+	window      time.Duration
+	logger      *log.Entry
+	mu          sync.Mutex
+	subscribers map[uint64]map[*resultSubscriber]struct{}
+	lastID      string
+}
+
+type resultSubscriber struct {
+	results chan *event.StreamedPurchaseResult
+}
+
+// NewPurchaseResultService factory
+func NewPurchaseResultService(config *conf.Config, resultRepo repo.PurchaseResultRepository) PurchaseResultService {
+	bufferSize := config.ResultStreamConfig.SubscriberBufferSize
+	if bufferSize < 1 {
+		bufferSize = 64
+	}
+	batchSize := config.ResultStreamConfig.ReadBatchSize
+	if batchSize < 1 {
+		batchSize = 100
+	}
+	block := time.Duration(config.ResultStreamConfig.ReadBlockMilli) * time.Millisecond
+	if block <= 0 {
+		block = time.Second
+	}
+	window := time.Duration(config.ResultStreamConfig.ReplayWindowSecond) * time.Second
+	if window <= 0 {
+		window = 5 * time.Minute
+	}
+	return &PurchaseResultServiceImpl{
+		resultRepo:  resultRepo,
+		bufferSize:  bufferSize,
+		batchSize:   batchSize,
+		block:       block,
+		window:      window,
+		subscribers: make(map[uint64]map[*resultSubscriber]struct{}),
+		logger: config.Logger.ContextLogger.WithFields(log.Fields{
+			"type": "service:PurchaseResultService",
+		}),
+	}
+}
+
+// SubscribePurchaseResults subscribes to the results of a customer until ctx is done
+// If lastEventID is given, results after it are replayed from the stream before live results are delivered;
+// since the stream is shared by all customers, only a last event ID within the replay window is replayed,
+// and ErrLastEventIDExpired tells the client to query its purchases and subscribe anew if it is older or has been trimmed
+// The returned channel is closed when ctx is done or the subscriber is dropped for falling behind
+func (svc *PurchaseResultServiceImpl) SubscribePurchaseResults(ctx context.Context, customerID uint64, lastEventID string) (<-chan *event.StreamedPurchaseResult, error) {
+	if lastEventID != "" {
+		ms, _, err := repo.ParseStreamID(lastEventID)
+		if err != nil {
+			return nil, ErrInvalidLastEventID
+		}
+		if time.Since(time.UnixMilli(int64(ms))) > svc.window {
+			return nil, ErrLastEventIDExpired
+		}
+		firstID, err := svc.resultRepo.GetFirstPurchaseResultID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if repo.StreamIDAfter(firstID, lastEventID) {
+			return nil, ErrLastEventIDExpired
+		}
+	}
+	subscriber := &resultSubscriber{
+		results: make(chan *event.StreamedPurchaseResult, svc.bufferSize),
+	}
+	svc.subscribe(customerID, subscriber)
+
+	out := make(chan *event.StreamedPurchaseResult)
+	go func() {
+		defer close(out)
+		defer svc.unsubscribe(customerID, subscriber)
+
+		lastID := lastEventID
+		if lastID != "" {
+			var ok bool
+			if lastID, ok = svc.replay(ctx, customerID, lastID, out); !ok {
+				return
+			}
+		}
+		for {
+			select {
+			case result, ok := <-subscriber.results:
+				if !ok {
+					svc.logger.Infof("drop slow subscriber of customer %v", customerID)
+					return
+				}
+				if lastID != "" && !repo.StreamIDAfter(result.ID, lastID) {
+					continue
+				}
+				select {
+				case out <- result:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// DispatchPurchaseResults reads new results from the stream and dispatches them to subscribers
+// It blocks until any result arrives or the read block duration elapses, and must not be called concurrently
+func (svc *PurchaseResultServiceImpl) DispatchPurchaseResults(ctx context.Context) error {
+	if svc.lastID == "" {
+		lastID, err := svc.resultRepo.GetLastPurchaseResultID(ctx)
+		if err != nil {
+			return err
+		}
+		svc.lastID = lastID
+	}
+	results, err := svc.resultRepo.ReadPurchaseResults(ctx, svc.lastID, svc.batchSize, svc.block)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	for i := range *results {
+		result := &(*results)[i]
+		svc.lastID = result.ID
+		for subscriber := range svc.subscribers[result.Result.CustomerID] {
+			select {
+			case subscriber.results <- result:
+			default:
+				close(subscriber.results)
+				svc.removeSubscriber(result.Result.CustomerID, subscriber)
+			}
+		}
+	}
+	return nil
+}
+
+// replay sends the results of a customer after lastID to out and returns the ID of the last result read
+func (svc *PurchaseResultServiceImpl) replay(ctx context.Context, customerID uint64, lastID string, out chan<- *event.StreamedPurchaseResult) (string, bool) {
+	for {
+		results, err := svc.resultRepo.ListPurchaseResults(ctx, lastID, svc.batchSize)
+		if err != nil {
+			svc.logger.Error(err)
+			return lastID, false
+		}
+		for i := range *results {
+			result := &(*results)[i]
+			lastID = result.ID
+			if result.Result.CustomerID != customerID {
+				continue
+			}
+			select {
+			case out <- result:
+			case <-ctx.Done():
+				return lastID, false
+			}
+		}
+		if int64(len(*results)) < svc.batchSize {
+			return lastID, true
+		}
+	}
+}
+
+func (svc *PurchaseResultServiceImpl) subscribe(customerID uint64, subscriber *resultSubscriber) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	if _, ok := svc.subscribers[customerID]; !ok {
+		svc.subscribers[customerID] = make(map[*resultSubscriber]struct{})
+	}
+	svc.subscribers[customerID][subscriber] = struct{}{}
+}
+
+func (svc *PurchaseResultServiceImpl) unsubscribe(customerID uint64, subscriber *resultSubscriber) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	svc.removeSubscriber(customerID, subscriber)
+}
+
+func (svc *PurchaseResultServiceImpl) removeSubscriber(customerID uint64, subscriber *resultSubscriber) {
+	delete(svc.subscribers[customerID], subscriber)
+	if len(svc.subscribers[customerID]) == 0 {
+		delete(svc.subscribers, customerID)
+	}
+}
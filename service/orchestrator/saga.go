@@ -0,0 +1,157 @@
+package orchestrator
+
+import (
+	"time"
+
+	conf "github.com/minghsu0107/saga-product/config"
+	"github.com/minghsu0107/saga-product/domain/event"
+)
+
+// SagaStep declares a local transaction of a saga and how to compensate it
+type SagaStep struct {
+	// Name is the step reported in purchase results
+	Name string
+	// CommandTopic is the topic to which the command of the step is published
+	CommandTopic string
+	// ReplyHandler identifies the reply of the command in the ReplyTopic
+	ReplyHandler string
+	// CompensationTopic is the topic to which the compensation command is published
+	// A step without compensation topic is skipped when the saga is compensated
+	CompensationTopic string
+	// CompensationReplyHandler identifies the reply of the compensation command in the ReplyTopic
+	CompensationReplyHandler string
+	// ConfirmationTopic is the topic to which the confirmation command is published once the saga succeeds
+	// The confirmation command is only replied when it fails, so its consumer must not fail otherwise
+	ConfirmationTopic string
+	// ConfirmationReplyHandler identifies the reply of a failed confirmation command in the ReplyTopic
+	// A failed confirmation cancels the succeeded saga
+	ConfirmationReplyHandler string
+	// Timeout is how long the step may run before it is compensated; zero means no timeout
+	Timeout time.Duration
+	// RetryInterval is how long a failed cancellation step waits before it is executed again; zero means it is not retried
+	RetryInterval time.Duration
+	// Undoes is the step whose change the cancellation step undoes
+	// The cancellation step is skipped if the confirmation of that step has failed, since its change is already undone
+	Undoes string
+}
+
+// SagaDefinition is an ordered list of saga steps
+// Steps are executed in order; when a step fails, it and all steps before it are compensated in reverse order
+// CancellationSteps undo a succeeded saga on request of its customer; they are executed in order
+// A failed cancellation step is executed again after its RetryInterval until it succeeds, while one without RetryInterval stops the cancellation
+// Each of them publishes a rollback command to its CommandTopic, whose reply is identified by its ReplyHandler; they neither compensate nor time out
+type SagaDefinition struct {
+	Steps             []SagaStep
+	CancellationSteps []SagaStep
+}
+
+// NewPurchaseSagaDefinition is the definition of the purchase saga
+// A purchase is cancelled by cancelling its order first, so that a purchase whose order can no longer be cancelled is neither refunded nor restocked
+// Once its order is cancelled, the payment is refunded and the inventory is restocked however many attempts it takes
+// A purchase oversold after its inventory hold expired fails the inventory confirmation, and is cancelled without restocking its inventory
+func NewPurchaseSagaDefinition(config *conf.Config) *SagaDefinition {
+	retryInterval := time.Duration(config.SagaConfig.CancellationRetryIntervalSecond) * time.Second
+	return &SagaDefinition{
+		Steps: []SagaStep{
+			{
+				Name:                     event.StepUpdateProductInventory,
+				CommandTopic:             conf.UpdateProductInventoryTopic,
+				ReplyHandler:             conf.UpdateProductInventoryHandler,
+				CompensationTopic:        conf.RollbackProductInventoryTopic,
+				CompensationReplyHandler: conf.RollbackProductInventoryHandler,
+				ConfirmationTopic:        conf.ConfirmProductInventoryTopic,
+				ConfirmationReplyHandler: conf.ConfirmProductInventoryHandler,
+				Timeout:                  time.Duration(config.SagaConfig.UpdateProductInventoryTimeoutSecond) * time.Second,
+			},
+			{
+				Name:                     event.StepCreateOrder,
+				CommandTopic:             conf.CreateOrderTopic,
+				ReplyHandler:             conf.CreateOrderHandler,
+				CompensationTopic:        conf.RollbackOrderTopic,
+				CompensationReplyHandler: conf.RollbackOrderHandler,
+				ConfirmationTopic:        conf.ConfirmOrderTopic,
+				Timeout:                  time.Duration(config.SagaConfig.CreateOrderTimeoutSecond) * time.Second,
+			},
+			{
+				Name:                     event.StepCreatePayment,
+				CommandTopic:             conf.CreatePaymentTopic,
+				ReplyHandler:             conf.CreatePaymentHandler,
+				CompensationTopic:        conf.RollbackPaymentTopic,
+				CompensationReplyHandler: conf.RollbackPaymentHandler,
+				Timeout:                  time.Duration(config.SagaConfig.CreatePaymentTimeoutSecond) * time.Second,
+			},
+		},
+		CancellationSteps: []SagaStep{
+			{
+				Name:         event.StepCancelOrder,
+				CommandTopic: conf.RollbackOrderTopic,
+				ReplyHandler: conf.RollbackOrderHandler,
+				Undoes:       event.StepCreateOrder,
+			},
+			{
+				Name:          event.StepRefundPayment,
+				CommandTopic:  conf.RollbackPaymentTopic,
+				ReplyHandler:  conf.RollbackPaymentHandler,
+				RetryInterval: retryInterval,
+				Undoes:        event.StepCreatePayment,
+			},
+			{
+				Name:          event.StepRestockProductInventory,
+				CommandTopic:  conf.RollbackProductInventoryTopic,
+				ReplyHandler:  conf.RollbackProductInventoryHandler,
+				RetryInterval: retryInterval,
+				Undoes:        event.StepUpdateProductInventory,
+			},
+		},
+	}
+}
+
+// stepIndex returns the index of the step with the given name
+func (def *SagaDefinition) stepIndex(name string) (int, bool) {
+	for i, step := range def.Steps {
+		if step.Name == name {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// cancellationIndex returns the index of the cancellation step with the given name
+func (def *SagaDefinition) cancellationIndex(name string) (int, bool) {
+	for i, step := range def.CancellationSteps {
+		if step.Name == name {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// confirmationIndex returns the index of the step that the reply handler of a failed confirmation belongs to
+func (def *SagaDefinition) confirmationIndex(handler string) (int, bool) {
+	if handler == "" {
+		return -1, false
+	}
+	for i, step := range def.Steps {
+		if step.ConfirmationReplyHandler == handler {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// replyIndex returns the index of the step that the reply handler belongs to
+// and whether the handler is the one of its compensation
+func (def *SagaDefinition) replyIndex(handler string) (int, bool, bool) {
+	if handler == "" {
+		return -1, false, false
+	}
+	for i, step := range def.Steps {
+		switch handler {
+		case step.ReplyHandler:
+			return i, false, true
+		case step.CompensationReplyHandler:
+			return i, true, true
+		}
+	}
+	return -1, false, false
+}
@@ -8,11 +8,12 @@ import (
 	pb "github.com/minghsu0107/saga-pb"
 	"github.com/minghsu0107/saga-product/domain/event"
 	"github.com/minghsu0107/saga-product/domain/model"
+	saga_pb "github.com/minghsu0107/saga-product/pb"
 	"github.com/minghsu0107/saga-product/pkg"
 )
 
 func decodeCreatePurchaseResponse(payload message.Payload) (*model.CreatePurchaseResponse, error) {
-	var resp pb.CreatePurchaseResponse
+	var resp saga_pb.CreatePurchaseResponse
 	if err := json.Unmarshal(payload, &resp); err != nil {
 		return nil, err
 	}
@@ -22,9 +23,15 @@ func decodeCreatePurchaseResponse(payload message.Payload) (*model.CreatePurchas
 	for _, pbPurchasedItem := range pbPurchasedItems {
 		purchasedItems = append(purchasedItems, model.PurchasedItem{
 			ProductID: pbPurchasedItem.ProductId,
+			VariantID: pbPurchasedItem.VariantId,
 			Amount:    pbPurchasedItem.Amount,
+			Price:     pbPurchasedItem.Price,
 		})
 	}
+	var placedAt time.Time
+	if resp.Purchase.Order.PlacedAt != nil {
+		placedAt = resp.Purchase.Order.PlacedAt.AsTime()
+	}
 
 	return &model.CreatePurchaseResponse{
 		Purchase: &model.Purchase{
@@ -33,6 +40,7 @@ func decodeCreatePurchaseResponse(payload message.Payload) (*model.CreatePurchas
 				ID:             purchaseID,
 				CustomerID:     resp.Purchase.Order.CustomerId,
 				PurchasedItems: &purchasedItems,
+				PlacedAt:       placedAt,
 			},
 			Payment: &model.Payment{
 				ID:           purchaseID,
@@ -58,65 +66,92 @@ func decodeRollbackResponse(payload message.Payload) (*model.RollbackResponse, e
 	}, nil
 }
 
-func encodeDomainPurchase(purchase *model.Purchase) *pb.CreatePurchaseCmd {
-	var pbPurchasedItems []*pb.PurchasedItem
+func decodeConfirmResponse(payload message.Payload) (*model.ConfirmResponse, error) {
+	var resp saga_pb.ConfirmResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return nil, err
+	}
+	return &model.ConfirmResponse{
+		PurchaseID: resp.PurchaseId,
+		Success:    resp.Success,
+		Error:      resp.Error,
+	}, nil
+}
+
+// encodeDomainPurchase encodes a purchase command, whose JSON encoding is that of saga-pb with product variants added
+func encodeDomainPurchase(purchase *model.Purchase) *saga_pb.CreatePurchaseCmd {
+	var pbPurchasedItems []*saga_pb.PurchasedItem
 	for _, purchasedItem := range *purchase.Order.PurchasedItems {
-		pbPurchasedItems = append(pbPurchasedItems, &pb.PurchasedItem{
+		pbPurchasedItems = append(pbPurchasedItems, &saga_pb.PurchasedItem{
 			ProductId: purchasedItem.ProductID,
+			VariantId: purchasedItem.VariantID,
 			Amount:    purchasedItem.Amount,
+			Price:     purchasedItem.Price,
 		})
 	}
-	cmd := &pb.CreatePurchaseCmd{
+	cmd := &saga_pb.CreatePurchaseCmd{
 		PurchaseId: purchase.ID,
-		Purchase: &pb.Purchase{
-			Order: &pb.Order{
+		Purchase: &saga_pb.Purchase{
+			Order: &saga_pb.Order{
 				CustomerId:     purchase.Order.CustomerID,
 				PurchasedItems: pbPurchasedItems,
 			},
-			Payment: &pb.Payment{
+			Payment: &saga_pb.Payment{
 				CurrencyCode: purchase.Payment.CurrencyCode,
 				Amount:       purchase.Payment.Amount,
 			},
 		},
 		Timestamp: pkg.Time2pbTimestamp(time.Now()),
 	}
+	if !purchase.Order.PlacedAt.IsZero() {
+		cmd.Purchase.Order.PlacedAt = pkg.Time2pbTimestamp(purchase.Order.PlacedAt)
+	}
 	return cmd
 }
 
-func encodeDomainPurchaseResult(purchaseResult *event.PurchaseResult) *pb.PurchaseResult {
-	return &pb.PurchaseResult{
+func encodeDomainPurchaseResult(purchaseResult *event.PurchaseResult) *saga_pb.PurchaseResult {
+	return &saga_pb.PurchaseResult{
 		CustomerId: purchaseResult.CustomerID,
 		PurchaseId: purchaseResult.PurchaseID,
 		Step:       getPbPurchaseStep(purchaseResult.Step),
 		Status:     getPbPurchaseStatus(purchaseResult.Status),
-		Timestamp:  pkg.Time2pbTimestamp(time.Now()),
+		Timestamp:  pkg.Time2pbTimestamp(purchaseResult.Timestamp),
+		Error:      purchaseResult.Error,
 	}
 }
 
-func getPbPurchaseStep(step string) pb.PurchaseStep {
+func getPbPurchaseStep(step string) saga_pb.PurchaseResultStep {
 	switch step {
 	case event.StepUpdateProductInventory:
-		return pb.PurchaseStep_STEP_UPDATE_PRODUCT_INVENTORY
+		return saga_pb.PurchaseResultStep_STEP_UPDATE_PRODUCT_INVENTORY
 	case event.StepCreateOrder:
-		return pb.PurchaseStep_STEP_CREATE_ORDER
+		return saga_pb.PurchaseResultStep_STEP_CREATE_ORDER
 	case event.StepCreatePayment:
-		return pb.PurchaseStep_STEP_CREATE_PAYMENT
+		return saga_pb.PurchaseResultStep_STEP_CREATE_PAYMENT
+	case event.StepCancelOrder:
+		return saga_pb.PurchaseResultStep_STEP_CANCEL_ORDER
+	case event.StepRefundPayment:
+		return saga_pb.PurchaseResultStep_STEP_REFUND_PAYMENT
+	case event.StepRestockProductInventory:
+		return saga_pb.PurchaseResultStep_STEP_RESTOCK_PRODUCT_INVENTORY
 	}
 	return -1
 }
 
-func getPbPurchaseStatus(status string) pb.PurchaseStatus {
+func getPbPurchaseStatus(status string) saga_pb.PurchaseResultStatus {
 	switch status {
 	case event.StatusExecute:
-		return pb.PurchaseStatus_STATUS_EXUCUTE
+		return saga_pb.PurchaseResultStatus_STATUS_EXUCUTE
 	case event.StatusSucess:
-		return pb.PurchaseStatus_STATUS_SUCCESS
+		return saga_pb.PurchaseResultStatus_STATUS_SUCCESS
 	case event.StatusFailed:
-		return pb.PurchaseStatus_STATUS_FAILED
+		return saga_pb.PurchaseResultStatus_STATUS_FAILED
 	case event.StatusRollbacked:
-		return pb.PurchaseStatus_STATUS_ROLLBACKED
+		return saga_pb.PurchaseResultStatus_STATUS_ROLLBACKED
 	case event.StatusRollbackFailed:
-		return pb.PurchaseStatus_STATUS_ROLLBACK_FAIL
+		return saga_pb.PurchaseResultStatus_STATUS_ROLLBACK_FAIL
+	case event.StatusTimeout:
+		return saga_pb.PurchaseResultStatus_STATUS_TIMEOUT
 	}
 	return -1
 }
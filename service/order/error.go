@@ -9,4 +9,12 @@ var (
 	ErrUnauthorized = errors.New("unauthorized")
 	// ErrOrderNotFound is order not found error
 	ErrOrderNotFound = errors.New("order not found")
+	// ErrInvalidOrderTransition is order status change not allowed error
+	ErrInvalidOrderTransition = errors.New("invalid order status transition")
+	// ErrOrderNotCancellable is cancelling an order that is not confirmed error
+	ErrOrderNotCancellable = errors.New("order cannot be cancelled")
+	// ErrInvalidOrderQuery is invalid order query error
+	ErrInvalidOrderQuery = errors.New("invalid order query")
+	// ErrInvalidCursor is invalid pagination cursor error
+	ErrInvalidCursor = errors.New("invalid cursor")
 )
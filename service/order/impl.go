@@ -2,40 +2,58 @@ package order
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"time"
 
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
 	conf "github.com/minghsu0107/saga-product/config"
 	"github.com/minghsu0107/saga-product/domain/model"
+	"github.com/minghsu0107/saga-product/infra/broker"
+	saga_pb "github.com/minghsu0107/saga-product/pb"
+	"github.com/minghsu0107/saga-product/pkg"
 	"github.com/minghsu0107/saga-product/repo"
 	"github.com/minghsu0107/saga-product/repo/proxy"
 	log "github.com/sirupsen/logrus"
 )
 
+// maxOrderPageSize is the maximum number of orders listed at once
+const maxOrderPageSize = 100
+
 // OrderServiceImpl implementation
 type OrderServiceImpl struct {
-	orderRepo proxy.OrderRepoCache
-	logger    *log.Entry
+	service    string
+	orderRepo  proxy.OrderRepoCache
+	outboxRepo repo.OutboxRepository
+	logger     *log.Entry
 }
 
 // SagaOrderServiceImpl implementation
 type SagaOrderServiceImpl struct {
-	orderRepo proxy.OrderRepoCache
-	logger    *log.Entry
+	orderRepo            proxy.OrderRepoCache
+	processedMessageRepo repo.ProcessedMessageRepository
+	logger               *log.Entry
 }
 
 // NewOrderService factory
-func NewOrderService(config *conf.Config, orderRepo proxy.OrderRepoCache) OrderService {
+func NewOrderService(config *conf.Config, orderRepo proxy.OrderRepoCache, outboxRepo repo.OutboxRepository) OrderService {
 	return &OrderServiceImpl{
-		orderRepo: orderRepo,
+		service:    config.App,
+		orderRepo:  orderRepo,
+		outboxRepo: outboxRepo,
 		logger: config.Logger.ContextLogger.WithFields(log.Fields{
 			"type": "service:OrderService",
 		}),
 	}
 }
 
-// GetOrder method
+// GetDetailedOrder method
+// Items are shown as they were when the order was created
 func (svc *OrderServiceImpl) GetDetailedOrder(ctx context.Context, customerID, orderID uint64) (*model.DetailedOrder, error) {
-	order, err := svc.orderRepo.GetOrder(ctx, orderID)
+	order, err := svc.orderRepo.GetDetailedOrder(ctx, orderID)
 	if err != nil {
 		svc.logger.Error(err.Error())
 		if errors.Is(err, repo.ErrOrderNotFound) {
@@ -47,25 +65,96 @@ func (svc *OrderServiceImpl) GetDetailedOrder(ctx context.Context, customerID, o
 	if customerID != order.CustomerID {
 		return nil, ErrUnauthorized
 	}
+	return order, nil
+}
 
-	detailedPurchasedItems, err := svc.orderRepo.GetDetailedPurchasedItems(ctx, order.PurchasedItems)
-
+// CancelOrder asks the orchestrator to cancel the purchase of a confirmed order
+// The purchase is cancelled asynchronously: its order is cancelled, its payment refunded and its inventory restocked, each reported as a purchase result
+func (svc *OrderServiceImpl) CancelOrder(ctx context.Context, customerID, orderID uint64) error {
+	order, err := svc.GetDetailedOrder(ctx, customerID, orderID)
+	if err != nil {
+		return err
+	}
+	if order.Status != model.OrderConfirmed {
+		return ErrOrderNotCancellable
+	}
+	payload, err := json.Marshal(&saga_pb.CancelPurchaseCmd{
+		PurchaseId: orderID,
+		CustomerId: customerID,
+		Timestamp:  pkg.Time2pbTimestamp(time.Now()),
+	})
 	if err != nil {
+		return err
+	}
+	msg := message.NewMessage(watermill.NewUUID(), payload)
+	middleware.SetCorrelationID(watermill.NewUUID(), msg)
+	broker.SetSpanContext(ctx, msg)
+	if err := svc.outboxRepo.CreateOutboxMessages(ctx, broker.NewOutboxMessage(svc.service, model.OutboxTransportTx, conf.CancelPurchaseTopic, msg)); err != nil {
 		svc.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
 
+// ListOrders method
+// Orders are listed from the latest with keyset pagination on the order ID
+func (svc *OrderServiceImpl) ListOrders(ctx context.Context, query *model.OrderQuery) (*model.OrderPage, error) {
+	if query.Size < 1 || query.Size > maxOrderPageSize || (!query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To)) {
+		return nil, ErrInvalidOrderQuery
+	}
+	var beforeID uint64
+	if query.Cursor != "" {
+		var err error
+		beforeID, err = decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	summaries, err := svc.orderRepo.ListCustomerOrders(ctx, query, beforeID)
+	if err != nil {
+		svc.logger.Error(err.Error())
 		return nil, err
 	}
-	return &model.DetailedOrder{
-		ID:                     order.ID,
-		CustomerID:             order.CustomerID,
-		DetailedPurchasedItems: detailedPurchasedItems,
-	}, nil
+	page := &model.OrderPage{
+		Orders: *summaries,
+	}
+	if len(page.Orders) == query.Size {
+		page.NextCursor = encodeCursor(page.Orders[len(page.Orders)-1].ID)
+	}
+	return page, nil
+}
+
+type orderCursor struct {
+	BeforeID uint64 `json:"before_id"`
+}
+
+func encodeCursor(beforeID uint64) string {
+	payload, _ := json.Marshal(&orderCursor{
+		BeforeID: beforeID,
+	})
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+func decodeCursor(cursor string) (uint64, error) {
+	payload, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	var decoded orderCursor
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		return 0, err
+	}
+	if decoded.BeforeID == 0 {
+		return 0, ErrInvalidCursor
+	}
+	return decoded.BeforeID, nil
 }
 
 // NewSagaOrderService factory
-func NewSagaOrderService(config *conf.Config, orderRepo proxy.OrderRepoCache) SagaOrderService {
+func NewSagaOrderService(config *conf.Config, orderRepo proxy.OrderRepoCache, processedMessageRepo repo.ProcessedMessageRepository) SagaOrderService {
 	return &SagaOrderServiceImpl{
-		orderRepo: orderRepo,
+		orderRepo:            orderRepo,
+		processedMessageRepo: processedMessageRepo,
 		logger: config.Logger.ContextLogger.WithFields(log.Fields{
 			"type": "service:SagaOrderService",
 		}),
@@ -73,8 +162,9 @@ func NewSagaOrderService(config *conf.Config, orderRepo proxy.OrderRepoCache) Sa
 }
 
 // CreateOrder method
-func (svc *SagaOrderServiceImpl) CreateOrder(ctx context.Context, order *model.Order) error {
-	if err := svc.orderRepo.CreateOrder(ctx, order); err != nil {
+// The command is recorded as processed together with its reply only if the order is created
+func (svc *SagaOrderServiceImpl) CreateOrder(ctx context.Context, order *model.Order, processed *model.ProcessedMessage) error {
+	if err := svc.orderRepo.CreateOrder(ctx, order, processed); err != nil {
 		svc.logger.Error(err.Error())
 		return err
 	}
@@ -82,9 +172,41 @@ func (svc *SagaOrderServiceImpl) CreateOrder(ctx context.Context, order *model.O
 }
 
 // RollbackOrder method
-func (svc *SagaOrderServiceImpl) RollbackOrder(ctx context.Context, orderID uint64) error {
-	err := svc.orderRepo.DeleteOrder(ctx, orderID)
+// The order is kept as a cancelled order; an order that has never been created has nothing to cancel
+// The command is recorded as processed together with its reply only if the order is cancelled
+func (svc *SagaOrderServiceImpl) RollbackOrder(ctx context.Context, orderID uint64, processed *model.ProcessedMessage) error {
+	err := svc.orderRepo.UpdateOrderStatus(ctx, orderID, model.OrderCancelled, processed)
 	if err != nil {
+		if errors.Is(err, repo.ErrOrderNotFound) {
+			return svc.RecordReply(ctx, processed)
+		}
+		svc.logger.Error(err.Error())
+		if errors.Is(err, repo.ErrInvalidOrderTransition) {
+			return ErrInvalidOrderTransition
+		}
+		return err
+	}
+	return nil
+}
+
+// ConfirmOrder confirms the order of a succeeded purchase
+// Confirming an order again does nothing, while confirming a cancelled order fails
+func (svc *SagaOrderServiceImpl) ConfirmOrder(ctx context.Context, orderID uint64) error {
+	err := svc.orderRepo.UpdateOrderStatus(ctx, orderID, model.OrderConfirmed, nil)
+	if err != nil {
+		if errors.Is(err, repo.ErrInvalidOrderTransition) {
+			svc.logger.Errorf("confirm order %v that can no longer be confirmed", orderID)
+			return ErrInvalidOrderTransition
+		}
+		svc.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
+
+// RecordReply records a command that is processed without any change, such as a failed command, together with its reply
+func (svc *SagaOrderServiceImpl) RecordReply(ctx context.Context, processed *model.ProcessedMessage) error {
+	if err := svc.processedMessageRepo.RecordProcessedMessage(ctx, processed); err != nil {
 		svc.logger.Error(err.Error())
 		return err
 	}
@@ -9,10 +9,14 @@ import (
 // OrderService interface
 type OrderService interface {
 	GetDetailedOrder(ctx context.Context, customerID, orderID uint64) (*model.DetailedOrder, error)
+	ListOrders(ctx context.Context, query *model.OrderQuery) (*model.OrderPage, error)
+	CancelOrder(ctx context.Context, customerID, orderID uint64) error
 }
 
 // SagaOrderService interface
 type SagaOrderService interface {
-	CreateOrder(ctx context.Context, order *model.Order) error
-	RollbackOrder(ctx context.Context, orderID uint64) error
+	CreateOrder(ctx context.Context, order *model.Order, processed *model.ProcessedMessage) error
+	RollbackOrder(ctx context.Context, orderID uint64, processed *model.ProcessedMessage) error
+	ConfirmOrder(ctx context.Context, orderID uint64) error
+	RecordReply(ctx context.Context, processed *model.ProcessedMessage) error
 }
@@ -9,4 +9,10 @@ var (
 	ErrUnauthorized = errors.New("unauthorized")
 	// ErrPaymentNotFound is payment not found error
 	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrInvalidRefund is invalid refund error
+	ErrInvalidRefund = errors.New("invalid refund")
+	// ErrRefundExceedsBalance is refunding more than the refundable amount of a payment error
+	ErrRefundExceedsBalance = errors.New("refund exceeds refundable amount")
+	// ErrIdempotencyKeyConflict is reusing an idempotency key for a different refund error
+	ErrIdempotencyKeyConflict = errors.New("idempotency key used by another refund")
 )
@@ -19,8 +19,9 @@ type PaymentServiceImpl struct {
 
 // SagaPaymentServiceImpl implementation
 type SagaPaymentServiceImpl struct {
-	paymentRepo proxy.PaymentRepoCache
-	logger      *log.Entry
+	paymentRepo          proxy.PaymentRepoCache
+	processedMessageRepo repo.ProcessedMessageRepository
+	logger               *log.Entry
 }
 
 // NewPaymentService factory
@@ -49,10 +50,47 @@ func (svc *PaymentServiceImpl) GetPayment(ctx context.Context, customerID, payme
 	return payment, nil
 }
 
+// ListRefunds method
+func (svc *PaymentServiceImpl) ListRefunds(ctx context.Context, customerID, paymentID uint64) (*[]model.Refund, error) {
+	if _, err := svc.GetPayment(ctx, customerID, paymentID); err != nil {
+		return nil, err
+	}
+	refunds, err := svc.paymentRepo.ListRefunds(ctx, paymentID)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return nil, err
+	}
+	return refunds, nil
+}
+
+// RefundPayment method
+// A refund with a used idempotency key returns the refund issued before, and the returned boolean indicates whether the refund is issued by this call
+func (svc *PaymentServiceImpl) RefundPayment(ctx context.Context, refund *model.Refund) (bool, *model.Refund, error) {
+	if !validRefund(refund) {
+		return false, nil, ErrInvalidRefund
+	}
+	issued, created, err := svc.paymentRepo.RefundPayment(ctx, refund)
+	if err != nil {
+		if errors.Is(err, repo.ErrPaymentNotFound) {
+			return false, nil, ErrPaymentNotFound
+		}
+		if errors.Is(err, repo.ErrRefundExceedsBalance) {
+			return false, nil, ErrRefundExceedsBalance
+		}
+		if errors.Is(err, repo.ErrIdempotencyKeyConflict) {
+			return false, nil, ErrIdempotencyKeyConflict
+		}
+		svc.logger.Error(err.Error())
+		return false, nil, err
+	}
+	return issued, created, nil
+}
+
 // NewSagaPaymentService factory
-func NewSagaPaymentService(config *conf.Config, paymentRepo proxy.PaymentRepoCache) SagaPaymentService {
+func NewSagaPaymentService(config *conf.Config, paymentRepo proxy.PaymentRepoCache, processedMessageRepo repo.ProcessedMessageRepository) SagaPaymentService {
 	return &SagaPaymentServiceImpl{
-		paymentRepo: paymentRepo,
+		paymentRepo:          paymentRepo,
+		processedMessageRepo: processedMessageRepo,
 		logger: config.Logger.ContextLogger.WithFields(log.Fields{
 			"type": "service:SagaPaymentService",
 		}),
@@ -60,8 +98,9 @@ func NewSagaPaymentService(config *conf.Config, paymentRepo proxy.PaymentRepoCac
 }
 
 // CreatePayment method
-func (svc *SagaPaymentServiceImpl) CreatePayment(ctx context.Context, payment *model.Payment) error {
-	if err := svc.paymentRepo.CreatePayment(ctx, payment); err != nil {
+// The command is recorded as processed together with its reply only if the payment is created
+func (svc *SagaPaymentServiceImpl) CreatePayment(ctx context.Context, payment *model.Payment, processed *model.ProcessedMessage) error {
+	if err := svc.paymentRepo.CreatePayment(ctx, payment, processed); err != nil {
 		svc.logger.Error(err.Error())
 		return err
 	}
@@ -69,11 +108,26 @@ func (svc *SagaPaymentServiceImpl) CreatePayment(ctx context.Context, payment *m
 }
 
 // RollbackPayment method
-func (svc *SagaPaymentServiceImpl) RollbackPayment(ctx context.Context, paymentID uint64) error {
-	err := svc.paymentRepo.DeletePayment(ctx, paymentID)
+// The payment is kept and its whole refundable amount is refunded instead
+// The command is recorded as processed together with its reply only if the refund is issued
+func (svc *SagaPaymentServiceImpl) RollbackPayment(ctx context.Context, paymentID uint64, processed *model.ProcessedMessage) error {
+	err := svc.paymentRepo.RefundFullPayment(ctx, paymentID, processed)
 	if err != nil {
 		svc.logger.Error(err.Error())
 		return err
 	}
 	return nil
 }
+
+// RecordReply records a command that is processed without any change, such as a failed command, together with its reply
+func (svc *SagaPaymentServiceImpl) RecordReply(ctx context.Context, processed *model.ProcessedMessage) error {
+	if err := svc.processedMessageRepo.RecordProcessedMessage(ctx, processed); err != nil {
+		svc.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
+
+func validRefund(refund *model.Refund) bool {
+	return refund.Amount > 0 && refund.IdempotencyKey != "" && len(refund.IdempotencyKey) <= 128 && refund.Actor != ""
+}
@@ -9,10 +9,13 @@ import (
 // PaymentService interface
 type PaymentService interface {
 	GetPayment(ctx context.Context, customerID, paymentID uint64) (*model.Payment, error)
+	ListRefunds(ctx context.Context, customerID, paymentID uint64) (*[]model.Refund, error)
+	RefundPayment(ctx context.Context, refund *model.Refund) (bool, *model.Refund, error)
 }
 
 // SagaPaymentService interface
 type SagaPaymentService interface {
-	CreatePayment(ctx context.Context, payment *model.Payment) error
-	RollbackPayment(ctx context.Context, paymentID uint64) error
+	CreatePayment(ctx context.Context, payment *model.Payment, processed *model.ProcessedMessage) error
+	RollbackPayment(ctx context.Context, paymentID uint64, processed *model.ProcessedMessage) error
+	RecordReply(ctx context.Context, processed *model.ProcessedMessage) error
 }
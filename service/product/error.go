@@ -5,8 +5,40 @@ import "errors"
 var (
 	// ErrInsuffientInventory is insufficient inventory error
 	ErrInsuffientInventory = errors.New("insufficient inventory")
+	// ErrReservationReleased is released inventory reservation error
+	ErrReservationReleased = errors.New("inventory reservation released")
 	// ErrInvalidIdempotency is invalid idempotency error
 	ErrInvalidIdempotency = errors.New("invalid idempotency")
 	// ErrProductNotFound is product not found error
 	ErrProductNotFound = errors.New("product not found")
+	// ErrInvalidProduct is invalid product error
+	ErrInvalidProduct = errors.New("invalid product")
+	// ErrTooManyProducts is too many imported products error
+	ErrTooManyProducts = errors.New("too many products")
+	// ErrVariantNotFound is product variant not found error
+	ErrVariantNotFound = errors.New("product variant not found")
+	// ErrDuplicateSKU is duplicate SKU error
+	ErrDuplicateSKU = errors.New("duplicate sku")
+	// ErrInvalidVariant is invalid product variant error
+	ErrInvalidVariant = errors.New("invalid product variant")
+	// ErrPriceNotFound is price not found error
+	ErrPriceNotFound = errors.New("price not found")
+	// ErrInvalidPriceChange is invalid scheduled price change error
+	ErrInvalidPriceChange = errors.New("invalid price change")
+	// ErrPriceChangeNotFound is scheduled price change not found error
+	ErrPriceChangeNotFound = errors.New("price change not found")
+	// ErrCategoryNotFound is category not found error
+	ErrCategoryNotFound = errors.New("category not found")
+	// ErrCategoryHasChildren is deleting category with children error
+	ErrCategoryHasChildren = errors.New("category has children")
+	// ErrInvalidCategoryParent is invalid parent category error
+	ErrInvalidCategoryParent = errors.New("invalid parent category")
+	// ErrInvalidCategory is invalid category error
+	ErrInvalidCategory = errors.New("invalid category")
+	// ErrInvalidProductQuery is invalid product query error
+	ErrInvalidProductQuery = errors.New("invalid product query")
+	// ErrInvalidCursor is invalid pagination cursor error
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvalidAdjustment is invalid inventory adjustment error
+	ErrInvalidAdjustment = errors.New("invalid inventory adjustment")
 )
@@ -2,25 +2,47 @@ package product
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"time"
 
 	conf "github.com/minghsu0107/saga-product/config"
 	"github.com/minghsu0107/saga-product/domain/model"
+	"github.com/minghsu0107/saga-product/pkg"
 	"github.com/minghsu0107/saga-product/repo"
 	"github.com/minghsu0107/saga-product/repo/proxy"
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	// maxImportProducts is the maximum number of products imported at once
+	maxImportProducts = 10000
+	// exportBatchSize is the number of products read from the database at a time during an export
+	exportBatchSize = 500
+)
+
 // ProductServiceImpl implementation
 type ProductServiceImpl struct {
-	productRepo proxy.ProductRepoCache
-	logger      *log.Entry
+	productRepo          proxy.ProductRepoCache
+	categoryRepo         proxy.CategoryRepoCache
+	clock                pkg.Clock
+	priceChangeBatchSize int
+	logger               *log.Entry
 }
 
 // NewProductService is the factory of ProductService
-func NewProductService(config *conf.Config, productRepo proxy.ProductRepoCache) ProductService {
+func NewProductService(config *conf.Config, productRepo proxy.ProductRepoCache, categoryRepo proxy.CategoryRepoCache, clock pkg.Clock) ProductService {
+	priceChangeBatchSize := config.PricingConfig.ChangeBatchSize
+	if priceChangeBatchSize < 1 {
+		priceChangeBatchSize = 100
+	}
 	return &ProductServiceImpl{
-		productRepo: productRepo,
+		productRepo:          productRepo,
+		categoryRepo:         categoryRepo,
+		clock:                clock,
+		priceChangeBatchSize: priceChangeBatchSize,
 		logger: config.Logger.ContextLogger.WithFields(log.Fields{
 			"type": "service:ProductService",
 		}),
@@ -40,22 +62,40 @@ func (svc *ProductServiceImpl) CheckProducts(ctx context.Context, cartItems *[]m
 	return &productStatuses, nil
 }
 
-func (svc *ProductServiceImpl) ListProducts(ctx context.Context, offset, size int) (*[]model.ProductCatalog, error) {
-	repoProductCatalogs, err := svc.productRepo.ListProducts(ctx, offset, size)
+func (svc *ProductServiceImpl) ListProducts(ctx context.Context, query *model.ProductQuery) (*model.ProductCatalogPage, error) {
+	if !validQuery(query) {
+		return nil, ErrInvalidProductQuery
+	}
+	var afterID uint64
+	if query.Cursor != "" {
+		if !orderedByID(query) || query.Offset != 0 {
+			return nil, ErrInvalidProductQuery
+		}
+		var err error
+		afterID, err = decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	repoProductCatalogs, err := svc.productRepo.ListProducts(ctx, query, afterID)
 	if err != nil {
 		svc.logger.Error(err.Error())
 		return nil, err
 	}
-	var productCatalogs []model.ProductCatalog
+	page := &model.ProductCatalogPage{}
 	for _, repoProductCatalog := range *repoProductCatalogs {
-		productCatalogs = append(productCatalogs, model.ProductCatalog{
+		page.Catalogs = append(page.Catalogs, model.ProductCatalog{
 			ID:        repoProductCatalog.ID,
 			Name:      repoProductCatalog.Name,
 			Inventory: repoProductCatalog.Inventory,
+			Reserved:  repoProductCatalog.Reserved,
 			Price:     repoProductCatalog.Price,
 		})
 	}
-	return &productCatalogs, nil
+	if orderedByID(query) && len(page.Catalogs) == query.Size {
+		page.NextCursor = encodeCursor(page.Catalogs[len(page.Catalogs)-1].ID)
+	}
+	return page, nil
 }
 
 func (svc *ProductServiceImpl) GetProducts(ctx context.Context, productIDs []uint64) (*[]model.Product, error) {
@@ -77,6 +117,16 @@ func (svc *ProductServiceImpl) GetProducts(ctx context.Context, productIDs []uin
 			}
 			return nil, err
 		}
+		categories, err := svc.categoryRepo.GetProductCategories(ctx, productID)
+		if err != nil {
+			svc.logger.Error(err.Error())
+			return nil, err
+		}
+		variants, err := svc.productRepo.ListProductVariants(ctx, productID)
+		if err != nil {
+			svc.logger.Error(err.Error())
+			return nil, err
+		}
 		products = append(products, model.Product{
 			ID: productID,
 			Detail: &model.ProductDetail{
@@ -84,8 +134,12 @@ func (svc *ProductServiceImpl) GetProducts(ctx context.Context, productIDs []uin
 				Description: productDetail.Description,
 				BrandName:   productDetail.BrandName,
 				Price:       productDetail.Price,
+				Archived:    productDetail.Archived,
 			},
-			Inventory: inventory,
+			Inventory:  inventory.Inventory,
+			Reserved:   inventory.Reserved,
+			Categories: *categories,
+			Variants:   *variants,
 		})
 	}
 	return &products, nil
@@ -100,9 +154,320 @@ func (svc *ProductServiceImpl) CreateProduct(ctx context.Context, product *model
 	return productID, nil
 }
 
+// ImportProducts method
+// Each product is validated on its own; valid products are created together even if some products are rejected
+func (svc *ProductServiceImpl) ImportProducts(ctx context.Context, products *[]model.Product) (*model.ProductImportResult, error) {
+	if len(*products) > maxImportProducts {
+		return nil, ErrTooManyProducts
+	}
+	result := &model.ProductImportResult{
+		ProductIDs: make([]uint64, len(*products)),
+	}
+	var validProducts []model.Product
+	var validIndexes []int
+	for i, product := range *products {
+		if err := validateProduct(&product); err != nil {
+			result.Errors = append(result.Errors, model.ProductImportError{
+				Index: i,
+				Err:   err,
+			})
+			continue
+		}
+		validProducts = append(validProducts, product)
+		validIndexes = append(validIndexes, i)
+	}
+	if len(validProducts) == 0 {
+		return result, nil
+	}
+	productIDs, err := svc.productRepo.CreateProducts(ctx, &validProducts)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return nil, err
+	}
+	for i, productID := range productIDs {
+		result.ProductIDs[validIndexes[i]] = productID
+	}
+	return result, nil
+}
+
+// ExportProducts method
+// Products are passed to fn in batches in product ID order
+func (svc *ProductServiceImpl) ExportProducts(ctx context.Context, fn func(products *[]model.Product) error) error {
+	if err := svc.productRepo.ExportProducts(ctx, exportBatchSize, fn); err != nil {
+		svc.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
+
+func (svc *ProductServiceImpl) UpdateProduct(ctx context.Context, productID uint64, update *model.ProductUpdate) error {
+	if err := svc.productRepo.UpdateProduct(ctx, productID, update); err != nil {
+		if errors.Is(err, repo.ErrProductNotFound) {
+			return ErrProductNotFound
+		}
+		svc.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
+
+func (svc *ProductServiceImpl) DeleteProduct(ctx context.Context, productID uint64) error {
+	if err := svc.productRepo.DeleteProduct(ctx, productID); err != nil {
+		if errors.Is(err, repo.ErrProductNotFound) {
+			return ErrProductNotFound
+		}
+		svc.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
+
+// CreateVariant method
+// A variant is created with its initial inventory, which is changed by inventory adjustments afterwards
+func (svc *ProductServiceImpl) CreateVariant(ctx context.Context, variant *model.ProductVariant) (uint64, error) {
+	if !validSKU(variant.SKU) || variant.Name == "" || variant.Price < 0 || variant.Inventory < 0 {
+		return 0, ErrInvalidVariant
+	}
+	variantID, err := svc.productRepo.CreateProductVariant(ctx, variant)
+	if err != nil {
+		return 0, svc.mapVariantError(err)
+	}
+	return variantID, nil
+}
+
+func (svc *ProductServiceImpl) ListVariants(ctx context.Context, productID uint64) (*[]model.ProductVariant, error) {
+	variants, err := svc.productRepo.ListProductVariants(ctx, productID)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return nil, err
+	}
+	return variants, nil
+}
+
+func (svc *ProductServiceImpl) UpdateVariant(ctx context.Context, productID, variantID uint64, update *model.ProductVariantUpdate) error {
+	if (update.SKU != nil && !validSKU(*update.SKU)) || (update.Name != nil && *update.Name == "") || (update.Price != nil && *update.Price < 0) {
+		return ErrInvalidVariant
+	}
+	if err := svc.productRepo.UpdateProductVariant(ctx, productID, variantID, update); err != nil {
+		return svc.mapVariantError(err)
+	}
+	return nil
+}
+
+func (svc *ProductServiceImpl) mapVariantError(err error) error {
+	switch {
+	case errors.Is(err, repo.ErrProductNotFound):
+		return ErrProductNotFound
+	case errors.Is(err, repo.ErrVariantNotFound):
+		return ErrVariantNotFound
+	case errors.Is(err, repo.ErrDuplicateSKU):
+		return ErrDuplicateSKU
+	}
+	svc.logger.Error(err.Error())
+	return err
+}
+
+// AdjustInventory method
+// Restocks must add inventory, shrinkages must remove inventory and corrections may do either
+func (svc *ProductServiceImpl) AdjustInventory(ctx context.Context, adjustment *model.InventoryAdjustment) (*model.InventoryLedgerEntry, error) {
+	if !validAdjustment(adjustment) {
+		return nil, ErrInvalidAdjustment
+	}
+	entry, err := svc.productRepo.AdjustProductInventory(ctx, adjustment)
+	if err != nil {
+		if errors.Is(err, repo.ErrProductNotFound) {
+			return nil, ErrProductNotFound
+		}
+		if errors.Is(err, repo.ErrVariantNotFound) {
+			return nil, ErrVariantNotFound
+		}
+		if errors.Is(err, repo.ErrInsuffientInventory) {
+			return nil, ErrInsuffientInventory
+		}
+		svc.logger.Error(err.Error())
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (svc *ProductServiceImpl) ListInventoryLedger(ctx context.Context, productID uint64, offset, size int) (*[]model.InventoryLedgerEntry, error) {
+	ledger, err := svc.productRepo.ListInventoryLedger(ctx, productID, offset, size)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return nil, err
+	}
+	return ledger, nil
+}
+
+// GetPricesAt method
+// It returns the prices of the items that were in effect at the given time
+// Items without a recorded price at that time, such as products created afterwards, are left out
+func (svc *ProductServiceImpl) GetPricesAt(ctx context.Context, at time.Time, items *[]model.PurchasedItem) (*[]model.ProductPrice, error) {
+	prices := []model.ProductPrice{}
+	for _, item := range *items {
+		price, err := svc.productRepo.GetPriceAt(ctx, item.ProductID, item.VariantID, at)
+		if err != nil {
+			if errors.Is(err, repo.ErrPriceNotFound) {
+				continue
+			}
+			svc.logger.Error(err.Error())
+			return nil, err
+		}
+		prices = append(prices, *price)
+	}
+	return &prices, nil
+}
+
+func (svc *ProductServiceImpl) ListPriceHistory(ctx context.Context, productID uint64, offset, size int) (*[]model.ProductPrice, error) {
+	prices, err := svc.productRepo.ListPriceHistory(ctx, productID, offset, size)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return nil, err
+	}
+	return prices, nil
+}
+
+// SchedulePriceChange method
+// A price change must take effect in the future; it is applied by ApplyDuePriceChanges once due
+func (svc *ProductServiceImpl) SchedulePriceChange(ctx context.Context, change *model.PriceChange) (uint64, error) {
+	if change.Price < 0 || !change.EffectiveAt.After(svc.clock.Now()) {
+		return 0, ErrInvalidPriceChange
+	}
+	changeID, err := svc.productRepo.SchedulePriceChange(ctx, change)
+	if err != nil {
+		return 0, svc.mapVariantError(err)
+	}
+	return changeID, nil
+}
+
+func (svc *ProductServiceImpl) ListPriceChanges(ctx context.Context, productID uint64) (*[]model.PriceChange, error) {
+	changes, err := svc.productRepo.ListPriceChanges(ctx, productID)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (svc *ProductServiceImpl) CancelPriceChange(ctx context.Context, productID, changeID uint64) error {
+	if err := svc.productRepo.CancelPriceChange(ctx, productID, changeID); err != nil {
+		if errors.Is(err, repo.ErrPriceChangeNotFound) {
+			return ErrPriceChangeNotFound
+		}
+		svc.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
+
+// ApplyDuePriceChanges applies the scheduled price changes that are due, in the order they take effect
+func (svc *ProductServiceImpl) ApplyDuePriceChanges(ctx context.Context) error {
+	now := svc.clock.Now()
+	changeIDs, err := svc.productRepo.ListDuePriceChanges(ctx, now, svc.priceChangeBatchSize)
+	if err != nil {
+		return err
+	}
+	for _, changeID := range changeIDs {
+		change, err := svc.productRepo.ApplyPriceChange(ctx, changeID, now)
+		if err != nil {
+			return err
+		}
+		if change != nil {
+			svc.logger.Infof("apply price change %v of product %v variant %v", change.ID, change.ProductID, change.VariantID)
+		}
+	}
+	return nil
+}
+
+func validQuery(query *model.ProductQuery) bool {
+	if query.Offset < 0 || query.Size < 1 || query.MinPrice < 0 || query.MaxPrice < 0 {
+		return false
+	}
+	if query.MaxPrice > 0 && query.MinPrice > query.MaxPrice {
+		return false
+	}
+	switch query.SortBy {
+	case model.SortByRelevance, model.SortByPrice, model.SortByName, model.SortByCreatedAt:
+		return true
+	}
+	return false
+}
+
+// orderedByID tells whether products are listed in product ID order, which keyset pagination relies on
+func orderedByID(query *model.ProductQuery) bool {
+	return query.SortBy == model.SortByRelevance && query.Keyword == ""
+}
+
+type productCursor struct {
+	AfterID uint64 `json:"after_id"`
+}
+
+func encodeCursor(afterID uint64) string {
+	payload, _ := json.Marshal(&productCursor{
+		AfterID: afterID,
+	})
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+func decodeCursor(cursor string) (uint64, error) {
+	payload, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	var decoded productCursor
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		return 0, err
+	}
+	if decoded.AfterID == 0 {
+		return 0, ErrInvalidCursor
+	}
+	return decoded.AfterID, nil
+}
+
+// validateProduct checks a product against the constraints of its columns
+func validateProduct(product *model.Product) error {
+	detail := product.Detail
+	switch {
+	case detail == nil:
+		return ErrInvalidProduct
+	case detail.Name == "" || len(detail.Name) > 256:
+		return fmt.Errorf("%w: name must have 1 to 256 characters", ErrInvalidProduct)
+	case detail.Description == "":
+		return fmt.Errorf("%w: description is required", ErrInvalidProduct)
+	case detail.BrandName == "" || len(detail.BrandName) > 256:
+		return fmt.Errorf("%w: brand name must have 1 to 256 characters", ErrInvalidProduct)
+	case detail.Price <= 0:
+		return fmt.Errorf("%w: price must be positive", ErrInvalidProduct)
+	case product.Inventory < 0:
+		return fmt.Errorf("%w: inventory must not be negative", ErrInvalidProduct)
+	}
+	return nil
+}
+
+// validSKU tells whether the SKU fits its column
+func validSKU(sku string) bool {
+	return sku != "" && len(sku) <= 64
+}
+
+func validAdjustment(adjustment *model.InventoryAdjustment) bool {
+	if adjustment.Actor == "" {
+		return false
+	}
+	switch adjustment.Reason {
+	case model.InventoryRestock:
+		return adjustment.Delta > 0
+	case model.InventoryShrinkage:
+		return adjustment.Delta < 0
+	case model.InventoryCorrection:
+		return adjustment.Delta != 0
+	}
+	return false
+}
+
 func mapProductStatus(status *repo.ProductStatus) *model.ProductStatus {
 	productStatus := &model.ProductStatus{
 		ProductID: status.ProductID,
+		VariantID: status.VariantID,
 		Price:     status.Price,
 	}
 	if status.Exist {
@@ -113,16 +478,117 @@ func mapProductStatus(status *repo.ProductStatus) *model.ProductStatus {
 	return productStatus
 }
 
+// CategoryServiceImpl implementation
+type CategoryServiceImpl struct {
+	categoryRepo proxy.CategoryRepoCache
+	logger       *log.Entry
+}
+
+// NewCategoryService is the factory of CategoryService
+func NewCategoryService(config *conf.Config, categoryRepo proxy.CategoryRepoCache) CategoryService {
+	return &CategoryServiceImpl{
+		categoryRepo: categoryRepo,
+		logger: config.Logger.ContextLogger.WithFields(log.Fields{
+			"type": "service:CategoryService",
+		}),
+	}
+}
+
+func (svc *CategoryServiceImpl) CreateCategory(ctx context.Context, category *model.Category) (uint64, error) {
+	if category.Name == "" {
+		return 0, ErrInvalidCategory
+	}
+	categoryID, err := svc.categoryRepo.CreateCategory(ctx, category)
+	if err != nil {
+		return 0, svc.mapError(err)
+	}
+	return categoryID, nil
+}
+
+func (svc *CategoryServiceImpl) GetCategory(ctx context.Context, categoryID uint64) (*model.Category, error) {
+	category, err := svc.categoryRepo.GetCategory(ctx, categoryID)
+	if err != nil {
+		return nil, svc.mapError(err)
+	}
+	return category, nil
+}
+
+func (svc *CategoryServiceImpl) ListCategories(ctx context.Context) (*[]model.Category, error) {
+	categories, err := svc.categoryRepo.ListCategories(ctx)
+	if err != nil {
+		return nil, svc.mapError(err)
+	}
+	return categories, nil
+}
+
+func (svc *CategoryServiceImpl) UpdateCategory(ctx context.Context, categoryID uint64, update *model.CategoryUpdate) error {
+	if update.Name != nil && *update.Name == "" {
+		return ErrInvalidCategory
+	}
+	if update.ParentID != nil && *update.ParentID == categoryID {
+		return ErrInvalidCategoryParent
+	}
+	if err := svc.categoryRepo.UpdateCategory(ctx, categoryID, update); err != nil {
+		return svc.mapError(err)
+	}
+	return nil
+}
+
+func (svc *CategoryServiceImpl) DeleteCategory(ctx context.Context, categoryID uint64) error {
+	if err := svc.categoryRepo.DeleteCategory(ctx, categoryID); err != nil {
+		return svc.mapError(err)
+	}
+	return nil
+}
+
+func (svc *CategoryServiceImpl) SetProductCategories(ctx context.Context, productID uint64, categoryIDs []uint64) error {
+	if err := svc.categoryRepo.SetProductCategories(ctx, productID, categoryIDs); err != nil {
+		return svc.mapError(err)
+	}
+	return nil
+}
+
+func (svc *CategoryServiceImpl) mapError(err error) error {
+	switch {
+	case errors.Is(err, repo.ErrCategoryNotFound):
+		return ErrCategoryNotFound
+	case errors.Is(err, repo.ErrCategoryHasChildren):
+		return ErrCategoryHasChildren
+	case errors.Is(err, repo.ErrInvalidCategoryParent):
+		return ErrInvalidCategoryParent
+	case errors.Is(err, repo.ErrProductNotFound):
+		return ErrProductNotFound
+	}
+	svc.logger.Error(err.Error())
+	return err
+}
+
 // SagaProductServiceImpl implementation
 type SagaProductServiceImpl struct {
-	productRepo proxy.ProductRepoCache
-	logger      *log.Entry
+	productRepo          proxy.ProductRepoCache
+	processedMessageRepo repo.ProcessedMessageRepository
+	clock                pkg.Clock
+	holdTTL              time.Duration
+	expiryBatchSize      int
+	logger               *log.Entry
 }
 
 // NewSagaProductService is the factory of ProductService
-func NewSagaProductService(config *conf.Config, productRepo proxy.ProductRepoCache) SagaProductService {
+func NewSagaProductService(config *conf.Config, productRepo proxy.ProductRepoCache, processedMessageRepo repo.ProcessedMessageRepository, clock pkg.Clock) SagaProductService {
+	holdTTL := time.Duration(config.ReservationConfig.HoldTTLSecond) * time.Second
+	if holdTTL <= 0 {
+		holdTTL = 5 * time.Minute
+	}
+	expiryBatchSize := config.ReservationConfig.ExpiryBatchSize
+	if expiryBatchSize < 1 {
+		expiryBatchSize = 100
+	}
 	return &SagaProductServiceImpl{
-		productRepo: productRepo,
+		productRepo:          productRepo,
+		processedMessageRepo: processedMessageRepo,
+		clock:                clock,
+		holdTTL:              holdTTL,
+		expiryBatchSize:      expiryBatchSize,
 		logger: config.Logger.ContextLogger.WithFields(log.Fields{
 			"type": "service:CustomerService",
 		}),
@@ -130,8 +596,10 @@ func NewSagaProductService(config *conf.Config, productRepo proxy.ProductRepoCac
 }
 
 // UpdateProductInventory method
-func (svc *SagaProductServiceImpl) UpdateProductInventory(ctx context.Context, idempotencyKey uint64, purchasedItems *[]model.PurchasedItem) error {
-	err := svc.productRepo.UpdateProductInventory(ctx, idempotencyKey, purchasedItems)
+// The purchased inventory is held until it is confirmed, rollbacked or expired after the hold TTL
+// The command is recorded as processed together with its reply only if the inventory is updated
+func (svc *SagaProductServiceImpl) UpdateProductInventory(ctx context.Context, idempotencyKey uint64, purchasedItems *[]model.PurchasedItem, processed *model.ProcessedMessage) error {
+	err := svc.productRepo.UpdateProductInventory(ctx, idempotencyKey, purchasedItems, svc.clock.Now().Add(svc.holdTTL), processed)
 	if err != nil {
 		if err == repo.ErrInsuffientInventory {
 			return ErrInsuffientInventory
@@ -146,9 +614,62 @@ func (svc *SagaProductServiceImpl) UpdateProductInventory(ctx context.Context, i
 }
 
 // RollbackProductInventory method
-func (svc *SagaProductServiceImpl) RollbackProductInventory(ctx context.Context, idempotencyKey uint64) error {
-	err := svc.productRepo.RollbackProductInventory(ctx, idempotencyKey)
+// The command is recorded as processed together with its reply only if the inventory is rollbacked
+func (svc *SagaProductServiceImpl) RollbackProductInventory(ctx context.Context, idempotencyKey uint64, processed *model.ProcessedMessage) error {
+	err := svc.productRepo.RollbackProductInventory(ctx, idempotencyKey, processed)
+	if err != nil {
+		svc.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
+
+// ConfirmProductInventory confirms the held inventory of a succeeded purchase
+// Confirming a hold again does nothing, and confirming a hold rollbacked by the saga fails
+// A hold that expired before the purchase succeeded is reserved again, or fails with ErrInsuffientInventory if the purchase is oversold,
+// in which case the failed command is recorded together with its reply in processed, so that the orchestrator cancels the purchase
+func (svc *SagaProductServiceImpl) ConfirmProductInventory(ctx context.Context, idempotencyKey uint64, processed *model.ProcessedMessage) error {
+	reserved, err := svc.productRepo.ConfirmProductInventory(ctx, idempotencyKey, processed)
 	if err != nil {
+		switch err {
+		case repo.ErrReservationReleased:
+			svc.logger.Errorf("confirm rollbacked inventory reservation of purchase %v", idempotencyKey)
+			return ErrReservationReleased
+		case repo.ErrInsuffientInventory:
+			svc.logger.Errorf("purchase %v is oversold since its inventory reservation expired before it succeeded", idempotencyKey)
+			return ErrInsuffientInventory
+		}
+		svc.logger.Error(err.Error())
+		return err
+	}
+	if reserved {
+		svc.logger.Errorf("reserve inventory of purchase %v again since its reservation expired before it succeeded", idempotencyKey)
+	}
+	return nil
+}
+
+// ReleaseExpiredReservations releases the holds that are neither confirmed nor rollbacked before they expire
+func (svc *SagaProductServiceImpl) ReleaseExpiredReservations(ctx context.Context) error {
+	now := svc.clock.Now()
+	idempotencyKeys, err := svc.productRepo.ListExpiredReservations(ctx, now, svc.expiryBatchSize)
+	if err != nil {
+		return err
+	}
+	for _, idempotencyKey := range idempotencyKeys {
+		released, err := svc.productRepo.ReleaseExpiredReservation(ctx, idempotencyKey, now)
+		if err != nil {
+			return err
+		}
+		if released {
+			svc.logger.Infof("release expired inventory reservation of purchase %v", idempotencyKey)
+		}
+	}
+	return nil
+}
+
+// RecordReply records a command that is processed without any change, such as a failed command, together with its reply
+func (svc *SagaProductServiceImpl) RecordReply(ctx context.Context, processed *model.ProcessedMessage) error {
+	if err := svc.processedMessageRepo.RecordProcessedMessage(ctx, processed); err != nil {
 		svc.logger.Error(err.Error())
 		return err
 	}
@@ -2,6 +2,7 @@ package product
 
 import (
 	"context"
+	"time"
 
 	"github.com/minghsu0107/saga-product/domain/model"
 )
@@ -9,13 +10,41 @@ import (
 // ProductService interface
 type ProductService interface {
 	CheckProducts(ctx context.Context, cartItems *[]model.CartItem) (*[]model.ProductStatus, error)
-	ListProducts(ctx context.Context, offset, size int) (*[]model.ProductCatalog, error)
+	ListProducts(ctx context.Context, query *model.ProductQuery) (*model.ProductCatalogPage, error)
 	GetProducts(ctx context.Context, productIDs []uint64) (*[]model.Product, error)
 	CreateProduct(ctx context.Context, product *model.Product) (uint64, error)
+	ImportProducts(ctx context.Context, products *[]model.Product) (*model.ProductImportResult, error)
+	ExportProducts(ctx context.Context, fn func(products *[]model.Product) error) error
+	UpdateProduct(ctx context.Context, productID uint64, update *model.ProductUpdate) error
+	DeleteProduct(ctx context.Context, productID uint64) error
+	CreateVariant(ctx context.Context, variant *model.ProductVariant) (uint64, error)
+	ListVariants(ctx context.Context, productID uint64) (*[]model.ProductVariant, error)
+	UpdateVariant(ctx context.Context, productID, variantID uint64, update *model.ProductVariantUpdate) error
+	AdjustInventory(ctx context.Context, adjustment *model.InventoryAdjustment) (*model.InventoryLedgerEntry, error)
+	ListInventoryLedger(ctx context.Context, productID uint64, offset, size int) (*[]model.InventoryLedgerEntry, error)
+	GetPricesAt(ctx context.Context, at time.Time, items *[]model.PurchasedItem) (*[]model.ProductPrice, error)
+	ListPriceHistory(ctx context.Context, productID uint64, offset, size int) (*[]model.ProductPrice, error)
+	SchedulePriceChange(ctx context.Context, change *model.PriceChange) (uint64, error)
+	ListPriceChanges(ctx context.Context, productID uint64) (*[]model.PriceChange, error)
+	CancelPriceChange(ctx context.Context, productID, changeID uint64) error
+	ApplyDuePriceChanges(ctx context.Context) error
+}
+
+// CategoryService interface
+type CategoryService interface {
+	CreateCategory(ctx context.Context, category *model.Category) (uint64, error)
+	GetCategory(ctx context.Context, categoryID uint64) (*model.Category, error)
+	ListCategories(ctx context.Context) (*[]model.Category, error)
+	UpdateCategory(ctx context.Context, categoryID uint64, update *model.CategoryUpdate) error
+	DeleteCategory(ctx context.Context, categoryID uint64) error
+	SetProductCategories(ctx context.Context, productID uint64, categoryIDs []uint64) error
 }
 
 // SagaProductService interface
 type SagaProductService interface {
-	UpdateProductInventory(ctx context.Context, idempotencyKey uint64, purchasedItems *[]model.PurchasedItem) error
-	RollbackProductInventory(ctx context.Context, idempotencyKey uint64) error
+	UpdateProductInventory(ctx context.Context, idempotencyKey uint64, purchasedItems *[]model.PurchasedItem, processed *model.ProcessedMessage) error
+	RollbackProductInventory(ctx context.Context, idempotencyKey uint64, processed *model.ProcessedMessage) error
+	ConfirmProductInventory(ctx context.Context, idempotencyKey uint64, processed *model.ProcessedMessage) error
+	ReleaseExpiredReservations(ctx context.Context) error
+	RecordReply(ctx context.Context, processed *model.ProcessedMessage) error
 }
@@ -0,0 +1,340 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	conf "github.com/minghsu0107/saga-product/config"
+	"github.com/minghsu0107/saga-product/domain/model"
+	"github.com/minghsu0107/saga-product/repo"
+	"github.com/minghsu0107/saga-product/repo/proxy"
+	log "github.com/sirupsen/logrus"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// catalogRepoCache serves product catalogs from the in-memory catalog repository
+type catalogRepoCache struct {
+	proxy.ProductRepoCache
+	catalogRepo   *repo.InMemoryProductCatalogRepository
+	lastProductID uint64
+	// prices are the recorded prices, the latest first
+	prices []model.ProductPrice
+}
+
+func (c *catalogRepoCache) ListProducts(ctx context.Context, query *model.ProductQuery, afterID uint64) (*[]repo.ProductCatalog, error) {
+	return c.catalogRepo.ListProducts(ctx, query, afterID)
+}
+
+func (c *catalogRepoCache) CreateProducts(ctx context.Context, products *[]model.Product) ([]uint64, error) {
+	var productIDs []uint64
+	for range *products {
+		c.lastProductID++
+		productIDs = append(productIDs, c.lastProductID)
+	}
+	return productIDs, nil
+}
+
+func (c *catalogRepoCache) GetPriceAt(ctx context.Context, productID, variantID uint64, at time.Time) (*model.ProductPrice, error) {
+	for _, price := range c.prices {
+		if price.ProductID == productID && price.VariantID == variantID && !price.EffectiveAt.After(at) {
+			return &price, nil
+		}
+	}
+	return nil, repo.ErrPriceNotFound
+}
+
+// fixedClock always reads the same time
+type fixedClock struct {
+	now time.Time
+}
+
+func (c *fixedClock) Now() time.Time {
+	return c.now
+}
+
+var (
+	now    = time.UnixMilli(1600000000000)
+	config = &conf.Config{
+		PricingConfig: &conf.PricingConfig{},
+		Logger: &conf.Logger{
+			Writer: ioutil.Discard,
+			ContextLogger: log.NewEntry(&log.Logger{
+				Out:       ioutil.Discard,
+				Formatter: new(log.TextFormatter),
+				Level:     log.DebugLevel,
+			}),
+		},
+	}
+	catalogRepo *repo.InMemoryProductCatalogRepository
+	svc         ProductService
+)
+
+func TestProduct(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "product suite")
+}
+
+var _ = BeforeEach(func() {
+	catalogRepo = repo.NewInMemoryProductCatalogRepository()
+	for _, product := range []model.Product{
+		{
+			ID: 1,
+			Detail: &model.ProductDetail{
+				Name:        "wireless keyboard",
+				Description: "compact keyboard with bluetooth",
+				BrandName:   "acme",
+				Price:       300,
+			},
+			Inventory: 0,
+		},
+		{
+			ID: 2,
+			Detail: &model.ProductDetail{
+				Name:        "wireless mouse",
+				Description: "ergonomic mouse",
+				BrandName:   "acme",
+				Price:       100,
+			},
+			Inventory: 5,
+		},
+		{
+			ID: 3,
+			Detail: &model.ProductDetail{
+				Name:        "mechanical keyboard",
+				Description: "wired keyboard, wireless ready",
+				BrandName:   "globex",
+				Price:       500,
+			},
+			Inventory: 5,
+		},
+		{
+			ID: 4,
+			Detail: &model.ProductDetail{
+				Name:        "archived keyboard",
+				Description: "discontinued keyboard",
+				BrandName:   "acme",
+				Price:       50,
+				Archived:    true,
+			},
+			Inventory: 5,
+		},
+	} {
+		product := product
+		catalogRepo.SaveProduct(&product)
+	}
+	for _, category := range []model.Category{
+		{ID: 10, Name: "peripherals"},
+		{ID: 11, ParentID: 10, Name: "keyboards"},
+		{ID: 12, ParentID: 10, Name: "mice"},
+		{ID: 13, ParentID: 11, Name: "mechanical keyboards"},
+	} {
+		category := category
+		catalogRepo.SaveCategory(&category)
+	}
+	catalogRepo.SetProductCategories(1, 11)
+	catalogRepo.SetProductCategories(2, 12)
+	catalogRepo.SetProductCategories(3, 13)
+	svc = NewProductService(config, &catalogRepoCache{
+		catalogRepo: catalogRepo,
+		prices: []model.ProductPrice{
+			{ProductID: 1, Price: 300, EffectiveAt: now.Add(-time.Hour)},
+			{ProductID: 1, Price: 350, EffectiveAt: now.Add(-2 * time.Hour)},
+			{ProductID: 1, VariantID: 5, Price: 320, EffectiveAt: now.Add(-2 * time.Hour)},
+		},
+	}, nil, &fixedClock{now: now})
+})
+
+func listIDs(query *model.ProductQuery) []uint64 {
+	if query.Size == 0 {
+		query.Size = 10
+	}
+	page, err := svc.ListProducts(context.Background(), query)
+	Expect(err).To(BeNil())
+	var ids []uint64
+	for _, catalog := range page.Catalogs {
+		ids = append(ids, catalog.ID)
+	}
+	return ids
+}
+
+var _ = Describe("product catalog", func() {
+	var _ = It("should filter products", func() {
+		Expect(listIDs(&model.ProductQuery{})).To(Equal([]uint64{1, 2, 3}))
+		Expect(listIDs(&model.ProductQuery{BrandName: "acme"})).To(Equal([]uint64{1, 2}))
+		Expect(listIDs(&model.ProductQuery{MinPrice: 100, MaxPrice: 300})).To(Equal([]uint64{1, 2}))
+		Expect(listIDs(&model.ProductQuery{MaxPrice: 99})).To(BeEmpty())
+		Expect(listIDs(&model.ProductQuery{InStock: true})).To(Equal([]uint64{2, 3}))
+	})
+	var _ = It("should filter products by category subtree", func() {
+		Expect(listIDs(&model.ProductQuery{CategoryID: 10})).To(Equal([]uint64{1, 2, 3}))
+		Expect(listIDs(&model.ProductQuery{CategoryID: 11})).To(Equal([]uint64{1, 3}))
+		Expect(listIDs(&model.ProductQuery{CategoryID: 13})).To(Equal([]uint64{3}))
+		Expect(listIDs(&model.ProductQuery{CategoryID: 12, InStock: true})).To(Equal([]uint64{2}))
+
+		catalogRepo.SaveCategory(&model.Category{ID: 13, ParentID: 12, Name: "mechanical keyboards"})
+		Expect(listIDs(&model.ProductQuery{CategoryID: 11})).To(Equal([]uint64{1}))
+		Expect(listIDs(&model.ProductQuery{CategoryID: 12})).To(Equal([]uint64{2, 3}))
+	})
+	var _ = It("should sort and paginate products", func() {
+		Expect(listIDs(&model.ProductQuery{SortBy: model.SortByPrice})).To(Equal([]uint64{2, 1, 3}))
+		Expect(listIDs(&model.ProductQuery{SortBy: model.SortByPrice, Descending: true})).To(Equal([]uint64{3, 1, 2}))
+		Expect(listIDs(&model.ProductQuery{SortBy: model.SortByName})).To(Equal([]uint64{3, 1, 2}))
+		Expect(listIDs(&model.ProductQuery{SortBy: model.SortByCreatedAt, Descending: true, Offset: 1})).To(Equal([]uint64{2, 1}))
+	})
+	var _ = It("should search products by relevance", func() {
+		Expect(listIDs(&model.ProductQuery{Keyword: "Keyboard"})).To(Equal([]uint64{1, 3}))
+		Expect(listIDs(&model.ProductQuery{Keyword: "wireless keyboard"})).To(Equal([]uint64{1, 3, 2}))
+		Expect(listIDs(&model.ProductQuery{Keyword: "wireless keyboard", SortBy: model.SortByPrice})).To(Equal([]uint64{2, 1, 3}))
+		Expect(listIDs(&model.ProductQuery{Keyword: "keyboard", InStock: true})).To(Equal([]uint64{3}))
+		Expect(listIDs(&model.ProductQuery{Keyword: "trackpad"})).To(BeEmpty())
+	})
+	var _ = It("should paginate products with cursors", func() {
+		query := &model.ProductQuery{Size: 2}
+		page, err := svc.ListProducts(context.Background(), query)
+		Expect(err).To(BeNil())
+		Expect(page.Catalogs).To(HaveLen(2))
+		Expect(page.NextCursor).NotTo(BeEmpty())
+
+		catalogRepo.SaveProduct(&model.Product{
+			ID: 5,
+			Detail: &model.ProductDetail{
+				Name:        "usb hub",
+				Description: "four port hub",
+				BrandName:   "acme",
+				Price:       80,
+			},
+		})
+		query.Cursor = page.NextCursor
+		page, err = svc.ListProducts(context.Background(), query)
+		Expect(err).To(BeNil())
+		Expect(page.Catalogs[0].ID).To(Equal(uint64(3)))
+		Expect(page.Catalogs[1].ID).To(Equal(uint64(5)))
+
+		query.Cursor = page.NextCursor
+		page, err = svc.ListProducts(context.Background(), query)
+		Expect(err).To(BeNil())
+		Expect(page.Catalogs).To(BeEmpty())
+		Expect(page.NextCursor).To(BeEmpty())
+
+		Expect(listIDs(&model.ProductQuery{Size: 2, Descending: true})).To(Equal([]uint64{5, 3}))
+		page, err = svc.ListProducts(context.Background(), &model.ProductQuery{Size: 2, Descending: true})
+		Expect(err).To(BeNil())
+		Expect(listIDs(&model.ProductQuery{Size: 2, Descending: true, Cursor: page.NextCursor})).To(Equal([]uint64{2, 1}))
+	})
+	var _ = It("should not return cursors when products are not listed in product ID order", func() {
+		page, err := svc.ListProducts(context.Background(), &model.ProductQuery{Size: 1, SortBy: model.SortByPrice})
+		Expect(err).To(BeNil())
+		Expect(page.NextCursor).To(BeEmpty())
+		page, err = svc.ListProducts(context.Background(), &model.ProductQuery{Size: 1, Keyword: "keyboard"})
+		Expect(err).To(BeNil())
+		Expect(page.NextCursor).To(BeEmpty())
+	})
+	var _ = It("should reject invalid queries", func() {
+		for _, query := range []*model.ProductQuery{
+			{Size: 0},
+			{Size: 10, Offset: -1},
+			{Size: 10, MinPrice: 300, MaxPrice: 100},
+			{Size: 10, MinPrice: -1},
+			{Size: 10, SortBy: "inventory"},
+			{Size: 10, SortBy: model.SortByPrice, Cursor: encodeCursor(1)},
+			{Size: 10, Offset: 1, Cursor: encodeCursor(1)},
+		} {
+			_, err := svc.ListProducts(context.Background(), query)
+			Expect(err).To(Equal(ErrInvalidProductQuery))
+		}
+		for _, cursor := range []string{"not a cursor", encodeCursor(0)} {
+			_, err := svc.ListProducts(context.Background(), &model.ProductQuery{Size: 10, Cursor: cursor})
+			Expect(err).To(Equal(ErrInvalidCursor))
+		}
+	})
+})
+
+var _ = Describe("product variant", func() {
+	var _ = It("should reject invalid variants", func() {
+		for _, variant := range []*model.ProductVariant{
+			{ProductID: 1, Name: "red"},
+			{ProductID: 1, SKU: "kb-red"},
+			{ProductID: 1, SKU: "kb-red", Name: "red", Price: -1},
+			{ProductID: 1, SKU: "kb-red", Name: "red", Inventory: -1},
+		} {
+			_, err := svc.CreateVariant(context.Background(), variant)
+			Expect(err).To(Equal(ErrInvalidVariant))
+		}
+		empty := ""
+		err := svc.UpdateVariant(context.Background(), 1, 2, &model.ProductVariantUpdate{SKU: &empty})
+		Expect(err).To(Equal(ErrInvalidVariant))
+	})
+})
+
+var _ = Describe("product import", func() {
+	var _ = It("should report invalid products by index", func() {
+		product := func(name string, price int64, inventory int64) model.Product {
+			return model.Product{
+				Detail: &model.ProductDetail{
+					Name:        name,
+					Description: "desc",
+					BrandName:   "brand",
+					Price:       price,
+				},
+				Inventory: inventory,
+			}
+		}
+		products := []model.Product{
+			product("keyboard", 100, 10),
+			product("", 100, 10),
+			product("mouse", 0, 10),
+			product("monitor", 300, 5),
+			product("cable", 10, -1),
+		}
+		result, err := svc.ImportProducts(context.Background(), &products)
+		Expect(err).To(BeNil())
+		Expect(result.ProductIDs).To(HaveLen(5))
+		Expect(result.ProductIDs[0]).NotTo(BeZero())
+		Expect(result.ProductIDs[3]).NotTo(BeZero())
+		Expect(result.ProductIDs[0]).NotTo(Equal(result.ProductIDs[3]))
+		Expect(result.ProductIDs[1]).To(BeZero())
+		Expect(result.Errors).To(HaveLen(3))
+		for i, index := range []int{1, 2, 4} {
+			Expect(result.Errors[i].Index).To(Equal(index))
+			Expect(errors.Is(result.Errors[i].Err, ErrInvalidProduct)).To(BeTrue())
+		}
+	})
+	var _ = It("should reject too many products", func() {
+		products := make([]model.Product, maxImportProducts+1)
+		_, err := svc.ImportProducts(context.Background(), &products)
+		Expect(err).To(Equal(ErrTooManyProducts))
+	})
+})
+
+var _ = Describe("product price", func() {
+	var _ = It("should look up prices in effect at a time", func() {
+		prices, err := svc.GetPricesAt(context.Background(), now.Add(-90*time.Minute), &[]model.PurchasedItem{
+			{ProductID: 1},
+			{ProductID: 1, VariantID: 5},
+			{ProductID: 2},
+		})
+		Expect(err).To(BeNil())
+		Expect(*prices).To(HaveLen(2))
+		Expect((*prices)[0].Price).To(Equal(int64(350)))
+		Expect((*prices)[1].Price).To(Equal(int64(320)))
+
+		prices, err = svc.GetPricesAt(context.Background(), now, &[]model.PurchasedItem{{ProductID: 1}})
+		Expect(err).To(BeNil())
+		Expect((*prices)[0].Price).To(Equal(int64(300)))
+	})
+	var _ = It("should reject invalid price changes", func() {
+		for _, change := range []*model.PriceChange{
+			{ProductID: 1, Price: 100, EffectiveAt: now},
+			{ProductID: 1, Price: 100, EffectiveAt: now.Add(-time.Minute)},
+			{ProductID: 1, Price: -1, EffectiveAt: now.Add(time.Minute)},
+		} {
+			_, err := svc.SchedulePriceChange(context.Background(), change)
+			Expect(err).To(Equal(ErrInvalidPriceChange))
+		}
+	})
+})